@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"vpn-route-manager/internal/config"
+)
+
+var serviceCatalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Browse the catalog of service templates",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		templates, err := config.LoadCatalog(cfg.Get().CatalogURL)
+		if err != nil {
+			fmt.Fprintf(stdout, "⚠️  Warning: %v\n", err)
+		}
+
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tVERSION\tINSTALLED\tNETWORKS\tDESCRIPTION")
+		fmt.Fprintln(w, "--\t-------\t---------\t--------\t-----------")
+
+		for _, template := range templates {
+			installed := "-"
+			if svc, exists := cfg.Get().Services[template.ID]; exists {
+				installed = "yes"
+				if svc.Version != "" {
+					installed = svc.Version
+				}
+				if svc.Version != "" && config.CompareVersions(svc.Version, template.Version) < 0 {
+					installed += " (update available)"
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", template.ID, template.Version, installed,
+				len(template.Service.Networks), template.Service.Description)
+		}
+		w.Flush()
+
+		fmt.Fprintln(stdout, "\n💡 Install with: vpn-route-manager service catalog install <id>")
+		return nil
+	},
+}
+
+var serviceCatalogInstallCmd = &cobra.Command{
+	Use:   "install <id>",
+	Short: "Install a service from the catalog",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		upgrade, _ := cmd.Flags().GetBool("upgrade")
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		templates, err := config.LoadCatalog(cfg.Get().CatalogURL)
+		if err != nil {
+			fmt.Fprintf(stdout, "⚠️  Warning: %v\n", err)
+		}
+
+		id := args[0]
+		var template *config.CatalogTemplate
+		for i := range templates {
+			if templates[i].ID == id {
+				template = &templates[i]
+				break
+			}
+		}
+		if template == nil {
+			return fmt.Errorf("template '%s' not found in the catalog", id)
+		}
+
+		svc := *template.Service
+		svc.Version = template.Version
+
+		existing, exists := cfg.Get().Services[id]
+		switch {
+		case exists && !upgrade:
+			return fmt.Errorf("service '%s' already exists (use --upgrade to replace it)", id)
+		case exists:
+			// Keep the user's choice of enabling the service
+			svc.Enabled = existing.Enabled
+			if err := cfg.UpdateService(id, &svc); err != nil {
+				return err
+			}
+		default:
+			svc.Enabled = false
+			if err := cfg.AddService(id, &svc); err != nil {
+				return err
+			}
+		}
+
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+
+		if exists {
+			fmt.Fprintf(stdout, "✅ Service '%s' upgraded to version %s\n", id, template.Version)
+			fmt.Fprintln(stdout, "⚠️  Restart the service to apply changes: vpn-route-manager restart")
+		} else {
+			fmt.Fprintf(stdout, "✅ Service '%s' installed (disabled by default)\n", id)
+			fmt.Fprintf(stdout, "💡 Enable with: vpn-route-manager service enable %s\n", id)
+		}
+		return nil
+	},
+}
+
+func init() {
+	serviceCatalogCmd.AddCommand(serviceCatalogInstallCmd)
+	serviceCmd.AddCommand(serviceCatalogCmd)
+
+	serviceCatalogInstallCmd.Flags().Bool("upgrade", false, "Replace an installed service with the catalog version")
+}
@@ -2,17 +2,23 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/logger"
 	"vpn-route-manager/internal/network"
 	"vpn-route-manager/internal/service"
 	"vpn-route-manager/internal/system"
@@ -26,20 +32,49 @@ var startCmd = &cobra.Command{
 		// Check if daemon flag is set
 		daemon, _ := cmd.Flags().GetBool("daemon")
 		if daemon {
-			return runDaemon()
+			launchdSocket, _ := cmd.Flags().GetBool("launchd-socket")
+			return runDaemon(launchdSocket)
 		}
 
 		// Otherwise, start via LaunchAgent
 		username := os.Getenv("USER")
 		launchAgent := system.NewLaunchAgent(username)
 		
+		if !launchAgent.IsInstalled() {
+			return fmt.Errorf("%w, run 'vpn-route-manager install' first", system.ErrNotInstalled)
+		}
+		if running, pid := launchAgent.IsRunning(); running {
+			fmt.Fprintf(stdout, "✅ Service already running (PID: %d)\n", pid)
+			return nil
+		}
+
+		fmt.Fprintln(stdout, "Starting VPN Route Manager service...")
+		// stop unloads jobs launchd would otherwise start again
 		if !launchAgent.IsLoaded() {
-			return fmt.Errorf("service not installed. Run 'vpn-route-manager install' first")
+			if err := launchAgent.Load(); err != nil {
+				return fmt.Errorf("failed to start service: %w", err)
+			}
+		}
+		// A job started on demand is started by connecting to its socket
+		var err error
+		if launchAgent.IsOnDemand() {
+			_, err = service.Control(service.ControlRequest{Command: service.ControlStatus})
+		} else {
+			err = launchAgent.Kickstart()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to start service: %w", err)
 		}
 
-		fmt.Println("Starting VPN Route Manager service...")
-		// The service is already loaded, just needs to start
-		fmt.Println("✅ Service started")
+		if wait, _ := cmd.Flags().GetBool("wait"); wait {
+			pid, err := waitForService(launchAgent, true)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "✅ Service started (PID: %d)\n", pid)
+			return nil
+		}
+		fmt.Fprintln(stdout, "✅ Service started")
 		return nil
 	},
 }
@@ -48,29 +83,114 @@ var startCmd = &cobra.Command{
 var stopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stop the VPN Route Manager service",
+	Long: `Stop the service until it is started again. The process is sent SIGTERM
+and exits cleanly; a job launchd restarts on any exit (keep_alive always)
+is unloaded instead, and loaded again by start or at the next login or
+boot.
+
+The bypass routes are removed as the daemon exits, so traffic doesn't keep
+bypassing the VPN while nothing manages the routes; stop waits for that and
+removes any the daemon left behind. --keep-routes leaves them in place
+until 'vpn-route-manager route clear' or the next start.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		username := os.Getenv("USER")
 		launchAgent := system.NewLaunchAgent(username)
 		
-		if !launchAgent.IsLoaded() {
-			return fmt.Errorf("service not running")
+		if !launchAgent.IsInstalled() {
+			return fmt.Errorf("%w, run 'vpn-route-manager install' first", system.ErrNotInstalled)
+		}
+		// A job between restarts isn't running but would be again
+		restarts := launchAgent.RestartsOnExit()
+		running, _ := launchAgent.IsRunning()
+		if !launchAgent.IsLoaded() || (!running && !restarts) {
+			return system.ErrDaemonNotRunning
+		}
+
+		// The daemon looks for the request as it stops
+		stateDir := currentStateDir()
+		keepRoutes, _ := cmd.Flags().GetBool("keep-routes")
+		if keepRoutes {
+			if err := service.WriteKeepRoutes(stateDir); err != nil {
+				return err
+			}
 		}
 
-		fmt.Println("Stopping VPN Route Manager service...")
-		if err := launchAgent.Unload(); err != nil {
+		fmt.Fprintln(stdout, "Stopping VPN Route Manager service...")
+		var err error
+		if restarts {
+			err = launchAgent.Unload()
+		} else {
+			err = launchAgent.Kill()
+		}
+		if err != nil {
+			if keepRoutes {
+				service.ClearKeepRoutes(stateDir)
+			}
 			return fmt.Errorf("failed to stop service: %w", err)
 		}
-		
-		// Reload to keep it registered but not running
-		if err := launchAgent.Load(); err != nil {
-			return fmt.Errorf("failed to reload service: %w", err)
+
+		// Routes are only known to be gone once the daemon has exited
+		if wait, _ := cmd.Flags().GetBool("wait"); wait || !keepRoutes {
+			if _, err := waitForService(launchAgent, false); err != nil {
+				return err
+			}
 		}
+		fmt.Fprintln(stdout, "✅ Service stopped")
 
-		fmt.Println("✅ Service stopped")
-		return nil
+		if keepRoutes {
+			fmt.Fprintln(stdout, "⚠️  Bypass routes kept, remove them with 'vpn-route-manager route clear'")
+			return nil
+		}
+		// A daemon that didn't exit cleanly leaves its status behind
+		removed, err := clearRecordedRoutes(stateDir)
+		if removed > 0 {
+			fmt.Fprintf(stdout, "🧹 Removed %s the service left behind\n", plural(removed, "bypass route"))
+		}
+		return err
 	},
 }
 
+// serviceWaitTimeout is how long --wait waits for the service to start or
+// stop. A stopping daemon may take up to 30 seconds to finish its work.
+const serviceWaitTimeout = 45 * time.Second
+
+// waitForService waits until the service is running, or until it is no
+// longer running, and returns its PID
+func waitForService(launchAgent *system.LaunchAgent, running bool) (int, error) {
+	deadline := time.Now().Add(serviceWaitTimeout)
+	for {
+		isRunning, pid := launchAgent.IsRunning()
+		if isRunning == running {
+			return pid, nil
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	if running {
+		return 0, fmt.Errorf("%w after %s, check 'vpn-route-manager logs --all'", system.ErrDaemonNotRunning, serviceWaitTimeout)
+	}
+	return 0, fmt.Errorf("service still running after %s", serviceWaitTimeout)
+}
+
+// waitForExit waits until the process pid has exited, such as a daemon
+// whose job was unloaded and no longer shows up in launchctl. A process of
+// another user, like the root daemon, can't be signalled but still exists.
+func waitForExit(pid int) error {
+	deadline := time.Now().Add(serviceWaitTimeout)
+	for {
+		if err := syscall.Kill(pid, 0); err != nil && err != syscall.EPERM {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service still running after %s", serviceWaitTimeout)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
 // Restart command
 var restartCmd = &cobra.Command{
 	Use:   "restart",
@@ -79,7 +199,11 @@ var restartCmd = &cobra.Command{
 		username := os.Getenv("USER")
 		launchAgent := system.NewLaunchAgent(username)
 		
-		fmt.Println("Restarting VPN Route Manager service...")
+		if !launchAgent.IsInstalled() {
+			return fmt.Errorf("%w, run 'vpn-route-manager install' first", system.ErrNotInstalled)
+		}
+
+		fmt.Fprintln(stdout, "Restarting VPN Route Manager service...")
 		
 		if launchAgent.IsLoaded() {
 			if err := launchAgent.Unload(); err != nil {
@@ -91,7 +215,82 @@ var restartCmd = &cobra.Command{
 			return fmt.Errorf("failed to start service: %w", err)
 		}
 
-		fmt.Println("✅ Service restarted")
+		fmt.Fprintln(stdout, "✅ Service restarted")
+		return nil
+	},
+}
+
+// Pause command
+var pauseCmd = &cobra.Command{
+	Use:   "pause [duration]",
+	Short: "Remove bypass routes and suspend monitoring",
+	Long: `Remove all bypass routes and suspend monitoring without stopping the
+service, until resumed or until the optional duration (e.g. 1h30m) has passed.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var duration time.Duration
+		if len(args) > 0 {
+			d, err := time.ParseDuration(args[0])
+			if err != nil || d <= 0 {
+				return fmt.Errorf("invalid duration '%s' (e.g. 30m, 2h)", args[0])
+			}
+			duration = d
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		pause, err := service.WritePause(cfg.Get().StateDir, duration)
+		if err != nil {
+			return err
+		}
+
+		if pause.Until.IsZero() {
+			fmt.Fprintln(stdout, "⏸️  Paused until resumed: vpn-route-manager resume")
+		} else {
+			fmt.Fprintf(stdout, "⏸️  Paused until %s\n", pause.Until.Format("15:04"))
+		}
+
+		username := os.Getenv("USER")
+		launchAgent := system.NewLaunchAgent(username)
+		if running, _ := launchAgent.IsRunning(); running {
+			fmt.Fprintf(stdout, "💡 Bypass routes will be removed within %d seconds\n", cfg.Get().CheckInterval)
+		}
+		return nil
+	},
+}
+
+// Resume command
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume monitoring after a pause",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		pause, err := service.ReadPause(cfg.Get().StateDir)
+		if err != nil {
+			return err
+		}
+		if pause == nil {
+			fmt.Fprintln(stdout, "Not paused")
+			return nil
+		}
+
+		if err := service.ClearPause(cfg.Get().StateDir); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(stdout, "▶️  Resumed")
+		username := os.Getenv("USER")
+		launchAgent := system.NewLaunchAgent(username)
+		if running, _ := launchAgent.IsRunning(); running {
+			fmt.Fprintf(stdout, "💡 Bypass routes will be added within %d seconds if the VPN is connected\n", cfg.Get().CheckInterval)
+		}
 		return nil
 	},
 }
@@ -104,53 +303,64 @@ var statusCmd = &cobra.Command{
 		// Check LaunchAgent status
 		username := os.Getenv("USER")
 		launchAgent := system.NewLaunchAgent(username)
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			outputFormat = outputJSON
+		}
+		if short, _ := cmd.Flags().GetBool("short"); short {
+			if structuredOutput() {
+				return fmt.Errorf("--short can't be combined with --json or --output")
+			}
+			fmt.Fprintln(stdout, shortStatus())
+			return nil
+		}
+		if structuredOutput() {
+			return printStructured(collectStatus(launchAgent))
+		}
 		
-		fmt.Println("🔍 VPN Route Manager Status")
-		fmt.Println("============================")
+		fmt.Fprintln(stdout, "🔍 VPN Route Manager Status")
+		fmt.Fprintln(stdout, "============================")
+		fmt.Fprintf(stdout, "Version: %s\n", currentBuild())
 		
 		// Service status
+		running := false
 		if launchAgent.IsLoaded() {
-			running, pid := launchAgent.IsRunning()
+			var pid int
+			running, pid = launchAgent.IsRunning()
 			if running {
-				fmt.Printf("Service: ✅ RUNNING (PID: %d)\n", pid)
+				fmt.Fprintf(stdout, "Service: ✅ RUNNING (PID: %d)\n", pid)
 			} else {
-				fmt.Println("Service: ⚠️  LOADED but NOT RUNNING")
+				fmt.Fprintln(stdout, "Service: ⚠️  LOADED but NOT RUNNING")
 			}
+		} else if launchAgent.IsInstalled() {
+			// stop unloads jobs launchd would otherwise start again
+			fmt.Fprintln(stdout, "Service: ⏹️  STOPPED")
 		} else {
-			fmt.Println("Service: ❌ NOT INSTALLED")
+			fmt.Fprintln(stdout, "Service: ❌ NOT INSTALLED")
 			return nil
 		}
 
 		// Read the saved state
-		homeDir, _ := os.UserHomeDir()
-		stateFile := filepath.Join(homeDir, ".vpn-route-manager", "state", "state.json")
+		cfg, cfgErr := loadConfig()
+		stateDir := config.DefaultPaths().State
+		if cfgErr == nil {
+			stateDir = cfg.Get().StateDir
+		}
+		stateFile := filepath.Join(stateDir, "state.json")
 		
 		var savedState map[string]interface{}
+		var details service.State
 		if data, err := os.ReadFile(stateFile); err == nil {
 			json.Unmarshal(data, &savedState)
+			json.Unmarshal(data, &details)
 		}
 
-		// Get actual route count from routing table
-		activeRouteCount := 0
-		countCmd := exec.Command("sh", "-c", `netstat -rn | grep -E "149\.154|91\.108|185\.76\.151|95\.161\.64|172\.217|142\.250|216\.58|74\.125|64\.233|66\.249|72\.14|209\.85" | grep -v "^default" | wc -l`)
-		if output, err := countCmd.Output(); err == nil {
-			fmt.Sscanf(strings.TrimSpace(string(output)), "%d", &activeRouteCount)
-		}
-
-		// Get gateway
-		gateway := "unknown"
-		gwCmd := exec.Command("route", "get", "default")
-		if output, err := gwCmd.Output(); err == nil {
-			lines := strings.Split(string(output), "\n")
-			for _, line := range lines {
-				if strings.Contains(line, "gateway:") {
-					parts := strings.Fields(line)
-					if len(parts) >= 2 {
-						gateway = parts[1]
-					}
-				}
-			}
+		var routeCfg *config.Manager
+		if cfgErr == nil {
+			routeCfg = cfg
 		}
+		activeRouteCount := bypassRouteCount(routeCfg, &details, stateDir, running)
+		gateway := defaultGateway()
 
 		// Get VPN status from state
 		vpnConnected := false
@@ -166,33 +376,60 @@ var statusCmd = &cobra.Command{
 			}
 		}
 
+		// A daemon started before an upgrade still runs the old build
+		if running {
+			if status, err := service.ReadStatusFile(stateDir); err == nil && status != nil &&
+				status.Version != "" && status.Version != currentBuild().String() {
+				fmt.Fprintf(stdout, "Daemon Version: ⚠️  %s, restart to run this version\n", status.Version)
+			}
+		}
+
+		// launchd respawning a daemon that keeps dying
+		if crashLoop := detectCrashLoop(&details); crashLoop != nil {
+			fmt.Fprintf(stdout, "Service: 💥 %s\n", crashLoop)
+			if len(crashLoop.Stderr) > 0 {
+				fmt.Fprintln(stdout, "Last stderr:")
+				for _, line := range crashLoop.Stderr {
+					fmt.Fprintf(stdout, "  %s\n", line)
+				}
+			}
+		}
+
+		// Pause status
+		if pause, err := service.ReadPause(stateDir); err == nil && pause != nil && !pause.Expired(time.Now()) {
+			if pause.Until.IsZero() {
+				fmt.Fprintln(stdout, "Monitoring: ⏸️  PAUSED until resumed")
+			} else {
+				fmt.Fprintf(stdout, "Monitoring: ⏸️  PAUSED until %s\n", pause.Until.Format("15:04"))
+			}
+		}
+
 		// Network status
-		fmt.Println("\n📡 Network Status")
-		fmt.Println("------------------")
+		fmt.Fprintln(stdout, "\n📡 Network Status")
+		fmt.Fprintln(stdout, "------------------")
 		if vpnConnected {
-			fmt.Println("VPN: ✅ CONNECTED")
+			fmt.Fprintln(stdout, "VPN: ✅ CONNECTED")
 		} else {
-			fmt.Println("VPN: ❌ DISCONNECTED")
+			fmt.Fprintln(stdout, "VPN: ❌ DISCONNECTED")
 		}
-		fmt.Printf("Gateway: %s\n", gateway)
-		fmt.Printf("Last Check: %s\n", lastCheck)
+		fmt.Fprintf(stdout, "Gateway: %s\n", gateway)
+		fmt.Fprintf(stdout, "Last Check: %s\n", lastCheck)
 
 		// Routes status
-		fmt.Println("\n🛣️  Routes Status")
-		fmt.Println("------------------")
+		fmt.Fprintln(stdout, "\n🛣️  Routes Status")
+		fmt.Fprintln(stdout, "------------------")
 		if activeRouteCount > 0 {
-			fmt.Printf("Active Routes: %d\n", activeRouteCount)
+			fmt.Fprintf(stdout, "Active Routes: %d\n", activeRouteCount)
 		} else {
-			fmt.Println("Active Routes: None")
+			fmt.Fprintln(stdout, "Active Routes: None")
 		}
 
 		// Services status
-		fmt.Println("\n📦 Services Status")
-		fmt.Println("------------------")
+		fmt.Fprintln(stdout, "\n📦 Services Status")
+		fmt.Fprintln(stdout, "------------------")
 		
-		// Load current configuration to check which services are enabled
-		cfg, err := loadConfig()
-		if err == nil {
+		// Check which services are enabled in the current configuration
+		if cfgErr == nil {
 			// Get all enabled services from config
 			enabledServices := cfg.GetEnabledServices()
 			
@@ -215,34 +452,69 @@ var statusCmd = &cobra.Command{
 			sort.Strings(serviceNames)
 			
 			for _, name := range serviceNames {
-				if activeServicesMap[name] && vpnConnected {
-					fmt.Printf("%s: ✅ ACTIVE\n", name)
-				} else if !vpnConnected {
-					fmt.Printf("%s: ⭕ ENABLED\n", name)
-				} else {
-					// VPN is connected but service has no routes yet
-					fmt.Printf("%s: 🔄 LOADING\n", name)
+				switch serviceStatus(&details, name, activeServicesMap[name], vpnConnected) {
+				case "unhealthy":
+					fmt.Fprintf(stdout, "%s: ⚠️  UNHEALTHY (%s)\n", name, details.ServiceHealth[name].Error)
+				case "active":
+					fmt.Fprintf(stdout, "%s: ✅ ACTIVE\n", name)
+				case "enabled":
+					fmt.Fprintf(stdout, "%s: ⭕ ENABLED\n", name)
+				default:
+					fmt.Fprintf(stdout, "%s: 🔄 LOADING\n", name)
 				}
 			}
 			
 			if len(enabledServices) == 0 {
-				fmt.Println("No services enabled")
+				fmt.Fprintln(stdout, "No services enabled")
+			}
+
+			// Routes are added by priority, shared networks go to the first
+			if vpnConnected && len(details.ApplyOrder) > 0 {
+				var order []string
+				for _, name := range details.ApplyOrder {
+					if svc, exists := enabledServices[name]; exists {
+						order = append(order, fmt.Sprintf("%s (%d)", name, svc.Priority))
+					}
+				}
+				fmt.Fprintf(stdout, "Apply order: %s\n", strings.Join(order, " → "))
 			}
 		} else {
 			// Fallback if can't load config
 			if activeServices, ok := savedState["active_services"].(map[string]interface{}); ok {
 				for name, active := range activeServices {
 					if isActive, ok := active.(bool); ok && isActive {
-						fmt.Printf("%s: ✅ ACTIVE\n", name)
+						fmt.Fprintf(stdout, "%s: ✅ ACTIVE\n", name)
 					}
 				}
 			}
 		}
 
+		// DNS leak check for bypassed domains
+		if vpnConnected {
+			var leaked []string
+			paths := checkServiceDNS()
+			for _, path := range paths {
+				if path.Leak {
+					leaked = append(leaked, path.Domain)
+				}
+			}
+			if len(paths) > 0 {
+				fmt.Fprintln(stdout, "\n🔒 DNS Status")
+				fmt.Fprintln(stdout, "------------------")
+				if len(leaked) == 0 {
+					fmt.Fprintf(stdout, "DNS: ✅ %d bypassed domains resolved locally\n", len(paths))
+				} else {
+					fmt.Fprintf(stdout, "DNS: ⚠️  %d/%d bypassed domains resolved via VPN: %s\n",
+						len(leaked), len(paths), strings.Join(leaked, ", "))
+					fmt.Fprintln(stdout, "💡 Run 'vpn-route-manager route test --dns' for details")
+				}
+			}
+		}
+
 		// Show logs tail
-		fmt.Println("\n📋 Recent Activity")
-		fmt.Println("------------------")
-		logFile := filepath.Join(homeDir, ".vpn-route-manager", "logs", "stdout.log")
+		fmt.Fprintln(stdout, "\n📋 Recent Activity")
+		fmt.Fprintln(stdout, "------------------")
+		logFile := filepath.Join(config.LogDir(), "stdout.log")
 		if data, err := os.ReadFile(logFile); err == nil {
 			lines := strings.Split(string(data), "\n")
 			start := len(lines) - 6
@@ -251,7 +523,7 @@ var statusCmd = &cobra.Command{
 			}
 			for i := start; i < len(lines) && i < start+5; i++ {
 				if lines[i] != "" {
-					fmt.Println(lines[i])
+					fmt.Fprintln(stdout, lines[i])
 				}
 			}
 		}
@@ -260,217 +532,1247 @@ var statusCmd = &cobra.Command{
 	},
 }
 
-// Uninstall command
-var uninstallCmd = &cobra.Command{
-	Use:   "uninstall",
-	Short: "Uninstall VPN Route Manager",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		fmt.Println("🗑️  Uninstalling VPN Route Manager...")
-		
-		username := os.Getenv("USER")
-		
-		// Stop and remove LaunchAgent
-		fmt.Println("📋 Removing LaunchAgent...")
-		launchAgent := system.NewLaunchAgent(username)
-		if err := launchAgent.Uninstall(); err != nil {
-			fmt.Printf("⚠️  Warning: %v\n", err)
-		}
-
-		// Remove sudo configuration
-		fmt.Println("🔐 Removing sudo configuration...")
-		sudoMgr := system.NewSudoManager(username)
-		if err := sudoMgr.Remove(); err != nil {
-			fmt.Printf("⚠️  Warning: %v\n", err)
-		}
+// statusReport is the machine-readable form of the status command. Routes
+// are those the daemon recorded, ActiveRoutes counts them or, without a
+// running daemon, the bypass routes left in the routing table.
+type statusReport struct {
+	Version       buildInfo       `json:"version"`
+	DaemonVersion string          `json:"daemon_version,omitempty"`
+	Installed     bool            `json:"installed"`
+	Running       bool            `json:"running"`
+	PID           int             `json:"pid,omitempty"`
+	StartedAt     *time.Time      `json:"started_at,omitempty"`
+	UptimeSeconds int64           `json:"uptime_seconds,omitempty"`
+	CrashLoop     *crashLoop      `json:"crash_loop,omitempty"`
+	Paused        bool            `json:"paused"`
+	PausedUntil   *time.Time      `json:"paused_until,omitempty"`
+	VPNConnected  bool            `json:"vpn_connected"`
+	VPNInterface  string          `json:"vpn_interface,omitempty"`
+	VPNProduct    string          `json:"vpn_product,omitempty"`
+	Gateway       string          `json:"gateway,omitempty"`
+	LastCheck     *time.Time      `json:"last_check,omitempty"`
+	ActiveRoutes  int             `json:"active_routes"`
+	Routes        []network.Route `json:"routes"`
+	Services      []serviceReport `json:"services"`
+	ApplyOrder    []string        `json:"apply_order,omitempty"`
+}
 
-		// Kill any remaining processes
-		fmt.Println("🛑 Stopping any remaining processes...")
-		procMgr := system.NewProcessManager("vpn-route-manager")
-		if err := procMgr.KillAllProcesses(false); err != nil {
-			fmt.Printf("⚠️  Warning: %v\n", err)
-		}
+// serviceReport is the status of one enabled service: active, unhealthy,
+// enabled (VPN disconnected) or loading (no routes yet)
+type serviceReport struct {
+	Name     string          `json:"name"`
+	Status   string          `json:"status"`
+	Priority int             `json:"priority"`
+	Error    string          `json:"error,omitempty"`
+	Runtime  *serviceRuntime `json:"runtime,omitempty"`
+}
 
-		// Ask about removing configuration
-		fmt.Print("\nRemove configuration and logs? [y/N]: ")
-		var response string
-		fmt.Scanln(&response)
-		
-		if strings.ToLower(response) == "y" {
-			homeDir, _ := os.UserHomeDir()
-			configDir := filepath.Join(homeDir, ".vpn-route-manager")
-			
-			fmt.Printf("📁 Removing %s...\n", configDir)
-			if err := os.RemoveAll(configDir); err != nil {
-				fmt.Printf("⚠️  Warning: %v\n", err)
-			}
-		}
+// collectStatus gathers what the status command shows, for --output
+func collectStatus(launchAgent *system.LaunchAgent) statusReport {
+	report := statusReport{
+		Version:   currentBuild(),
+		Installed: launchAgent.IsInstalled(),
+		Routes:    []network.Route{},
+		Services:  []serviceReport{},
+	}
+	if !report.Installed {
+		return report
+	}
+	report.Running, report.PID = launchAgent.IsRunning()
 
-		// Remove binary if in /usr/local/bin
-		binaryPath := "/usr/local/bin/vpn-route-manager"
-		if _, err := os.Stat(binaryPath); err == nil {
-			fmt.Printf("🗑️  Removing %s...\n", binaryPath)
-			if err := os.Remove(binaryPath); err != nil {
-				fmt.Printf("⚠️  Warning: %v\n", err)
-			}
-		}
+	cfg, cfgErr := loadConfig()
+	stateDir := config.DefaultPaths().State
+	if cfgErr == nil {
+		stateDir = cfg.Get().StateDir
+	}
 
-		fmt.Println("\n✅ Uninstallation completed!")
-		return nil
-	},
-}
+	state, err := service.ReadStateFile(stateDir)
+	if err != nil {
+		state = &service.State{}
+	}
+	report.CrashLoop = detectCrashLoop(state)
+	report.VPNConnected = state.VPNConnected
+	report.VPNInterface = state.VPNInterface
+	if !state.LastCheck.IsZero() {
+		report.LastCheck = &state.LastCheck
+	}
+	if cfgErr != nil {
+		cfg = nil
+	}
+	report.ActiveRoutes = bypassRouteCount(cfg, state, stateDir, report.Running)
+	report.Gateway = defaultGateway()
+	if state.VPNConnected {
+		report.VPNProduct = network.NewVPNDetector().GetVPNInfo().Product
+	}
 
-// Debug command
-var debugCmd = &cobra.Command{
-	Use:   "debug",
-	Short: "Run in debug mode",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		debug = true
-		return runDaemon()
-	},
-}
+	// Only the daemon knows its routes, and only while it runs
+	if report.Running {
+		if status, err := service.ReadStatusFile(stateDir); err == nil && status != nil {
+			report.StartedAt = &status.StartTime
+			report.UptimeSeconds = int64(status.Uptime.Seconds())
+			report.Routes = status.ActiveRoutes
+			report.DaemonVersion = status.Version
+		}
+	}
 
-// Logs command
-var logsCmd = &cobra.Command{
-	Use:   "logs",
-	Short: "Show service logs",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		follow, _ := cmd.Flags().GetBool("follow")
-		lines, _ := cmd.Flags().GetInt("lines")
-		
-		homeDir, _ := os.UserHomeDir()
-		logPath := filepath.Join(homeDir, ".vpn-route-manager", "logs", "vpn-route-manager.log")
-		
-		if _, err := os.Stat(logPath); os.IsNotExist(err) {
-			return fmt.Errorf("log file not found: %s", logPath)
+	if pause, err := service.ReadPause(stateDir); err == nil && pause != nil && !pause.Expired(time.Now()) {
+		report.Paused = true
+		if !pause.Until.IsZero() {
+			report.PausedUntil = &pause.Until
 		}
+	}
 
-		if follow {
-			// Use tail -f
-			tailCmd := exec.Command("tail", "-f", logPath)
-			tailCmd.Stdout = os.Stdout
-			tailCmd.Stderr = os.Stderr
-			return tailCmd.Run()
-		} else {
-			// Show last N lines
-			tailCmd := exec.Command("tail", fmt.Sprintf("-%d", lines), logPath)
-			tailCmd.Stdout = os.Stdout
-			tailCmd.Stderr = os.Stderr
-			return tailCmd.Run()
+	if cfgErr != nil {
+		// Without the config only the services with routes are known
+		for name, active := range state.ActiveServices {
+			if active {
+				report.Services = append(report.Services, serviceReport{Name: name, Status: "active"})
+			}
 		}
-	},
-}
+		sort.Slice(report.Services, func(i, j int) bool {
+			return report.Services[i].Name < report.Services[j].Name
+		})
+		return report
+	}
 
-// Config command group
-var configCmd = &cobra.Command{
-	Use:   "config",
-	Short: "Configuration management",
-}
+	enabledServices := cfg.GetEnabledServices()
+	var names []string
+	for name := range enabledServices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-var configGetCmd = &cobra.Command{
-	Use:   "get [key]",
-	Short: "Get configuration value",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, err := loadConfig()
-		if err != nil {
-			return err
+	for _, name := range names {
+		status := serviceStatus(state, name, state.ActiveServices[name], state.VPNConnected)
+		runtime := serviceRuntimeOf(cfg, name, state)
+		entry := serviceReport{
+			Name:     name,
+			Status:   status,
+			Priority: enabledServices[name].Priority,
+			Runtime:  &runtime,
 		}
+		if status == "unhealthy" {
+			entry.Error = state.ServiceHealth[name].Error
+		}
+		report.Services = append(report.Services, entry)
+	}
 
-		if len(args) == 0 {
-			// Show all config
-			data, err := json.MarshalIndent(cfg.Get(), "", "  ")
-			if err != nil {
-				return err
-			}
-			fmt.Println(string(data))
-		} else {
-			// Show specific key
-			switch args[0] {
-			case "gateway":
-				fmt.Println(cfg.Get().Gateway)
-			case "check_interval":
-				fmt.Println(cfg.Get().CheckInterval)
-			case "debug":
-				fmt.Println(cfg.Get().Debug)
-			default:
-				return fmt.Errorf("unknown config key: %s", args[0])
+	if state.VPNConnected {
+		for _, name := range state.ApplyOrder {
+			if _, exists := enabledServices[name]; exists {
+				report.ApplyOrder = append(report.ApplyOrder, name)
 			}
 		}
-		return nil
-	},
+	}
+	return report
 }
 
-var configSetCmd = &cobra.Command{
-	Use:   "set <key> <value>",
-	Short: "Set configuration value",
-	Args:  cobra.ExactArgs(2),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, err := loadConfig()
-		if err != nil {
-			return err
+// shortStatus summarizes the status in one line, e.g. "VPN up · 3
+// services · 47 routes · gw 192.168.1.1". It is run from shell prompts, so
+// it only reads the state and status files the daemon writes.
+func shortStatus() string {
+	stateDir := config.DefaultPaths().State
+	state, err := service.ReadStateFile(stateDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "not running"
 		}
+		return "unknown"
+	}
+	if restarts := service.CrashLoopRestarts(state, time.Now()); restarts > 0 {
+		loop := &crashLoop{Restarts: restarts, WindowMinutes: int(service.CrashLoopWindow.Minutes())}
+		return strings.ToLower(loop.String())
+	}
 
-		key, value := args[0], args[1]
-		config := cfg.Get()
+	// The daemon removes its status file when it stops
+	status, err := service.ReadStatusFile(stateDir)
+	if err != nil {
+		return "unknown"
+	}
+	if status == nil {
+		return "stopped"
+	}
 
-		switch key {
-		case "gateway":
-			config.Gateway = value
-		case "check_interval":
-			var interval int
-			if _, err := fmt.Sscanf(value, "%d", &interval); err != nil {
-				return fmt.Errorf("invalid interval: %s", value)
+	var parts []string
+	services := 0
+	if state.VPNConnected {
+		parts = append(parts, "VPN up")
+		for _, active := range state.ActiveServices {
+			if active {
+				services++
 			}
-			config.CheckInterval = interval
-		case "debug":
-			config.Debug = value == "true"
-		default:
-			return fmt.Errorf("unknown config key: %s", key)
 		}
-
-		if err := cfg.Save(); err != nil {
-			return err
+	} else {
+		parts = append(parts, "VPN down")
+		for _, enabled := range status.EnabledServices {
+			if enabled {
+				services++
+			}
 		}
+	}
+	parts = append(parts, plural(services, "service"))
 
-		fmt.Printf("✅ Set %s = %s\n", key, value)
-		return nil
-	},
+	if state.VPNConnected {
+		parts = append(parts, plural(len(status.ActiveRoutes), "route"))
+	}
+	if state.LastGateway != "" {
+		parts = append(parts, "gw "+state.LastGateway)
+	}
+	return strings.Join(parts, " · ")
 }
 
-func init() {
-	// Add daemon flag to start command
+// crashLoop is a daemon launchd keeps respawning, with the last lines it
+// wrote to stderr
+type crashLoop struct {
+	Restarts      int      `json:"restarts"`
+	WindowMinutes int      `json:"window_minutes"`
+	Stderr        []string `json:"stderr,omitempty"`
+}
+
+// String describes the crash loop, e.g. "CRASH LOOPING (5 restarts in 10m)"
+func (c *crashLoop) String() string {
+	return fmt.Sprintf("CRASH LOOPING (%s in %dm)", plural(c.Restarts, "restart"), c.WindowMinutes)
+}
+
+// stderrExcerptLines is how many of the last lines of stderr.log a crash
+// loop is reported with
+const stderrExcerptLines = 5
+
+// detectCrashLoop returns the crash loop the daemon starts recorded in
+// state show, or nil when there is none
+func detectCrashLoop(state *service.State) *crashLoop {
+	restarts := service.CrashLoopRestarts(state, time.Now())
+	if restarts == 0 {
+		return nil
+	}
+
+	report := &crashLoop{Restarts: restarts, WindowMinutes: int(service.CrashLoopWindow.Minutes())}
+	if data, err := os.ReadFile(filepath.Join(config.LogDir(), "stderr.log")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				report.Stderr = append(report.Stderr, line)
+			}
+		}
+		if len(report.Stderr) > stderrExcerptLines {
+			report.Stderr = report.Stderr[len(report.Stderr)-stderrExcerptLines:]
+		}
+	}
+	return report
+}
+
+// plural formats a count with a noun, adding an s unless it is one
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+// serviceStatus classifies an enabled service from the saved state
+func serviceStatus(state *service.State, name string, active, vpnConnected bool) string {
+	health, probed := state.ServiceHealth[name]
+	switch {
+	case active && vpnConnected && probed && !health.Healthy:
+		return "unhealthy"
+	case active && vpnConnected:
+		return "active"
+	case !vpnConnected:
+		return "enabled"
+	default:
+		// VPN is connected but service has no routes yet
+		return "loading"
+	}
+}
+
+// bypassRouteCount counts the bypass routes. While the daemon runs these
+// are the routes it tracks; otherwise the routing table is searched for
+// routes left behind to the networks of the configured services and the
+// addresses their domains resolved to.
+func bypassRouteCount(cfg *config.Manager, state *service.State, stateDir string, running bool) int {
+	if running {
+		if status, err := service.ReadStatusFile(stateDir); err == nil && status != nil {
+			return len(status.ActiveRoutes)
+		}
+	}
+	if cfg == nil {
+		return 0
+	}
+
+	var networks []string
+	for _, name := range config.ServiceNames(cfg.Get().Services) {
+		networks = append(networks, cfg.ServiceNetworks(name)...)
+	}
+	for _, resolved := range state.ResolvedDomains {
+		for _, address := range resolved {
+			networks = append(networks, address.IP)
+		}
+	}
+
+	count, err := network.CountTableRoutes(networks)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+// defaultGateway returns the gateway of the default route, or "unknown"
+func defaultGateway() string {
+	gateway := "unknown"
+	gwCmd := exec.Command("route", "get", "default")
+	if output, err := gwCmd.Output(); err == nil {
+		lines := strings.Split(string(output), "\n")
+		for _, line := range lines {
+			if strings.Contains(line, "gateway:") {
+				parts := strings.Fields(line)
+				if len(parts) >= 2 {
+					gateway = parts[1]
+				}
+			}
+		}
+	}
+	return gateway
+}
+
+// Uninstall command
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Uninstall VPN Route Manager",
+	Long: `Stop the service and remove its LaunchAgent, wait for it to exit and
+remove any bypass routes it left in the routing table, then the sudo rules
+and the binary.
+
+You are asked whether to remove the configuration and logs as well; --yes
+answers yes, backing the configuration up to your home directory first.
+--keep-config keeps them without asking, and --purge removes them and the
+state without asking or keeping a backup.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keepConfig, _ := cmd.Flags().GetBool("keep-config")
+		purge, _ := cmd.Flags().GetBool("purge")
+		if keepConfig && purge {
+			return fmt.Errorf("--keep-config and --purge can't be used together")
+		}
+
+		fmt.Fprintln(stdout, "🗑️  Uninstalling VPN Route Manager...")
+		
+		username := os.Getenv("USER")
+		stateDir := currentStateDir()
+
+		// Stop and remove LaunchAgent. The daemon removes its routes as it
+		// exits; until it has, it may still add them back.
+		launchAgent := system.NewLaunchAgent(username)
+		binaryPath := installedBinaryPath(launchAgent)
+		_, pid := launchAgent.IsRunning()
+		if launchAgent.IsDaemon() {
+			fmt.Fprintln(stdout, "📋 Removing LaunchDaemon...")
+		} else {
+			fmt.Fprintln(stdout, "📋 Removing LaunchAgent...")
+		}
+		if err := launchAgent.Uninstall(); err != nil {
+			fmt.Fprintf(stdout, "⚠️  Warning: %v\n", err)
+		}
+		if pid > 0 {
+			if err := waitForExit(pid); err != nil {
+				fmt.Fprintf(stdout, "⚠️  Warning: %v\n", err)
+			}
+		}
+
+		// Kill any remaining processes
+		fmt.Fprintln(stdout, "🛑 Stopping any remaining processes...")
+		procMgr := system.NewProcessManager("vpn-route-manager")
+		if err := procMgr.KillAllProcesses(false); err != nil {
+			fmt.Fprintf(stdout, "⚠️  Warning: %v\n", err)
+		}
+
+		// Routes the daemon left behind go before the sudo rules that
+		// allow removing them
+		fmt.Fprintln(stdout, "🛣️  Removing bypass routes...")
+		removed, err := clearRecordedRoutes(stateDir)
+		if err != nil {
+			fmt.Fprintf(stdout, "⚠️  Warning: %v\n", err)
+		} else if removed > 0 {
+			fmt.Fprintf(stdout, "🧹 Removed %s the service left behind\n", plural(removed, "bypass route"))
+		} else {
+			fmt.Fprintln(stdout, "✅ No bypass routes to remove")
+		}
+
+		// Remove sudo configuration
+		fmt.Fprintln(stdout, "🔐 Removing sudo configuration...")
+		sudoMgr := system.NewSudoManager(username)
+		if err := sudoMgr.Remove(); err != nil {
+			fmt.Fprintf(stdout, "⚠️  Warning: %v\n", err)
+		}
+		if err := system.RemoveHelperDaemon(); err != nil {
+			fmt.Fprintf(stdout, "⚠️  Warning: %v\n", err)
+		}
+
+		// Remove the installed binary, and the user's bin directory with it
+		if _, err := os.Stat(binaryPath); err == nil {
+			fmt.Fprintf(stdout, "🗑️  Removing %s...\n", binaryPath)
+			if err := os.Remove(binaryPath); err != nil {
+				fmt.Fprintf(stdout, "⚠️  Warning: %v\n", err)
+			}
+		}
+		if filepath.Dir(binaryPath) == config.UserBinDir() {
+			os.Remove(config.UserBinDir())
+			os.Remove(filepath.Dir(config.UserBinDir()))
+		}
+
+		// Ask about removing configuration
+		fmt.Fprintln(stdout)
+		paths := config.DefaultPaths()
+		if keepConfig {
+			fmt.Fprintf(stdout, "📁 Keeping configuration in %s and logs in %s\n", paths.Config, paths.Logs)
+		} else if purge || confirm("Remove configuration and logs?", false) {
+			homeDir, _ := os.UserHomeDir()
+			if !purge {
+				// The backup directory is removed too, keep this one at home
+				if backup, err := config.Backup(homeDir, getConfigPath()); err != nil {
+					fmt.Fprintf(stdout, "⚠️  Warning: failed to back up configuration: %v\n", err)
+				} else {
+					fmt.Fprintf(stdout, "💾 Configuration backed up to %s\n", backup)
+				}
+			}
+
+			for _, dir := range []string{paths.Config, paths.State, paths.Logs} {
+				fmt.Fprintf(stdout, "📁 Removing %s...\n", dir)
+				if err := os.RemoveAll(dir); err != nil {
+					fmt.Fprintf(stdout, "⚠️  Warning: %v\n", err)
+				}
+			}
+
+			// Drop the root of the default layout once it is empty
+			os.Remove(filepath.Join(homeDir, ".vpn-route-manager"))
+		}
+
+		fmt.Fprintln(stdout, "\n✅ Uninstallation completed!")
+		return nil
+	},
+}
+
+// currentStateDir returns the configured state directory, or the default
+// one when the configuration doesn't load
+func currentStateDir() string {
+	if cfg, err := loadConfig(); err == nil {
+		return cfg.Get().StateDir
+	}
+	return config.StateDir()
+}
+
+// clearRecordedRoutes deletes the routes recorded in the status in
+// stateDir from the routing table and returns how many it removed
+func clearRecordedRoutes(stateDir string) (int, error) {
+	status, err := service.ReadStatusFile(stateDir)
+	if err != nil {
+		return 0, err
+	}
+	if status == nil || len(status.ActiveRoutes) == 0 {
+		return 0, nil
+	}
+
+	log, err := createLogger()
+	if err != nil {
+		return 0, err
+	}
+	defer log.Close()
+
+	return network.NewManager(log).ClearRoutes(status.ActiveRoutes)
+}
+
+// Debug command
+var debugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Run in debug mode",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		debug = true
+		return runDaemon(false)
+	},
+}
+
+// Logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show service logs",
+	Long: `Show service logs, including the rotated files, filtered by level, time
+and pattern. --since and --until take a duration back from now (e.g. 2h),
+a time today (e.g. 14:30) or a date and time (e.g. "2024-05-01 14:30").
+With --all the output launchd captured from the service is merged in, for
+errors during startup that happen before the log is open.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		follow, _ := cmd.Flags().GetBool("follow")
+		lines, _ := cmd.Flags().GetInt("lines")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		all, _ := cmd.Flags().GetBool("all")
+		
+		logPath := filepath.Join(config.LogDir(), "vpn-route-manager.log")
+		
+		if _, err := os.Stat(logPath); os.IsNotExist(err) {
+			if !logSettings().HasOutput(config.LogOutputFile) {
+				return fmt.Errorf("logging only goes to syslog, see: log show --predicate 'process == \"vpn-route-manager\"'")
+			}
+			return fmt.Errorf("log file not found: %s", logPath)
+		}
+
+		filter, err := logFilter(cmd)
+		if err != nil {
+			return err
+		}
+
+		show := func(entry logger.Entry) {
+			if asJSON {
+				data, _ := json.Marshal(entry)
+				fmt.Fprintln(stdout, string(data))
+			} else if entry.Source != "" {
+				fmt.Fprintf(stdout, "%s: %s\n", entry.Source, entry.String())
+			} else {
+				fmt.Fprintln(stdout, entry.String())
+			}
+		}
+
+		entries, err := logger.ReadEntries(logger.LogFiles(logPath), filter)
+		if err != nil {
+			return err
+		}
+		if all {
+			if entries, err = mergeLaunchdStreams(entries, filter); err != nil {
+				return err
+			}
+		}
+		if lines > 0 && len(entries) > lines {
+			entries = entries[len(entries)-lines:]
+		}
+		for _, entry := range entries {
+			show(entry)
+		}
+
+		if follow && all {
+			return followAll(logPath, filter, show)
+		} else if follow {
+			return logger.Follow(logPath, filter, show)
+		}
+		return nil
+	},
+}
+
+// launchdStreams are the names of the files in the log directory launchd
+// writes the service's stdout and stderr to
+var launchdStreams = []string{"stdout", "stderr"}
+
+// mergeLaunchdStreams interleaves the entries of the launchd streams with
+// those of the log. Timestamped lines in stdout.log are the log's own
+// lines echoed, so those already in the log are left out.
+func mergeLaunchdStreams(entries []logger.Entry, filter logger.Filter) ([]logger.Entry, error) {
+	logged := make(map[string]bool)
+	for _, entry := range entries {
+		logged[entryKey(entry)] = true
+	}
+
+	sources := [][]logger.Entry{entries}
+	for _, name := range launchdStreams {
+		path := filepath.Join(config.LogDir(), name+".log")
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		streamEntries, err := logger.ReadEntries([]string{path}, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		var kept []logger.Entry
+		for _, entry := range streamEntries {
+			if !logged[entryKey(entry)] {
+				entry.Source = name
+				kept = append(kept, entry)
+			}
+		}
+		sources = append(sources, kept)
+	}
+	return logger.MergeEntries(sources...), nil
+}
+
+// entryKey identifies a log entry whether or not its emoji were left out,
+// as they are in stdout.log when it is not a terminal
+func entryKey(entry logger.Entry) string {
+	return fmt.Sprintf("%d %s %s", entry.Time.Unix(), entry.Level, plainText(entry.Message))
+}
+
+// followAll follows the log and the launchd streams together. Only lines
+// without a timestamp are shown from stdout.log, the others echo the log.
+func followAll(logPath string, filter logger.Filter, show func(logger.Entry)) error {
+	var mu sync.Mutex
+	errs := make(chan error, 1+len(launchdStreams))
+
+	follow := func(path, source string) {
+		errs <- logger.Follow(path, filter, func(entry logger.Entry) {
+			if source == "stdout" && !entry.Time.IsZero() {
+				return
+			}
+			entry.Source = source
+
+			mu.Lock()
+			defer mu.Unlock()
+			show(entry)
+		})
+	}
+
+	go follow(logPath, "")
+	for _, name := range launchdStreams {
+		path := filepath.Join(config.LogDir(), name+".log")
+		if _, err := os.Stat(path); err == nil {
+			go follow(path, name)
+		}
+	}
+	return <-errs
+}
+
+// logFilter builds the log filter from the logs command's flags
+func logFilter(cmd *cobra.Command) (logger.Filter, error) {
+	var filter logger.Filter
+
+	if value, _ := cmd.Flags().GetString("level"); value != "" {
+		level, err := logger.ParseLevel(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.Level = level
+	}
+
+	for _, flag := range []struct {
+		name string
+		t    *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		value, _ := cmd.Flags().GetString(flag.name)
+		if value == "" {
+			continue
+		}
+		t, err := parseLogTime(value, time.Now())
+		if err != nil {
+			return filter, fmt.Errorf("invalid --%s: %w", flag.name, err)
+		}
+		*flag.t = t
+	}
+
+	if pattern, _ := cmd.Flags().GetString("grep"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --grep pattern: %w", err)
+		}
+		filter.Grep = re
+	}
+	return filter, nil
+}
+
+// parseLogTime parses a --since or --until value: a duration back from
+// now, a time today or a date with an optional time
+func parseLogTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local), nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("expected a duration, a time or a date, got '%s'", value)
+}
+
+// Config command group
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Configuration management",
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Get configuration value",
+	Long: `Get the whole configuration or a single value by dotted key path,
+e.g. check_interval, health_checks.timeout or services.telegram.priority.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			// Show all config, which is JSON already unless YAML was asked for
+			if outputFormat == outputYAML {
+				if err := printStructured(cfg.Get()); err != nil {
+					return err
+				}
+			} else {
+				data, err := json.MarshalIndent(cfg.Get(), "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(stdout, string(data))
+			}
+			if system := cfg.SystemConfig(); system != "" {
+				fmt.Fprintf(stderr, "💡 Layered on the system configuration: %s\n", system)
+			}
+			if fragments := cfg.Fragments(); len(fragments) > 0 {
+				names := make([]string, len(fragments))
+				for i, fragment := range fragments {
+					names[i] = filepath.Base(fragment)
+				}
+				fmt.Fprintf(stderr, "💡 Merged from config.d: %s\n", strings.Join(names, ", "))
+			}
+			if overrides := cfg.Overrides(); len(overrides) > 0 {
+				fmt.Fprintf(stderr, "💡 Overridden by environment: %s\n", strings.Join(overrides, ", "))
+			}
+		} else {
+			// Show specific key
+			value, err := cfg.GetKey(args[0])
+			if err != nil {
+				return err
+			}
+			if structuredOutput() {
+				// Scalars are encoded too, so strings come out quoted
+				return printStructured(value)
+			}
+			switch value.(type) {
+			case string, bool, int, float64:
+				fmt.Fprintln(stdout, value)
+			default:
+				data, err := json.MarshalIndent(value, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(stdout, string(data))
+			}
+		}
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set configuration value",
+	Long: `Set a configuration value by dotted key path. Values are parsed by
+the type of the field; lists take comma-separated values.
+
+Examples:
+  vpn-route-manager config set check_interval 30
+  vpn-route-manager config set services.telegram.priority 200
+  vpn-route-manager config set services.telegram.domains telegram.org,t.me`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		key, value := args[0], args[1]
+		if err := cfg.SetKey(key, value); err != nil {
+			return err
+		}
+
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "✅ Set %s = %s\n", key, value)
+		return nil
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit [service]",
+	Short: "Edit the configuration or a service file in $EDITOR",
+	Long: `Open the main configuration, or the file of the given service, in
+$VISUAL or $EDITOR. The result is validated when the editor exits and
+invalid edits are never saved.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		path := getConfigPath()
+		validate := func(data []byte) error {
+			_, err := config.ParseConfig(data)
+			return err
+		}
+
+		if len(args) == 1 {
+			name := args[0]
+			service, exists := cfg.Get().Services[name]
+			if !exists {
+				return fmt.Errorf("service '%s' not found", name)
+			}
+			if sub := service.Subscription(); sub != "" {
+				return fmt.Errorf("service '%s' is managed by subscription '%s'", name, sub)
+			}
+
+			path = filepath.Join(getServicesPath(), name+".json")
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				// Built-in defaults have no file until first saved
+				if err := cfg.UpdateService(name, service); err != nil {
+					return err
+				}
+			}
+			validate = func(data []byte) error {
+				service, err := config.ParseService(data)
+				if err != nil {
+					return err
+				}
+				return config.ValidateService(name, service)
+			}
+		} else if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+		}
+
+		changed, err := editFile(path, validate)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			fmt.Fprintln(stdout, "No changes")
+			return nil
+		}
+
+		fmt.Fprintf(stdout, "✅ Saved %s\n", path)
+
+		// Check if daemon is running
+		username := os.Getenv("USER")
+		launchAgent := system.NewLaunchAgent(username)
+		if running, _ := launchAgent.IsRunning(); running {
+			fmt.Fprintln(stdout, "⚠️  Restart the service to apply changes: vpn-route-manager restart")
+		}
+
+		return nil
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration and service files for errors",
+	Long: `Check the main configuration and every service file without loading
+them, reporting all problems at once with the file and line they are on.
+Run it before restarting the service to catch mistakes in manual edits.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, problems, err := checkConfigFiles()
+		if err != nil {
+			return err
+		}
+
+		if len(files) == 0 {
+			fmt.Fprintf(stdout, "No configuration files in %s, using defaults\n", config.ConfigDir())
+			return nil
+		}
+
+		errors, warnings := printFileProblems(files, problems)
+		fmt.Fprintf(stdout, "\n%d files checked, %d errors, %d warnings\n", len(files), errors, warnings)
+		if errors > 0 {
+			return fmt.Errorf("configuration has %d errors", errors)
+		}
+		return nil
+	},
+}
+
+// checkConfigFiles checks the configuration and service files without
+// loading them, returning the files checked, named for display, and the
+// problems found in them
+func checkConfigFiles() ([]string, []config.FileProblem, error) {
+	var files []string
+	var problems []config.FileProblem
+
+	// The system configuration is shown by its full path
+	systemPath := config.SystemConfigPath()
+	if _, err := os.Stat(systemPath); err == nil {
+		found, err := config.CheckConfigFile(systemPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, systemPath)
+		for _, problem := range found {
+			problem.File = systemPath
+			problems = append(problems, problem)
+		}
+	}
+
+	configPath := getConfigPath()
+	if _, err := os.Stat(configPath); err == nil {
+		found, err := config.CheckConfigFile(configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, filepath.Base(configPath))
+		problems = append(problems, found...)
+	}
+
+	fragments, err := config.FragmentFiles(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, fragment := range fragments {
+		found, err := config.CheckConfigFile(fragment)
+		if err != nil {
+			return nil, nil, err
+		}
+		name := filepath.Join("config.d", filepath.Base(fragment))
+		files = append(files, name)
+		for _, problem := range found {
+			problem.File = name
+			problems = append(problems, problem)
+		}
+	}
+
+	if _, err := os.Stat(getServicesPath()); err == nil {
+		serviceFiles, found, err := config.CheckServiceFiles(getServicesPath())
+		if err != nil {
+			return nil, nil, err
+		}
+		// Show service files relative to the config directory
+		for _, file := range serviceFiles {
+			files = append(files, filepath.Join("services", file))
+		}
+		for _, problem := range found {
+			if problem.File != "" {
+				problem.File = filepath.Join("services", problem.File)
+			}
+			problems = append(problems, problem)
+		}
+	}
+
+	return files, problems, nil
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the configuration",
+	Long: `Print a JSON Schema for config.json, or with --service for service
+files, for editor completion and for validating generated configs.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schema := config.ConfigSchema()
+		if forService, _ := cmd.Flags().GetBool("service"); forService {
+			schema = config.ServiceSchema()
+		}
+
+		data, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(data))
+		return nil
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init [dir]",
+	Short: "Write a fresh default configuration",
+	Long: `Write the default config.json and service files to a directory, by
+default the configuration directory. Unlike install this needs no
+administrator privileges, and existing files are kept unless --force is
+given.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := config.ConfigDir()
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		force, _ := cmd.Flags().GetBool("force")
+
+		// Overwriting the live configuration is backed up first
+		if force && filepath.Clean(dir) == filepath.Clean(config.ConfigDir()) {
+			if _, err := os.Stat(getConfigPath()); err == nil {
+				backup, err := config.Backup(config.BackupDir(), getConfigPath())
+				if err != nil {
+					return fmt.Errorf("failed to back up the current configuration: %w", err)
+				}
+				fmt.Fprintf(stdout, "💾 Current configuration backed up to %s\n", backup)
+			}
+		}
+
+		files, err := config.WriteDefaults(dir, force)
+		for _, file := range files {
+			fmt.Fprintf(stdout, "✅ Wrote %s\n", file)
+		}
+		if err != nil {
+			return err
+		}
+
+		if filepath.Clean(dir) != filepath.Clean(config.ConfigDir()) {
+			fmt.Fprintf(stdout, "💡 Use it with: %s=%s vpn-route-manager ...\n", config.EnvConfigDir, dir)
+		}
+		return nil
+	},
+}
+
+var configDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show configuration changes the running service hasn't loaded",
+	Long: `Compare the configuration on disk with the one the running service
+has loaded, to tell whether it needs a restart to pick up changes.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		username := os.Getenv("USER")
+		launchAgent := system.NewLaunchAgent(username)
+		if running, _ := launchAgent.IsRunning(); !running {
+			fmt.Fprintln(stdout, "Service is not running, the configuration on disk is used when it starts")
+			return nil
+		}
+
+		stateDir := cfg.Get().StateDir
+		loaded, err := service.ReadLoadedConfig(stateDir)
+		if err != nil {
+			return err
+		}
+		if loaded == nil {
+			return fmt.Errorf("the running service hasn't recorded its configuration, restart it to use config diff")
+		}
+
+		changes, err := config.Diff(loaded, cfg.Get())
+		if err != nil {
+			return err
+		}
+
+		if len(changes) == 0 {
+			fmt.Fprintln(stdout, "✅ The running service has the current configuration")
+		} else {
+			fmt.Fprintln(stdout, "Changes on disk not loaded by the running service:")
+			for _, change := range changes {
+				printConfigChange(change)
+			}
+		}
+
+		if state, err := service.ReadStateFile(stateDir); err == nil && state.RoutesActive {
+			var applied []string
+			for name, active := range state.ActiveServices {
+				if active {
+					applied = append(applied, name)
+				}
+			}
+			sort.Strings(applied)
+			fmt.Fprintf(stdout, "\nRoutes applied for: %s (gateway %s)\n", strings.Join(applied, ", "), state.LastGateway)
+		}
+
+		if len(changes) > 0 {
+			fmt.Fprintln(stdout, "\n⚠️  Restart the service to apply changes: vpn-route-manager restart")
+		}
+		return nil
+	},
+}
+
+// printConfigChange prints a single difference found by config diff.
+// Lists show the items added and removed, objects only their key.
+func printConfigChange(change config.Change) {
+	switch {
+	case change.Old == nil:
+		fmt.Fprintf(stdout, "  + %s%s\n", change.Key, assignedValue(change.New))
+		return
+	case change.New == nil:
+		fmt.Fprintf(stdout, "  - %s%s\n", change.Key, assignedValue(change.Old))
+		return
+	}
+
+	oldList, oldIsList := change.Old.([]interface{})
+	newList, newIsList := change.New.([]interface{})
+	if !oldIsList || !newIsList {
+		fmt.Fprintf(stdout, "  ~ %s: %s → %s\n", change.Key, formatValue(change.Old), formatValue(change.New))
+		return
+	}
+
+	fmt.Fprintf(stdout, "  ~ %s:\n", change.Key)
+	for _, item := range newList {
+		if !containsValue(oldList, item) {
+			fmt.Fprintf(stdout, "      + %s\n", formatValue(item))
+		}
+	}
+	for _, item := range oldList {
+		if !containsValue(newList, item) {
+			fmt.Fprintf(stdout, "      - %s\n", formatValue(item))
+		}
+	}
+}
+
+// formatValue shortens a decoded JSON value for display
+func formatValue(value interface{}) string {
+	data, _ := json.Marshal(value)
+	if len(data) > 60 {
+		return string(data[:57]) + "..."
+	}
+	return string(data)
+}
+
+// assignedValue formats a value added or removed as " = value", or
+// nothing for objects, which are listed by key only
+func assignedValue(value interface{}) string {
+	if _, isObject := value.(map[string]interface{}); isObject {
+		return ""
+	}
+	return " = " + formatValue(value)
+}
+
+// containsValue checks if a decoded JSON list holds a value
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+var configBackupCmd = &cobra.Command{
+	Use:   "backup [file]",
+	Short: "Back up the configuration, service files and state",
+	Long: `Write a tar.gz of config.json, the service files and the service state
+to file, or to a timestamped file in the backup directory.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := ""
+		if len(args) == 1 {
+			path = args[0]
+			if _, err := config.CreateBackup(path, getConfigPath()); err != nil {
+				return err
+			}
+		} else {
+			var err error
+			if path, err = config.Backup(config.BackupDir(), getConfigPath()); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(stdout, "✅ Backed up configuration to %s\n", path)
+		return nil
+	},
+}
+
+var configRestoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore the configuration from a backup",
+	Long: `Replace config.json, the service files and the service state with the
+contents of a backup. The current configuration is backed up first.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		current, err := config.Backup(config.BackupDir(), getConfigPath())
+		if err != nil {
+			return fmt.Errorf("failed to back up the current configuration: %w", err)
+		}
+		fmt.Fprintf(stdout, "💾 Current configuration backed up to %s\n", current)
+
+		restored, err := config.RestoreBackup(args[0], getConfigPath())
+		if err != nil {
+			return err
+		}
+		sort.Strings(restored)
+		for _, name := range restored {
+			fmt.Fprintf(stdout, "✅ Restored %s\n", name)
+		}
+
+		// Check if daemon is running
+		username := os.Getenv("USER")
+		launchAgent := system.NewLaunchAgent(username)
+		if running, _ := launchAgent.IsRunning(); running {
+			fmt.Fprintln(stdout, "⚠️  Restart the service to apply changes: vpn-route-manager restart")
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	// Add flags to status command
+	statusCmd.Flags().Bool("json", false, "Print the status as JSON, same as --output json")
+	statusCmd.Flags().Bool("short", false, "Print a one-line summary for shell prompts and status bars")
+
+	// Add daemon flag to start command
 	startCmd.Flags().Bool("daemon", false, "Run as daemon (internal use)")
+	startCmd.Flags().Bool("launchd-socket", false, "Take control requests on the socket launchd passes (internal use)")
+	startCmd.Flags().Bool("wait", false, "Wait until the service is running")
+	stopCmd.Flags().Bool("wait", false, "Wait until the service has exited")
+	stopCmd.Flags().Bool("keep-routes", false, "Leave the bypass routes in place instead of removing them")
 	
 	// Add flags to logs command
 	logsCmd.Flags().BoolP("follow", "f", false, "Follow log output")
-	logsCmd.Flags().IntP("lines", "n", 50, "Number of lines to show")
+	logsCmd.Flags().IntP("lines", "n", 50, "Number of entries to show (0 for all)")
+	logsCmd.Flags().String("level", "", "Only show entries at this level or above (debug, info, warn, error)")
+	logsCmd.Flags().String("since", "", "Only show entries from this time on")
+	logsCmd.Flags().String("until", "", "Only show entries up to this time")
+	logsCmd.Flags().String("grep", "", "Only show entries whose message matches this regular expression")
+	logsCmd.Flags().Bool("json", false, "Print entries as JSON, one per line")
+	logsCmd.Flags().Bool("all", false, "Merge in the stdout and stderr launchd captured from the service")
 
 	// Add config subcommands
-	configCmd.AddCommand(configGetCmd, configSetCmd)
+	configCmd.AddCommand(configGetCmd, configSetCmd, configEditCmd, configValidateCmd, configSchemaCmd, configInitCmd, configDiffCmd,
+		configBackupCmd, configRestoreCmd)
+	configSchemaCmd.Flags().Bool("service", false, "Print the schema of service files instead")
+	configInitCmd.Flags().Bool("force", false, "Overwrite existing files")
+	uninstallCmd.Flags().Bool("keep-config", false, "Keep the configuration and logs without asking")
+	uninstallCmd.Flags().Bool("purge", false, "Remove the configuration, state and logs without asking or backing up")
 }
 
-// runDaemon runs the service in daemon mode
-func runDaemon() error {
-	// Create logger
-	log, err := createLogger()
+// runDaemon runs the service in daemon mode. With launchdSocket it was
+// started on demand and takes control requests on the socket launchd
+// created.
+func runDaemon(launchdSocket bool) error {
+	// Anchor what ends up in the launchd streams in time for `logs --all`;
+	// panics and early errors are written there without a timestamp
+	marker := logger.Entry{Time: time.Now(), Level: "INFO", Message: fmt.Sprintf("Daemon %s starting (PID %d)", currentBuild(), os.Getpid())}
+	fmt.Fprintln(stderr, marker.String())
+
+	// Upgrade files written by older versions before anything loads them
+	migrateErr := migrateConfig()
+
+	// Create logger, the one that rotates the log file
+	logConfig := loggerConfig()
+	logConfig.Rotate = true
+	log, err := logger.New(logConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create logger: %w", err)
 	}
 	defer log.Close()
 
-	// Load configuration
+	// Load configuration, recording the start first so launchd respawning
+	// a daemon that can't even load it shows as a crash loop
 	cfg, err := loadConfig()
+	if migrateErr != nil {
+		err = migrateErr
+	}
+	stateDir := config.DefaultPaths().State
+	if err == nil {
+		stateDir = cfg.Get().StateDir
+	}
+	if err := service.RecordStart(stateDir, time.Now()); err != nil {
+		log.Warn("Failed to record start: %v", err)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
 	// Create network manager
 	netMgr := network.NewManager(log)
+	if err := netMgr.Configure(cfg.Get()); err != nil {
+		return err
+	}
 
 	// Create service manager
 	svcMgr, err := service.NewManager(cfg, netMgr, log)
 	if err != nil {
 		return fmt.Errorf("failed to create service manager: %w", err)
 	}
+	svcMgr.SetVersion(currentBuild().String())
+	if launchdSocket {
+		listener, err := system.LaunchdSocket()
+		if err != nil {
+			return err
+		}
+		svcMgr.SetControlListener(listener)
+	}
 
 	// Start service
 	if err := svcMgr.Start(); err != nil {
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/service"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion <bash|zsh|fish>",
+	Short: "Generate a shell completion script",
+	Long: `Generate a completion script for bash, zsh or fish. Service names and
+the networks of active services are completed from the configuration.
+
+  bash: source <(vpn-route-manager completion bash)
+  zsh:  vpn-route-manager completion zsh > "${fpath[1]}/_vpn-route-manager"
+  fish: vpn-route-manager completion fish > ~/.config/fish/completions/vpn-route-manager.fish`,
+	Args:      cobra.ExactValidArgs(1),
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			return rootCmd.GenFishCompletion(os.Stdout, true)
+		}
+		return fmt.Errorf("unsupported shell '%s'", args[0])
+	},
+}
+
+// completionConfig loads the configuration for completions. Unlike
+// loadConfig it never migrates files, so pressing tab changes nothing.
+func completionConfig() (*config.Manager, error) {
+	cfg := config.NewManager(getConfigPath())
+	if err := cfg.Load(); err != nil {
+		return nil, err
+	}
+	if err := cfg.LoadServices(getServicesPath()); err != nil {
+		return nil, err
+	}
+	if err := cfg.LoadSubscriptions(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// completeServiceNames completes the names of configured services with
+// their descriptions, leaving out those already on the command line
+func completeServiceNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := completionConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, name := range config.ServiceNames(cfg.Get().Services) {
+		if strings.HasPrefix(name, toComplete) && !containsString(args, name) {
+			names = append(names, name+"\t"+cfg.Get().Services[name].Description)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeServiceName completes a service name as the first argument only
+func completeServiceName(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeServiceNames(cmd, args, toComplete)
+}
+
+// completeTags completes the tags used by configured services
+func completeTags(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := completionConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	seen := make(map[string]bool)
+	var tags []string
+	for _, svc := range cfg.Get().Services {
+		for _, tag := range svc.Tags {
+			if !seen[tag] && strings.HasPrefix(tag, toComplete) {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeActiveNetworks completes the networks of the services the
+// daemon last recorded as having routes, described by service name
+func completeActiveNetworks(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	cfg, err := completionConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	state, err := service.ReadStateFile(cfg.Get().StateDir)
+	if err != nil || !state.VPNConnected {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	seen := make(map[string]bool)
+	var networks []string
+	for _, name := range config.ServiceNames(cfg.Get().Services) {
+		if !state.ActiveServices[name] {
+			continue
+		}
+		for _, network := range cfg.ServiceNetworks(name) {
+			if !seen[network] && strings.HasPrefix(network, toComplete) {
+				seen[network] = true
+				networks = append(networks, network+"\t"+name)
+			}
+		}
+	}
+	return networks, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	// The completion command above replaces cobra's default one
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+
+	serviceEnableCmd.ValidArgsFunction = completeServiceNames
+	serviceDisableCmd.ValidArgsFunction = completeServiceNames
+	for _, cmd := range []*cobra.Command{
+		serviceShowCmd, serviceEditCmd, serviceRemoveCmd, serviceRenameCmd, serviceExportCmd,
+		serviceResolveCmd, serviceSyncASNCmd, serviceUpdateNetworksCmd, configEditCmd,
+	} {
+		cmd.ValidArgsFunction = completeServiceName
+	}
+	routeRemoveCmd.ValidArgsFunction = completeActiveNetworks
+	routeShowCmd.ValidArgsFunction = completeActiveNetworks
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/logger"
+	"vpn-route-manager/internal/service"
+	"vpn-route-manager/internal/system"
+)
+
+// bundleStateFiles are the files of the state directory put in a debug
+// bundle as they are
+var bundleStateFiles = []string{"state.json", "status.json", "pause.json"}
+
+var debugBundleCmd = &cobra.Command{
+	Use:   "debug-bundle [file]",
+	Short: "Collect logs, state and configuration for a bug report",
+	Long: `Write a tar.gz with everything needed to look into a problem: the logs
+including rotated ones and the launchd output, the daemon's state files, the
+configuration as loaded now and as the daemon loaded it, the routing table,
+the network interfaces and the status. Without file it is written to a
+timestamped file in the current directory.
+
+URL credentials and query parameters, Wi-Fi network names and the
+arguments of the detection command are redacted from the configuration.
+The logs and the routing table are included as they are.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stamp := time.Now().Format("20060102-150405")
+		path := fmt.Sprintf("vpn-route-manager-debug-%s.tar.gz", stamp)
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		username := os.Getenv("USER")
+		if username == "" {
+			return fmt.Errorf("could not determine current user")
+		}
+
+		out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create bundle: %w", err)
+		}
+		defer out.Close()
+
+		gz := gzip.NewWriter(out)
+		bundle := &debugBundle{
+			tw:     tar.NewWriter(gz),
+			prefix: fmt.Sprintf("vpn-route-manager-debug-%s/", stamp),
+		}
+
+		// The configuration is both redacted and where the state is kept
+		stateDir := config.StateDir()
+		if cfg, err := loadConfig(); err != nil {
+			bundle.add("config.json.error", []byte(err.Error()+"\n"))
+		} else {
+			stateDir = cfg.Get().StateDir
+			safe, err := config.Redact(cfg.Get())
+			if err != nil {
+				return err
+			}
+			bundle.addJSON("config.json", safe)
+		}
+
+		for _, file := range bundleStateFiles {
+			bundle.addFile("state/"+file, filepath.Join(stateDir, file))
+		}
+		if loaded, err := service.ReadLoadedConfig(stateDir); err != nil {
+			bundle.add("state/loaded-config.json.error", []byte(err.Error()+"\n"))
+		} else if loaded != nil {
+			safe, err := config.Redact(loaded)
+			if err != nil {
+				return err
+			}
+			bundle.addJSON("state/loaded-config.json", safe)
+		}
+
+		logDir := config.LogDir()
+		for _, name := range append([]string{"vpn-route-manager"}, launchdStreams...) {
+			for _, file := range logger.LogFiles(filepath.Join(logDir, name+".log")) {
+				bundle.addFile("logs/"+filepath.Base(file), file)
+			}
+		}
+
+		bundle.addCommand("routes.txt", "netstat", "-rn")
+		bundle.addCommand("interfaces.txt", "ifconfig", "-a")
+		bundle.addJSON("status.json", collectStatus(system.NewLaunchAgent(username)))
+		bundle.addJSON("version.json", currentBuild())
+
+		if bundle.err != nil {
+			return fmt.Errorf("failed to write bundle: %w", bundle.err)
+		}
+		if err := bundle.tw.Close(); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+
+		fmt.Fprintf(stdout, "✅ Debug bundle written to %s (%s)\n", path, plural(len(bundle.names), "file"))
+		fmt.Fprintln(stdout, "💡 The logs and routing table are not redacted, look through them before sharing")
+		return nil
+	},
+}
+
+// debugBundle writes the files of a debug bundle into one directory of a
+// tarball, keeping the first error
+type debugBundle struct {
+	tw     *tar.Writer
+	prefix string
+	names  []string
+	err    error
+}
+
+// add writes a file to the bundle
+func (b *debugBundle) add(name string, data []byte) {
+	if b.err != nil {
+		return
+	}
+	header := &tar.Header{Name: b.prefix + name, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}
+	if b.err = b.tw.WriteHeader(header); b.err != nil {
+		return
+	}
+	if _, b.err = b.tw.Write(data); b.err == nil {
+		b.names = append(b.names, name)
+	}
+}
+
+// addJSON writes value to the bundle as JSON
+func (b *debugBundle) addJSON(name string, value interface{}) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		b.add(name+".error", []byte(err.Error()+"\n"))
+		return
+	}
+	b.add(name, append(data, '\n'))
+}
+
+// addFile copies the file at path into the bundle if it exists
+func (b *debugBundle) addFile(name, path string) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		b.add(name+".error", []byte(err.Error()+"\n"))
+		return
+	}
+	b.add(name, data)
+}
+
+// addCommand writes the output of a command to the bundle, followed by
+// its error if it failed
+func (b *debugBundle) addCommand(name, command string, args ...string) {
+	output, err := exec.Command(command, args...).CombinedOutput()
+	if err != nil {
+		output = append(output, []byte(fmt.Sprintf("\n%s failed: %v\n", command, err))...)
+	}
+	b.add(name, output)
+}
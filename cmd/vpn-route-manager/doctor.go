@@ -0,0 +1,366 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/logger"
+	"vpn-route-manager/internal/network"
+	"vpn-route-manager/internal/system"
+)
+
+// doctorTestNetwork is the network of the test route. It is reserved for
+// documentation (TEST-NET-2), so no real traffic is redirected.
+const doctorTestNetwork = "198.51.100.0/24"
+
+// Results of a doctor check
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// checkResult is the outcome of one doctor check, with a suggested fix
+// when it didn't pass
+type checkResult struct {
+	Name   string `json:"name"`
+	Result string `json:"result"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+var skipRouteTest bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that everything the service depends on works",
+	Long: `Check every dependency of the service end to end: the binary the
+LaunchAgent starts, the LaunchAgent itself, the sudoers entries, the
+configuration, gateway and VPN detection, and adding and removing a test
+route. Each check passes or fails with a suggested fix.
+
+The test route is for ` + doctorTestNetwork + `, a network reserved for
+documentation, and is removed again right away.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username := os.Getenv("USER")
+		if username == "" {
+			return fmt.Errorf("could not determine current user")
+		}
+
+		// Route changes only go to the log file, the checks report them
+		log, err := logger.New(logger.Config{
+			LogPath:    filepath.Join(config.LogDir(), "vpn-route-manager.log"),
+			MaxSizeMB:  10,
+			MaxBackups: 5,
+			Debug:      debug || config.DebugFromEnv(),
+			Console:    io.Discard,
+		})
+		if err != nil {
+			return err
+		}
+		defer log.Close()
+
+		launchAgent := system.NewLaunchAgent(username)
+		sudoMgr := system.NewSudoManager(username)
+		netMgr := network.NewManager(log)
+
+		var results []checkResult
+		results = append(results, checkBinary(launchAgent))
+		results = append(results, checkLaunchAgent(launchAgent))
+		sudoResult := checkSudoers(sudoMgr, launchAgent)
+		results = append(results, sudoResult)
+		results = append(results, checkConfiguration(netMgr))
+		gatewayResult, gateway := checkGateway(netMgr)
+		results = append(results, gatewayResult)
+		results = append(results, checkVPN(netMgr))
+
+		switch {
+		case skipRouteTest:
+			results = append(results, checkResult{"Test route", checkSkip, "skipped with --skip-route-test", ""})
+		case sudoResult.Result == checkFail:
+			results = append(results, checkResult{"Test route", checkSkip, "needs working sudoers entries", ""})
+		case sudoResult.Result == checkSkip && system.RequiresSudo() && !system.HelperDaemonInstalled():
+			results = append(results, checkResult{"Test route", checkSkip, "needs root, run 'sudo vpn-route-manager doctor'", ""})
+		case gateway == "":
+			results = append(results, checkResult{"Test route", checkSkip, "needs a gateway", ""})
+		default:
+			results = append(results, checkTestRoute(netMgr, gateway))
+		}
+
+		failed := 0
+		for _, result := range results {
+			if result.Result == checkFail {
+				failed++
+			}
+		}
+
+		if structuredOutput() {
+			if err := printStructured(results); err != nil {
+				return err
+			}
+		} else {
+			printCheckResults(results)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%s failed", plural(failed, "check"))
+		}
+		return nil
+	},
+}
+
+// printCheckResults prints the doctor checks with the fixes for those
+// that didn't pass and a summary
+func printCheckResults(results []checkResult) {
+	fmt.Fprintln(stdout, "🩺 VPN Route Manager Doctor")
+	fmt.Fprintln(stdout, "===========================")
+
+	passed, warned, failed := 0, 0, 0
+	for _, result := range results {
+		icon := "✅"
+		switch result.Result {
+		case checkPass:
+			passed++
+		case checkWarn:
+			icon = "⚠️ "
+			warned++
+		case checkFail:
+			icon = "❌"
+			failed++
+		case checkSkip:
+			icon = "⏭️ "
+		}
+		fmt.Fprintf(stdout, "%s %s: %s\n", icon, result.Name, result.Detail)
+		if result.Fix != "" {
+			fmt.Fprintf(stdout, "   💡 %s\n", result.Fix)
+		}
+	}
+
+	fmt.Fprintf(stdout, "\n%d passed, %d warnings, %d failed\n", passed, warned, failed)
+}
+
+// checkBinary checks that the binary the LaunchAgent starts exists and
+// is the one running this check
+func checkBinary(launchAgent *system.LaunchAgent) checkResult {
+	result := checkResult{Name: "Binary"}
+
+	executable, err := os.Executable()
+	if err != nil {
+		result.Result, result.Detail = checkFail, fmt.Sprintf("failed to get executable path: %v", err)
+		return result
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+
+	if !launchAgent.IsInstalled() {
+		result.Result, result.Detail = checkPass, executable
+		return result
+	}
+
+	binaryPath, err := launchAgent.BinaryPath()
+	if err != nil {
+		result.Result, result.Detail = checkFail, err.Error()
+		result.Fix = "Run 'sudo vpn-route-manager install' to recreate the LaunchAgent"
+		return result
+	}
+
+	info, err := os.Stat(binaryPath)
+	switch {
+	case err != nil:
+		result.Result, result.Detail = checkFail, fmt.Sprintf("the LaunchAgent starts %s, which is missing", binaryPath)
+		result.Fix = "Run 'sudo vpn-route-manager install' to install the binary again"
+	case info.Mode()&0111 == 0:
+		result.Result, result.Detail = checkFail, fmt.Sprintf("%s is not executable", binaryPath)
+		result.Fix = fmt.Sprintf("Run 'sudo chmod 755 %s'", binaryPath)
+	default:
+		resolved := binaryPath
+		if path, err := filepath.EvalSymlinks(binaryPath); err == nil {
+			resolved = path
+		}
+		if resolved != executable {
+			result.Result = checkWarn
+			result.Detail = fmt.Sprintf("the LaunchAgent starts %s, not this binary (%s)", binaryPath, executable)
+			result.Fix = "Run 'sudo vpn-route-manager install' with this binary to use it for the service"
+		} else {
+			result.Result, result.Detail = checkPass, binaryPath
+		}
+	}
+	return result
+}
+
+// checkLaunchAgent checks that the LaunchAgent is installed, loaded and
+// has the daemon running
+func checkLaunchAgent(launchAgent *system.LaunchAgent) checkResult {
+	result := checkResult{Name: "LaunchAgent"}
+
+	switch running, pid := launchAgent.IsRunning(); {
+	case !launchAgent.IsInstalled():
+		result.Result, result.Detail = checkFail, "not installed"
+		result.Fix = "Run 'sudo vpn-route-manager install'"
+	case !launchAgent.IsLoaded():
+		result.Result, result.Detail = checkFail, "installed but not loaded"
+		result.Fix = "Run 'vpn-route-manager start'"
+	case !running:
+		result.Result, result.Detail = checkFail, "loaded but the daemon is not running"
+		result.Fix = "Check 'vpn-route-manager logs --all' for why it exited, then run 'vpn-route-manager restart'"
+	default:
+		result.Result, result.Detail = checkPass, fmt.Sprintf("running (PID %d)", pid)
+	}
+	return result
+}
+
+// checkSudoers checks that the sudoers file is intact: owned by root with
+// mode 0440, valid, holding the expected entries and allowing every command
+// the daemon runs without a password. A daemon running as root needs none.
+func checkSudoers(sudoMgr *system.SudoManager, launchAgent *system.LaunchAgent) checkResult {
+	result := checkResult{Name: "Sudoers"}
+
+	if launchAgent.IsRoot() {
+		result.Result, result.Detail = checkSkip, "not needed, the service runs as root"
+		return result
+	}
+	if system.HelperDaemonInstalled() {
+		result.Result, result.Detail = checkSkip, "not needed, the service uses the helper daemon"
+		return result
+	}
+
+	integrity := sudoMgr.CheckIntegrity()
+	if !integrity.OK() {
+		result.Result = checkFail
+		result.Detail = strings.Join(integrity.Problems, "; ")
+		result.Fix = "Run 'sudo vpn-route-manager install --repair-sudo'"
+		return result
+	}
+
+	result.Result, result.Detail = checkPass, integrity.File
+	if integrity.Unverified {
+		result.Detail += " (run doctor with sudo to check its syntax and entries)"
+	}
+	return result
+}
+
+// checkConfiguration checks the configuration files and that they load,
+// configuring netMgr from them when they do
+func checkConfiguration(netMgr *network.Manager) checkResult {
+	result := checkResult{Name: "Configuration"}
+	fix := "Run 'vpn-route-manager config validate' for details"
+
+	files, problems, err := checkConfigFiles()
+	if err != nil {
+		result.Result, result.Detail, result.Fix = checkFail, err.Error(), fix
+		return result
+	}
+	errors, warnings := 0, 0
+	for _, problem := range problems {
+		if problem.Error {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	if errors > 0 {
+		result.Result, result.Fix = checkFail, fix
+		result.Detail = fmt.Sprintf("%s in %s", plural(errors, "error"), plural(len(files), "file"))
+		return result
+	}
+
+	// Loading catches what the file checks can't, such as a broken extends
+	cfg, err := completionConfig()
+	if err == nil {
+		err = netMgr.Configure(cfg.Get())
+	}
+	if err != nil {
+		result.Result, result.Detail, result.Fix = checkFail, err.Error(), fix
+		return result
+	}
+
+	switch {
+	case len(files) == 0:
+		result.Result, result.Detail = checkPass, "no configuration files, using defaults"
+	case warnings > 0:
+		result.Result, result.Fix = checkWarn, fix
+		result.Detail = fmt.Sprintf("%s in %s", plural(warnings, "warning"), plural(len(files), "file"))
+	default:
+		result.Result = checkPass
+		result.Detail = fmt.Sprintf("%s, %s enabled", plural(len(files), "file"), plural(len(cfg.GetEnabledServices()), "service"))
+	}
+	return result
+}
+
+// checkGateway checks that the local gateway is detected, returning it
+func checkGateway(netMgr *network.Manager) (checkResult, string) {
+	result := checkResult{Name: "Gateway"}
+
+	gateway, err := netMgr.DetectGateway()
+	if err != nil || gateway == "" {
+		result.Result = checkFail
+		result.Detail = "no gateway detected"
+		if err != nil {
+			result.Detail = err.Error()
+		}
+		result.Fix = "Check the network connection, or set 'gateway' in the configuration"
+		return result, ""
+	}
+
+	result.Result, result.Detail = checkPass, gateway
+	return result, gateway
+}
+
+// checkVPN reports the VPN detection. Being disconnected is only a
+// warning, as routes are simply not needed then.
+func checkVPN(netMgr *network.Manager) checkResult {
+	result := checkResult{Name: "VPN detection"}
+
+	detection := netMgr.DetectVPN()
+	score := fmt.Sprintf("score %.2f, threshold %.2f", detection.Score, detection.Threshold)
+	if !detection.Connected {
+		result.Result = checkWarn
+		result.Detail = fmt.Sprintf("not connected (%s)", score)
+		result.Fix = "If the VPN is connected, run 'vpn-route-manager vpn status' and adjust 'detection' in the configuration"
+		return result
+	}
+
+	info := netMgr.GetVPNInfo()
+	result.Result = checkPass
+	result.Detail = fmt.Sprintf("connected via %s, %s (%s)", valueOrUnknown(info.Interface), valueOrUnknown(info.Product), score)
+	return result
+}
+
+// checkTestRoute adds a route for doctorTestNetwork through gateway,
+// checks it is in the routing table and removes it again
+func checkTestRoute(netMgr *network.Manager, gateway string) checkResult {
+	result := checkResult{Name: "Test route"}
+
+	if err := netMgr.AddRoute(doctorTestNetwork, gateway, "doctor"); err != nil {
+		result.Result, result.Detail = checkFail, err.Error()
+		result.Fix = "Run 'sudo vpn-route-manager install' to recreate the sudoers entries"
+		return result
+	}
+
+	verified := netMgr.VerifyRoutes()[doctorTestNetwork]
+	if err := netMgr.RemoveRoute(doctorTestNetwork); err != nil {
+		result.Result, result.Detail = checkFail, err.Error()
+		result.Fix = fmt.Sprintf("Remove it with 'sudo route delete -net %s'", doctorTestNetwork)
+		return result
+	}
+	if !verified {
+		result.Result = checkFail
+		result.Detail = fmt.Sprintf("%s was added but is not in the routing table", doctorTestNetwork)
+		result.Fix = "Check for other software managing routes, such as a VPN client that resets them"
+		return result
+	}
+
+	result.Result, result.Detail = checkPass, fmt.Sprintf("added and removed %s via %s", doctorTestNetwork, gateway)
+	return result
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&skipRouteTest, "skip-route-test", false, "Don't add and remove a test route")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"vpn-route-manager/internal/config"
+)
+
+// editorCommand returns the user's editor and its arguments, preferring
+// $VISUAL over $EDITOR
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
+
+// editFile opens a copy of path in the user's editor and writes it back
+// once validate accepts it. Invalid edits are never written; the user is
+// offered to re-open the editor to fix them. It returns false when the
+// file was left unchanged.
+func editFile(path string, validate func([]byte) error) (bool, error) {
+	if nonInteractive || !stdinIsTerminal() {
+		return false, fmt.Errorf("editing needs a terminal, use 'config set' in scripts")
+	}
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	// Keep the extension so editors pick the right syntax
+	tmp, err := os.CreateTemp("", "vrm-*-"+filepath.Base(path))
+	if err != nil {
+		return false, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Close()
+
+	if err := os.WriteFile(tmp.Name(), original, 0600); err != nil {
+		return false, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	editor := editorCommand()
+
+	for {
+		cmd := exec.Command(editor[0], append(editor[1:], tmp.Name())...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return false, fmt.Errorf("editor %s failed: %w", editor[0], err)
+		}
+
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return false, fmt.Errorf("failed to read edited file: %w", err)
+		}
+		if bytes.Equal(edited, original) {
+			return false, nil
+		}
+
+		if err := validate(edited); err != nil {
+			fmt.Fprintf(stdout, "❌ %v\n", err)
+			if confirm("Re-open the editor to fix it?", true) {
+				continue
+			}
+			return false, fmt.Errorf("edit discarded, %s is unchanged", path)
+		}
+
+		if err := config.WriteFile(path, edited); err != nil {
+			return false, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		return true, nil
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+
+	"vpn-route-manager/internal/system"
+)
+
+// Exit codes, documented in the README. Scripts depend on them, so they
+// must not change.
+const (
+	exitOK               = 0
+	exitError            = 1
+	exitNotInstalled     = 2
+	exitDaemonNotRunning = 3
+	exitPermission       = 4
+	exitRouteFailed      = 5
+)
+
+// exitCodes maps the errors scripts may tell apart to their exit codes.
+// A permission problem wins over the route command it stopped.
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{system.ErrPermission, exitPermission},
+	{system.ErrNotInstalled, exitNotInstalled},
+	{system.ErrDaemonNotRunning, exitDaemonNotRunning},
+	{system.ErrRouteFailed, exitRouteFailed},
+}
+
+// exitCode returns the exit code for the error a command failed with
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	for _, mapping := range exitCodes {
+		if errors.Is(err, mapping.err) {
+			return mapping.code
+		}
+	}
+	return exitError
+}
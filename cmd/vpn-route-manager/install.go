@@ -1,26 +1,357 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/service"
 	"vpn-route-manager/internal/system"
 )
 
 var installCmd = &cobra.Command{
 	Use:   "install",
 	Short: "Install VPN Route Manager as a system service",
-	Long:  `Installs VPN Route Manager as a macOS LaunchAgent that starts automatically at login.`,
-	RunE:  runInstall,
+	Long: `Installs VPN Route Manager as a macOS LaunchAgent that starts automatically at login.
+
+When run in a terminal it asks which services to enable, how often to check
+the VPN, whether to install a LaunchDaemon that starts at boot instead, and
+which gateway to route through. The flags give the answers up front, and are
+all that is used with --yes or without a terminal.
+
+With --system the LaunchDaemon runs as root, so it changes routes itself and
+no sudoers rules are installed. The CLI then asks it to clear or repair
+routes over a socket that only members of --group may use.
+
+With --user the binary is kept in ~/.vpn-route-manager/bin, or with the
+XDG layout in $XDG_DATA_HOME/vpn-route-manager/bin, rather than
+/usr/local/bin, so install itself needs no sudo; only the sudoers entries
+ask for your password.
+
+With --bundle the binary and configuration come from a directory written
+by 'vpn-route-manager package', checked against its checksums, so nothing
+is downloaded; its configuration replaces the questions about services,
+interval and gateway.
+
+With --helper-daemon the privileged helper is registered with launchd as a
+LaunchDaemon and the service sends it route changes over a socket only you
+may use, so no sudoers rules are installed either.
+
+With --repair-sudo nothing else is installed: the sudoers entries are
+checked and rewritten if they have drifted, such as after an OS update.`,
+	RunE: runInstall,
+}
+
+// installOptions are the choices made while installing
+type installOptions struct {
+	services []string
+	interval int
+	daemon   bool
+	system   bool
+	group    string
+	user     bool
+	gateway  string
+	// reach the privileged helper through launchd rather than sudo
+	helperDaemon bool
+	// keep an existing configuration rather than writing one from the
+	// options above
+	keepConfig bool
+	// install the binary and configuration of a package bundle instead
+	bundle string
+}
+
+// installFlags reads the install options from the command line
+func installFlags(cmd *cobra.Command) (*installOptions, error) {
+	opts := &installOptions{}
+	opts.services, _ = cmd.Flags().GetStringSlice("services")
+	opts.interval, _ = cmd.Flags().GetInt("interval")
+	opts.daemon, _ = cmd.Flags().GetBool("launch-daemon")
+	opts.system, _ = cmd.Flags().GetBool("system")
+	opts.helperDaemon, _ = cmd.Flags().GetBool("helper-daemon")
+	opts.group, _ = cmd.Flags().GetString("group")
+	opts.user, _ = cmd.Flags().GetBool("user")
+	opts.gateway, _ = cmd.Flags().GetString("gateway")
+	if bundle, _ := cmd.Flags().GetString("bundle"); bundle != "" {
+		dir, err := filepath.Abs(bundle)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle directory: %w", err)
+		}
+		// Every file installed from the bundle must have been checked
+		required := []string{bundleBinary, bundleConfigDir + "/config.json"}
+		serviceFiles, _ := filepath.Glob(filepath.Join(dir, bundleConfigDir, "services", "*.json"))
+		for _, file := range serviceFiles {
+			required = append(required, bundleConfigDir+"/services/"+filepath.Base(file))
+		}
+		if err := system.VerifyChecksums(dir, required...); err != nil {
+			return nil, fmt.Errorf("invalid bundle %s: %w", bundle, err)
+		}
+		opts.bundle = dir
+	}
+	if opts.system {
+		if opts.user {
+			return nil, fmt.Errorf("--user can't be used with --system, root must not run a binary you can replace")
+		}
+		if opts.helperDaemon {
+			return nil, fmt.Errorf("--helper-daemon can't be used with --system, the service then changes routes itself")
+		}
+		opts.daemon = true
+	}
+
+	if !cmd.Flags().Changed("services") {
+		opts.services = nil
+		for name, service := range config.GetDefaultServiceConfigs() {
+			if service.Enabled {
+				opts.services = append(opts.services, name)
+			}
+		}
+		sort.Strings(opts.services)
+	}
+
+	if err := checkInstallServices(opts.services); err != nil {
+		return nil, err
+	}
+	if opts.interval < 1 || opts.interval > 300 {
+		return nil, fmt.Errorf("interval must be between 1 and 300 seconds")
+	}
+	if err := checkInstallGateway(opts.gateway); err != nil {
+		return nil, err
+	}
+	if opts.system {
+		if _, err := user.LookupGroup(opts.group); err != nil {
+			return nil, fmt.Errorf("unknown group '%s'", opts.group)
+		}
+	}
+	return opts, nil
+}
+
+// checkInstallServices checks that services are all default services
+func checkInstallServices(services []string) error {
+	defaults := config.GetDefaultServiceConfigs()
+	for _, name := range services {
+		if _, ok := defaults[name]; !ok {
+			return fmt.Errorf("unknown service '%s', choose from %s", name, strings.Join(sortedServiceNames(defaults), ", "))
+		}
+	}
+	return nil
+}
+
+// checkInstallGateway checks that gateway is auto or an IP address
+func checkInstallGateway(gateway string) error {
+	if gateway != "auto" && net.ParseIP(gateway) == nil {
+		return fmt.Errorf("invalid gateway '%s', use auto or an IP address", gateway)
+	}
+	return nil
+}
+
+// askInstallOptions walks through the install options, offering the
+// current ones as defaults. The configuration options aren't asked for
+// when the existing configuration is kept or a bundle's is installed.
+func askInstallOptions(opts *installOptions) error {
+	defaults := config.GetDefaultServiceConfigs()
+	w := newServiceWizard(0)
+	askConfig := !opts.keepConfig && opts.bundle == ""
+
+	if askConfig {
+		fmt.Fprintln(stdout, "\nAvailable services:")
+		for _, name := range sortedServiceNames(defaults) {
+			fmt.Fprintf(stdout, "  • %-14s %s\n", name, defaults[name].Description)
+		}
+		for {
+			answer, err := w.prompt("Services to enable (comma-separated, or none)", strings.Join(opts.services, ","))
+			if err != nil {
+				return err
+			}
+			var services []string
+			if answer != "none" {
+				for _, name := range strings.Split(answer, ",") {
+					if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+						services = append(services, name)
+					}
+				}
+			}
+			if err := checkInstallServices(services); err != nil {
+				fmt.Fprintf(stdout, "  ❌ %v\n", err)
+				continue
+			}
+			opts.services = services
+			break
+		}
+
+		for {
+			value, err := w.prompt("Seconds between VPN checks (1-300)", strconv.Itoa(opts.interval))
+			if err != nil {
+				return err
+			}
+			interval, err := strconv.Atoi(value)
+			if err == nil && interval >= 1 && interval <= 300 {
+				opts.interval = interval
+				break
+			}
+			fmt.Fprintln(stdout, "  ❌ Enter a number between 1 and 300")
+		}
+	}
+
+	fmt.Fprintln(stdout, "\nA LaunchAgent starts when you log in. A LaunchDaemon starts at boot,")
+	fmt.Fprintln(stdout, "before anyone logs in, and still runs as you.")
+	opts.daemon = confirm("Install as a LaunchDaemon?", opts.daemon)
+	if opts.daemon && !opts.user {
+		fmt.Fprintln(stdout, "\nA LaunchDaemon can run as root instead, changing routes without any")
+		fmt.Fprintf(stdout, "sudoers rules. Members of the %s group can then control it.\n", opts.group)
+		opts.system = confirm("Run it as root?", opts.system)
+	} else {
+		opts.system = false
+	}
+
+	if askConfig {
+		fmt.Fprintln(stdout, "\nBypass routes go through the gateway of the physical network. With auto")
+		fmt.Fprintln(stdout, "it is detected when the VPN connects, or enter a fixed gateway address.")
+		for {
+			gateway, err := w.prompt("Gateway (auto or IP address)", opts.gateway)
+			if err != nil {
+				return err
+			}
+			if err := checkInstallGateway(gateway); err != nil {
+				fmt.Fprintf(stdout, "  ❌ %v\n", err)
+				continue
+			}
+			opts.gateway = gateway
+			break
+		}
+	}
+	fmt.Fprintln(stdout)
+	return nil
+}
+
+// jobOptions returns the launchd settings of the configuration
+func jobOptions(cfg *config.Config) system.JobOptions {
+	return system.JobOptions{
+		KeepAlive:        cfg.Launchd.KeepAlive,
+		ThrottleInterval: cfg.Launchd.ThrottleInterval,
+		Nice:             cfg.Launchd.Nice,
+		OnDemand:         cfg.Launchd.OnDemand,
+		ControlGroup:     service.ControlGroupName(cfg),
+		ControlSocket:    service.ControlSocket,
+	}
+}
+
+// inPath reports whether dir is in PATH
+func inPath(dir string) bool {
+	for _, entry := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(entry) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// installedBinaryPath returns where the binary is installed: the one the
+// service starts, otherwise the one in /usr/local/bin or, failing that, in
+// the user's bin directory
+func installedBinaryPath(launchAgent *system.LaunchAgent) string {
+	if launchAgent.IsInstalled() {
+		if path, err := launchAgent.BinaryPath(); err == nil {
+			return path
+		}
+	}
+	systemPath := filepath.Join(config.SystemBinDir, "vpn-route-manager")
+	userPath := filepath.Join(config.UserBinDir(), "vpn-route-manager")
+	if _, err := os.Stat(systemPath); err != nil {
+		if _, err := os.Stat(userPath); err == nil {
+			return userPath
+		}
+	}
+	return systemPath
+}
+
+// sortedServiceNames returns the names of services in order
+func sortedServiceNames(services map[string]*config.Service) []string {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// repairSudo rewrites the sudoers entries when they aren't intact, without
+// reinstalling anything else
+func repairSudo() error {
+	username := os.Getenv("USER")
+	if username == "" {
+		return fmt.Errorf("could not determine current user")
+	}
+	if system.NewLaunchAgent(username).IsRoot() {
+		fmt.Fprintln(stdout, "✅ The service runs as root and needs no sudoers entries")
+		return nil
+	}
+	if system.HelperDaemonInstalled() {
+		fmt.Fprintln(stdout, "✅ The service uses the helper daemon and needs no sudoers entries")
+		return nil
+	}
+
+	fmt.Fprintln(stdout, "🔍 Checking sudoers entries...")
+	sudoMgr := system.NewSudoManager(username)
+	integrity := sudoMgr.CheckIntegrity()
+	for _, problem := range integrity.Problems {
+		fmt.Fprintf(stdout, "  ❌ %s\n", problem)
+	}
+	if integrity.OK() && !integrity.Unverified {
+		fmt.Fprintf(stdout, "✅ %s is intact\n", integrity.File)
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "🔐 Rewriting %s...\n", integrity.File)
+	if err := sudoMgr.Setup(); err != nil {
+		return fmt.Errorf("failed to setup sudo: %w", err)
+	}
+	if err := sudoMgr.TestAccess(); err != nil {
+		return fmt.Errorf("sudo test failed: %w", err)
+	}
+	if integrity = sudoMgr.CheckIntegrity(); !integrity.OK() {
+		return fmt.Errorf("%w: sudoers entries still not intact: %s", system.ErrPermission, strings.Join(integrity.Problems, "; "))
+	}
+	fmt.Fprintln(stdout, "✅ Sudo permissions repaired")
+	return nil
 }
 
 func runInstall(cmd *cobra.Command, args []string) error {
-	fmt.Println("🚀 Installing VPN Route Manager...")
+	if repair, _ := cmd.Flags().GetBool("repair-sudo"); repair {
+		return repairSudo()
+	}
+
+	opts, err := installFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, "🚀 Installing VPN Route Manager...")
+
+	// Installing again keeps the configuration unless told otherwise. A
+	// bundle is installed for its configuration, so it always replaces it.
+	if _, err := os.Stat(getConfigPath()); err == nil && opts.bundle != "" {
+		backup, err := config.Backup(config.BackupDir(), getConfigPath())
+		if err != nil {
+			return fmt.Errorf("failed to back up the configuration: %w", err)
+		}
+		fmt.Fprintf(stdout, "\n📋 Replacing %s with the bundle's, backed up to %s\n", getConfigPath(), backup)
+	} else if err == nil {
+		fmt.Fprintf(stdout, "\n📋 %s already exists; 'vpn-route-manager upgrade' upgrades an\n", getConfigPath())
+		fmt.Fprintln(stdout, "installation keeping it, or it can be replaced with new settings.")
+		opts.keepConfig = !confirm("Replace the existing configuration?", false)
+	}
+	if interactive() {
+		if err := askInstallOptions(opts); err != nil {
+			return err
+		}
+	}
 
 	// Get current user
 	username := os.Getenv("USER")
@@ -29,14 +360,19 @@ func runInstall(cmd *cobra.Command, args []string) error {
 	}
 
 	// For system operations, check if we have necessary permissions
-	if os.Geteuid() != 0 {
+	binDir := config.SystemBinDir
+	if opts.user {
+		binDir = config.UserBinDir()
+	} else if os.Geteuid() != 0 {
 		// Check if we can write to /usr/local/bin
 		testFile := "/usr/local/bin/.vpn-route-manager-test"
 		if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
-			fmt.Println("\n⚠️  This command requires administrator privileges.")
-			fmt.Println("Please run with sudo:")
-			fmt.Printf("\n  sudo %s install\n\n", os.Args[0])
-			return fmt.Errorf("insufficient privileges")
+			fmt.Fprintln(stdout, "\n⚠️  This command requires administrator privileges.")
+			fmt.Fprintln(stdout, "Please run with sudo:")
+			fmt.Fprintf(stdout, "\n  sudo %s install\n\n", os.Args[0])
+			fmt.Fprintln(stdout, "Or keep the binary in your home directory instead:")
+			fmt.Fprintf(stdout, "\n  %s install --user\n\n", os.Args[0])
+			return system.ErrPermission
 		}
 		os.Remove(testFile)
 	}
@@ -46,18 +382,21 @@ func runInstall(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get executable path: %w", err)
 	}
+	if opts.bundle != "" {
+		fmt.Fprintf(stdout, "📦 Installing from bundle %s (checksums verified)\n", opts.bundle)
+		binaryPath = filepath.Join(opts.bundle, bundleBinary)
+	}
 
 	// Ensure binary is in a permanent location
-	homeDir, _ := os.UserHomeDir()
-	installPath := filepath.Join("/usr/local/bin", "vpn-route-manager")
+	installPath := filepath.Join(binDir, "vpn-route-manager")
 	
 	// Check if we need to copy the binary
 	if binaryPath != installPath {
-		fmt.Printf("📁 Installing binary to %s...\n", installPath)
+		fmt.Fprintf(stdout, "📁 Installing binary to %s...\n", installPath)
 		
-		// Ensure /usr/local/bin exists
-		if err := os.MkdirAll("/usr/local/bin", 0755); err != nil {
-			return fmt.Errorf("failed to create /usr/local/bin: %w", err)
+		// Ensure the bin directory exists
+		if err := os.MkdirAll(binDir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", binDir, err)
 		}
 
 		// Copy binary
@@ -75,14 +414,9 @@ func runInstall(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create configuration directories
-	fmt.Println("📂 Creating configuration directories...")
-	configDir := filepath.Join(homeDir, ".vpn-route-manager")
-	dirs := []string{
-		filepath.Join(configDir, "config"),
-		filepath.Join(configDir, "config", "services"),
-		filepath.Join(configDir, "logs"),
-		filepath.Join(configDir, "state"),
-	}
+	fmt.Fprintln(stdout, "📂 Creating configuration directories...")
+	configDir := config.ConfigDir()
+	dirs := append(config.DefaultPaths().Dirs(), filepath.Join(configDir, "services"), config.LogDir())
 
 	for _, dir := range dirs {
 		if err := os.MkdirAll(dir, 0755); err != nil {
@@ -90,101 +424,206 @@ func runInstall(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Create default configuration
-	fmt.Println("⚙️  Creating default configuration...")
-	cfgManager := config.NewManager(filepath.Join(configDir, "config", "config.json"))
-	
-	// Set default services
-	cfg := cfgManager.Get()
-	cfg.Services = config.GetDefaultServiceConfigs()
-	
-	// Ensure directories are set
+	// Create the configuration from the defaults and the chosen options,
+	// or keep the existing one
+	var cfg *config.Config
+	var services map[string]*config.Service
+	if opts.keepConfig {
+		fmt.Fprintln(stdout, "⚙️  Keeping existing configuration...")
+		if err := migrateConfig(); err != nil {
+			return err
+		}
+		cfgManager, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		cfg, services = cfgManager.Get(), cfgManager.Get().Services
+		if opts.system && cfg.ControlGroup != opts.group {
+			cfg.ControlGroup = opts.group
+			if err := cfgManager.Save(); err != nil {
+				return fmt.Errorf("failed to save configuration: %w", err)
+			}
+		}
+	} else if opts.bundle != "" {
+		fmt.Fprintln(stdout, "⚙️  Installing the bundle's configuration...")
+		var err error
+		if cfg, services, err = config.ReadInitial(filepath.Join(opts.bundle, bundleConfigDir)); err != nil {
+			return fmt.Errorf("failed to read bundle configuration: %w", err)
+		}
+		if opts.system {
+			cfg.ControlGroup = opts.group
+		}
+		if _, err := config.WriteInitial(configDir, cfg, services, true); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+	} else {
+		fmt.Fprintln(stdout, "⚙️  Creating default configuration...")
+		cfg = config.GetDefaultConfig()
+		cfg.CheckInterval = opts.interval
+		cfg.Gateway = opts.gateway
+		if opts.system {
+			cfg.ControlGroup = opts.group
+		}
+		services = config.GetDefaultServiceConfigs()
+		for name, service := range services {
+			service.Enabled = containsString(opts.services, name)
+		}
+		if _, err := config.WriteInitial(configDir, cfg, services, true); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+	}
 	if err := config.EnsureDirectories(cfg); err != nil {
 		return fmt.Errorf("failed to create directories: %w", err)
 	}
+	servicesDir := filepath.Join(configDir, "services")
 
-	// Save configuration
-	if err := cfgManager.Save(); err != nil {
-		return fmt.Errorf("failed to save configuration: %w", err)
-	}
+	sudoMgr := system.NewSudoManager(username)
+	if opts.system {
+		// The root daemon needs no sudo rules, so drop any left over
+		if sudoMgr.IsConfigured() {
+			if err := sudoMgr.Remove(); err != nil {
+				return fmt.Errorf("failed to remove sudo permissions: %w", err)
+			}
+		}
+		if err := system.RemoveHelperDaemon(); err != nil {
+			return fmt.Errorf("failed to remove helper daemon: %w", err)
+		}
+		fmt.Fprintln(stdout, "🔐 No sudo permissions needed, the service runs as root")
+	} else if opts.helperDaemon {
+		// The helper daemon takes the commands sudo would otherwise run
+		if sudoMgr.IsConfigured() {
+			if err := sudoMgr.Remove(); err != nil {
+				return fmt.Errorf("failed to remove sudo permissions: %w", err)
+			}
+		}
+		account, err := user.Lookup(username)
+		if err != nil {
+			return fmt.Errorf("failed to look up user %s: %w", username, err)
+		}
+		uid, err := strconv.Atoi(account.Uid)
+		if err != nil {
+			return fmt.Errorf("invalid ID of user %s: %s", username, account.Uid)
+		}
+		fmt.Fprintf(stdout, "🔐 Registering the privileged helper with launchd as %s...\n", system.HelperDaemonLabel)
+		if err := system.InstallHelperDaemon(binaryPath, uid); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "✅ Routes are changed through %s, no sudo permissions needed\n", system.HelperSocket)
+	} else {
+		if err := system.RemoveHelperDaemon(); err != nil {
+			return fmt.Errorf("failed to remove helper daemon: %w", err)
+		}
+		// Setup sudo permissions
+		fmt.Fprintln(stdout, "🔐 Setting up sudo permissions...")
+		if err := sudoMgr.Setup(); err != nil {
+			return fmt.Errorf("failed to setup sudo: %w", err)
+		}
 
-	// Save individual service files
-	servicesDir := filepath.Join(configDir, "config", "services")
-	for name, svc := range cfg.Services {
-		svcPath := filepath.Join(servicesDir, name+".json")
-		if err := saveServiceFile(svcPath, svc); err != nil {
-			fmt.Printf("⚠️  Warning: failed to save service %s: %v\n", name, err)
+		// Test sudo access
+		if err := sudoMgr.TestAccess(); err != nil {
+			return fmt.Errorf("sudo test failed: %w", err)
 		}
+		fmt.Fprintln(stdout, "✅ Sudo permissions configured")
 	}
 
-	// Setup sudo permissions
-	fmt.Println("🔐 Setting up sudo permissions...")
-	sudoMgr := system.NewSudoManager(username)
-	if err := sudoMgr.Setup(); err != nil {
-		return fmt.Errorf("failed to setup sudo: %w", err)
+	// Install the LaunchAgent or LaunchDaemon, replacing another kind or
+	// one under another label
+	launchAgent := system.NewLaunchAgent(username)
+	label := launchAgent.WithLabel(cfg.Launchd.Label).Label()
+	kind := "LaunchAgent"
+	switch {
+	case opts.system:
+		kind = "LaunchDaemon (root)"
+	case opts.daemon:
+		kind = "LaunchDaemon"
 	}
-
-	// Test sudo access
-	if err := sudoMgr.TestAccess(); err != nil {
-		return fmt.Errorf("sudo test failed: %w", err)
+	if launchAgent.IsInstalled() && (launchAgent.IsDaemon() != opts.daemon || launchAgent.IsRoot() != opts.system || launchAgent.Label() != label) {
+		if err := launchAgent.Uninstall(); err != nil {
+			return fmt.Errorf("failed to remove previous installation: %w", err)
+		}
+	}
+	switch {
+	case opts.system:
+		launchAgent = system.NewRootDaemon(username)
+	case opts.daemon:
+		launchAgent = system.NewLaunchDaemon(username)
+	case launchAgent.IsDaemon():
+		launchAgent = system.NewLaunchAgent(username)
+	}
+	launchAgent = launchAgent.WithLabel(label)
+	launchAgent.SetOptions(jobOptions(cfg))
+	// Only the root daemon has a control socket to be started through
+	onDemand := opts.system && cfg.Launchd.OnDemand
+	if cfg.Launchd.OnDemand && !opts.system {
+		fmt.Fprintln(stdout, "⚠️  launchd.on_demand only applies to install --system, the service starts when loaded")
 	}
-	fmt.Println("✅ Sudo permissions configured")
 
-	// Install LaunchAgent
-	fmt.Println("🎯 Installing LaunchAgent...")
-	launchAgent := system.NewLaunchAgent(username)
-	if err := launchAgent.Install(binaryPath); err != nil {
-		return fmt.Errorf("failed to install LaunchAgent: %w", err)
+	fmt.Fprintf(stdout, "🎯 Installing %s...\n", kind)
+	if err := launchAgent.Install(binaryPath, config.LogDir()); err != nil {
+		return fmt.Errorf("failed to install %s: %w", kind, err)
 	}
 
 	// Verify installation
 	if launchAgent.IsLoaded() {
-		fmt.Println("✅ LaunchAgent installed and loaded")
+		fmt.Fprintf(stdout, "✅ %s installed and loaded\n", kind)
 		
 		// Check if running
 		if running, pid := launchAgent.IsRunning(); running {
-			fmt.Printf("✅ Service is running (PID: %d)\n", pid)
+			fmt.Fprintf(stdout, "✅ Service is running (PID: %d)\n", pid)
+		} else if onDemand {
+			fmt.Fprintln(stdout, "💤 Service starts when the CLI first connects to it")
 		} else {
-			fmt.Println("⚠️  Service loaded but not yet running")
+			fmt.Fprintln(stdout, "⚠️  Service loaded but not yet running")
 		}
 	} else {
-		return fmt.Errorf("LaunchAgent installation verification failed")
+		return fmt.Errorf("%s installation verification failed", kind)
 	}
 
 	// Print summary
-	fmt.Println("\n✅ Installation completed successfully!")
-	fmt.Println("\n📋 Installation Summary:")
-	fmt.Printf("  • Binary: %s\n", binaryPath)
-	fmt.Printf("  • Config: %s\n", filepath.Join(configDir, "config", "config.json"))
-	fmt.Printf("  • Services: %s\n", servicesDir)
-	fmt.Printf("  • Logs: %s\n", filepath.Join(configDir, "logs"))
-	fmt.Println("\n📋 Default Services:")
-	fmt.Println("  ✅ Telegram: ENABLED")
-	fmt.Println("  ✅ YouTube: ENABLED")
-	fmt.Println("  ❌ WhatsApp: disabled")
-	fmt.Println("  ❌ Spotify: disabled")
-	fmt.Println("  ❌ Apple Music: disabled")
-	fmt.Println("  ❌ Facebook: disabled")
-	fmt.Println("  ❌ Instagram: disabled")
-	fmt.Println("\n💡 Management Commands:")
-	fmt.Println("  • Status:  vpn-route-manager status")
-	fmt.Println("  • Services: vpn-route-manager service list")
-	fmt.Println("  • Logs:    vpn-route-manager logs")
-	fmt.Println("\n🎉 VPN Route Manager is now monitoring your VPN connection!")
+	fmt.Fprintln(stdout, "\n✅ Installation completed successfully!")
+	fmt.Fprintln(stdout, "\n📋 Installation Summary:")
+	fmt.Fprintf(stdout, "  • Binary: %s\n", binaryPath)
+	fmt.Fprintf(stdout, "  • Config: %s\n", filepath.Join(configDir, "config", "config.json"))
+	fmt.Fprintf(stdout, "  • Services: %s\n", servicesDir)
+	fmt.Fprintf(stdout, "  • Logs: %s\n", filepath.Join(configDir, "logs"))
+	fmt.Fprintf(stdout, "  • Service: %s (%s)\n", kind, launchAgent.PlistPath())
+	fmt.Fprintf(stdout, "  • Gateway: %s, checked every %s\n", cfg.Gateway, plural(cfg.CheckInterval, "second"))
+	if opts.system {
+		fmt.Fprintf(stdout, "  • Control: %s (group %s)\n", service.ControlSocket, opts.group)
+	}
+	if onDemand {
+		fmt.Fprintln(stdout, "  • Started: on demand, by 'vpn-route-manager start' or a command that needs it")
+	}
+	fmt.Fprintln(stdout, "\n📋 Services:")
+	for _, name := range sortedServiceNames(services) {
+		if services[name].Enabled {
+			fmt.Fprintf(stdout, "  ✅ %s: ENABLED\n", services[name].Name)
+		} else {
+			fmt.Fprintf(stdout, "  ❌ %s: disabled\n", services[name].Name)
+		}
+	}
+	if !inPath(binDir) {
+		fmt.Fprintf(stdout, "\n💡 Add %s to your PATH to run vpn-route-manager by name\n", binDir)
+	}
+	fmt.Fprintln(stdout, "\n💡 Management Commands:")
+	fmt.Fprintln(stdout, "  • Status:  vpn-route-manager status")
+	fmt.Fprintln(stdout, "  • Services: vpn-route-manager service list")
+	fmt.Fprintln(stdout, "  • Logs:    vpn-route-manager logs")
+	fmt.Fprintln(stdout, "\n🎉 VPN Route Manager is now monitoring your VPN connection!")
 
 	return nil
 }
 
-func saveServiceFile(path string, service *config.Service) error {
-	// Create wrapper format for compatibility
-	wrapper := map[string]*config.Service{
-		service.Name: service,
-	}
-	
-	data, err := json.MarshalIndent(wrapper, "", "  ")
-	if err != nil {
-		return err
-	}
-	
-	return os.WriteFile(path, data, 0644)
-}
\ No newline at end of file
+func init() {
+	defaults := config.GetDefaultConfig()
+	installCmd.Flags().StringSlice("services", nil, "Default services to enable (default the ones enabled by default)")
+	installCmd.Flags().Int("interval", defaults.CheckInterval, "Seconds between VPN checks")
+	installCmd.Flags().Bool("launch-daemon", false, "Install a LaunchDaemon that starts at boot instead of a LaunchAgent")
+	installCmd.Flags().Bool("system", false, "Install a LaunchDaemon running as root, without sudoers rules")
+	installCmd.Flags().Bool("helper-daemon", false, "Register the privileged helper with launchd and reach it over a socket, without sudoers rules")
+	installCmd.Flags().String("group", "admin", "Group allowed to control the root LaunchDaemon")
+	installCmd.Flags().Bool("user", false, "Keep the binary in your home directory, so install needs no sudo")
+	installCmd.Flags().String("bundle", "", "Install the binary and configuration of a bundle written by 'vpn-route-manager package'")
+	installCmd.Flags().Bool("repair-sudo", false, "Only check the sudoers entries and rewrite them if they aren't intact")
+	installCmd.Flags().String("gateway", defaults.Gateway, "Gateway for bypass routes: auto or an IP address")
+}
@@ -4,15 +4,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/spf13/cobra"
 	"vpn-route-manager/internal/config"
 	"vpn-route-manager/internal/logger"
+	"vpn-route-manager/internal/network"
+	"vpn-route-manager/internal/system"
 )
 
 var (
-	version = "1.0.0"
 	cfgFile string
+	baseDir string
 	debug   bool
 )
 
@@ -22,12 +25,28 @@ var rootCmd = &cobra.Command{
 	Long: `VPN Route Manager automatically manages network routes to allow specific 
 applications and services to bypass VPN connections while maintaining 
 VPN protection for all other traffic.`,
-	Version: version,
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.vpn-route-manager/config/config.json)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is config.json in the config directory)")
+	rootCmd.PersistentFlags().StringVar(&baseDir, "base-dir", "", "root directory for config, state and logs (default $VRM_HOME, ~/.vpn-route-manager or XDG directories)")
+
+	// The base directory is passed on through the environment, so the
+	// LaunchAgent installed with it uses it too
+	cobra.OnInitialize(func() {
+		if baseDir != "" {
+			os.Setenv(config.EnvHome, baseDir)
+		}
+	})
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "enable debug logging")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "answer yes to all confirmations")
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "never prompt, taking the default answers (implied when stdin is not a terminal)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, "output format for status, service list/show, route list/show/verify, config get, doctor and sudoers audit: table, json or yaml")
+	rootCmd.PersistentFlags().BoolVar(&plainOutput, "plain", false, "print without emoji and symbols (implied by NO_COLOR or when stdout is not a terminal)")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		setupPlainOutput()
+		return checkOutputFormat()
+	}
 
 	// Add subcommands
 	rootCmd.AddCommand(
@@ -36,19 +55,36 @@ func init() {
 		startCmd,
 		stopCmd,
 		restartCmd,
+		pauseCmd,
+		resumeCmd,
 		statusCmd,
+		doctorCmd,
+		sudoersCmd,
 		serviceCmd,
 		routeCmd,
+		vpnCmd,
+		subscriptionCmd,
 		configCmd,
 		debugCmd,
+		debugBundleCmd,
+		versionCmd,
+		updateCmd,
+		upgradeCmd,
+		packageCmd,
 		logsCmd,
+		completionCmd,
 	)
 }
 
 func main() {
+	// Installed as the privileged helper the binary only runs its commands
+	if system.IsHelper() {
+		os.Exit(network.RunHelper(os.Args[1:]))
+	}
+
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		fmt.Fprintln(stderr, err)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -57,33 +93,93 @@ func getConfigPath() string {
 	if cfgFile != "" {
 		return cfgFile
 	}
-	homeDir, _ := os.UserHomeDir()
-	return filepath.Join(homeDir, ".vpn-route-manager", "config", "config.json")
+	return filepath.Join(config.ConfigDir(), "config.json")
 }
 
 // getServicesPath returns the services directory path
 func getServicesPath() string {
-	homeDir, _ := os.UserHomeDir()
-	return filepath.Join(homeDir, ".vpn-route-manager", "config", "services")
+	return filepath.Join(config.ConfigDir(), "services")
 }
 
 // createLogger creates a logger instance
 func createLogger() (*logger.Logger, error) {
-	homeDir, _ := os.UserHomeDir()
-	logPath := filepath.Join(homeDir, ".vpn-route-manager", "logs", "vpn-route-manager.log")
+	return logger.New(loggerConfig())
+}
+
+// loggerConfig returns the logger configuration from the logging settings.
+// It doesn't rotate the log file, which only the daemon does.
+func loggerConfig() logger.Config {
+	settings := logSettings()
+	logPath := ""
+	if settings.HasOutput(config.LogOutputFile) {
+		logPath = filepath.Join(config.LogDir(), "vpn-route-manager.log")
+	}
+
+	// Keep stdout for the data when machine-readable output was asked for
+	console := stdout
+	if structuredOutput() {
+		console = stderr
+	}
 	
-	return logger.New(logger.Config{
-		LogPath:    logPath,
-		MaxSizeMB:  10,
-		MaxBackups: 5,
-		Debug:      debug,
-	})
+	return logger.Config{
+		LogPath:        logPath,
+		MaxSizeMB:      settings.MaxSizeMB,
+		MaxBackups:     settings.MaxBackups,
+		Daily:          settings.Daily,
+		Compress:       settings.Compress,
+		MaxAgeDays:     settings.MaxAgeDays,
+		MaxTotalSizeMB: settings.MaxTotalSizeMB,
+		Debug:          debug || config.DebugFromEnv(),
+		Console:        console,
+		Format:         settings.Format,
+		Syslog:         settings.HasOutput(config.LogOutputSyslog),
+		SyslogTag:      "vpn-route-manager",
+	}
+}
+
+// logSettings returns the logging section of the configuration, or the
+// defaults when it can't be loaded, in which case the error comes up
+// again wherever the configuration is needed
+func logSettings() config.LoggingConfig {
+	cfgManager, err := loadConfig()
+	if err != nil {
+		return config.GetDefaultConfig().Logging
+	}
+	return cfgManager.Get().Logging
+}
+
+// migrateConfig upgrades files written by older versions in place. Only
+// install and the daemon's start do, as upgrade does on its own; files of
+// older versions still load without it.
+func migrateConfig() error {
+	migrated, err := config.NewManager(getConfigPath()).Migrate(getServicesPath())
+	for _, m := range migrated {
+		fmt.Fprintf(stderr, "Migrated %s from schema version %d to %d (backup: %s)\n",
+			m.File, m.From, config.SchemaVersion, m.Backup)
+	}
+	return err
 }
 
-// loadConfig loads the configuration
+// The configuration is loaded once per run and shared by the logger and
+// the command
+var (
+	configOnce   sync.Once
+	sharedConfig *config.Manager
+	configErr    error
+)
+
+// loadConfig loads the configuration, the first time it is called
 func loadConfig() (*config.Manager, error) {
+	configOnce.Do(func() {
+		sharedConfig, configErr = readConfig()
+	})
+	return sharedConfig, configErr
+}
+
+// readConfig reads the configuration and the services
+func readConfig() (*config.Manager, error) {
 	cfgManager := config.NewManager(getConfigPath())
-	
+
 	// Load main config
 	if err := cfgManager.Load(); err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
@@ -101,5 +197,30 @@ func loadConfig() (*config.Manager, error) {
 		}
 	}
 
+	// Add services from subscribed lists
+	if err := cfgManager.LoadSubscriptions(); err != nil {
+		return nil, fmt.Errorf("failed to load subscriptions: %w", err)
+	}
+
+	if err := config.ValidateExtends(cfgManager.Get().Services); err != nil {
+		fmt.Fprintf(stderr, "Warning: %v\n", err)
+	}
+
 	return cfgManager, nil
-}
\ No newline at end of file
+}
+
+// createNetworkManager creates a network manager configured from the
+// config file, keeping built-in defaults if the config can't be loaded
+func createNetworkManager(log *logger.Logger) (*network.Manager, error) {
+	netMgr := network.NewManager(log)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return netMgr, nil
+	}
+
+	if err := netMgr.Configure(cfg.Get()); err != nil {
+		return nil, err
+	}
+	return netMgr, nil
+}
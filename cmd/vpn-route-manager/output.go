@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"vpn-route-manager/internal/config"
+)
+
+// Formats accepted by --output
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFormat string
+
+// checkOutputFormat rejects unknown --output values before a command runs
+func checkOutputFormat() error {
+	switch outputFormat {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("invalid output format '%s': expected table, json or yaml", outputFormat)
+}
+
+// structuredOutput reports whether machine-readable output was requested
+// instead of the human-readable text
+func structuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// printStructured writes value to stdout as JSON or YAML, whichever
+// --output asked for
+func printStructured(value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	if outputFormat == outputYAML {
+		if data, err = config.JSONToYAML(data); err != nil {
+			return err
+		}
+	} else {
+		data = append(data, '\n')
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
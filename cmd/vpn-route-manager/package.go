@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/system"
+)
+
+// Layout of an offline install bundle
+const (
+	bundleBinary    = "vpn-route-manager"
+	bundleConfigDir = "config"
+)
+
+var packageCmd = &cobra.Command{
+	Use:   "package <dir>",
+	Short: "Write a self-contained bundle to install on offline machines",
+	Long: `Write a directory holding everything install needs, to be copied to a
+machine without internet access and installed there with
+'install --bundle <dir>':
+
+  • vpn-route-manager   this binary, so only for its architecture
+  • config/             config.json and the service files, the defaults
+                        or with --current-config this machine's
+  • <label>.plist       the launchd job, for review; install writes it
+                        again for the user it runs as
+  • checksums.txt       SHA-256 sums install checks the files against
+
+The log and state directories in the configuration are replaced with the
+target machine's when installing.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := args[0]
+		force, _ := cmd.Flags().GetBool("force")
+		if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 && !force {
+			return fmt.Errorf("%s is not empty, use --force to overwrite", dir)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+
+		fmt.Fprintf(stdout, "📦 Packaging VPN Route Manager %s for darwin/%s...\n", currentBuild().Version, runtime.GOARCH)
+
+		executable, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to get executable path: %w", err)
+		}
+		if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+			executable = resolved
+		}
+		binary, err := os.ReadFile(executable)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", executable, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, bundleBinary), binary, 0755); err != nil {
+			return fmt.Errorf("failed to write binary: %w", err)
+		}
+
+		// Service files are written apart from config.json
+		cfg := config.GetDefaultConfig()
+		services := config.GetDefaultServiceConfigs()
+		if current, _ := cmd.Flags().GetBool("current-config"); current {
+			cfgManager, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			copied := *cfgManager.Get()
+			cfg, services = &copied, copied.Services
+			cfg.Services = make(map[string]*config.Service)
+		}
+		files, err := config.WriteInitial(filepath.Join(dir, bundleConfigDir), cfg, services, true)
+		if err != nil {
+			return fmt.Errorf("failed to write configuration: %w", err)
+		}
+		fmt.Fprintf(stdout, "⚙️  Wrote the configuration and %s\n", plural(len(files)-1, "service file"))
+
+		username := os.Getenv("USER")
+		if username == "" {
+			return fmt.Errorf("could not determine current user")
+		}
+		job := system.NewLaunchAgent(username).WithLabel(cfg.Launchd.Label)
+		job.SetOptions(jobOptions(cfg))
+		plist, err := job.Plist(filepath.Join(config.SystemBinDir, bundleBinary), config.LogDir())
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, job.Label()+".plist"), plist, 0644); err != nil {
+			return fmt.Errorf("failed to write plist: %w", err)
+		}
+
+		if err := system.WriteChecksums(dir); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "✅ Bundle written to %s\n", dir)
+		fmt.Fprintln(stdout, "\n💡 Copy it to the target machine and install from it there:")
+		fmt.Fprintf(stdout, "  cd %s && sudo ./%s install --bundle .\n", filepath.Base(dir), bundleBinary)
+		return nil
+	},
+}
+
+func init() {
+	packageCmd.Flags().Bool("current-config", false, "Package this machine's configuration and services instead of the defaults")
+	packageCmd.Flags().Bool("force", false, "Write into a directory that isn't empty")
+}
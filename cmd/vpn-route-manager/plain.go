@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+var (
+	plainOutput bool
+
+	// stdout and stderr are where commands print their messages, through
+	// plainWriter in plain mode. Data such as --output or export goes to
+	// os.Stdout unchanged.
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+// setupPlainOutput switches to plain output with --plain, when NO_COLOR
+// is set or when stdout is not a terminal, e.g. in logs and scripts
+func setupPlainOutput() {
+	if !plainOutput && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout) {
+		return
+	}
+	stdout = plainWriter{os.Stdout}
+	stderr = plainWriter{os.Stderr}
+}
+
+// plainWriter drops emoji along with the spaces after them, and replaces
+// the other symbols with ASCII
+type plainWriter struct {
+	w io.Writer
+}
+
+func (p plainWriter) Write(data []byte) (int, error) {
+	if _, err := io.WriteString(p.w, plainText(string(data))); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// plainText strips emoji and symbols from s
+func plainText(s string) string {
+	var b strings.Builder
+	skipSpace := false
+	for _, r := range s {
+		if skipSpace && r == ' ' {
+			continue
+		}
+		skipSpace = false
+
+		switch {
+		case r == '•':
+			b.WriteRune('-')
+		case r == '→':
+			b.WriteString("->")
+		case isEmoji(r):
+			skipSpace = true
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isEmoji checks if r is an emoji, a pictographic symbol or a variation
+// selector turning one into an emoji
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF:
+		return true
+	case r >= 0x2190 && r <= 0x2BFF:
+		return unicode.IsSymbol(r)
+	case r == 0xFE0F || r == 0x200D:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	assumeYes      bool
+	nonInteractive bool
+
+	// stdin is shared by all prompts so no answer is lost in a buffer
+	stdin = bufio.NewReader(os.Stdin)
+)
+
+// isTerminal checks if f is a terminal rather than a pipe, a file or
+// /dev/null as under launchd
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	// /dev/null is a character device too
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// stdinIsTerminal checks if someone can type answers on stdin
+func stdinIsTerminal() bool {
+	return isTerminal(os.Stdin)
+}
+
+// interactive reports whether questions are asked at all: not with --yes
+// or --non-interactive, nor without a terminal
+func interactive() bool {
+	return !assumeYes && !nonInteractive && stdinIsTerminal()
+}
+
+// confirm asks a yes/no question. With --yes it is answered yes, and when
+// nobody can answer the default is taken.
+func confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	fmt.Fprintf(stdout, "%s [%s]: ", question, hint)
+
+	if assumeYes {
+		fmt.Fprintln(stdout, "y")
+		return true
+	}
+	if !interactive() {
+		answer := "n"
+		if def {
+			answer = "y"
+		}
+		fmt.Fprintln(stdout, answer)
+		if !def {
+			fmt.Fprintln(stderr, "💡 Not running interactively, pass --yes to confirm")
+		}
+		return def
+	}
+
+	answer, _ := stdin.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"net"
+	"sort"
+	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
+	"vpn-route-manager/internal/config"
 	"vpn-route-manager/internal/network"
+	"vpn-route-manager/internal/service"
+	"vpn-route-manager/internal/system"
 )
 
 // Route command group
@@ -30,13 +36,17 @@ var routeListCmd = &cobra.Command{
 		netMgr := network.NewManager(log)
 		routes := netMgr.GetActiveRoutes()
 
+		if structuredOutput() {
+			return printStructured(routes)
+		}
+
 		if len(routes) == 0 {
-			fmt.Println("No active routes")
+			fmt.Fprintln(stdout, "No active routes")
 			return nil
 		}
 
 		// Print table
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
 		fmt.Fprintln(w, "NETWORK\tGATEWAY\tSERVICE\tAGE")
 		fmt.Fprintln(w, "-------\t-------\t-------\t---")
 
@@ -47,18 +57,49 @@ var routeListCmd = &cobra.Command{
 		}
 		w.Flush()
 
-		fmt.Printf("\nTotal: %d routes\n", len(routes))
+		fmt.Fprintf(stdout, "\nTotal: %d routes\n", len(routes))
 		return nil
 	},
 }
 
 var routeAddCmd = &cobra.Command{
-	Use:   "add <network>",
+	Use:   "add <network> | --service <name>",
 	Short: "Manually add a route",
-	Args:  cobra.ExactArgs(1),
+	Long: `Manually add a bypass route for a network, or with --service for all
+networks of a service, including those it inherits. The service doesn't
+need to be enabled, so a bypass set can be tried out once without
+changing the configuration.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if name, _ := cmd.Flags().GetString("service"); name != "" {
+			if len(args) > 0 {
+				return fmt.Errorf("pass either a network or --service, not both")
+			}
+			return nil
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		networkCIDR := args[0]
 		gateway, _ := cmd.Flags().GetString("gateway")
+		serviceName, _ := cmd.Flags().GetString("service")
+
+		// Look the service up before touching any routes
+		var networks []string
+		iface := ""
+		if serviceName != "" {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			svc, exists := cfg.Get().Services[serviceName]
+			if !exists {
+				return fmt.Errorf("service '%s' not found", serviceName)
+			}
+			networks = cfg.ServiceNetworks(serviceName)
+			if len(networks) == 0 {
+				return fmt.Errorf("service '%s' has no networks", serviceName)
+			}
+			iface = svc.Interface
+		}
 
 		log, err := createLogger()
 		if err != nil {
@@ -66,23 +107,41 @@ var routeAddCmd = &cobra.Command{
 		}
 		defer log.Close()
 
-		netMgr := network.NewManager(log)
+		netMgr, err := createNetworkManager(log)
+		if err != nil {
+			return err
+		}
 
 		// Detect gateway if not specified
-		if gateway == "" {
+		if gateway == "" && iface == "" {
 			gateway, err = netMgr.DetectGateway()
 			if err != nil {
 				return fmt.Errorf("failed to detect gateway: %w", err)
 			}
-			fmt.Printf("Using detected gateway: %s\n", gateway)
+			fmt.Fprintf(stdout, "Using detected gateway: %s\n", gateway)
 		}
 
+		if serviceName != "" {
+			if err := netMgr.AddServiceRoutes(serviceName, networks, gateway, iface); err != nil {
+				return fmt.Errorf("failed to add routes: %w", err)
+			}
+			via := gateway
+			if iface != "" {
+				via = iface
+			}
+			fmt.Fprintf(stdout, "✅ Added %s for %s via %s\n", plural(len(networks), "route"), serviceName, via)
+			fmt.Fprintln(stdout, "💡 The service doesn't track these routes, they stay until removed or the next reboot")
+			return nil
+		}
+
+		networkCIDR := args[0]
+
 		// Add route
 		if err := netMgr.AddRoute(networkCIDR, gateway, "manual"); err != nil {
 			return fmt.Errorf("failed to add route: %w", err)
 		}
 
-		fmt.Printf("✅ Route added: %s -> %s\n", networkCIDR, gateway)
+		fmt.Fprintf(stdout, "✅ Route added: %s -> %s\n", networkCIDR, gateway)
 		return nil
 	},
 }
@@ -107,7 +166,7 @@ var routeRemoveCmd = &cobra.Command{
 			return fmt.Errorf("failed to remove route: %w", err)
 		}
 
-		fmt.Printf("✅ Route removed: %s\n", networkCIDR)
+		fmt.Fprintf(stdout, "✅ Route removed: %s\n", networkCIDR)
 		return nil
 	},
 }
@@ -115,43 +174,447 @@ var routeRemoveCmd = &cobra.Command{
 var routeClearCmd = &cobra.Command{
 	Use:   "clear",
 	Short: "Remove all routes",
+	Long: `Remove all routes the running service added from the routing table,
+reporting progress service by service. The service adds them back when it
+next verifies its routes, pause it to keep them off.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		log, err := createLogger()
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		status, err := service.ReadStatusFile(cfg.Get().StateDir)
 		if err != nil {
 			return err
 		}
-		defer log.Close()
 
-		netMgr := network.NewManager(log)
-		routes := netMgr.GetActiveRoutes()
+		if status == nil || len(status.ActiveRoutes) == 0 {
+			fmt.Fprintln(stdout, "No routes to remove")
+			return nil
+		}
+		routes := status.ActiveRoutes
 
-		if len(routes) == 0 {
-			fmt.Println("No routes to remove")
+		if !confirm(fmt.Sprintf("Remove %d routes?", len(routes)), false) {
+			fmt.Fprintln(stdout, "Cancelled")
 			return nil
 		}
 
-		fmt.Printf("Remove %d routes? [y/N]: ", len(routes))
-		var response string
-		fmt.Scanln(&response)
-		
-		if response != "y" && response != "Y" {
-			fmt.Println("Cancelled")
+		// A system daemon removes its routes itself, reporting progress in
+		// its log
+		if service.ControlAvailable() {
+			response, err := service.Control(service.ControlRequest{Command: service.ControlClear})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "✅ Removed %s\n", plural(response.Removed, "route"))
+			fmt.Fprintln(stdout, "💡 The service adds them back when it next checks the VPN, run 'vpn-route-manager pause' to keep them off")
 			return nil
 		}
 
-		// Remove all routes
-		if err := netMgr.RemoveAllRoutes(); err != nil {
-			return fmt.Errorf("failed to remove routes: %w", err)
+		log, err := createLogger()
+		if err != nil {
+			return err
+		}
+		defer log.Close()
+		netMgr := network.NewManager(log)
+
+		// Service by service, to report progress along the way
+		byService := make(map[string][]network.Route)
+		for _, route := range routes {
+			byService[route.Service] = append(byService[route.Service], route)
 		}
+		names := make([]string, 0, len(byService))
+		for name := range byService {
+			names = append(names, name)
+		}
+		sort.Strings(names)
 
-		fmt.Printf("✅ Removed %d routes\n", len(routes))
+		progress := network.NewProgress(log, "Removed", len(routes))
+		var errs []error
+		for _, name := range names {
+			progress.Start()
+			removed, err := netMgr.ClearRoutes(byService[name])
+			if err != nil {
+				errs = append(errs, err)
+			}
+			progress.Done(name, removed)
+		}
+		progress.Finish()
+		if len(errs) > 0 {
+			return fmt.Errorf("failed to remove routes: %w", errors.Join(errs...))
+		}
+
+		fmt.Fprintln(stdout, "✅ Routes removed")
+		fmt.Fprintln(stdout, "💡 The service adds them back when it next verifies its routes, run 'vpn-route-manager pause' to keep them off")
 		return nil
 	},
 }
 
+// checkServicePaths checks that traffic to one address of every enabled
+// service goes through gateway rather than the VPN tunnel, printing
+// PASS or FAIL per service
+func checkServicePaths(gateway string, trace bool) {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(stdout, "❌ Failed to load configuration: %v\n", err)
+		return
+	}
+
+	enabled := cfg.GetEnabledServices()
+	if len(enabled) == 0 {
+		fmt.Fprintln(stdout, "No enabled services")
+		return
+	}
+
+	passed := 0
+	for _, name := range config.ServiceNames(enabled) {
+		address := serviceTestAddress(cfg, name)
+		if address == "" {
+			fmt.Fprintf(stdout, "⚠️  SKIP %s: no IPv4 network or resolvable domain\n", name)
+			continue
+		}
+
+		path := network.CheckTrafficPath(address, gateway, trace)
+		via := path.Gateway
+		if via == "" {
+			via = "direct"
+		}
+		detail := fmt.Sprintf("%s via %s (%s)", address, via, valueOrUnknown(path.Interface))
+		if trace {
+			detail += fmt.Sprintf(", first hop %s", valueOrUnknown(path.FirstHop))
+		}
+
+		if path.Bypassed {
+			fmt.Fprintf(stdout, "✅ PASS %s: %s\n", name, detail)
+			passed++
+		} else {
+			fmt.Fprintf(stdout, "❌ FAIL %s: %s\n", name, detail)
+		}
+	}
+
+	fmt.Fprintf(stdout, "\nBypass: %d/%d services go through %s\n", passed, len(enabled), gateway)
+}
+
+// serviceTestAddress picks an address to test a service's path with: the
+// first host of its first IPv4 network, or the first address of its
+// first domain that resolves
+func serviceTestAddress(cfg *config.Manager, name string) string {
+	for _, cidr := range cfg.ServiceNetworks(name) {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		ip := ipNet.IP.To4()
+		if ip == nil {
+			continue
+		}
+		// Prefer a host address over the network address
+		host := make(net.IP, len(ip))
+		copy(host, ip)
+		if ones, _ := ipNet.Mask.Size(); ones < 31 {
+			host[3]++
+		}
+		return host.String()
+	}
+
+	for _, domain := range cfg.Get().Services[name].Domains {
+		addrs, err := net.LookupIP(strings.TrimPrefix(domain, "*."))
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ip := addr.To4(); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	return ""
+}
+
+// checkServiceDNS checks the DNS path of every domain of the enabled services
+func checkServiceDNS() []network.DNSPath {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil
+	}
+
+	var domains []string
+	seen := make(map[string]bool)
+	for _, service := range cfg.GetEnabledServices() {
+		for _, domain := range service.Domains {
+			domain = strings.TrimPrefix(domain, "*.")
+			if !seen[domain] {
+				seen[domain] = true
+				domains = append(domains, domain)
+			}
+		}
+	}
+	sort.Strings(domains)
+
+	return network.CheckDNSPaths(domains)
+}
+
+var routeVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Compare the service's routes to the routing table",
+	Long: `Compare the routes the running service added to the kernel's routing
+table, reporting routes that are missing and stray routes for the same
+networks through another gateway. With --fix missing routes are added
+back and stray ones removed.
+
+The service does the same by itself every minute while the VPN is
+connected.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fix, _ := cmd.Flags().GetBool("fix")
+
+		results, err := verifyServiceRoutes(fix)
+		if err != nil {
+			return err
+		}
+		unresolved := 0
+		for _, result := range results {
+			if !result.OK() && !result.Fixed {
+				unresolved++
+			}
+		}
+
+		if structuredOutput() {
+			if err := printStructured(results); err != nil {
+				return err
+			}
+		} else {
+			printRouteVerification(results, fix)
+		}
+
+		if unresolved > 0 {
+			err := fmt.Errorf("%s don't match the routing table", plural(unresolved, "route"))
+			return &system.CommandError{Err: err, Kind: system.ErrRouteFailed}
+		}
+		return nil
+	},
+}
+
+// verifyServiceRoutes verifies the running service's routes. A system
+// daemon does it itself, as only it may repair them.
+func verifyServiceRoutes(fix bool) ([]service.VerifiedRoute, error) {
+	if service.ControlAvailable() {
+		response, err := service.Control(service.ControlRequest{Command: service.ControlVerify, Fix: fix})
+		if err != nil {
+			return nil, err
+		}
+		return response.Routes, nil
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	status, err := service.ReadStatusFile(cfg.Get().StateDir)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		return nil, fmt.Errorf("%w, so it has no routes to verify", system.ErrDaemonNotRunning)
+	}
+
+	log, err := createLogger()
+	if err != nil {
+		return nil, err
+	}
+	defer log.Close()
+	return service.VerifyRoutes(network.NewManager(log), status.ActiveRoutes, fix)
+}
+
+// printRouteVerification prints the verified routes as a table with a
+// summary
+func printRouteVerification(results []service.VerifiedRoute, fix bool) {
+	if len(results) == 0 {
+		fmt.Fprintln(stdout, "No active routes")
+		return
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NETWORK\tGATEWAY\tSERVICE\tRESULT")
+	fmt.Fprintln(w, "-------\t-------\t-------\t------")
+
+	broken := 0
+	for _, result := range results {
+		problems := result.Problems()
+
+		outcome := "✅ ok"
+		switch {
+		case len(problems) == 0:
+		case result.Fixed:
+			outcome = fmt.Sprintf("🔧 %s, fixed", strings.Join(problems, ", "))
+		case result.Error != "":
+			outcome = fmt.Sprintf("❌ %s, fix failed: %s", strings.Join(problems, ", "), result.Error)
+		default:
+			outcome = "❌ " + strings.Join(problems, ", ")
+		}
+		if len(problems) > 0 {
+			broken++
+		}
+
+		gateway := result.Route.Gateway
+		if gateway == "" {
+			gateway = result.Route.Interface
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Route.Network, gateway, result.Route.Service, outcome)
+	}
+	w.Flush()
+
+	fmt.Fprintf(stdout, "\n%d/%d routes match the routing table\n", len(results)-broken, len(results))
+	if broken > 0 && !fix {
+		fmt.Fprintln(stdout, "💡 Run 'vpn-route-manager route verify --fix' to repair them")
+	}
+}
+
+// routeDetails is everything known about one of the service's routes
+type routeDetails struct {
+	network.Route
+	InTable      bool                 `json:"in_table"`
+	Stray        []network.TableRoute `json:"stray,omitempty"`
+	NextHop      string               `json:"next_hop"`
+	Reachable    bool                 `json:"reachable"`
+	LatencyMS    float64              `json:"latency_ms,omitempty"`
+	VerifiedAt   *time.Time           `json:"verified_at,omitempty"`
+	Verification string               `json:"verification,omitempty"`
+}
+
+var routeShowCmd = &cobra.Command{
+	Use:   "show <network>",
+	Short: "Show everything known about a route",
+	Long: `Show one of the running service's routes: the service it belongs to,
+its gateway and when it was added, whether it is in the routing table,
+whether its next hop answers, and the result of the service's last
+verification of it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		status, err := service.ReadStatusFile(cfg.Get().StateDir)
+		if err != nil {
+			return err
+		}
+		if status == nil {
+			return fmt.Errorf("%w, so it has no routes", system.ErrDaemonNotRunning)
+		}
+
+		route, ok := findRoute(status.ActiveRoutes, args[0])
+		if !ok {
+			return fmt.Errorf("no active route for %s", args[0])
+		}
+		details := routeDetails{Route: route, NextHop: route.Gateway}
+
+		if table, err := network.ReadRoutingTable(); err == nil {
+			check := network.CheckRoutes([]network.Route{route}, table)[0]
+			details.InTable, details.Stray = check.Present, check.Stray
+		}
+
+		if details.NextHop == "" {
+			// Interface routes go straight onto the link
+			details.NextHop = route.Interface
+			details.Reachable = interfaceUp(route.Interface)
+		} else if latency, err := network.PingGateway(route.Gateway, 2*time.Second); err == nil {
+			details.Reachable = true
+			details.LatencyMS = float64(latency.Microseconds()) / 1000
+		}
+
+		// Routes added after the last verification haven't been verified
+		if v := status.Verification; v != nil && !v.CheckedAt.Before(route.AddedAt) {
+			details.VerifiedAt = &v.CheckedAt
+			details.Verification = "ok"
+			if problem, failed := v.Failed[route.Network]; failed {
+				details.Verification = problem
+			}
+		}
+
+		if structuredOutput() {
+			return printStructured(details)
+		}
+
+		fmt.Fprintf(stdout, "🛣️  Route %s\n", route.Network)
+		fmt.Fprintf(stdout, "Service: %s\n", route.Service)
+		fmt.Fprintf(stdout, "Gateway: %s\n", valueOrUnknown(route.Gateway))
+		if route.Interface != "" {
+			fmt.Fprintf(stdout, "Interface: %s\n", route.Interface)
+		}
+		fmt.Fprintf(stdout, "Added: %s (%v ago)\n", route.AddedAt.Local().Format("2006-01-02 15:04:05"),
+			time.Since(route.AddedAt).Round(time.Second))
+
+		if details.InTable {
+			fmt.Fprintln(stdout, "Routing table: ✅ present")
+		} else {
+			fmt.Fprintln(stdout, "Routing table: ❌ missing")
+		}
+		for _, stray := range details.Stray {
+			fmt.Fprintf(stdout, "  ⚠️  stray route via %s (%s)\n", stray.Gateway, stray.Interface)
+		}
+
+		switch {
+		case details.Reachable && details.LatencyMS > 0:
+			fmt.Fprintf(stdout, "Next hop: ✅ %s answers (%.1f ms)\n", details.NextHop, details.LatencyMS)
+		case details.Reachable:
+			fmt.Fprintf(stdout, "Next hop: ✅ %s is up\n", details.NextHop)
+		default:
+			fmt.Fprintf(stdout, "Next hop: ❌ %s is not reachable\n", valueOrUnknown(details.NextHop))
+		}
+
+		if details.VerifiedAt == nil {
+			fmt.Fprintln(stdout, "Last verification: not verified yet")
+		} else {
+			mark := "✅"
+			if details.Verification != "ok" {
+				mark = "❌"
+			}
+			fmt.Fprintf(stdout, "Last verification: %s %s (%v ago)\n", mark, details.Verification,
+				time.Since(*details.VerifiedAt).Round(time.Second))
+		}
+
+		if !details.InTable || len(details.Stray) > 0 {
+			fmt.Fprintln(stdout, "\n💡 Run 'vpn-route-manager route verify --fix' to repair it")
+		}
+		return nil
+	},
+}
+
+// findRoute finds the route for a network among routes. The network may
+// be written in any form, such as a bare address for a host route.
+func findRoute(routes []network.Route, value string) (network.Route, bool) {
+	if !strings.Contains(value, "/") {
+		value += "/32"
+	}
+	_, wanted, err := net.ParseCIDR(value)
+	if err != nil {
+		return network.Route{}, false
+	}
+
+	for _, route := range routes {
+		cidr := route.Network
+		if !strings.Contains(cidr, "/") {
+			cidr += "/32"
+		}
+		if _, ipnet, err := net.ParseCIDR(cidr); err == nil && ipnet.String() == wanted.String() {
+			return route, true
+		}
+	}
+	return network.Route{}, false
+}
+
+// interfaceUp checks if a network interface exists and is up
+func interfaceUp(name string) bool {
+	iface, err := net.InterfaceByName(name)
+	return err == nil && iface.Flags&net.FlagUp != 0
+}
+
 var routeTestCmd = &cobra.Command{
 	Use:   "test",
 	Short: "Test route functionality",
+	Long: `Test gateway and VPN detection, then check that traffic to one address
+of every enabled service goes through the local gateway rather than the
+VPN tunnel, reporting PASS or FAIL per service. With --traceroute the
+first hop packets reach must be the local gateway too.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		log, err := createLogger()
 		if err != nil {
@@ -159,42 +622,98 @@ var routeTestCmd = &cobra.Command{
 		}
 		defer log.Close()
 
-		netMgr := network.NewManager(log)
+		netMgr, err := createNetworkManager(log)
+		if err != nil {
+			return err
+		}
 
 		// Test gateway detection
-		fmt.Println("🔍 Testing gateway detection...")
+		fmt.Fprintln(stdout, "🔍 Testing gateway detection...")
 		gateway, err := netMgr.DetectGateway()
 		if err != nil {
-			fmt.Printf("❌ Gateway detection failed: %v\n", err)
+			fmt.Fprintf(stdout, "❌ Gateway detection failed: %v\n", err)
 		} else {
-			fmt.Printf("✅ Detected gateway: %s\n", gateway)
+			fmt.Fprintf(stdout, "✅ Detected gateway: %s\n", gateway)
 		}
 
 		// Test VPN detection
-		fmt.Println("\n🔍 Testing VPN detection...")
-		if netMgr.IsVPNConnected() {
-			fmt.Println("✅ VPN is connected")
+		fmt.Fprintln(stdout, "\n🔍 Testing VPN detection...")
+		detection := netMgr.DetectVPN()
+		if detection.Connected {
+			fmt.Fprintln(stdout, "✅ VPN is connected")
 		} else {
-			fmt.Println("❌ VPN is not connected")
+			fmt.Fprintln(stdout, "❌ VPN is not connected")
+		}
+		fmt.Fprintf(stdout, "   Confidence: %.2f (threshold %.2f)\n", detection.Score, detection.Threshold)
+		if detection.VetoedBy != "" {
+			fmt.Fprintf(stdout, "   Ruled out by %s\n", detection.VetoedBy)
+		}
+		signalNames := make([]string, 0, len(detection.Signals))
+		for name := range detection.Signals {
+			signalNames = append(signalNames, name)
+		}
+		sort.Strings(signalNames)
+		for _, name := range signalNames {
+			mark := "❌"
+			if detection.Signals[name] {
+				mark = "✅"
+			}
+			fmt.Fprintf(stdout, "   %s %s\n", mark, name)
+		}
+
+		// Test the traffic path of every enabled service
+		fmt.Fprintln(stdout, "\n🔍 Testing bypass paths of enabled services...")
+		if gateway == "" {
+			fmt.Fprintln(stdout, "⚠️  Skipped, no gateway to compare with")
+		} else {
+			trace, _ := cmd.Flags().GetBool("traceroute")
+			if !detection.Connected {
+				fmt.Fprintln(stdout, "💡 The VPN is not connected, so all traffic uses the local network")
+			}
+			checkServicePaths(gateway, trace)
+		}
+
+		// Test DNS paths for bypassed domains
+		if checkDNS, _ := cmd.Flags().GetBool("dns"); checkDNS {
+			fmt.Fprintln(stdout, "\n🔍 Testing DNS paths for bypassed domains...")
+			paths := checkServiceDNS()
+			leaks := 0
+			for _, path := range paths {
+				switch {
+				case path.Nameserver == "":
+					fmt.Fprintf(stdout, "⚠️  %s: no resolver found\n", path.Domain)
+				case path.Leak:
+					fmt.Fprintf(stdout, "❌ %s: resolved by %s via %s (VPN)\n", path.Domain, path.Nameserver, path.Interface)
+					leaks++
+				default:
+					fmt.Fprintf(stdout, "✅ %s: resolved by %s via %s\n", path.Domain, path.Nameserver, valueOrUnknown(path.Interface))
+				}
+			}
+			if len(paths) == 0 {
+				fmt.Fprintln(stdout, "No domains configured for enabled services")
+			} else if leaks > 0 {
+				fmt.Fprintf(stdout, "\n%d/%d domains are resolved through the VPN\n", leaks, len(paths))
+				fmt.Fprintln(stdout, "💡 Enable split_dns in the configuration to resolve them locally")
+			}
 		}
 
 		// Test route verification
 		routes := netMgr.GetActiveRoutes()
 		if len(routes) > 0 {
-			fmt.Printf("\n🔍 Verifying %d active routes...\n", len(routes))
+			fmt.Fprintf(stdout, "\n🔍 Verifying %d active routes...\n", len(routes))
 			results := netMgr.VerifyRoutes()
 			
 			working := 0
 			for network, ok := range results {
 				if ok {
-					fmt.Printf("✅ %s: Working\n", network)
+					fmt.Fprintf(stdout, "✅ %s: Working\n", network)
 					working++
 				} else {
-					fmt.Printf("❌ %s: Not working\n", network)
+					fmt.Fprintf(stdout, "❌ %s: Not working\n", network)
 				}
 			}
 			
-			fmt.Printf("\nVerification: %d/%d routes working\n", working, len(results))
+			fmt.Fprintf(stdout, "\nVerification: %d/%d routes working\n", working, len(results))
 		}
 
 		return nil
@@ -208,9 +727,16 @@ func init() {
 		routeAddCmd,
 		routeRemoveCmd,
 		routeClearCmd,
+		routeVerifyCmd,
+		routeShowCmd,
 		routeTestCmd,
 	)
 
 	// Add flags
 	routeAddCmd.Flags().String("gateway", "", "Gateway IP (auto-detect if not specified)")
+	routeAddCmd.Flags().String("service", "", "Add the routes for all networks of this service")
+	routeAddCmd.RegisterFlagCompletionFunc("service", completeServiceNames)
+	routeVerifyCmd.Flags().Bool("fix", false, "Add missing routes back and remove stray ones")
+	routeTestCmd.Flags().Bool("dns", false, "Check that DNS for bypassed domains avoids the VPN")
+	routeTestCmd.Flags().Bool("traceroute", false, "Also check the first hop of each service path with traceroute")
 }
\ No newline at end of file
@@ -2,13 +2,21 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/network"
+	"vpn-route-manager/internal/service"
 	"vpn-route-manager/internal/system"
 )
 
@@ -29,10 +37,6 @@ var serviceListCmd = &cobra.Command{
 		}
 
 		services := cfg.Get().Services
-		if len(services) == 0 {
-			fmt.Println("No services configured")
-			return nil
-		}
 
 		// Sort services by name
 		var names []string
@@ -41,22 +45,74 @@ var serviceListCmd = &cobra.Command{
 		}
 		sort.Strings(names)
 
+		if structuredOutput() {
+			list := make([]*config.Service, 0, len(names))
+			for _, name := range names {
+				list = append(list, services[name])
+			}
+			return printStructured(list)
+		}
+
+		if len(services) == 0 {
+			fmt.Fprintln(stdout, "No services configured")
+			return nil
+		}
+
 		// Print table
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "NAME\tSTATUS\tNETWORKS\tDESCRIPTION")
-		fmt.Fprintln(w, "----\t------\t--------\t-----------")
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSTATUS\tNETWORKS\tTAGS\tDESCRIPTION")
+		fmt.Fprintln(w, "----\t------\t--------\t----\t-----------")
+
+		// Count enabled services per tag for the group summary
+		tagTotal := make(map[string]int)
+		tagEnabled := make(map[string]int)
 
 		for _, name := range names {
 			svc := services[name]
 			status := "DISABLED"
 			if svc.Enabled {
 				status = "ENABLED"
+				if svc.EnabledUntil != nil {
+					left := time.Until(*svc.EnabledUntil).Round(time.Minute)
+					if left < 0 {
+						left = 0
+					}
+					status = fmt.Sprintf("ENABLED (%s left)", left)
+				}
 			}
-			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", 
-				name, status, len(svc.Networks), svc.Description)
+			for _, tag := range svc.Tags {
+				tagTotal[tag]++
+				if svc.Enabled {
+					tagEnabled[tag]++
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", 
+				name, status, len(svc.Networks), strings.Join(svc.Tags, ","), svc.Description)
 		}
 		w.Flush()
 
+		if len(tagTotal) > 0 {
+			var tags []string
+			for tag := range tagTotal {
+				tags = append(tags, tag)
+			}
+			sort.Strings(tags)
+
+			fmt.Fprintln(stdout, "\nGroups:")
+			w = tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+			for _, tag := range tags {
+				status := "PARTIAL"
+				switch tagEnabled[tag] {
+				case 0:
+					status = "DISABLED"
+				case tagTotal[tag]:
+					status = "ENABLED"
+				}
+				fmt.Fprintf(w, "  %s\t%s\t%d/%d enabled\n", tag, status, tagEnabled[tag], tagTotal[tag])
+			}
+			w.Flush()
+		}
+
 		return nil
 	},
 }
@@ -77,54 +133,208 @@ var serviceShowCmd = &cobra.Command{
 			return fmt.Errorf("service '%s' not found", name)
 		}
 
-		fmt.Printf("Service: %s\n", svc.Name)
-		fmt.Printf("Description: %s\n", svc.Description)
-		fmt.Printf("Enabled: %v\n", svc.Enabled)
-		fmt.Printf("Priority: %d\n", svc.Priority)
+		if structuredOutput() {
+			details := serviceDetails{Service: svc}
+			if svc.Extends != "" {
+				details.InheritedNetworks = len(cfg.ServiceNetworks(name)) - len(svc.Networks)
+			}
+			if state, err := service.ReadStateFile(cfg.Get().StateDir); err == nil {
+				runtime := serviceRuntimeOf(cfg, name, state)
+				details.Runtime = &runtime
+			}
+			return printStructured(details)
+		}
+
+		fmt.Fprintf(stdout, "Service: %s\n", svc.Name)
+		fmt.Fprintf(stdout, "Description: %s\n", svc.Description)
+		fmt.Fprintf(stdout, "Enabled: %v\n", svc.Enabled)
+		if svc.Enabled && svc.EnabledUntil != nil {
+			fmt.Fprintf(stdout, "Enabled until: %s\n", svc.EnabledUntil.Format("2006-01-02 15:04"))
+		}
+		fmt.Fprintf(stdout, "Priority: %d\n", svc.Priority)
+		if len(svc.Tags) > 0 {
+			fmt.Fprintf(stdout, "Tags: %s\n", strings.Join(svc.Tags, ", "))
+		}
+		if svc.Interface != "" {
+			fmt.Fprintf(stdout, "Interface: %s\n", svc.Interface)
+		}
+		if svc.Probe != "" {
+			fmt.Fprintf(stdout, "Probe: %s\n", svc.Probe)
+		}
 		
-		fmt.Printf("\nNetworks (%d):\n", len(svc.Networks))
+		fmt.Fprintf(stdout, "\nNetworks (%d):\n", len(svc.Networks))
 		for _, network := range svc.Networks {
-			fmt.Printf("  %s\n", network)
+			fmt.Fprintf(stdout, "  %s\n", network)
+		}
+
+		if svc.Extends != "" {
+			inherited := len(cfg.ServiceNetworks(name)) - len(svc.Networks)
+			fmt.Fprintf(stdout, "\nExtends: %s (%d inherited networks)\n", svc.Extends, inherited)
 		}
 
 		if len(svc.Domains) > 0 {
-			fmt.Printf("\nDomains (%d):\n", len(svc.Domains))
+			fmt.Fprintf(stdout, "\nDomains (%d):\n", len(svc.Domains))
 			for _, domain := range svc.Domains {
-				fmt.Printf("  %s\n", domain)
+				fmt.Fprintf(stdout, "  %s\n", domain)
 			}
 		}
 
+		if len(svc.Schedule) > 0 {
+			fmt.Fprintf(stdout, "\nSchedule (%d):\n", len(svc.Schedule))
+			for _, rule := range svc.Schedule {
+				days := "daily"
+				if len(rule.Days) > 0 {
+					days = strings.Join(rule.Days, ",")
+				}
+				fmt.Fprintf(stdout, "  %s %s-%s\n", days, rule.Start, rule.End)
+			}
+		}
+
+		if len(svc.ASNs) > 0 {
+			fmt.Fprintf(stdout, "\nASNs (%d):\n", len(svc.ASNs))
+			for _, asn := range svc.ASNs {
+				fmt.Fprintf(stdout, "  %s\n", asn)
+			}
+		}
+
+		if state, err := service.ReadStateFile(cfg.Get().StateDir); err == nil {
+			printServiceRuntime(cfg, name, state)
+		}
+
 		return nil
 	},
 }
 
+// serviceDetails is the machine-readable form of service show: the
+// service config with what the daemon last recorded about it
+type serviceDetails struct {
+	*config.Service
+	InheritedNetworks int             `json:"inherited_networks,omitempty"`
+	Runtime           *serviceRuntime `json:"runtime,omitempty"`
+}
+
+// serviceRuntime is what the daemon last recorded about a service's
+// routes. Installed is false while the VPN is down or the service has no
+// routes yet.
+type serviceRuntime struct {
+	Installed          bool                   `json:"installed"`
+	Routes             int                    `json:"routes"`
+	ConfiguredNetworks int                    `json:"configured_networks"`
+	DomainRoutes       int                    `json:"domain_routes"`
+	ResolvedAddresses  int                    `json:"resolved_addresses"`
+	AppliedAt          *time.Time             `json:"applied_at,omitempty"`
+	Health             *service.ServiceHealth `json:"health,omitempty"`
+}
+
+// serviceRuntimeOf collects the runtime details of a service from the
+// saved state
+func serviceRuntimeOf(cfg *config.Manager, name string, state *service.State) serviceRuntime {
+	runtime := serviceRuntime{
+		Installed:          state.ActiveServices[name] && state.VPNConnected,
+		ConfiguredNetworks: len(cfg.ServiceNetworks(name)),
+	}
+	if !runtime.Installed {
+		return runtime
+	}
+
+	stats := state.ServiceStats[name]
+	runtime.Routes = stats.Routes
+	runtime.DomainRoutes = stats.DomainRoutes
+	if !stats.AppliedAt.IsZero() {
+		runtime.AppliedAt = &stats.AppliedAt
+	}
+
+	for _, resolved := range state.ResolvedDomains {
+		for _, address := range resolved {
+			if address.Service == name && address.Expires.After(time.Now()) {
+				runtime.ResolvedAddresses++
+			}
+		}
+	}
+
+	if health, probed := state.ServiceHealth[name]; probed {
+		runtime.Health = &health
+	}
+	return runtime
+}
+
+// printServiceRuntime prints what the daemon last recorded about a
+// service's routes
+func printServiceRuntime(cfg *config.Manager, name string, state *service.State) {
+	runtime := serviceRuntimeOf(cfg, name, state)
+
+	fmt.Fprintln(stdout, "\nRuntime:")
+	if !runtime.Installed {
+		fmt.Fprintln(stdout, "  Routes: not installed")
+		return
+	}
+
+	fmt.Fprintf(stdout, "  Routes: %d installed (%d networks configured)\n", runtime.Routes, runtime.ConfiguredNetworks)
+	if runtime.AppliedAt != nil {
+		fmt.Fprintf(stdout, "  Last applied: %s\n", runtime.AppliedAt.Format("2006-01-02 15:04:05"))
+	}
+
+	if len(cfg.Get().Services[name].Domains) > 0 {
+		fmt.Fprintf(stdout, "  Domain routes: %d (%d resolved addresses)\n", runtime.DomainRoutes, runtime.ResolvedAddresses)
+	}
+
+	if health := runtime.Health; health != nil {
+		checked := health.CheckedAt.Format("15:04:05")
+		if health.Healthy {
+			fmt.Fprintf(stdout, "  Health: ✅ healthy, %v (checked %s)\n", health.Latency.Round(time.Millisecond), checked)
+		} else {
+			fmt.Fprintf(stdout, "  Health: ⚠️  unhealthy: %s (checked %s)\n", health.Error, checked)
+		}
+	}
+}
+
 var serviceEnableCmd = &cobra.Command{
-	Use:   "enable <name>",
-	Short: "Enable a service",
-	Args:  cobra.ExactArgs(1),
+	Use:   "enable [name|pattern]...",
+	Short: "Enable services by name, glob pattern or tag",
+	Args:  cobra.ArbitraryArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := loadConfig()
 		if err != nil {
 			return err
 		}
 
-		name := args[0]
-		if err := cfg.EnableService(name); err != nil {
+		names, err := serviceTargets(cmd, cfg, args)
+		if err != nil {
 			return err
 		}
 
+		duration, _ := cmd.Flags().GetDuration("for")
+		if duration < 0 {
+			return fmt.Errorf("--for must be a positive duration")
+		}
+
+		var until *time.Time
+		if duration > 0 {
+			t := time.Now().Add(duration)
+			until = &t
+		}
+
+		if err := cfg.SetServicesEnabled(names, true, until); err != nil {
+			return err
+		}
 		if err := cfg.Save(); err != nil {
 			return err
 		}
 
-		fmt.Printf("✅ Service '%s' enabled\n", name)
-		fmt.Println("💡 Routes will be added when VPN connects")
+		for _, name := range names {
+			if until := cfg.Get().Services[name].EnabledUntil; until != nil {
+				fmt.Fprintf(stdout, "✅ Service '%s' enabled until %s\n", name, until.Format("2006-01-02 15:04"))
+			} else {
+				fmt.Fprintf(stdout, "✅ Service '%s' enabled\n", name)
+			}
+		}
+		fmt.Fprintln(stdout, "💡 Routes will be added when VPN connects")
 		
 		// Check if daemon is running
 		username := os.Getenv("USER")
 		launchAgent := system.NewLaunchAgent(username)
 		if running, _ := launchAgent.IsRunning(); running {
-			fmt.Println("⚠️  Restart the service to apply changes: vpn-route-manager restart")
+			fmt.Fprintln(stdout, "⚠️  Restart the service to apply changes: vpn-route-manager restart")
 		}
 		
 		return nil
@@ -132,38 +342,109 @@ var serviceEnableCmd = &cobra.Command{
 }
 
 var serviceDisableCmd = &cobra.Command{
-	Use:   "disable <name>",
-	Short: "Disable a service",
-	Args:  cobra.ExactArgs(1),
+	Use:   "disable [name|pattern]...",
+	Short: "Disable services by name, glob pattern or tag",
+	Args:  cobra.ArbitraryArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := loadConfig()
 		if err != nil {
 			return err
 		}
 
-		name := args[0]
-		if err := cfg.DisableService(name); err != nil {
+		names, err := serviceTargets(cmd, cfg, args)
+		if err != nil {
 			return err
 		}
 
+		if err := cfg.SetServicesEnabled(names, false, nil); err != nil {
+			return err
+		}
 		if err := cfg.Save(); err != nil {
 			return err
 		}
 
-		fmt.Printf("✅ Service '%s' disabled\n", name)
-		fmt.Println("💡 Routes will be removed if currently active")
+		for _, name := range names {
+			fmt.Fprintf(stdout, "✅ Service '%s' disabled\n", name)
+		}
+		fmt.Fprintln(stdout, "💡 Routes will be removed if currently active")
 		
 		// Check if daemon is running
 		username := os.Getenv("USER")
 		launchAgent := system.NewLaunchAgent(username)
 		if running, _ := launchAgent.IsRunning(); running {
-			fmt.Println("⚠️  Restart the service to apply changes: vpn-route-manager restart")
+			fmt.Fprintln(stdout, "⚠️  Restart the service to apply changes: vpn-route-manager restart")
 		}
 		
 		return nil
 	},
 }
 
+// serviceTargets returns the services a command acts on: the named ones,
+// where names may be glob patterns such as 'youtube*', every service with
+// the tag given by --tag, or every service with --all. Unknown names and
+// patterns matching nothing are errors, so nothing is changed for a typo.
+func serviceTargets(cmd *cobra.Command, cfg *config.Manager, args []string) ([]string, error) {
+	tag, _ := cmd.Flags().GetString("tag")
+	all, _ := cmd.Flags().GetBool("all")
+
+	selectors := 0
+	for _, set := range []bool{tag != "", all, len(args) > 0} {
+		if set {
+			selectors++
+		}
+	}
+	switch {
+	case selectors > 1:
+		return nil, fmt.Errorf("specify either service names, --tag or --all")
+	case all:
+		return config.ServiceNames(cfg.Get().Services), nil
+	case tag != "":
+		names := cfg.ServicesWithTag(tag)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no services tagged '%s'", tag)
+		}
+		return names, nil
+	case len(args) == 0:
+		return nil, fmt.Errorf("specify service names, --tag or --all")
+	}
+
+	services := cfg.Get().Services
+	seen := make(map[string]bool)
+	var names []string
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, "*?[") {
+			if _, exists := services[arg]; !exists {
+				return nil, fmt.Errorf("service '%s' not found", arg)
+			}
+			if !seen[arg] {
+				seen[arg] = true
+				names = append(names, arg)
+			}
+			continue
+		}
+
+		matched := false
+		for _, name := range config.ServiceNames(services) {
+			ok, err := path.Match(arg, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s': %w", arg, err)
+			}
+			if ok {
+				matched = true
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no services match '%s'", arg)
+		}
+	}
+
+	return names, nil
+}
+
 var serviceAddCmd = &cobra.Command{
 	Use:   "add <name>",
 	Short: "Add a new service",
@@ -173,9 +454,12 @@ var serviceAddCmd = &cobra.Command{
 		networks, _ := cmd.Flags().GetString("networks")
 		description, _ := cmd.Flags().GetString("description")
 		priority, _ := cmd.Flags().GetInt("priority")
+		iface, _ := cmd.Flags().GetString("interface")
+		tags, _ := cmd.Flags().GetStringSlice("tags")
+		interactive, _ := cmd.Flags().GetBool("interactive")
 
-		if networks == "" {
-			return fmt.Errorf("--networks is required")
+		if networks == "" && !interactive {
+			return fmt.Errorf("--networks is required (or use --interactive)")
 		}
 
 		cfg, err := loadConfig()
@@ -187,20 +471,45 @@ var serviceAddCmd = &cobra.Command{
 		if _, exists := cfg.Get().Services[name]; exists {
 			return fmt.Errorf("service '%s' already exists", name)
 		}
-
-		// Parse networks
-		networkList := strings.Split(networks, ",")
-		for i, net := range networkList {
-			networkList[i] = strings.TrimSpace(net)
+		if err := config.ValidateServiceKey(name); err != nil {
+			return err
 		}
 
-		// Create service
-		service := &config.Service{
-			Name:        name,
-			Description: description,
-			Enabled:     false,
-			Networks:    networkList,
-			Priority:    priority,
+		var service *config.Service
+		if interactive {
+			if nonInteractive || !stdinIsTerminal() {
+				return fmt.Errorf("--interactive needs a terminal to prompt on")
+			}
+			wizard := newServiceWizard(24)
+			service, err = wizard.run(name, &config.Service{Description: description, Priority: priority, Tags: tags})
+			if err != nil {
+				return err
+			}
+			service.Interface = iface
+			if err := config.ValidateService(name, service); err != nil {
+				return err
+			}
+			if !wizard.preview(name, service) {
+				fmt.Fprintln(stdout, "Cancelled")
+				return nil
+			}
+		} else {
+			// Parse networks
+			networkList := strings.Split(networks, ",")
+			for i, net := range networkList {
+				networkList[i] = strings.TrimSpace(net)
+			}
+
+			// Create service
+			service = &config.Service{
+				Name:        name,
+				Description: description,
+				Enabled:     false,
+				Networks:    networkList,
+				Priority:    priority,
+				Interface:   iface,
+				Tags:        tags,
+			}
 		}
 
 		// Validate service
@@ -216,12 +525,297 @@ var serviceAddCmd = &cobra.Command{
 			return err
 		}
 
-		fmt.Printf("✅ Service '%s' added (disabled by default)\n", name)
-		fmt.Printf("💡 Enable with: vpn-route-manager service enable %s\n", name)
+		fmt.Fprintf(stdout, "✅ Service '%s' added (disabled by default)\n", name)
+		fmt.Fprintf(stdout, "💡 Enable with: vpn-route-manager service enable %s\n", name)
+		return nil
+	},
+}
+
+var serviceEditCmd = &cobra.Command{
+	Use:   "edit <name>",
+	Short: "Modify an existing service",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		name := args[0]
+		svc, exists := cfg.Get().Services[name]
+		if !exists {
+			return fmt.Errorf("service '%s' not found", name)
+		}
+
+		// Edit a copy so a failed validation leaves the service untouched
+		edited := *svc
+		flags := cmd.Flags()
+		var changes []string
+
+		if flags.Changed("description") {
+			edited.Description, _ = flags.GetString("description")
+			changes = append(changes, "description")
+		}
+		if flags.Changed("priority") {
+			edited.Priority, _ = flags.GetInt("priority")
+			changes = append(changes, "priority")
+		}
+		if flags.Changed("interface") {
+			edited.Interface, _ = flags.GetString("interface")
+			changes = append(changes, "interface")
+		}
+		if flags.Changed("tags") {
+			edited.Tags, _ = flags.GetStringSlice("tags")
+			changes = append(changes, "tags")
+		}
+
+		addNetworks, _ := flags.GetStringSlice("add-network")
+		removeNetworks, _ := flags.GetStringSlice("remove-network")
+		if len(addNetworks) > 0 || len(removeNetworks) > 0 {
+			if edited.Networks, err = editList(svc.Networks, addNetworks, removeNetworks, "network"); err != nil {
+				return err
+			}
+			changes = append(changes, "networks")
+		}
+
+		addDomains, _ := flags.GetStringSlice("add-domain")
+		removeDomains, _ := flags.GetStringSlice("remove-domain")
+		if len(addDomains) > 0 || len(removeDomains) > 0 {
+			if edited.Domains, err = editList(svc.Domains, addDomains, removeDomains, "domain"); err != nil {
+				return err
+			}
+			changes = append(changes, "domains")
+		}
+
+		if len(changes) == 0 {
+			return fmt.Errorf("nothing to change, see 'vpn-route-manager service edit --help'")
+		}
+
+		if err := cfg.UpdateService(name, &edited); err != nil {
+			return err
+		}
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "✅ Service '%s' updated (%s)\n", name, strings.Join(changes, ", "))
+
+		// Check if daemon is running
+		username := os.Getenv("USER")
+		launchAgent := system.NewLaunchAgent(username)
+		if running, _ := launchAgent.IsRunning(); running {
+			fmt.Fprintln(stdout, "⚠️  Restart the service to apply changes: vpn-route-manager restart")
+		}
+
+		return nil
+	},
+}
+
+// editList returns a copy of list with the entries of remove taken out and
+// those of add appended, failing on entries that are missing or duplicated
+func editList(list, add, remove []string, kind string) ([]string, error) {
+	removeSet := make(map[string]bool)
+	for _, entry := range remove {
+		removeSet[strings.TrimSpace(entry)] = true
+	}
+
+	var result []string
+	present := make(map[string]bool)
+	for _, entry := range list {
+		if removeSet[entry] {
+			delete(removeSet, entry)
+			continue
+		}
+		result = append(result, entry)
+		present[entry] = true
+	}
+	for entry := range removeSet {
+		return nil, fmt.Errorf("%s '%s' is not part of the service", kind, entry)
+	}
+
+	for _, entry := range add {
+		entry = strings.TrimSpace(entry)
+		if present[entry] {
+			return nil, fmt.Errorf("%s '%s' is already part of the service", kind, entry)
+		}
+		result = append(result, entry)
+		present[entry] = true
+	}
+
+	return result, nil
+}
+
+var serviceRenameCmd = &cobra.Command{
+	Use:   "rename <name> <new-name>",
+	Short: "Rename a service",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		oldName, newName := args[0], args[1]
+
+		// The daemon keeps its own copy of the services, state and route
+		// tags and would write the old name back
+		username := os.Getenv("USER")
+		launchAgent := system.NewLaunchAgent(username)
+		if running, _ := launchAgent.IsRunning(); running {
+			return fmt.Errorf("the service is running, stop it first: vpn-route-manager stop")
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		if err := cfg.RenameService(oldName, newName); err != nil {
+			return err
+		}
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+
+		if err := service.RenameServiceInStateFile(cfg.Get().StateDir, oldName, newName); err != nil {
+			fmt.Fprintf(stdout, "⚠️  Warning: %v\n", err)
+		}
+
+		fmt.Fprintf(stdout, "✅ Service '%s' renamed to '%s'\n", oldName, newName)
+		return nil
+	},
+}
+
+var serviceExportCmd = &cobra.Command{
+	Use:   "export [name]",
+	Short: "Export a service, or all services as a bundle",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		if format != "json" && format != "yaml" {
+			return fmt.Errorf("--format must be json or yaml")
+		}
+		if all == (len(args) == 1) {
+			return fmt.Errorf("specify either a service name or --all")
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		services := cfg.Get().Services
+		if !all {
+			svc, exists := services[args[0]]
+			if !exists {
+				return fmt.Errorf("service '%s' not found", args[0])
+			}
+			services = map[string]*config.Service{args[0]: svc}
+		}
+
+		data, err := config.MarshalServices(services, all, format == "yaml")
+		if err != nil {
+			return fmt.Errorf("failed to encode services: %w", err)
+		}
+
+		if output == "" || output == "-" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err := os.WriteFile(output, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", output, err)
+		}
+		fmt.Fprintf(stdout, "✅ Exported %d services to %s\n", len(services), output)
+		return nil
+	},
+}
+
+var serviceImportCmd = &cobra.Command{
+	Use:   "import <file|url>",
+	Short: "Import services from a JSON or YAML file or URL",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		replace, _ := cmd.Flags().GetBool("replace")
+		source := args[0]
+
+		var data []byte
+		var err error
+		if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+			data, err = fetchURL(source)
+		} else {
+			data, err = os.ReadFile(source)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", source, err)
+		}
+
+		// A single service file is named after its key
+		name := source
+		if parsed, err := url.Parse(source); err == nil && parsed.Scheme != "" {
+			name = parsed.Path
+		}
+		name = strings.TrimSuffix(path.Base(name), path.Ext(name))
+
+		imported, err := config.ParseServices(data, name)
+		if err != nil {
+			return fmt.Errorf("invalid service definitions in %s: %w", source, err)
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		added, replaced, skipped := 0, 0, 0
+		for _, name := range config.ServiceNames(imported) {
+			svc := imported[name]
+			if _, exists := cfg.Get().Services[name]; exists {
+				if !replace {
+					fmt.Fprintf(stdout, "⏭️  %s: already exists (use --replace to overwrite)\n", name)
+					skipped++
+					continue
+				}
+				if err := cfg.UpdateService(name, svc); err != nil {
+					return err
+				}
+				fmt.Fprintf(stdout, "✅ %s: replaced\n", name)
+				replaced++
+				continue
+			}
+
+			if err := cfg.AddService(name, svc); err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "✅ %s: added\n", name)
+			added++
+		}
+
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "\nImported %d services (%d added, %d replaced, %d skipped)\n",
+			added+replaced, added, replaced, skipped)
+		if added+replaced > 0 {
+			fmt.Fprintln(stdout, "💡 Restart the service to apply changes: vpn-route-manager restart")
+		}
 		return nil
 	},
 }
 
+// fetchURL downloads a small document
+func fetchURL(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+}
+
 var serviceRemoveCmd = &cobra.Command{
 	Use:   "remove <name>",
 	Short: "Remove a service",
@@ -239,12 +833,8 @@ var serviceRemoveCmd = &cobra.Command{
 		}
 
 		// Confirm
-		fmt.Printf("Remove service '%s'? [y/N]: ", name)
-		var response string
-		fmt.Scanln(&response)
-		
-		if strings.ToLower(response) != "y" {
-			fmt.Println("Cancelled")
+		if !confirm(fmt.Sprintf("Remove service '%s'?", name), false) {
+			fmt.Fprintln(stdout, "Cancelled")
 			return nil
 		}
 
@@ -254,11 +844,455 @@ var serviceRemoveCmd = &cobra.Command{
 			return err
 		}
 
-		fmt.Printf("✅ Service '%s' removed\n", name)
+		fmt.Fprintf(stdout, "✅ Service '%s' removed\n", name)
+		return nil
+	},
+}
+
+var serviceResolveCmd = &cobra.Command{
+	Use:   "resolve <name>",
+	Short: "Resolve a service's domains and check them against its networks",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		add, _ := cmd.Flags().GetBool("add")
+		prefix, _ := cmd.Flags().GetInt("prefix")
+		if prefix < 8 || prefix > 32 {
+			return fmt.Errorf("--prefix must be between 8 and 32")
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		name := args[0]
+		svc, exists := cfg.Get().Services[name]
+		if !exists {
+			return fmt.Errorf("service '%s' not found", name)
+		}
+		if len(svc.Domains) == 0 {
+			return fmt.Errorf("service '%s' has no domains", name)
+		}
+
+		log, err := createLogger()
+		if err != nil {
+			return err
+		}
+		defer log.Close()
+
+		netMgr, err := createNetworkManager(log)
+		if err != nil {
+			return err
+		}
+
+		var missing []string
+		seen := make(map[string]bool)
+
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DOMAIN\tADDRESS\tCOVERED BY")
+		fmt.Fprintln(w, "------\t-------\t----------")
+
+		for _, domain := range svc.Domains {
+			if network.IsWildcardDomain(domain) {
+				fmt.Fprintf(w, "%s\t-\twildcard, resolved via the DNS proxy\n", domain)
+				continue
+			}
+
+			answers, err := netMgr.ResolveDomain(domain)
+			if err != nil {
+				fmt.Fprintf(w, "%s\t-\t%v\n", domain, err)
+				continue
+			}
+
+			for _, answer := range answers {
+				covering := coveringNetwork(cfg.ServiceNetworks(name), answer.IP)
+				if covering == "" {
+					covering = "❌ not covered"
+					_, ipnet, _ := net.ParseCIDR(fmt.Sprintf("%s/%d", answer.IP, prefix))
+					if ipnet != nil && !seen[ipnet.String()] {
+						seen[ipnet.String()] = true
+						missing = append(missing, ipnet.String())
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", domain, answer.IP, covering)
+			}
+		}
+		w.Flush()
+
+		if len(missing) == 0 {
+			fmt.Fprintln(stdout, "\n✅ All resolved addresses are covered by the service's networks")
+			return nil
+		}
+
+		sort.Strings(missing)
+		fmt.Fprintf(stdout, "\nMissing networks (%d):\n", len(missing))
+		for _, cidr := range missing {
+			fmt.Fprintf(stdout, "  %s\n", cidr)
+		}
+
+		if !add {
+			fmt.Fprintf(stdout, "💡 Add them with: vpn-route-manager service resolve %s --add\n", name)
+			return nil
+		}
+
+		if err := cfg.AddServiceNetworks(name, missing); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "✅ Added %d networks to service '%s'\n", len(missing), name)
+		return nil
+	},
+}
+
+var serviceSyncASNCmd = &cobra.Command{
+	Use:   "sync-asn [name]",
+	Short: "Regenerate service networks from the prefixes their ASNs announce",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		var names []string
+		if len(args) == 1 {
+			svc, exists := cfg.Get().Services[args[0]]
+			if !exists {
+				return fmt.Errorf("service '%s' not found", args[0])
+			}
+			if len(svc.ASNs) == 0 {
+				return fmt.Errorf("service '%s' has no ASNs", args[0])
+			}
+			names = append(names, args[0])
+		} else {
+			for name, svc := range cfg.Get().Services {
+				if len(svc.ASNs) > 0 {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+		}
+
+		if len(names) == 0 {
+			fmt.Fprintln(stdout, "No services with ASNs configured")
+			return nil
+		}
+
+		failed := 0
+		for _, name := range names {
+			svc := cfg.Get().Services[name]
+			prefixes, err := network.FetchASNPrefixes(cfg.Get().ASNSync.Source, svc.ASNs)
+			if err != nil {
+				fmt.Fprintf(stdout, "❌ %s: %v\n", name, err)
+				failed++
+				continue
+			}
+
+			prefixes, err = limitNetworks(cfg, name, prefixes)
+			if err != nil {
+				fmt.Fprintf(stdout, "❌ %s: %v\n", name, err)
+				failed++
+				continue
+			}
+
+			previous := len(svc.Networks)
+			if err := cfg.SetServiceNetworks(name, prefixes); err != nil {
+				fmt.Fprintf(stdout, "❌ %s: %v\n", name, err)
+				failed++
+				continue
+			}
+			fmt.Fprintf(stdout, "✅ %s: %d networks from %s (was %d)\n",
+				name, len(prefixes), strings.Join(svc.ASNs, ", "), previous)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("failed to sync %d services", failed)
+		}
+
+		fmt.Fprintln(stdout, "💡 Restart the service to apply changes: vpn-route-manager restart")
+		return nil
+	},
+}
+
+var serviceUpdateNetworksCmd = &cobra.Command{
+	Use:   "update-networks [name]",
+	Short: "Refresh service networks from the IP ranges their operators publish",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		services := cfg.Get().Services
+		var names []string
+		if len(args) == 1 {
+			svc, exists := services[args[0]]
+			if !exists {
+				return fmt.Errorf("service '%s' not found", args[0])
+			}
+			if len(svc.ASNs) > 0 {
+				return fmt.Errorf("service '%s' lists ASNs, use 'vpn-route-manager service sync-asn %s'", args[0], args[0])
+			}
+			if config.NetworksSource(args[0], svc) == "" {
+				return fmt.Errorf("no published IP ranges known for service '%s', set networks_url in its file", args[0])
+			}
+			names = append(names, args[0])
+		} else {
+			for name, svc := range services {
+				if len(svc.ASNs) == 0 && config.NetworksSource(name, svc) != "" {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+		}
+
+		if len(names) == 0 {
+			fmt.Fprintln(stdout, "No services with published IP ranges")
+			return nil
+		}
+
+		// Services sharing a list are only fetched once
+		fetched := make(map[string][]string)
+		failed := 0
+		for _, name := range names {
+			svc := services[name]
+			source := config.NetworksSource(name, svc)
+
+			networks, ok := fetched[source]
+			if !ok {
+				if networks, err = network.FetchPublishedNetworks(source); err != nil {
+					fmt.Fprintf(stdout, "❌ %s: %v\n", name, err)
+					failed++
+					continue
+				}
+				fetched[source] = networks
+			}
+
+			limited, err := limitNetworks(cfg, name, append([]string(nil), networks...))
+			if err != nil {
+				fmt.Fprintf(stdout, "❌ %s: %v\n", name, err)
+				failed++
+				continue
+			}
+
+			previous := len(svc.Networks)
+			if err := cfg.SetServiceNetworks(name, limited); err != nil {
+				fmt.Fprintf(stdout, "❌ %s: %v\n", name, err)
+				failed++
+				continue
+			}
+			fmt.Fprintf(stdout, "✅ %s: %d networks (was %d)\n", name, len(limited), previous)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("failed to update %d services", failed)
+		}
+
+		fmt.Fprintln(stdout, "💡 Restart the service to apply changes: vpn-route-manager restart")
+		return nil
+	},
+}
+
+var serviceValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the service files for problems",
+	Long: `Check every service file for invalid CIDRs, duplicate networks, networks
+overlapping other services and other problems, without loading them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("dir")
+		if dir == "" {
+			dir = getServicesPath()
+		}
+
+		files, problems, err := config.CheckServiceFiles(dir)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			fmt.Fprintf(stdout, "No service files in %s\n", dir)
+			return nil
+		}
+
+		errors, warnings := printFileProblems(files, problems)
+		fmt.Fprintf(stdout, "\n%d files checked, %d errors, %d warnings\n", len(files), errors, warnings)
+		if errors > 0 {
+			return fmt.Errorf("service files have %d errors", errors)
+		}
+		return nil
+	},
+}
+
+// printFileProblems prints the problems found in each file, problems not
+// tied to a file first, and returns the number of errors and warnings
+func printFileProblems(files []string, problems []config.FileProblem) (int, int) {
+	byFile := make(map[string][]config.FileProblem)
+	errors, warnings := 0, 0
+	for _, problem := range problems {
+		byFile[problem.File] = append(byFile[problem.File], problem)
+		if problem.Error {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+
+	for _, file := range append([]string{""}, files...) {
+		list := byFile[file]
+		switch {
+		case len(list) > 0 && file == "":
+			fmt.Fprintln(stdout, "All services:")
+		case len(list) > 0:
+			fmt.Fprintf(stdout, "%s:\n", file)
+		case file != "":
+			fmt.Fprintf(stdout, "✅ %s\n", file)
+			continue
+		default:
+			continue
+		}
+		for _, problem := range list {
+			message := problem.Message
+			if problem.Line > 0 {
+				message = fmt.Sprintf("line %d: %s", problem.Line, message)
+			}
+			if problem.Error {
+				fmt.Fprintf(stdout, "  ❌ %s\n", message)
+			} else {
+				fmt.Fprintf(stdout, "  ⚠️  %s\n", message)
+			}
+		}
+	}
+
+	return errors, warnings
+}
+
+var serviceOverlapsCmd = &cobra.Command{
+	Use:   "overlaps",
+	Short: "List networks declared by more than one service",
+	Long: `List networks declared by more than one service. Each is routed once, for
+its owner: the service with the highest priority.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		enabledOnly, _ := cmd.Flags().GetBool("enabled")
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		services := cfg.Get().Services
+		if enabledOnly {
+			services = cfg.GetEnabledServices()
+		}
+
+		shared := cfg.SharedNetworks(services)
+		if len(shared) == 0 {
+			fmt.Fprintln(stdout, "No networks are declared by more than one service")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NETWORK\tOWNER\tALSO IN")
+		fmt.Fprintln(w, "-------\t-----\t-------")
+		for _, network := range shared {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", network.Network, network.Owner, strings.Join(network.Services[1:], ", "))
+		}
+		w.Flush()
+
+		fmt.Fprintf(stdout, "\n%d shared networks\n", len(shared))
+		return nil
+	},
+}
+
+// limitNetworks applies the route limits to a service's new networks,
+// warning when some had to be dropped
+func limitNetworks(cfg *config.Manager, name string, networks []string) ([]string, error) {
+	limited, dropped, err := cfg.LimitNetworks(name, networks)
+	if err != nil {
+		return nil, err
+	}
+	if dropped > 0 {
+		fmt.Fprintf(stdout, "⚠️  %s: dropped %d of %d networks to stay within the route limit\n", name, dropped, len(networks))
+	}
+	return limited, nil
+}
+
+// defaultGeoIPSource serves aggregated per-country IPv4 blocks; %s is
+// replaced with the lowercase country code
+const defaultGeoIPSource = "https://www.ipdeny.com/ipblocks/data/aggregated/%s-aggregated.zone"
+
+var serviceGenerateCmd = &cobra.Command{
+	Use:   "generate <name>",
+	Short: "Generate a service covering a country's address space",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		country, _ := cmd.Flags().GetString("country")
+		source, _ := cmd.Flags().GetString("source")
+		priority, _ := cmd.Flags().GetInt("priority")
+
+		country = strings.ToLower(strings.TrimSpace(country))
+		if len(country) != 2 {
+			return fmt.Errorf("--country must be a two-letter country code")
+		}
+		if !strings.Contains(source, "%s") {
+			return fmt.Errorf("--source must contain %%s for the country code")
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		if _, exists := cfg.Get().Services[name]; exists {
+			return fmt.Errorf("service '%s' already exists", name)
+		}
+
+		url := fmt.Sprintf(source, country)
+		fmt.Fprintf(stdout, "Fetching address blocks for %s...\n", strings.ToUpper(country))
+		networks, err := network.FetchPublishedNetworks(url)
+		if err != nil {
+			return err
+		}
+
+		// Keep the list refreshable with update-networks
+		service := &config.Service{
+			Name:        name,
+			Description: fmt.Sprintf("All %s address space", strings.ToUpper(country)),
+			Enabled:     false,
+			Networks:    networks,
+			NetworksURL: url,
+			Priority:    priority,
+		}
+
+		if err := config.ValidateService(name, service); err != nil {
+			return err
+		}
+
+		cfg.Get().Services[name] = service
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "✅ Service '%s' generated with %d networks (disabled by default)\n", name, len(networks))
+		if len(networks) > 1000 {
+			fmt.Fprintf(stdout, "⚠️  %d routes will be added when enabled, which slows down route setup\n", len(networks))
+		}
+		fmt.Fprintf(stdout, "💡 Enable with: vpn-route-manager service enable %s\n", name)
 		return nil
 	},
 }
 
+// coveringNetwork returns the first of the networks containing an address,
+// or "" when none does
+func coveringNetwork(networks []string, address string) string {
+	ip := net.ParseIP(address)
+	for _, network := range networks {
+		if _, ipnet, err := net.ParseCIDR(network); err == nil && ipnet.Contains(ip) {
+			return network
+		}
+	}
+	return ""
+}
+
 func init() {
 	// Add subcommands
 	serviceCmd.AddCommand(
@@ -267,11 +1301,62 @@ func init() {
 		serviceEnableCmd,
 		serviceDisableCmd,
 		serviceAddCmd,
+		serviceEditCmd,
+		serviceRenameCmd,
 		serviceRemoveCmd,
+		serviceExportCmd,
+		serviceImportCmd,
+		serviceResolveCmd,
+		serviceSyncASNCmd,
+		serviceUpdateNetworksCmd,
+		serviceGenerateCmd,
+		serviceValidateCmd,
+		serviceOverlapsCmd,
 	)
 
 	// Add flags to add command
 	serviceAddCmd.Flags().String("networks", "", "Comma-separated list of networks (CIDR format)")
 	serviceAddCmd.Flags().String("description", "", "Service description")
 	serviceAddCmd.Flags().Int("priority", 50, "Service priority (0-1000)")
+	serviceAddCmd.Flags().String("interface", "", "Bind routes to this interface (e.g. en7)")
+	serviceAddCmd.Flags().StringSlice("tags", nil, "Comma-separated list of tags (e.g. streaming)")
+	serviceAddCmd.Flags().BoolP("interactive", "i", false, "Prompt for the service's fields")
+
+	serviceOverlapsCmd.Flags().Bool("enabled", false, "Only consider enabled services")
+	serviceValidateCmd.Flags().String("dir", "", "Services directory to check (default is the configured one)")
+
+	// Add flags to edit command
+	serviceEditCmd.Flags().StringSlice("add-network", nil, "Networks to add (CIDR format)")
+	serviceEditCmd.Flags().StringSlice("remove-network", nil, "Networks to remove")
+	serviceEditCmd.Flags().StringSlice("add-domain", nil, "Domains to add")
+	serviceEditCmd.Flags().StringSlice("remove-domain", nil, "Domains to remove")
+	serviceEditCmd.Flags().String("description", "", "Service description")
+	serviceEditCmd.Flags().Int("priority", 50, "Service priority (0-1000)")
+	serviceEditCmd.Flags().String("interface", "", "Bind routes to this interface (empty to unbind)")
+	serviceEditCmd.Flags().StringSlice("tags", nil, "Replace the service's tags")
+
+	// Add flags to export and import commands
+	serviceExportCmd.Flags().Bool("all", false, "Export all services as a bundle")
+	serviceExportCmd.Flags().String("format", "json", "Output format (json or yaml)")
+	serviceExportCmd.Flags().StringP("output", "o", "", "Write to this file instead of stdout")
+	serviceImportCmd.Flags().Bool("replace", false, "Overwrite existing services with the same name")
+
+	// Add flags to enable and disable commands
+	serviceEnableCmd.Flags().String("tag", "", "Enable all services with this tag")
+	serviceEnableCmd.Flags().Duration("for", 0, "Disable the service again after this long (e.g. 2h)")
+	serviceDisableCmd.Flags().String("tag", "", "Disable all services with this tag")
+	serviceEnableCmd.Flags().Bool("all", false, "Enable all services")
+	serviceDisableCmd.Flags().Bool("all", false, "Disable all services")
+	serviceEnableCmd.RegisterFlagCompletionFunc("tag", completeTags)
+	serviceDisableCmd.RegisterFlagCompletionFunc("tag", completeTags)
+
+	// Add flags to resolve command
+	serviceResolveCmd.Flags().Bool("add", false, "Append networks for uncovered addresses to the service file")
+	serviceResolveCmd.Flags().Int("prefix", 32, "Prefix length of the networks added for uncovered addresses")
+
+	// Add flags to generate command
+	serviceGenerateCmd.Flags().String("country", "", "Two-letter country code (e.g. UA)")
+	serviceGenerateCmd.Flags().String("source", defaultGeoIPSource, "URL of the per-country CIDR list, with %s for the country code")
+	serviceGenerateCmd.Flags().Int("priority", 10, "Service priority (0-1000)")
+	serviceGenerateCmd.MarkFlagRequired("country")
 }
\ No newline at end of file
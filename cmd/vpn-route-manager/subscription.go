@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"vpn-route-manager/internal/config"
+)
+
+// Subscription command group
+var subscriptionCmd = &cobra.Command{
+	Use:   "subscription",
+	Short: "Service list subscriptions",
+	Long:  "Subscribe to centrally maintained lists of services that bypass the VPN",
+}
+
+var subscriptionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List subscriptions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		subs := cfg.Get().Subscriptions
+		if len(subs) == 0 {
+			fmt.Fprintln(stdout, "No subscriptions configured")
+			return nil
+		}
+
+		dir := config.SubscriptionsDir(cfg.Get())
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSERVICES\tUPDATED\tURL")
+		fmt.Fprintln(w, "----\t--------\t-------\t---")
+
+		for _, sub := range subs {
+			services, updated := "-", "never"
+			if cache, err := config.ReadSubscriptionCache(dir, sub.Name); err == nil {
+				services = fmt.Sprintf("%d", len(cache.Services))
+				updated = cache.FetchedAt.Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", sub.Name, services, updated, sub.URL)
+		}
+		w.Flush()
+
+		return nil
+	},
+}
+
+var subscriptionAddCmd = &cobra.Command{
+	Use:   "add <name> <url>",
+	Short: "Subscribe to a service list",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		interval, _ := cmd.Flags().GetInt("interval")
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		name := args[0]
+		for _, sub := range cfg.Get().Subscriptions {
+			if sub.Name == name {
+				return fmt.Errorf("subscription '%s' already exists", name)
+			}
+		}
+
+		sub := config.Subscription{Name: name, URL: args[1], Interval: interval}
+		cfg.Get().Subscriptions = append(cfg.Get().Subscriptions, sub)
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+
+		// Fetch right away so a bad URL is reported now
+		if _, err := config.FetchSubscription(sub, config.SubscriptionsDir(cfg.Get())); err != nil {
+			return err
+		}
+
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+
+		cache, err := config.ReadSubscriptionCache(config.SubscriptionsDir(cfg.Get()), name)
+		if err != nil {
+			return err
+		}
+
+		var names []string
+		for service := range cache.Services {
+			names = append(names, service)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintf(stdout, "✅ Subscribed to '%s' (%d services)\n", name, len(names))
+		for _, service := range names {
+			fmt.Fprintf(stdout, "  %s\n", service)
+		}
+		fmt.Fprintln(stdout, "⚠️  Restart the service to apply changes: vpn-route-manager restart")
+		return nil
+	},
+}
+
+var subscriptionRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Unsubscribe from a service list",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		name := args[0]
+		var remaining []config.Subscription
+		for _, sub := range cfg.Get().Subscriptions {
+			if sub.Name != name {
+				remaining = append(remaining, sub)
+			}
+		}
+		if len(remaining) == len(cfg.Get().Subscriptions) {
+			return fmt.Errorf("subscription '%s' not found", name)
+		}
+
+		cfg.Get().Subscriptions = remaining
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+
+		cacheFile := filepath.Join(config.SubscriptionsDir(cfg.Get()), name+".json")
+		if err := os.Remove(cacheFile); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(stdout, "⚠️  Warning: %v\n", err)
+		}
+
+		fmt.Fprintf(stdout, "✅ Unsubscribed from '%s'\n", name)
+		fmt.Fprintln(stdout, "⚠️  Restart the service to apply changes: vpn-route-manager restart")
+		return nil
+	},
+}
+
+var subscriptionRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Refresh all subscriptions now",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		subs := cfg.Get().Subscriptions
+		if len(subs) == 0 {
+			fmt.Fprintln(stdout, "No subscriptions configured")
+			return nil
+		}
+
+		failed := 0
+		for _, sub := range subs {
+			start := time.Now()
+			updated, err := config.FetchSubscription(sub, config.SubscriptionsDir(cfg.Get()))
+			switch {
+			case err != nil:
+				fmt.Fprintf(stdout, "❌ %s: %v\n", sub.Name, err)
+				failed++
+			case updated:
+				fmt.Fprintf(stdout, "✅ %s: updated (%v)\n", sub.Name, time.Since(start).Round(time.Millisecond))
+			default:
+				fmt.Fprintf(stdout, "✅ %s: unchanged\n", sub.Name)
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("failed to refresh %d subscriptions", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	subscriptionCmd.AddCommand(
+		subscriptionListCmd,
+		subscriptionAddCmd,
+		subscriptionRemoveCmd,
+		subscriptionRefreshCmd,
+	)
+
+	subscriptionAddCmd.Flags().Int("interval", 0, "Refresh interval in hours (default 6)")
+}
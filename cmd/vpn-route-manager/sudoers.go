@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"vpn-route-manager/internal/system"
+)
+
+// Sudoers command group
+var sudoersCmd = &cobra.Command{
+	Use:   "sudoers",
+	Short: "Sudoers entry commands",
+	Long: `Inspect and rewrite the sudoers entries that let the service change routes
+without a password.
+
+The entries only allow running the privileged helper, a root-owned copy of
+this binary in /Library/PrivilegedHelperTools that checks every argument
+itself: it adds, changes and deletes routes to IPv4 networks of /8 or
+longer through a gateway or interface, and writes and removes its own split
+DNS files in /etc/resolver. It doesn't change the default route or run
+route otherwise.`,
+}
+
+var sudoersAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Compare the sudoers entries with the ones the service needs",
+	Long: `Reads the sudoers file, with sudo unless run as root, and reports entries
+that are missing and entries that don't belong there, such as the /sbin/route
+patterns older versions installed. Fails when any are found.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sudoMgr, err := newSudoersManager()
+		if err != nil {
+			return err
+		}
+
+		audit, err := sudoMgr.Audit()
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			if err := printStructured(audit); err != nil {
+				return err
+			}
+		} else {
+			printSudoersAudit(audit)
+		}
+
+		if !audit.OK() {
+			return fmt.Errorf("sudoers entries don't match, run 'vpn-route-manager sudoers regenerate'")
+		}
+		return nil
+	},
+}
+
+var sudoersRegenerateCmd = &cobra.Command{
+	Use:   "regenerate",
+	Short: "Rewrite the sudoers entries",
+	Long: `Rewrites the sudoers file with the entries the service needs, replacing
+whatever it held, and removes the file older installers wrote. The entries
+are checked with visudo before they are installed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sudoMgr, err := newSudoersManager()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "🔐 Writing %s...\n", sudoMgr.GetSudoersFile())
+		if err := sudoMgr.Setup(); err != nil {
+			return fmt.Errorf("failed to setup sudo: %w", err)
+		}
+		if err := sudoMgr.TestAccess(); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, "✅ Sudo permissions configured")
+		return nil
+	},
+}
+
+// newSudoersManager returns the sudo manager of the current user, unless
+// the service runs as root and needs no sudoers entries
+func newSudoersManager() (*system.SudoManager, error) {
+	username := os.Getenv("USER")
+	if username == "" {
+		return nil, fmt.Errorf("could not determine current user")
+	}
+	if system.NewLaunchAgent(username).IsRoot() {
+		return nil, fmt.Errorf("the service runs as root and needs no sudoers entries")
+	}
+	return system.NewSudoManager(username), nil
+}
+
+// printSudoersAudit prints the differences an audit found
+func printSudoersAudit(audit *system.SudoersAudit) {
+	fmt.Fprintf(stdout, "📄 %s\n", audit.File)
+	if !audit.Installed {
+		fmt.Fprintln(stdout, "❌ Not installed")
+	}
+	if audit.Legacy != "" {
+		fmt.Fprintf(stdout, "⚠️  Older entries still installed in %s\n", audit.Legacy)
+	}
+	if audit.Installed && len(audit.Missing) > 0 {
+		fmt.Fprintf(stdout, "\n❌ Missing (%d):\n", len(audit.Missing))
+		for _, line := range audit.Missing {
+			fmt.Fprintf(stdout, "  %s\n", line)
+		}
+	}
+	if len(audit.Unexpected) > 0 {
+		fmt.Fprintf(stdout, "\n⚠️  Unexpected (%d):\n", len(audit.Unexpected))
+		for _, line := range audit.Unexpected {
+			fmt.Fprintf(stdout, "  %s\n", line)
+		}
+	}
+	if audit.OK() {
+		fmt.Fprintln(stdout, "✅ Entries match the ones the service needs")
+	}
+}
+
+func init() {
+	sudoersCmd.AddCommand(sudoersAuditCmd, sudoersRegenerateCmd)
+}
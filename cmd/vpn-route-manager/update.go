@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/system"
+)
+
+var (
+	updateCheck bool
+	updateForce bool
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update to the latest release",
+	Long: `Check GitHub for the latest release and, when it is newer than this
+binary, download the package for this Mac's architecture, verify its SHA-256
+checksum against the release's checksums.txt, replace the installed binary
+and restart the service if it is running.
+
+The binary is replaced atomically, so an interrupted update leaves the old
+one in place. Development builds are only replaced with --force.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username := os.Getenv("USER")
+		launchAgent := system.NewLaunchAgent(username)
+		installPath := installedBinaryPath(launchAgent)
+
+		fmt.Fprintln(stdout, "🔍 Checking for updates...")
+		release, err := system.LatestRelease()
+		if err != nil {
+			return err
+		}
+
+		current := currentBuild().Version
+		fmt.Fprintf(stdout, "Installed: %s\n", current)
+		fmt.Fprintf(stdout, "Latest: %s\n", release.Version)
+
+		newer := current != "dev" && config.CompareVersions(release.Version, current) > 0
+		if !newer && !updateForce {
+			if current == "dev" {
+				fmt.Fprintln(stdout, "💡 This is a development build, pass --force to replace it with the release")
+			} else {
+				fmt.Fprintln(stdout, "✅ Already up to date")
+			}
+			return nil
+		}
+		if updateCheck {
+			fmt.Fprintf(stdout, "⬆️  %s is available: %s\n", release.Version, release.URL)
+			return nil
+		}
+
+		if _, err := os.Stat(installPath); err != nil {
+			return fmt.Errorf("%w: no binary at %s, run 'vpn-route-manager install' first", system.ErrNotInstalled, installPath)
+		}
+		if !confirm(fmt.Sprintf("Update %s to %s?", installPath, release.Version), true) {
+			return nil
+		}
+
+		fmt.Fprintf(stdout, "📥 Downloading %s...\n", system.BinaryAsset(runtime.GOARCH))
+		binary, err := release.DownloadBinary(runtime.GOARCH)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, "✅ Checksum verified")
+
+		fmt.Fprintf(stdout, "📁 Installing binary to %s...\n", installPath)
+		if err := system.ReplaceBinary(installPath, binary); err != nil {
+			return err
+		}
+
+		// The helper routes are changed through must run the new checks too
+		if _, err := upgradeHelper(username, installPath); err != nil {
+			return err
+		}
+
+		if launchAgent.IsLoaded() {
+			fmt.Fprintln(stdout, "🔄 Restarting service...")
+			if err := launchAgent.Unload(); err != nil {
+				return fmt.Errorf("failed to stop service: %w", err)
+			}
+			if err := launchAgent.Load(); err != nil {
+				return fmt.Errorf("failed to start service: %w", err)
+			}
+		}
+
+		fmt.Fprintf(stdout, "✅ Updated to %s\n", release.Version)
+		return nil
+	},
+}
+
+func init() {
+	updateCmd.Flags().BoolVar(&updateCheck, "check", false, "Only check whether a newer release is available")
+	updateCmd.Flags().BoolVar(&updateForce, "force", false, "Install the latest release even if it isn't newer")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/service"
+	"vpn-route-manager/internal/system"
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade the installation to this binary, keeping its configuration",
+	Long: `Upgrade an existing installation to the binary this command is run from,
+such as a newly downloaded release, without touching its configuration the
+way running install again would:
+
+  • configuration and service files are migrated to this version's schema,
+    backed up first, and must load before anything else changes
+  • state this version can't read is set aside to be rebuilt
+  • the installed binary is replaced if it differs from this one, and so
+    is the privileged helper the sudoers entries run, with sudo; sudoers
+    entries older versions wrote are rewritten to run it
+  • the plist is rewritten from the launchd settings in the configuration
+    only if it differs from the installed one, which is moved when the
+    label changed
+  • the service is restarted if it was running
+
+Each step is skipped when there is nothing to do, so running it again
+changes nothing.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username := os.Getenv("USER")
+		if username == "" {
+			return fmt.Errorf("could not determine current user")
+		}
+		launchAgent := system.NewLaunchAgent(username)
+		if !launchAgent.IsInstalled() {
+			return fmt.Errorf("%w: run 'vpn-route-manager install' first", system.ErrNotInstalled)
+		}
+		installPath, err := launchAgent.BinaryPath()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "🚀 Upgrading VPN Route Manager to %s...\n", currentBuild().Version)
+		changed := false
+
+		// The configuration must load with this version before anything
+		// else is changed
+		cfgManager := config.NewManager(getConfigPath())
+		migrated, err := cfgManager.Migrate(getServicesPath())
+		for _, m := range migrated {
+			fmt.Fprintf(stdout, "⚙️  Migrated %s from schema version %d to %d (backup: %s)\n",
+				m.File, m.From, config.SchemaVersion, m.Backup)
+			changed = true
+		}
+		if err != nil {
+			return err
+		}
+		if err := cfgManager.Load(); err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if err := cfgManager.LoadServices(getServicesPath()); err != nil {
+			return fmt.Errorf("failed to load services: %w", err)
+		}
+
+		moved, err := service.UpgradeState(cfgManager.Get().StateDir)
+		if err != nil {
+			return err
+		}
+		if moved != "" {
+			fmt.Fprintf(stdout, "📂 Set aside unreadable state as %s\n", moved)
+			changed = true
+		}
+
+		binary, err := upgradedBinary(installPath)
+		if err != nil {
+			return err
+		}
+		job := launchAgent.WithLabel(cfgManager.Get().Launchd.Label)
+		job.SetOptions(jobOptions(cfgManager.Get()))
+		relabeled := job.Label() != launchAgent.Label()
+		plistChanged := relabeled
+		if !relabeled {
+			if plistChanged, err = job.PlistChanged(installPath, config.LogDir()); err != nil {
+				return err
+			}
+		}
+		if binary == nil && !plistChanged {
+			helperChanged, err := upgradeHelper(username, installPath)
+			if err != nil {
+				return err
+			}
+			if changed || helperChanged {
+				fmt.Fprintln(stdout, "✅ Upgrade complete")
+			} else {
+				fmt.Fprintln(stdout, "✅ Already up to date")
+			}
+			return nil
+		}
+
+		wasLoaded := launchAgent.IsLoaded()
+		if wasLoaded {
+			fmt.Fprintln(stdout, "🛑 Stopping service...")
+			if err := launchAgent.Unload(); err != nil {
+				return fmt.Errorf("failed to stop service: %w", err)
+			}
+		}
+
+		if binary != nil {
+			fmt.Fprintf(stdout, "📁 Installing binary to %s...\n", installPath)
+			if err := system.ReplaceBinary(installPath, binary); err != nil {
+				return err
+			}
+			if _, err := upgradeHelper(username, installPath); err != nil {
+				return err
+			}
+		}
+		if relabeled {
+			fmt.Fprintf(stdout, "🗑️  Removing %s...\n", launchAgent.PlistPath())
+			if err := launchAgent.Uninstall(); err != nil {
+				return fmt.Errorf("failed to remove plist: %w", err)
+			}
+		}
+		if plistChanged {
+			fmt.Fprintf(stdout, "🎯 Updating %s...\n", job.PlistPath())
+			if err := job.WritePlist(installPath, config.LogDir()); err != nil {
+				return fmt.Errorf("failed to update plist: %w", err)
+			}
+		}
+
+		if wasLoaded {
+			fmt.Fprintln(stdout, "🔄 Starting service...")
+			if err := job.Load(); err != nil {
+				return fmt.Errorf("failed to start service: %w", err)
+			}
+		}
+
+		fmt.Fprintln(stdout, "✅ Upgrade complete")
+		return nil
+	},
+}
+
+// upgradedBinary returns this binary when it differs from the one
+// installed at installPath, or nil when upgrading it isn't needed
+func upgradedBinary(installPath string) ([]byte, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get executable path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	if executable == installPath {
+		return nil, nil
+	}
+
+	binary, err := os.ReadFile(executable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", executable, err)
+	}
+	if installed, err := os.ReadFile(installPath); err == nil && bytes.Equal(installed, binary) {
+		return nil, nil
+	}
+	return binary, nil
+}
+
+// upgradeHelper replaces the privileged helper with the binary installed at
+// installPath when the service runs it through sudo or the helper daemon
+// and it differs, and rewrites sudoers entries that don't allow it yet,
+// reporting whether it changed either
+func upgradeHelper(username, installPath string) (bool, error) {
+	if system.HelperDaemonInstalled() {
+		if !system.HelperOutdated(installPath) {
+			return false, nil
+		}
+		fmt.Fprintf(stdout, "🔐 Updating %s...\n", system.HelperPath)
+		if err := system.InstallHelper(installPath); err != nil {
+			return false, err
+		}
+		return true, system.RestartHelperDaemon()
+	}
+
+	sudoMgr := system.NewSudoManager(username)
+	if _, err := os.Stat(sudoMgr.GetSudoersFile()); err != nil {
+		return false, nil
+	}
+	if missing := sudoMgr.MissingCommands(); len(missing) > 0 {
+		fmt.Fprintf(stdout, "🔐 Writing %s...\n", sudoMgr.GetSudoersFile())
+		if err := sudoMgr.Setup(); err != nil {
+			return false, fmt.Errorf("failed to setup sudo: %w", err)
+		}
+		return true, nil
+	}
+	if !system.HelperOutdated(installPath) {
+		return false, nil
+	}
+	fmt.Fprintf(stdout, "🔐 Updating %s...\n", system.HelperPath)
+	if err := system.InstallHelper(installPath); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	runtimedebug "runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, set at build time with
+//
+//	-ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+//
+// Without it the commit and date come from the VCS information Go embeds.
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildInfo identifies the exact build of the binary for bug reports
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// currentBuild returns the build metadata of this binary
+func currentBuild() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if build, ok := runtimedebug.ReadBuildInfo(); ok {
+		modified := false
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" && len(setting.Value) > 12 {
+					info.Commit = setting.Value[:12]
+				} else if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if modified && commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+
+	return info
+}
+
+// String formats the build as shown by --version
+func (b buildInfo) String() string {
+	s := b.Version
+	if b.Commit != "" {
+		s += fmt.Sprintf(" (commit %s", b.Commit)
+		if b.BuildDate != "" {
+			s += fmt.Sprintf(", built %s", b.BuildDate)
+		}
+		s += ")"
+	} else if b.BuildDate != "" {
+		s += fmt.Sprintf(" (built %s)", b.BuildDate)
+	}
+	return s + fmt.Sprintf(" %s %s", b.GoVersion, b.Platform)
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show version and build information",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		build := currentBuild()
+		if structuredOutput() {
+			return printStructured(build)
+		}
+
+		fmt.Fprintf(stdout, "Version: %s\n", build.Version)
+		fmt.Fprintf(stdout, "Commit: %s\n", valueOrUnknown(build.Commit))
+		fmt.Fprintf(stdout, "Built: %s\n", valueOrUnknown(build.BuildDate))
+		fmt.Fprintf(stdout, "Go: %s\n", build.GoVersion)
+		fmt.Fprintf(stdout, "Platform: %s\n", build.Platform)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.Version = currentBuild().String()
+	rootCmd.SetVersionTemplate("vpn-route-manager {{.Version}}\n")
+}
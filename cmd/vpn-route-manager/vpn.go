@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// VPN command group
+var vpnCmd = &cobra.Command{
+	Use:   "vpn",
+	Short: "VPN connection commands",
+	Long:  "Inspect the VPN connection as seen by the route manager",
+}
+
+var vpnStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show VPN tunnel details",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log, err := createLogger()
+		if err != nil {
+			return err
+		}
+		defer log.Close()
+
+		netMgr, err := createNetworkManager(log)
+		if err != nil {
+			return err
+		}
+
+		detection := netMgr.DetectVPN()
+
+		fmt.Fprintln(stdout, "🔒 VPN Status")
+		fmt.Fprintln(stdout, "=============")
+		if detection.Connected {
+			fmt.Fprintln(stdout, "VPN: ✅ CONNECTED")
+		} else {
+			fmt.Fprintln(stdout, "VPN: ❌ DISCONNECTED")
+		}
+		fmt.Fprintf(stdout, "Confidence: %.2f (threshold %.2f)\n", detection.Score, detection.Threshold)
+
+		info := netMgr.GetVPNInfo()
+		if info.Interface == "" {
+			fmt.Fprintln(stdout, "\nNo VPN tunnel interface found")
+			return nil
+		}
+
+		mode := "split-tunnel"
+		if info.FullTunnel {
+			mode = "full-tunnel"
+		}
+
+		fmt.Fprintln(stdout, "\n🚇 Tunnel")
+		fmt.Fprintln(stdout, "---------")
+		fmt.Fprintf(stdout, "Interface: %s\n", info.Interface)
+		fmt.Fprintf(stdout, "Tunnel IP: %s\n", valueOrUnknown(info.TunnelIP))
+		fmt.Fprintf(stdout, "Gateway: %s\n", valueOrUnknown(info.Gateway))
+		fmt.Fprintf(stdout, "Mode: %s\n", mode)
+		fmt.Fprintf(stdout, "Product: %s\n", valueOrUnknown(info.Product))
+		fmt.Fprintf(stdout, "Servers: %s\n", valueOrUnknown(strings.Join(info.Servers, ", ")))
+
+		fmt.Fprintln(stdout, "\n🌐 DNS Servers")
+		fmt.Fprintln(stdout, "--------------")
+		if len(info.DNSServers) == 0 {
+			fmt.Fprintln(stdout, "None pushed")
+		}
+		for _, server := range info.DNSServers {
+			fmt.Fprintf(stdout, "  %s\n", server)
+		}
+
+		fmt.Fprintf(stdout, "\n🛣️  Pushed Routes (%d)\n", len(info.Routes))
+		fmt.Fprintln(stdout, "------------------")
+		for _, route := range info.Routes {
+			fmt.Fprintf(stdout, "  %s\n", route)
+		}
+
+		return nil
+	},
+}
+
+// valueOrUnknown returns the value, or "unknown" when it is empty
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
+
+func init() {
+	vpnCmd.AddCommand(vpnStatusCmd)
+}
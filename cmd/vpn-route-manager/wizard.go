@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/network"
+)
+
+// serviceWizard builds a service by prompting for its fields. Networks are
+// validated as they are entered, and domains are resolved to suggest the
+// networks covering them.
+type serviceWizard struct {
+	in     *bufio.Reader
+	prefix int
+	netMgr *network.Manager
+}
+
+// prompt asks a question and returns the trimmed answer, or def when the
+// answer is empty. Once the input ends, such as on Ctrl-D, it returns
+// errInputEnded so questions aren't asked again forever.
+func (w *serviceWizard) prompt(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(stdout, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(stdout, "%s: ", question)
+	}
+
+	answer, err := w.in.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if err != nil && answer == "" {
+		fmt.Fprintln(stdout)
+		if errors.Is(err, io.EOF) {
+			return "", errInputEnded
+		}
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// errInputEnded is returned by prompts once there is no more input
+var errInputEnded = errors.New("cancelled, the input ended")
+
+// run prompts for a new service called name, offering the fields of
+// defaults as the answers
+func (w *serviceWizard) run(name string, defaults *config.Service) (*config.Service, error) {
+	service := &config.Service{Name: name}
+
+	fmt.Fprintf(stdout, "Adding service '%s'. Press Enter to accept defaults.\n\n", name)
+	description, err := w.prompt("Description", defaults.Description)
+	if err != nil {
+		return nil, err
+	}
+	service.Description = description
+
+	for {
+		value, err := w.prompt("Priority (0-1000)", strconv.Itoa(defaults.Priority))
+		if err != nil {
+			return nil, err
+		}
+		priority, err := strconv.Atoi(value)
+		if err == nil && priority >= 0 && priority <= 1000 {
+			service.Priority = priority
+			break
+		}
+		fmt.Fprintln(stdout, "  ❌ Enter a number between 0 and 1000")
+	}
+
+	tags, err := w.prompt("Tags (comma-separated)", strings.Join(defaults.Tags, ","))
+	if err != nil {
+		return nil, err
+	}
+	if tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				service.Tags = append(service.Tags, tag)
+			}
+		}
+	}
+
+	fmt.Fprintln(stdout, "\nEnter networks in CIDR notation or domains to look up, one per line.")
+	fmt.Fprintln(stdout, "Leave empty when done.")
+	seen := make(map[string]bool)
+	add := func(network string) {
+		if seen[network] {
+			fmt.Fprintf(stdout, "  ⚠️  %s already added\n", network)
+			return
+		}
+		seen[network] = true
+		service.Networks = append(service.Networks, network)
+		fmt.Fprintf(stdout, "  ✅ %s\n", network)
+	}
+
+	for {
+		entry, err := w.prompt("Network or domain", "")
+		if err != nil {
+			return nil, err
+		}
+		if entry == "" {
+			if len(service.Networks) == 0 {
+				fmt.Fprintln(stdout, "  ❌ Add at least one network")
+				continue
+			}
+			break
+		}
+
+		if strings.Contains(entry, "/") {
+			_, ipnet, err := net.ParseCIDR(entry)
+			if err != nil {
+				fmt.Fprintf(stdout, "  ❌ Invalid CIDR: %v\n", err)
+				continue
+			}
+			if ipnet.String() != entry {
+				fmt.Fprintf(stdout, "  ⚠️  %s has host bits set, using %s\n", entry, ipnet)
+			}
+			add(ipnet.String())
+			continue
+		}
+
+		if ip := net.ParseIP(entry); ip != nil {
+			if ip.To4() == nil {
+				fmt.Fprintln(stdout, "  ❌ Only IPv4 addresses are routed")
+				continue
+			}
+			add(ip.String() + "/32")
+			continue
+		}
+
+		for _, suggestion := range w.suggest(entry) {
+			if confirm(fmt.Sprintf("  Add %s?", suggestion), true) {
+				add(suggestion)
+			}
+		}
+		if !containsString(service.Domains, entry) && confirm(fmt.Sprintf("  Also route %s by domain?", entry), false) {
+			service.Domains = append(service.Domains, entry)
+		}
+	}
+
+	return service, nil
+}
+
+// suggest resolves a domain and returns the networks covering its
+// addresses
+func (w *serviceWizard) suggest(domain string) []string {
+	if w.netMgr == nil {
+		log, err := createLogger()
+		if err != nil {
+			fmt.Fprintf(stdout, "  ❌ %v\n", err)
+			return nil
+		}
+		if w.netMgr, err = createNetworkManager(log); err != nil {
+			fmt.Fprintf(stdout, "  ❌ %v\n", err)
+			return nil
+		}
+	}
+
+	answers, err := w.netMgr.ResolveDomain(domain)
+	if err != nil {
+		fmt.Fprintf(stdout, "  ❌ Failed to resolve %s: %v\n", domain, err)
+		return nil
+	}
+
+	var suggestions []string
+	seen := make(map[string]bool)
+	for _, answer := range answers {
+		_, ipnet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", answer.IP, w.prefix))
+		if err != nil || seen[ipnet.String()] {
+			continue
+		}
+		seen[ipnet.String()] = true
+		fmt.Fprintf(stdout, "  %s resolves to %s\n", domain, answer.IP)
+		suggestions = append(suggestions, ipnet.String())
+	}
+	return suggestions
+}
+
+// preview prints the service that will be written to name's file and
+// asks to save it
+func (w *serviceWizard) preview(name string, service *config.Service) bool {
+	data, err := json.MarshalIndent(service, "", "  ")
+	if err != nil {
+		return false
+	}
+
+	fmt.Fprintf(stdout, "\nservices/%s.json:\n%s\n\n", name, data)
+	return confirm("Save this service?", true)
+}
+
+// containsString checks if a list holds a value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// newServiceWizard creates a wizard reading answers from stdin. Networks
+// suggested for domains have the given prefix length.
+func newServiceWizard(prefix int) *serviceWizard {
+	return &serviceWizard{in: stdin, prefix: prefix}
+}
@@ -62,30 +62,13 @@ echo ""
 echo "🔐 Setting up sudo permissions..."
 echo "This allows the service to manage network routes without password prompts."
 
-SUDO_FILE="/etc/sudoers.d/vpn-route-manager-$USERNAME"
-SUDO_CONTENT="$USERNAME ALL=(root) NOPASSWD: /sbin/route"
-
-# Create temporary file
-TEMP_FILE="/tmp/vpn-route-sudoers-$$"
-echo "$SUDO_CONTENT" > "$TEMP_FILE"
-
-# Validate and install
-if visudo -c -f "$TEMP_FILE"; then
-    sudo cp "$TEMP_FILE" "$SUDO_FILE"
-    sudo chmod 440 "$SUDO_FILE"
-    rm -f "$TEMP_FILE"
-    echo "✅ Sudo permissions configured"
-else
-    echo "❌ Invalid sudoers syntax"
-    rm -f "$TEMP_FILE"
+# Only bypass route and split DNS commands are allowed, see
+# 'vpn-route-manager sudoers audit'
+if ! vpn-route-manager sudoers regenerate; then
+    echo "❌ Failed to configure sudo permissions"
     exit 1
 fi
 
-# Test sudo access
-if ! sudo -n route get default >/dev/null 2>&1; then
-    echo "⚠️  Sudo configuration may need manual adjustment"
-fi
-
 # Step 3: Create directories
 echo ""
 echo "📁 Creating configuration directories..."
@@ -114,47 +97,45 @@ echo "📋 Setting up default services..."
 # Telegram service
 cat > ~/.vpn-route-manager/config/services/telegram.json << 'EOF'
 {
-  "telegram": {
-    "name": "Telegram",
-    "description": "Telegram messaging service",
-    "enabled": true,
-    "priority": 100,
-    "networks": [
-      "149.154.160.0/20",
-      "149.154.164.0/22",
-      "149.154.168.0/22",
-      "149.154.172.0/22",
-      "91.108.4.0/22",
-      "91.108.8.0/22",
-      "91.108.12.0/22",
-      "91.108.16.0/22",
-      "91.108.56.0/22",
-      "185.76.151.0/24",
-      "95.161.64.0/20"
-    ]
-  }
+  "name": "Telegram",
+  "description": "Telegram messaging service",
+  "enabled": true,
+  "priority": 100,
+  "networks": [
+    "149.154.160.0/20",
+    "149.154.164.0/22",
+    "149.154.168.0/22",
+    "149.154.172.0/22",
+    "91.108.4.0/22",
+    "91.108.8.0/22",
+    "91.108.12.0/22",
+    "91.108.16.0/22",
+    "91.108.56.0/22",
+    "185.76.151.0/24",
+    "95.161.64.0/20"
+  ],
+  "schema_version": 2
 }
 EOF
 
 # YouTube service
 cat > ~/.vpn-route-manager/config/services/youtube.json << 'EOF'
 {
-  "youtube": {
-    "name": "YouTube",
-    "description": "YouTube and Google services",
-    "enabled": true,
-    "priority": 90,
-    "networks": [
-      "172.217.0.0/16",
-      "142.250.0.0/15",
-      "216.58.192.0/19",
-      "74.125.0.0/16",
-      "64.233.160.0/19",
-      "66.249.80.0/20",
-      "72.14.192.0/18",
-      "209.85.128.0/17"
-    ]
-  }
+  "name": "YouTube",
+  "description": "YouTube and Google services",
+  "enabled": true,
+  "priority": 90,
+  "networks": [
+    "172.217.0.0/16",
+    "142.250.0.0/15",
+    "216.58.192.0/19",
+    "74.125.0.0/16",
+    "64.233.160.0/19",
+    "66.249.80.0/20",
+    "72.14.192.0/18",
+    "209.85.128.0/17"
+  ],
+  "schema_version": 2
 }
 EOF
 
@@ -165,28 +146,27 @@ echo "📋 Installing additional service configurations..."
 # WhatsApp service
 cat > ~/.vpn-route-manager/config/services/whatsapp.json << 'EOF'
 {
-  "whatsapp": {
-    "name": "WhatsApp",
-    "description": "WhatsApp messaging service",
-    "enabled": false,
-    "priority": 80,
-    "networks": [
-      "31.13.64.0/18",
-      "31.13.24.0/21",
-      "31.13.64.0/19",
-      "31.13.96.0/19",
-      "157.240.0.0/16",
-      "173.252.64.0/18",
-      "179.60.192.0/22",
-      "18.194.0.0/15",
-      "34.224.0.0/12"
-    ],
-    "domains": [
-      "whatsapp.com",
-      "whatsapp.net",
-      "wa.me"
-    ]
-  }
+  "name": "WhatsApp",
+  "description": "WhatsApp messaging service",
+  "enabled": false,
+  "priority": 80,
+  "networks": [
+    "31.13.64.0/18",
+    "31.13.24.0/21",
+    "31.13.64.0/19",
+    "31.13.96.0/19",
+    "157.240.0.0/16",
+    "173.252.64.0/18",
+    "179.60.192.0/22",
+    "18.194.0.0/15",
+    "34.224.0.0/12"
+  ],
+  "domains": [
+    "whatsapp.com",
+    "whatsapp.net",
+    "wa.me"
+  ],
+  "schema_version": 2
 }
 EOF
 echo "  ✅ Installed: whatsapp"
@@ -194,29 +174,28 @@ echo "  ✅ Installed: whatsapp"
 # YouTube Music service
 cat > ~/.vpn-route-manager/config/services/youtube-music.json << 'EOF'
 {
-  "youtube-music": {
-    "name": "YouTube Music",
-    "description": "YouTube Music streaming service",
-    "enabled": false,
-    "priority": 85,
-    "networks": [
-      "172.217.0.0/16",
-      "142.250.0.0/15",
-      "216.58.192.0/19",
-      "74.125.0.0/16",
-      "64.233.160.0/19",
-      "66.249.80.0/20",
-      "72.14.192.0/18",
-      "209.85.128.0/17",
-      "34.64.0.0/10",
-      "35.184.0.0/13"
-    ],
-    "domains": [
-      "music.youtube.com",
-      "youtubei.googleapis.com",
-      "youtube.com"
-    ]
-  }
+  "name": "YouTube Music",
+  "description": "YouTube Music streaming service",
+  "enabled": false,
+  "priority": 85,
+  "networks": [
+    "172.217.0.0/16",
+    "142.250.0.0/15",
+    "216.58.192.0/19",
+    "74.125.0.0/16",
+    "64.233.160.0/19",
+    "66.249.80.0/20",
+    "72.14.192.0/18",
+    "209.85.128.0/17",
+    "34.64.0.0/10",
+    "35.184.0.0/13"
+  ],
+  "domains": [
+    "music.youtube.com",
+    "youtubei.googleapis.com",
+    "youtube.com"
+  ],
+  "schema_version": 2
 }
 EOF
 echo "  ✅ Installed: youtube-music"
@@ -224,30 +203,29 @@ echo "  ✅ Installed: youtube-music"
 # Spotify service
 cat > ~/.vpn-route-manager/config/services/spotify.json << 'EOF'
 {
-  "spotify": {
-    "name": "Spotify",
-    "description": "Spotify music streaming service",
-    "enabled": false,
-    "priority": 75,
-    "networks": [
-      "78.31.8.0/21",
-      "193.182.8.0/21",
-      "194.68.28.0/22",
-      "34.64.0.0/10",
-      "35.184.0.0/13",
-      "35.192.0.0/14",
-      "35.196.0.0/15",
-      "104.154.0.0/15",
-      "104.196.0.0/14",
-      "104.199.64.0/18",
-      "35.186.224.0/20"
-    ],
-    "domains": [
-      "spotify.com",
-      "spclient.wg.spotify.com",
-      "audio-ak-spotify-com.akamaized.net"
-    ]
-  }
+  "name": "Spotify",
+  "description": "Spotify music streaming service",
+  "enabled": false,
+  "priority": 75,
+  "networks": [
+    "78.31.8.0/21",
+    "193.182.8.0/21",
+    "194.68.28.0/22",
+    "34.64.0.0/10",
+    "35.184.0.0/13",
+    "35.192.0.0/14",
+    "35.196.0.0/15",
+    "104.154.0.0/15",
+    "104.196.0.0/14",
+    "104.199.64.0/18",
+    "35.186.224.0/20"
+  ],
+  "domains": [
+    "spotify.com",
+    "spclient.wg.spotify.com",
+    "audio-ak-spotify-com.akamaized.net"
+  ],
+  "schema_version": 2
 }
 EOF
 echo "  ✅ Installed: spotify"
@@ -255,28 +233,27 @@ echo "  ✅ Installed: spotify"
 # Apple Music service
 cat > ~/.vpn-route-manager/config/services/apple-music.json << 'EOF'
 {
-  "apple-music": {
-    "name": "Apple Music",
-    "description": "Apple Music streaming service",
-    "enabled": false,
-    "priority": 70,
-    "networks": [
-      "17.0.0.0/8",
-      "139.178.128.0/17",
-      "144.178.0.0/18",
-      "63.92.224.0/19",
-      "198.183.16.0/20",
-      "65.199.22.0/23",
-      "192.35.50.0/24",
-      "204.79.190.0/24"
-    ],
-    "domains": [
-      "music.apple.com",
-      "itunes.apple.com",
-      "audio-ssl.itunes.apple.com",
-      "streamingaudio.itunes.apple.com"
-    ]
-  }
+  "name": "Apple Music",
+  "description": "Apple Music streaming service",
+  "enabled": false,
+  "priority": 70,
+  "networks": [
+    "17.0.0.0/8",
+    "139.178.128.0/17",
+    "144.178.0.0/18",
+    "63.92.224.0/19",
+    "198.183.16.0/20",
+    "65.199.22.0/23",
+    "192.35.50.0/24",
+    "204.79.190.0/24"
+  ],
+  "domains": [
+    "music.apple.com",
+    "itunes.apple.com",
+    "audio-ssl.itunes.apple.com",
+    "streamingaudio.itunes.apple.com"
+  ],
+  "schema_version": 2
 }
 EOF
 echo "  ✅ Installed: apple-music"
@@ -284,35 +261,34 @@ echo "  ✅ Installed: apple-music"
 # Facebook service
 cat > ~/.vpn-route-manager/config/services/facebook.json << 'EOF'
 {
-  "facebook": {
-    "name": "Facebook",
-    "description": "Facebook social network",
-    "enabled": false,
-    "priority": 65,
-    "networks": [
-      "31.13.24.0/21",
-      "31.13.64.0/18",
-      "45.64.40.0/22",
-      "66.220.0.0/16",
-      "69.63.176.0/20",
-      "69.171.0.0/16",
-      "74.119.76.0/22",
-      "102.132.96.0/20",
-      "103.4.96.0/22",
-      "129.134.0.0/16",
-      "157.240.0.0/16",
-      "173.252.64.0/18",
-      "179.60.192.0/22",
-      "185.60.216.0/22",
-      "204.15.20.0/22"
-    ],
-    "domains": [
-      "facebook.com",
-      "fb.com",
-      "fbcdn.net",
-      "facebook.net"
-    ]
-  }
+  "name": "Facebook",
+  "description": "Facebook social network",
+  "enabled": false,
+  "priority": 65,
+  "networks": [
+    "31.13.24.0/21",
+    "31.13.64.0/18",
+    "45.64.40.0/22",
+    "66.220.0.0/16",
+    "69.63.176.0/20",
+    "69.171.0.0/16",
+    "74.119.76.0/22",
+    "102.132.96.0/20",
+    "103.4.96.0/22",
+    "129.134.0.0/16",
+    "157.240.0.0/16",
+    "173.252.64.0/18",
+    "179.60.192.0/22",
+    "185.60.216.0/22",
+    "204.15.20.0/22"
+  ],
+  "domains": [
+    "facebook.com",
+    "fb.com",
+    "fbcdn.net",
+    "facebook.net"
+  ],
+  "schema_version": 2
 }
 EOF
 echo "  ✅ Installed: facebook"
@@ -320,34 +296,33 @@ echo "  ✅ Installed: facebook"
 # Instagram service
 cat > ~/.vpn-route-manager/config/services/instagram.json << 'EOF'
 {
-  "instagram": {
-    "name": "Instagram",
-    "description": "Instagram social network",
-    "enabled": false,
-    "priority": 60,
-    "networks": [
-      "31.13.24.0/21",
-      "31.13.64.0/18",
-      "45.64.40.0/22",
-      "66.220.0.0/16",
-      "69.63.176.0/20",
-      "69.171.0.0/16",
-      "74.119.76.0/22",
-      "102.132.96.0/20",
-      "103.4.96.0/22",
-      "129.134.0.0/16",
-      "157.240.0.0/16",
-      "173.252.64.0/18",
-      "179.60.192.0/22",
-      "185.60.216.0/22",
-      "204.15.20.0/22"
-    ],
-    "domains": [
-      "instagram.com",
-      "cdninstagram.com",
-      "instagramstatic-a.akamaihd.net"
-    ]
-  }
+  "name": "Instagram",
+  "description": "Instagram social network",
+  "enabled": false,
+  "priority": 60,
+  "networks": [
+    "31.13.24.0/21",
+    "31.13.64.0/18",
+    "45.64.40.0/22",
+    "66.220.0.0/16",
+    "69.63.176.0/20",
+    "69.171.0.0/16",
+    "74.119.76.0/22",
+    "102.132.96.0/20",
+    "103.4.96.0/22",
+    "129.134.0.0/16",
+    "157.240.0.0/16",
+    "173.252.64.0/18",
+    "179.60.192.0/22",
+    "185.60.216.0/22",
+    "204.15.20.0/22"
+  ],
+  "domains": [
+    "instagram.com",
+    "cdninstagram.com",
+    "instagramstatic-a.akamaihd.net"
+  ],
+  "schema_version": 2
 }
 EOF
 echo "  ✅ Installed: instagram"
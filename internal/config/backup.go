@@ -0,0 +1,234 @@
+package config
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// stateFiles are the files of the state directory kept in backups; the
+// rest is rebuilt by the daemon
+var stateFiles = []string{"state.json", "pause.json"}
+
+// BackupDir returns where automatic backups are kept
+func BackupDir() string {
+	return filepath.Join(StateDir(), "backups")
+}
+
+// Backup writes a timestamped backup of the configuration to dir and
+// returns its path
+func Backup(dir, configPath string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	// Several backups may be taken in the same second
+	stamp := time.Now().Format("20060102-150405")
+	path := filepath.Join(dir, fmt.Sprintf("vpn-route-manager-%s.tar.gz", stamp))
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		path = filepath.Join(dir, fmt.Sprintf("vpn-route-manager-%s-%d.tar.gz", stamp, i))
+	}
+	if _, err := CreateBackup(path, configPath); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// CreateBackup writes a gzipped tarball of the config file, the service
+// files and the daemon's state metadata to path, returning the archived
+// names. Entries are stored as config.json, services/<name>.json and
+// state/<file>.
+func CreateBackup(path, configPath string) ([]string, error) {
+	sources := map[string]string{"config.json": configPath}
+	names := []string{"config.json"}
+
+	servicesDir := filepath.Join(ConfigDir(), "services")
+	entries, err := os.ReadDir(servicesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read services directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		name := "services/" + entry.Name()
+		sources[name] = filepath.Join(servicesDir, entry.Name())
+		names = append(names, name)
+	}
+
+	for _, file := range stateFiles {
+		name := "state/" + file
+		sources[name] = filepath.Join(StateDir(), file)
+		names = append(names, name)
+	}
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup: %w", err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	var archived []string
+	for _, name := range names {
+		data, err := os.ReadFile(sources[name])
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", sources[name], err)
+		}
+
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("failed to write backup: %w", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to write backup: %w", err)
+		}
+		archived = append(archived, name)
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write backup: %w", err)
+	}
+	return archived, out.Close()
+}
+
+// RestoreBackup replaces the config file, the service files and the state
+// metadata with the contents of a backup made by CreateBackup. Service
+// files missing from the backup are removed. It returns the restored
+// names.
+func RestoreBackup(archive, configPath string) ([]string, error) {
+	files, err := readBackup(archive)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s holds no configuration", archive)
+	}
+
+	unlock, err := lockConfig()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	servicesDir := filepath.Join(ConfigDir(), "services")
+	if err := os.MkdirAll(servicesDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create services directory: %w", err)
+	}
+	if err := os.MkdirAll(StateDir(), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Drop the service files the backup doesn't have
+	entries, err := os.ReadDir(servicesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read services directory: %w", err)
+	}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		if _, ok := files["services/"+entry.Name()]; !ok {
+			if err := os.Remove(filepath.Join(servicesDir, entry.Name())); err != nil {
+				return nil, fmt.Errorf("failed to remove %s: %w", entry.Name(), err)
+			}
+		}
+	}
+
+	var restored []string
+	for name, data := range files {
+		target := configPath
+		if dir, file := path.Split(name); dir == "services/" {
+			target = filepath.Join(servicesDir, file)
+		} else if dir == "state/" {
+			target = filepath.Join(StateDir(), file)
+		}
+
+		if err := writeFileAtomic(target, data); err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+		restored = append(restored, name)
+	}
+
+	return restored, nil
+}
+
+// readBackup reads the entries of a backup, rejecting names CreateBackup
+// doesn't write so an archive can't place files elsewhere
+func readBackup(path string) (map[string][]byte, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup %s: %w", path, err)
+	}
+	tr := tar.NewReader(gz)
+
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup %s: %w", path, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if !validBackupEntry(header.Name) {
+			return nil, fmt.Errorf("unexpected file %s in backup", header.Name)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup %s: %w", path, err)
+		}
+		files[header.Name] = data
+	}
+
+	return files, nil
+}
+
+// validBackupEntry checks if a name is one CreateBackup writes
+func validBackupEntry(name string) bool {
+	if name == "config.json" {
+		return true
+	}
+	dir, file := path.Split(name)
+	if file == "" || strings.HasPrefix(file, ".") || path.Ext(file) != ".json" {
+		return false
+	}
+	if dir == "services/" {
+		return ValidateServiceKey(strings.TrimSuffix(file, ".json")) == nil
+	}
+	if dir == "state/" {
+		for _, allowed := range stateFiles {
+			if file == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
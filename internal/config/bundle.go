@@ -0,0 +1,141 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseServices parses service definitions in JSON or YAML. The data is
+// either services keyed by name, a bundle with those under a "services"
+// key, as in the main configuration, or a single service file of schema
+// version 2, which is keyed by name as service files are by their file
+// name.
+func ParseServices(data []byte, name string) (map[string]*Service, error) {
+	data, err := yamlToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var bundle struct {
+		Services map[string]*Service `json:"services"`
+	}
+	if err := json.Unmarshal(data, &bundle); err == nil && len(bundle.Services) > 0 {
+		return validateServiceList(bundle.Services)
+	}
+
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if json.Unmarshal(data, &header) == nil && header.SchemaVersion >= 2 {
+		if name == "" {
+			return nil, fmt.Errorf("a single service needs a name")
+		}
+		service, err := ParseService(data)
+		if err != nil {
+			return nil, err
+		}
+		return validateServiceList(map[string]*Service{name: service})
+	}
+
+	var services map[string]*Service
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, err
+	}
+	return validateServiceList(services)
+}
+
+// MarshalServices encodes services keyed by name as JSON or YAML. As a
+// bundle they are wrapped in a "services" key.
+func MarshalServices(services map[string]*Service, bundle, asYAML bool) ([]byte, error) {
+	var value interface{} = services
+	if bundle {
+		value = map[string]interface{}{"services": services}
+	}
+
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if !asYAML {
+		return append(data, '\n'), nil
+	}
+	return JSONToYAML(data)
+}
+
+// JSONToYAML converts JSON to block-style YAML, keeping the key order
+func JSONToYAML(data []byte) ([]byte, error) {
+	// JSON is valid YAML; decoding it into a node keeps the field order
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	clearStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlToJSON converts YAML to JSON so it can be decoded with the json tags
+// of the configuration types. JSON input passes through unchanged.
+func yamlToJSON(data []byte) ([]byte, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return data, nil
+	}
+
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	return json.Marshal(value)
+}
+
+// clearStyle switches a node tree from the flow style of JSON to block style
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// AddService adds a new service and writes its file
+func (m *Manager) AddService(name string, service *Service) error {
+	if err := ValidateServiceKey(name); err != nil {
+		return err
+	}
+	if _, exists := m.config.Services[name]; exists {
+		return fmt.Errorf("service '%s' already exists", name)
+	}
+	if err := ValidateService(name, service); err != nil {
+		return fmt.Errorf("service '%s': %w", name, err)
+	}
+
+	if m.config.Services == nil {
+		m.config.Services = make(map[string]*Service)
+	}
+	m.config.Services[name] = service
+
+	if err := m.saveServiceFile(name, service); err != nil {
+		return fmt.Errorf("failed to write service file: %w", err)
+	}
+
+	return nil
+}
+
+// ServiceNames returns the names of services sorted alphabetically
+func ServiceNames(services map[string]*Service) []string {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed catalog/catalog.json
+var embeddedCatalog []byte
+
+// builtinTemplateVersion is the catalog version of the built-in services
+const builtinTemplateVersion = "1.0.0"
+
+// CatalogTemplate is a curated service definition that can be installed
+// by its ID
+type CatalogTemplate struct {
+	ID      string   `json:"id"`
+	Version string   `json:"version"`
+	Service *Service `json:"service"`
+}
+
+// catalogIndex is the layout of the embedded and remote catalogs
+type catalogIndex struct {
+	Templates []CatalogTemplate `json:"templates"`
+}
+
+// LoadCatalog returns the service catalog sorted by ID: the built-in
+// services, the embedded templates and, when remoteURL is set, the
+// templates of a remote index. A template from a later source replaces
+// one with the same ID if its version is newer.
+func LoadCatalog(remoteURL string) ([]CatalogTemplate, error) {
+	templates := make(map[string]CatalogTemplate)
+	merge := func(entries []CatalogTemplate) {
+		for _, entry := range entries {
+			if existing, exists := templates[entry.ID]; exists && CompareVersions(entry.Version, existing.Version) <= 0 {
+				continue
+			}
+			templates[entry.ID] = entry
+		}
+	}
+
+	var builtin []CatalogTemplate
+	for id, service := range GetDefaultServiceConfigs() {
+		builtin = append(builtin, CatalogTemplate{ID: id, Version: builtinTemplateVersion, Service: service})
+	}
+	merge(builtin)
+
+	var embedded catalogIndex
+	if err := json.Unmarshal(embeddedCatalog, &embedded); err != nil {
+		return nil, fmt.Errorf("invalid embedded catalog: %w", err)
+	}
+	merge(embedded.Templates)
+
+	var remoteErr error
+	if remoteURL != "" {
+		remote, err := fetchCatalog(remoteURL)
+		if err != nil {
+			remoteErr = fmt.Errorf("failed to load remote catalog: %w", err)
+		} else {
+			merge(remote.Templates)
+		}
+	}
+
+	result := make([]CatalogTemplate, 0, len(templates))
+	for _, template := range templates {
+		result = append(result, template)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, remoteErr
+}
+
+// fetchCatalog downloads a remote catalog index and validates its templates
+func fetchCatalog(url string) (*catalogIndex, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var index catalogIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+
+	var valid []CatalogTemplate
+	for _, template := range index.Templates {
+		if ValidateServiceKey(template.ID) != nil || ValidateService(template.ID, template.Service) != nil {
+			continue
+		}
+		valid = append(valid, template)
+	}
+	index.Templates = valid
+
+	return &index, nil
+}
+
+// CompareVersions compares dotted numeric versions, returning -1, 0 or 1
+func CompareVersions(a, b string) int {
+	partsA := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	partsB := strings.Split(strings.TrimPrefix(b, "v"), ".")
+
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var x, y int
+		if i < len(partsA) {
+			x, _ = strconv.Atoi(partsA[i])
+		}
+		if i < len(partsB) {
+			y, _ = strconv.Atoi(partsB[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -3,35 +3,324 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"vpn-route-manager/internal/system"
 )
 
 // Config represents the main configuration structure
 type Config struct {
-	Gateway       string              `json:"gateway"`
-	CheckInterval int                 `json:"check_interval"`
-	LogDir        string              `json:"log_dir"`
-	StateDir      string              `json:"state_dir"`
-	Services      map[string]*Service `json:"services"`
-	AutoStart     bool                `json:"auto_start"`
-	Debug         bool                `json:"debug"`
+	SchemaVersion     int                    `json:"schema_version"`
+	Gateway           string                 `json:"gateway"`
+	CheckInterval     int                    `json:"check_interval"`
+	LogDir            string                 `json:"log_dir"`
+	StateDir          string                 `json:"state_dir"`
+	Services          map[string]*Service    `json:"services"`
+	AutoStart         bool                   `json:"auto_start"`
+	Debug             bool                   `json:"debug"`
+	Detection         DetectionConfig        `json:"detection"`
+	DisconnectGrace   int                    `json:"disconnect_grace"`
+	Interfaces        []string               `json:"interfaces,omitempty"`
+	StrictGateway     bool                   `json:"strict_gateway"`
+	ConnectivityCheck string                 `json:"connectivity_check"`
+	Profiles          []NetworkProfile       `json:"profiles,omitempty"`
+	DomainResolution  DomainResolutionConfig `json:"domain_resolution"`
+	DNSProxy          DNSProxyConfig         `json:"dns_proxy"`
+	SplitDNS          SplitDNSConfig         `json:"split_dns"`
+	ASNSync           ASNSyncConfig          `json:"asn_sync"`
+	NetworkUpdates    NetworkUpdatesConfig   `json:"network_updates"`
+	Subscriptions     []Subscription         `json:"subscriptions,omitempty"`
+	CatalogURL        string                 `json:"catalog_url,omitempty"`
+	HealthChecks      HealthCheckConfig      `json:"health_checks"`
+	RouteLimits       RouteLimitsConfig      `json:"route_limits"`
+	ControlGroup      string                 `json:"control_group,omitempty"`
+	Launchd           LaunchdConfig          `json:"launchd"`
+	Logging           LoggingConfig          `json:"logging"`
+}
+
+// Conditions under which launchd restarts the daemon
+const (
+	KeepAliveAlways    = "always"
+	KeepAliveOnFailure = "on-failure"
+	KeepAliveCrashed   = "crashed"
+	KeepAliveNever     = "never"
+)
+
+// LaunchdConfig controls the launchd job install and upgrade write. Label
+// replaces com.<user>.vpn.route.manager when set. KeepAlive is when the
+// daemon is restarted after exiting: always, on-failure (a non-zero exit),
+// crashed (killed by a signal) or never. ThrottleInterval is the least
+// number of seconds between starts and Nice the daemon's scheduling
+// priority. OnDemand has launchd create the control socket of the system
+// daemon running as root and start the daemon when the CLI first connects
+// rather than at boot; keep_alive always then only restarts it on failure.
+type LaunchdConfig struct {
+	Label            string `json:"label,omitempty"`
+	KeepAlive        string `json:"keep_alive"`
+	ThrottleInterval int    `json:"throttle_interval"`
+	Nice             int    `json:"nice"`
+	OnDemand         bool   `json:"on_demand,omitempty"`
+}
+
+// Formats of the daemon log file
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Destinations of log entries
+const (
+	LogOutputFile   = "file"
+	LogOutputSyslog = "syslog"
+)
+
+// LoggingConfig controls where log entries go and how. Format is that of
+// the log file: text, one "time [LEVEL] message" line per entry, or json,
+// one object per entry with the level, ts, component, service, network and
+// gateway as fields, for log collectors to ingest without parsing the
+// message. Outputs are the destinations, the file, syslog or both; on
+// macOS syslog messages end up in the unified log shown by Console.app.
+//
+// The file is rotated once it reaches MaxSizeMB and with Daily also at the
+// first entry of each day. Rotated files are gzipped with Compress. At most
+// MaxBackups of them are kept, none older than MaxAgeDays, and no more
+// than fit in MaxTotalSizeMB along with the current file; 0 is no limit.
+type LoggingConfig struct {
+	Format         string   `json:"format"`
+	Outputs        []string `json:"outputs"`
+	MaxSizeMB      int      `json:"max_size_mb"`
+	MaxBackups     int      `json:"max_backups"`
+	Daily          bool     `json:"daily"`
+	Compress       bool     `json:"compress"`
+	MaxAgeDays     int      `json:"max_age_days"`
+	MaxTotalSizeMB int      `json:"max_total_size_mb"`
+}
+
+// HasOutput reports whether entries go to output
+func (l LoggingConfig) HasOutput(output string) bool {
+	for _, o := range l.Outputs {
+		if o == output {
+			return true
+		}
+	}
+	return false
+}
+
+// HealthCheckConfig controls probing the health check targets of active
+// services through their bypass routes. Interval and Timeout are in
+// seconds.
+type HealthCheckConfig struct {
+	Interval int `json:"interval"`
+	Timeout  int `json:"timeout"`
+}
+
+// NetworkUpdatesConfig controls refreshing service networks from the IP
+// range lists their operators publish. Interval is in hours. When Enabled
+// the daemon refreshes periodically, otherwise only
+// `service update-networks` does.
+type NetworkUpdatesConfig struct {
+	Enabled  bool `json:"enabled"`
+	Interval int  `json:"interval"`
+}
+
+// ASNSyncConfig controls regenerating the networks of services that list
+// AS numbers from the prefixes those ASes announce. Source is the base URL
+// of a RIPEstat-compatible API; Interval is in hours. When Enabled the
+// daemon syncs periodically, otherwise only `service sync-asn` does.
+type ASNSyncConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Interval int    `json:"interval"`
+	Source   string `json:"source,omitempty"`
+}
+
+// SplitDNSConfig controls /etc/resolver entries for the domains of bypassed
+// services, so they are resolved by a non-VPN nameserver instead of the
+// corporate resolver. The DNS proxy is used when it is enabled, otherwise
+// Nameserver, falling back to the nameserver handed out by DHCP.
+type SplitDNSConfig struct {
+	Enabled    bool   `json:"enabled"`
+	Nameserver string `json:"nameserver,omitempty"`
+}
+
+// DNSProxyConfig controls the optional local DNS forwarder. Queries for
+// service domains get host routes installed for their answers before the
+// response is returned, giving domain-based bypass. Upstream defaults to
+// the nameserver handed out by DHCP on the physical network.
+type DNSProxyConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Listen   string `json:"listen"`
+	Upstream string `json:"upstream,omitempty"`
+}
+
+// DomainResolutionConfig controls periodic re-resolution of service
+// domains. A host route is added for every address a domain resolves to
+// and removed once the answer's TTL, clamped to MinTTL..MaxTTL seconds,
+// runs out without the address being returned again. Resolver selects
+// the server used: an IP address with optional port for plain DNS, an
+// https:// URL for DNS over HTTPS or tls://host[:port] for DNS over TLS.
+// When empty the local network's nameserver is used.
+type DomainResolutionConfig struct {
+	Enabled  bool   `json:"enabled"`
+	MinTTL   int    `json:"min_ttl"`
+	MaxTTL   int    `json:"max_ttl"`
+	Resolver string `json:"resolver,omitempty"`
+}
+
+// NetworkProfile changes which services bypass the VPN while on a given
+// network, matched by Wi-Fi SSID or by a local address inside one of the
+// subnets. Services lists the services to route ("*" for all); when empty
+// the services' own enabled flags apply. DisableBypass turns bypass off.
+type NetworkProfile struct {
+	Name          string   `json:"name"`
+	SSIDs         []string `json:"ssids,omitempty"`
+	Subnets       []string `json:"subnets,omitempty"`
+	Services      []string `json:"services,omitempty"`
+	DisableBypass bool     `json:"disable_bypass,omitempty"`
+}
+
+// Matches checks if the profile applies to a network with the given SSID
+// and local addresses
+func (p *NetworkProfile) Matches(ssid string, addresses []string) bool {
+	for _, candidate := range p.SSIDs {
+		if ssid != "" && candidate == ssid {
+			return true
+		}
+	}
+
+	for _, subnet := range p.Subnets {
+		_, network, err := net.ParseCIDR(subnet)
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			if ip := net.ParseIP(address); ip != nil && network.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// DetectionConfig controls how VPN connections are detected. Each signal
+// contributes its weight to a confidence score when it fires, and the VPN
+// is considered connected once the score reaches the threshold. A state
+// change is only acted on after DebounceChecks consecutive checks agree.
+// SkipSplitTunnel leaves split-tunnel VPNs alone, since traffic for the
+// bypassed services never enters their tunnel anyway. DNSServers and
+// DNSDomains list the corporate resolvers and search domains a VPN pushes.
+// Command is an optional shell command run on every check whose result is
+// used as an extra signal, for VPN clients the built-in checks miss; when
+// it exits with status 1 no VPN is considered connected, whatever the
+// other signals say.
+// Detectors lists which detectors run and in what order; empty means all.
+type DetectionConfig struct {
+	Threshold       float64            `json:"threshold"`
+	Weights         map[string]float64 `json:"weights,omitempty"`
+	DebounceChecks  int                `json:"debounce_checks"`
+	Allowlist       []VPNMatch         `json:"allowlist,omitempty"`
+	SkipSplitTunnel bool               `json:"skip_split_tunnel"`
+	DNSServers      []string           `json:"dns_servers,omitempty"`
+	DNSDomains      []string           `json:"dns_domains,omitempty"`
+	Command         string             `json:"command,omitempty"`
+	CommandTimeout  int                `json:"command_timeout,omitempty"`
+	Detectors       []string           `json:"detectors,omitempty"`
+}
+
+// VPNMatch identifies a VPN by server address, tunnel interface or client
+// product. When an allowlist is configured, routes are only managed for
+// VPNs matching one of its entries.
+type VPNMatch struct {
+	Server    string `json:"server,omitempty"`
+	Interface string `json:"interface,omitempty"`
+	Product   string `json:"product,omitempty"`
 }
 
-// Service represents a service that can bypass VPN
+// Service represents a service that can bypass VPN. Interface optionally
+// binds the service's routes to a specific interface (e.g. en7 or
+// bridge100) instead of the default physical gateway. When ASNs are set
+// (e.g. AS62041) Networks is regenerated from their announced prefixes.
+// NetworksURL points at a published IP range list to refresh Networks
+// from, overriding the built-in source for the service. Version is the
+// catalog template version the service was installed from. Extends names
+// another service whose networks are routed for this one as well. When
+// Schedule is set the service is only routed inside one of its windows.
+// Probe is a host:port reached over TCP to check the bypass works.
+// MaxRoutes overrides route_limits.max_service_routes for the service.
+// EnabledUntil is set for services enabled temporarily, which the daemon
+// disables again once it has passed.
 type Service struct {
-	Name        string   `json:"name"`
-	Enabled     bool     `json:"enabled"`
-	Networks    []string `json:"networks"`
-	Domains     []string `json:"domains,omitempty"`
-	Priority    int      `json:"priority"`
-	Description string   `json:"description"`
+	Name          string         `json:"name"`
+	Enabled       bool           `json:"enabled"`
+	Networks      []string       `json:"networks"`
+	Domains       []string       `json:"domains,omitempty"`
+	ASNs          []string       `json:"asns,omitempty"`
+	Extends       string         `json:"extends,omitempty"`
+	Schedule      []ScheduleRule `json:"schedule,omitempty"`
+	Probe         string         `json:"probe,omitempty"`
+	MaxRoutes     int            `json:"max_routes,omitempty"`
+	EnabledUntil  *time.Time     `json:"enabled_until,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+	NetworksURL   string         `json:"networks_url,omitempty"`
+	Priority      int            `json:"priority"`
+	Description   string         `json:"description"`
+	Interface     string         `json:"interface,omitempty"`
+	Version       string         `json:"version,omitempty"`
+	SchemaVersion int            `json:"schema_version,omitempty"`
+
+	subscription string
+}
+
+// HasTag checks if the service is tagged with tag
+func (s *Service) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired checks if a temporarily enabled service has run out of time
+func (s *Service) Expired(now time.Time) bool {
+	return s.Enabled && s.EnabledUntil != nil && !now.Before(*s.EnabledUntil)
+}
+
+// Subscription returns the name of the subscription the service came
+// from, or "" for a locally defined service
+func (s *Service) Subscription() string {
+	return s.subscription
+}
+
+// NetworksSource returns the URL of the published IP range list for a
+// service, or "" when there is none
+func NetworksSource(name string, service *Service) string {
+	if service.NetworksURL != "" {
+		return service.NetworksURL
+	}
+	return GetDefaultNetworkSources()[name]
 }
 
 // Manager handles configuration loading and saving
 type Manager struct {
-	configPath string
-	config     *Config
+	configPath  string
+	config      *Config
+	file        *Config
+	overridden  []appliedOverride
+	systemPath  string
+	base        interface{}
+	user        interface{}
+	mandatory   map[string]interface{}
+	neverBypass []*net.IPNet
+
+	fragments        []string
+	belowFragments   interface{}
+	appliedFragments interface{}
 }
 
 // NewManager creates a new configuration manager
@@ -42,19 +331,38 @@ func NewManager(configPath string) *Manager {
 	}
 }
 
-// Load reads configuration from file
+// Load reads configuration from file, on top of the system configuration
+// when there is one, and merges the config.d fragments into it
 func (m *Manager) Load() error {
+	// The user's settings are layered on the system configuration
+	if err := m.loadSystemConfig(); err != nil {
+		return err
+	}
+
 	data, err := os.ReadFile(m.configPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			// Use default config if file doesn't exist
-			return nil
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+		// Use default config if file doesn't exist
+	} else if err := json.Unmarshal(data, &m.config); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	} else if m.base != nil {
+		if err := json.Unmarshal(data, &m.user); err != nil {
+			return fmt.Errorf("failed to parse config file: %w", err)
 		}
-		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	if err := json.Unmarshal(data, &m.config); err != nil {
-		return fmt.Errorf("failed to parse config file: %w", err)
+	if err := m.applyFragments(); err != nil {
+		return err
+	}
+
+	if err := m.applyEnvOverrides(); err != nil {
+		return err
+	}
+
+	if err := m.applyMandatory(); err != nil {
+		return err
 	}
 
 	return m.Validate()
@@ -68,12 +376,38 @@ func (m *Manager) Save() error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(m.config, "", "  ")
+	// Environment overrides are not persisted
+	cfg := *m.config
+	m.restoreFileValues(&cfg)
+
+	// Subscribed services live in the subscription caches
+	cfg.Services = make(map[string]*Service)
+	for name, service := range m.config.Services {
+		if service.subscription == "" {
+			cfg.Services[name] = service
+		}
+	}
+
+	// Settings from config.d stay in their fragments
+	if m.fragments != nil {
+		restored, err := m.withoutFragments(&cfg)
+		if err != nil {
+			return err
+		}
+		cfg = *restored
+	}
+
+	data, err := json.MarshalIndent(&cfg, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
+	if m.base != nil {
+		if data, err = m.layeredData(&cfg); err != nil {
+			return err
+		}
+	}
 
-	if err := os.WriteFile(m.configPath, data, 0644); err != nil {
+	if err := WriteFile(m.configPath, data); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -94,6 +428,19 @@ func (m *Manager) Set(config *Config) error {
 	return nil
 }
 
+// ParseConfig parses and validates the contents of a config file. Fields
+// missing from the file keep their defaults, as in Load.
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := GetDefaultConfig()
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 // Validate checks if the current configuration is valid
 func (m *Manager) Validate() error {
 	return ValidateConfig(m.config)
@@ -131,7 +478,7 @@ func (m *Manager) LoadServices(servicesDir string) error {
 		m.config.Services[key] = service
 	}
 
-	return nil
+	return m.applyMandatory()
 }
 
 // LoadServiceFile loads a single service configuration file
@@ -141,7 +488,25 @@ func LoadServiceFile(path string) (*Service, error) {
 		return nil, fmt.Errorf("failed to read service file: %w", err)
 	}
 
-	// Support both direct service format and wrapped format
+	return ParseService(data)
+}
+
+// ParseService parses the contents of a service file in the current or
+// an older format
+func ParseService(data []byte) (*Service, error) {
+	// Version 2 files hold the service itself and say so
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if json.Unmarshal(data, &header) == nil && header.SchemaVersion >= 2 {
+		var service Service
+		if err := json.Unmarshal(data, &service); err != nil {
+			return nil, fmt.Errorf("failed to parse service file: %w", err)
+		}
+		return &service, nil
+	}
+
+	// Older files: support both direct service format and wrapped format
 	var wrapper map[string]*Service
 	if err := json.Unmarshal(data, &wrapper); err != nil {
 		// Try direct service format
@@ -171,6 +536,44 @@ func (m *Manager) GetEnabledServices() map[string]*Service {
 	return enabled
 }
 
+// ServiceNetworks returns the networks routed for a service: its own plus
+// those inherited through extends, without duplicates or networks the
+// system configuration never bypasses
+func (m *Manager) ServiceNetworks(name string) []string {
+	var networks []string
+	seen := make(map[string]bool)
+	visited := make(map[string]bool)
+
+	for name != "" && !visited[name] {
+		visited[name] = true
+		service, exists := m.config.Services[name]
+		if !exists {
+			break
+		}
+		for _, network := range service.Networks {
+			if !seen[network] && m.Bypassable(network) {
+				seen[network] = true
+				networks = append(networks, network)
+			}
+		}
+		name = service.Extends
+	}
+
+	return networks
+}
+
+// ServicesWithTag returns the names of all services tagged with tag, sorted
+func (m *Manager) ServicesWithTag(tag string) []string {
+	var names []string
+	for name, service := range m.config.Services {
+		if service.HasTag(tag) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // EnableService enables a service by name
 func (m *Manager) EnableService(name string) error {
 	service, exists := m.config.Services[name]
@@ -178,6 +581,7 @@ func (m *Manager) EnableService(name string) error {
 		return fmt.Errorf("service '%s' not found", name)
 	}
 	service.Enabled = true
+	service.EnabledUntil = nil
 	
 	// Also update the service file
 	if err := m.saveServiceFile(name, service); err != nil {
@@ -194,6 +598,7 @@ func (m *Manager) DisableService(name string) error {
 		return fmt.Errorf("service '%s' not found", name)
 	}
 	service.Enabled = false
+	service.EnabledUntil = nil
 	
 	// Also update the service file
 	if err := m.saveServiceFile(name, service); err != nil {
@@ -203,25 +608,317 @@ func (m *Manager) DisableService(name string) error {
 	return nil
 }
 
-// saveServiceFile saves a service configuration to its individual file
+// ExpireService disables a temporarily enabled service whose time has run
+// out, updating only its file, which takes precedence over config.json.
+// The file is read again under the config lock, so changes other
+// processes made since this configuration was loaded are kept; when the
+// service was enabled again or disabled there, that is taken over
+// instead.
+func (m *Manager) ExpireService(name string, now time.Time) error {
+	service, exists := m.config.Services[name]
+	if !exists {
+		return fmt.Errorf("service '%s' not found", name)
+	}
+	if service.subscription != "" {
+		service.Enabled = false
+		service.EnabledUntil = nil
+		return nil
+	}
+
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current := *service
+	if data, err := os.ReadFile(serviceFilePath(name)); err == nil {
+		onDisk, err := ParseService(data)
+		if err != nil {
+			return fmt.Errorf("failed to read service file: %w", err)
+		}
+		current = *onDisk
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read service file: %w", err)
+	}
+
+	if current.Expired(now) {
+		current.Enabled = false
+		current.EnabledUntil = nil
+		if err := writeServiceFile(name, &current); err != nil {
+			return fmt.Errorf("failed to update service file: %w", err)
+		}
+	}
+
+	service.Enabled = current.Enabled
+	service.EnabledUntil = current.EnabledUntil
+	return nil
+}
+
+// SetServicesEnabled enables or disables several services at once. The
+// service files are written to temporary files first and only then moved
+// into place, so failing to write any of them leaves every service
+// unchanged. When until is set the services are enabled until then.
+func (m *Manager) SetServicesEnabled(names []string, enabled bool, until *time.Time) error {
+	for _, name := range names {
+		service, exists := m.config.Services[name]
+		if !exists {
+			return fmt.Errorf("service '%s' not found", name)
+		}
+		if service.subscription != "" {
+			return fmt.Errorf("service '%s' is managed by subscription '%s'", name, service.subscription)
+		}
+		if key := "services." + name + ".enabled"; m.Locked(key) {
+			return m.lockedError(key)
+		}
+	}
+
+	// Validate and encode every change before any file is written
+	files := make([][]byte, len(names))
+	for i, name := range names {
+		updated := *m.config.Services[name]
+		updated.Enabled = enabled
+		updated.EnabledUntil = nil
+		if enabled {
+			updated.EnabledUntil = until
+		}
+		if err := ValidateService(name, &updated); err != nil {
+			return err
+		}
+
+		data, err := marshalServiceFile(&updated)
+		if err != nil {
+			return err
+		}
+		files[i] = data
+	}
+
+	if len(names) > 0 {
+		if err := os.MkdirAll(filepath.Dir(serviceFilePath(names[0])), 0755); err != nil {
+			return fmt.Errorf("failed to create services directory: %w", err)
+		}
+	}
+
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var written []string
+	cleanup := func() {
+		for _, path := range written {
+			os.Remove(path + ".tmp")
+		}
+	}
+
+	for i, name := range names {
+		path := serviceFilePath(name)
+		if err := system.WriteFile(path+".tmp", files[i], 0644); err != nil {
+			cleanup()
+			return fmt.Errorf("failed to write service file: %w", err)
+		}
+		written = append(written, path)
+	}
+
+	// Keep the current files, to put back if a later one can't be replaced
+	originals := make([][]byte, len(written))
+	for i, path := range written {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			cleanup()
+			return fmt.Errorf("failed to read service file: %w", err)
+		}
+		originals[i] = data
+	}
+
+	for i, path := range written {
+		if err := os.Rename(path+".tmp", path); err != nil {
+			for j := 0; j < i; j++ {
+				restoreServiceFile(written[j], originals[j])
+			}
+			cleanup()
+			return fmt.Errorf("failed to update service file: %w", err)
+		}
+	}
+
+	for _, name := range names {
+		service := m.config.Services[name]
+		service.Enabled = enabled
+		service.EnabledUntil = nil
+		if enabled {
+			service.EnabledUntil = until
+		}
+	}
+
+	return nil
+}
+
+// restoreServiceFile puts back the contents a service file had, removing
+// it when there was none
+func restoreServiceFile(path string, data []byte) {
+	if data == nil {
+		os.Remove(path)
+		return
+	}
+	system.WriteFile(path, data, 0644)
+}
+
+// AddServiceNetworks appends networks to a service and updates its file
+func (m *Manager) AddServiceNetworks(name string, networks []string) error {
+	service, exists := m.config.Services[name]
+	if !exists {
+		return fmt.Errorf("service '%s' not found", name)
+	}
+
+	// Validate a copy, so a failure leaves the service unchanged
+	updated := *service
+	updated.Networks = append(append([]string(nil), service.Networks...), networks...)
+	if err := ValidateService(name, &updated); err != nil {
+		return err
+	}
+
+	if err := m.saveServiceFile(name, &updated); err != nil {
+		return fmt.Errorf("failed to update service file: %w", err)
+	}
+
+	*service = updated
+	return nil
+}
+
+// UpdateService replaces a service's settings after validating them and
+// updates its file
+func (m *Manager) UpdateService(name string, service *Service) error {
+	existing, exists := m.config.Services[name]
+	if !exists {
+		return fmt.Errorf("service '%s' not found", name)
+	}
+
+	if err := ValidateService(name, service); err != nil {
+		return err
+	}
+
+	service.subscription = existing.subscription
+	m.config.Services[name] = service
+
+	if err := m.saveServiceFile(name, service); err != nil {
+		return fmt.Errorf("failed to update service file: %w", err)
+	}
+
+	return nil
+}
+
+// SetServiceNetworks replaces the networks of a service and updates its
+// file, or for a subscribed service only the loaded copy
+func (m *Manager) SetServiceNetworks(name string, networks []string) error {
+	service, exists := m.config.Services[name]
+	if !exists {
+		return fmt.Errorf("service '%s' not found", name)
+	}
+
+	// Validate a copy, so a failure leaves the service unchanged
+	updated := *service
+	updated.Networks = networks
+	if err := ValidateService(name, &updated); err != nil {
+		return err
+	}
+
+	// Subscribed services keep the new networks in memory only, until
+	// their subscription is loaded again
+	if updated.subscription == "" {
+		if err := m.saveServiceFile(name, &updated); err != nil {
+			return fmt.Errorf("failed to update service file: %w", err)
+		}
+	}
+
+	*service = updated
+	return nil
+}
+
+// RenameService moves a service to a new key, renaming its file
+func (m *Manager) RenameService(oldName, newName string) error {
+	service, exists := m.config.Services[oldName]
+	if !exists {
+		return fmt.Errorf("service '%s' not found", oldName)
+	}
+	if _, exists := m.config.Services[newName]; exists {
+		return fmt.Errorf("service '%s' already exists", newName)
+	}
+	if service.subscription != "" {
+		return fmt.Errorf("service '%s' comes from subscription '%s' and cannot be renamed", oldName, service.subscription)
+	}
+	if err := ValidateServiceKey(newName); err != nil {
+		return err
+	}
+
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := writeServiceFile(newName, service); err != nil {
+		return fmt.Errorf("failed to write service file: %w", err)
+	}
+	if err := os.Remove(serviceFilePath(oldName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove old service file: %w", err)
+	}
+
+	delete(m.config.Services, oldName)
+	m.config.Services[newName] = service
+	return nil
+}
+
+// serviceFilePath returns the path of a service's individual file
+func serviceFilePath(name string) string {
+	return filepath.Join(ConfigDir(), "services", name+".json")
+}
+
+// saveServiceFile saves a service configuration to its individual file.
+// Subscribed services live in the subscription caches and are refused: a
+// file would turn them into local services overriding the subscription.
 func (m *Manager) saveServiceFile(name string, service *Service) error {
-	homeDir, _ := os.UserHomeDir()
-	servicesDir := filepath.Join(homeDir, ".vpn-route-manager", "config", "services")
-	filePath := filepath.Join(servicesDir, name+".json")
-	
-	// Create the wrapped format that matches the original files
-	wrapper := map[string]*Service{
-		name: service,
+	if service.subscription != "" {
+		return fmt.Errorf("service '%s' is managed by subscription '%s'", name, service.subscription)
+	}
+
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return writeServiceFile(name, service)
+}
+
+// writeServiceFile atomically writes a service file. The caller holds the
+// config lock.
+func writeServiceFile(name string, service *Service) error {
+	filePath := serviceFilePath(name)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create services directory: %w", err)
 	}
 	
-	data, err := json.MarshalIndent(wrapper, "", "  ")
+	data, err := marshalServiceFile(service)
 	if err != nil {
-		return fmt.Errorf("failed to marshal service: %w", err)
+		return err
 	}
 	
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	if err := writeFileAtomic(filePath, data); err != nil {
 		return fmt.Errorf("failed to write service file: %w", err)
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+
+// marshalServiceFile encodes a service file: the service itself, stamped
+// with the current schema version. The file name is the service's key.
+func marshalServiceFile(service *Service) ([]byte, error) {
+	service.SchemaVersion = SchemaVersion
+
+	data, err := json.MarshalIndent(service, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal service: %w", err)
+	}
+	return data, nil
+}
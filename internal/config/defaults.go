@@ -1,22 +1,78 @@
 package config
 
-import (
-	"os"
-	"path/filepath"
-)
-
 // GetDefaultConfig returns the default configuration
 func GetDefaultConfig() *Config {
-	homeDir, _ := os.UserHomeDir()
-	
+	paths := DefaultPaths()
+
 	return &Config{
-		Gateway:       "auto",
-		CheckInterval: 5,
-		LogDir:        filepath.Join(homeDir, ".vpn-route-manager", "logs"),
-		StateDir:      filepath.Join(homeDir, ".vpn-route-manager", "state"),
-		Services:      make(map[string]*Service),
-		AutoStart:     true,
-		Debug:         false,
+		SchemaVersion:     SchemaVersion,
+		Gateway:           "auto",
+		CheckInterval:     5,
+		LogDir:            paths.Logs,
+		StateDir:          paths.State,
+		Services:          make(map[string]*Service),
+		AutoStart:         true,
+		Debug:             false,
+		DisconnectGrace:   30,
+		StrictGateway:     true,
+		ConnectivityCheck: "http://connectivitycheck.gstatic.com/generate_204",
+		Detection: DetectionConfig{
+			Threshold:      0.5,
+			DebounceChecks: 2,
+		},
+		DomainResolution: DomainResolutionConfig{
+			MinTTL: 60,
+			MaxTTL: 3600,
+		},
+		DNSProxy: DNSProxyConfig{
+			Listen: "127.0.0.1:5300",
+		},
+		ASNSync: ASNSyncConfig{
+			Interval: 24,
+			Source:   "https://stat.ripe.net",
+		},
+		NetworkUpdates: NetworkUpdatesConfig{
+			Interval: 24,
+		},
+		HealthChecks: HealthCheckConfig{
+			Interval: 60,
+			Timeout:  5,
+		},
+		RouteLimits: RouteLimitsConfig{
+			MaxRoutes:        10000,
+			MaxServiceRoutes: 2000,
+			Policy:           RouteLimitRefuse,
+		},
+		Launchd: LaunchdConfig{
+			KeepAlive:        KeepAliveAlways,
+			ThrottleInterval: 10,
+			Nice:             1,
+		},
+		Logging: LoggingConfig{
+			Format:     LogFormatText,
+			Outputs:    []string{LogOutputFile},
+			MaxSizeMB:  10,
+			MaxBackups: 5,
+			MaxAgeDays: 30,
+		},
+	}
+}
+
+// GetDefaultNetworkSources returns where the operators of the built-in
+// services publish their IP ranges. Services without a published list
+// use the prefixes announced by the operator's AS. YouTube has neither of
+// its own, as Google only publishes all of its ranges together, so it
+// keeps its built-in networks.
+func GetDefaultNetworkSources() map[string]string {
+	const ripeStat = "https://stat.ripe.net/data/announced-prefixes/data.json?resource="
+
+	return map[string]string{
+		"telegram":    "https://core.telegram.org/resources/cidr.txt",
+		"whatsapp":    ripeStat + "AS32934",
+		"facebook":    ripeStat + "AS32934",
+		"instagram":   ripeStat + "AS32934",
+		"spotify":     ripeStat + "AS8403",
+		"apple-music": ripeStat + "AS714",
 	}
 }
 
@@ -46,6 +102,7 @@ func GetDefaultServiceConfigs() map[string]*Service {
 				"web.telegram.org",
 				"api.telegram.org",
 			},
+			Tags: []string{"messaging"},
 		},
 		"youtube": {
 			Name:        "YouTube",
@@ -64,9 +121,10 @@ func GetDefaultServiceConfigs() map[string]*Service {
 			},
 			Domains: []string{
 				"youtube.com",
-				"googlevideo.com",
+				"*.googlevideo.com",
 				"google.com",
 			},
+			Tags: []string{"streaming", "video"},
 		},
 		"whatsapp": {
 			Name:        "WhatsApp",
@@ -89,6 +147,7 @@ func GetDefaultServiceConfigs() map[string]*Service {
 				"whatsapp.net",
 				"wa.me",
 			},
+			Tags: []string{"messaging"},
 		},
 		"spotify": {
 			Name:        "Spotify",
@@ -113,6 +172,7 @@ func GetDefaultServiceConfigs() map[string]*Service {
 				"spclient.wg.spotify.com",
 				"audio-ak-spotify-com.akamaized.net",
 			},
+			Tags: []string{"streaming", "music"},
 		},
 		"apple-music": {
 			Name:        "Apple Music",
@@ -135,6 +195,7 @@ func GetDefaultServiceConfigs() map[string]*Service {
 				"audio-ssl.itunes.apple.com",
 				"streamingaudio.itunes.apple.com",
 			},
+			Tags: []string{"streaming", "music"},
 		},
 		"facebook": {
 			Name:        "Facebook",
@@ -164,6 +225,7 @@ func GetDefaultServiceConfigs() map[string]*Service {
 				"fbcdn.net",
 				"facebook.net",
 			},
+			Tags: []string{"social"},
 		},
 		"instagram": {
 			Name:        "Instagram",
@@ -192,21 +254,15 @@ func GetDefaultServiceConfigs() map[string]*Service {
 				"cdninstagram.com",
 				"instagramstatic-a.akamaihd.net",
 			},
+			Tags: []string{"social"},
 		},
 		"youtube-music": {
 			Name:        "YouTube Music",
 			Description: "YouTube Music streaming service",
 			Enabled:     false,
 			Priority:    70,
+			Extends:     "youtube",
 			Networks: []string{
-				"172.217.0.0/16",
-				"142.250.0.0/15",
-				"216.58.192.0/19",
-				"74.125.0.0/16",
-				"64.233.160.0/19",
-				"66.249.80.0/20",
-				"72.14.192.0/18",
-				"209.85.128.0/17",
 				"34.64.0.0/10",
 				"35.184.0.0/13",
 			},
@@ -215,6 +271,7 @@ func GetDefaultServiceConfigs() map[string]*Service {
 				"youtubei.googleapis.com",
 				"youtube.com",
 			},
+			Tags: []string{"streaming", "music"},
 		},
 	}
 }
\ No newline at end of file
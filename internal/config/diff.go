@@ -0,0 +1,81 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Change is a setting that differs between two configurations. Old is nil
+// for a setting only in the new configuration and New is nil for one only
+// in the old. Key is the dotted path used by `config get`.
+type Change struct {
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// Diff lists the settings that differ between two configurations, by
+// their JSON encoding. Objects are compared field by field; lists and
+// other values as a whole.
+func Diff(old, new *Config) ([]Change, error) {
+	a, err := toJSONValue(old)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toJSONValue(new)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	diffValues("", a, b, &changes)
+	return changes, nil
+}
+
+// toJSONValue converts a value to its generic JSON form
+func toJSONValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return value, nil
+}
+
+// diffValues appends the differences between a and b below key
+func diffValues(key string, a, b interface{}, changes *[]Change) {
+	mapA, okA := a.(map[string]interface{})
+	mapB, okB := b.(map[string]interface{})
+	if !okA || !okB {
+		if !reflect.DeepEqual(a, b) {
+			*changes = append(*changes, Change{Key: key, Old: a, New: b})
+		}
+		return
+	}
+
+	keys := make(map[string]bool)
+	for k := range mapA {
+		keys[k] = true
+	}
+	for k := range mapB {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		child := k
+		if key != "" {
+			child = key + "." + k
+		}
+		diffValues(child, mapA[k], mapB[k], changes)
+	}
+}
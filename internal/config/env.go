@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Environment variables overriding the configuration, for the LaunchAgent
+// plist and for test runs. Overrides apply on load and are never written
+// back to the config file.
+const (
+	EnvConfigDir         = "VRM_CONFIG_DIR"
+	EnvGateway           = "VRM_GATEWAY"
+	EnvCheckInterval     = "VRM_CHECK_INTERVAL"
+	EnvDebug             = "VRM_DEBUG"
+	EnvLogDir            = "VRM_LOG_DIR"
+	EnvStateDir          = "VRM_STATE_DIR"
+	EnvDisconnectGrace   = "VRM_DISCONNECT_GRACE"
+	EnvConnectivityCheck = "VRM_CONNECTIVITY_CHECK"
+)
+
+// envOverride sets a config field from an environment variable. get
+// returns the field's value and restore copies it back from the config as
+// read from the file.
+type envOverride struct {
+	name    string
+	apply   func(cfg *Config, value string) error
+	get     func(cfg *Config) interface{}
+	restore func(cfg, file *Config)
+}
+
+// appliedOverride is an override in effect and the value it set
+type appliedOverride struct {
+	envOverride
+	value interface{}
+}
+
+var envOverrides = []envOverride{
+	{
+		name:    EnvGateway,
+		apply:   func(cfg *Config, value string) error { cfg.Gateway = value; return nil },
+		get:     func(cfg *Config) interface{} { return cfg.Gateway },
+		restore: func(cfg, file *Config) { cfg.Gateway = file.Gateway },
+	},
+	{
+		name: EnvCheckInterval,
+		apply: func(cfg *Config, value string) error {
+			n, err := strconv.Atoi(value)
+			cfg.CheckInterval = n
+			return err
+		},
+		get:     func(cfg *Config) interface{} { return cfg.CheckInterval },
+		restore: func(cfg, file *Config) { cfg.CheckInterval = file.CheckInterval },
+	},
+	{
+		name: EnvDebug,
+		apply: func(cfg *Config, value string) error {
+			b, err := strconv.ParseBool(value)
+			cfg.Debug = b
+			return err
+		},
+		get:     func(cfg *Config) interface{} { return cfg.Debug },
+		restore: func(cfg, file *Config) { cfg.Debug = file.Debug },
+	},
+	{
+		name:    EnvLogDir,
+		apply:   func(cfg *Config, value string) error { cfg.LogDir = value; return nil },
+		get:     func(cfg *Config) interface{} { return cfg.LogDir },
+		restore: func(cfg, file *Config) { cfg.LogDir = file.LogDir },
+	},
+	{
+		name:    EnvStateDir,
+		apply:   func(cfg *Config, value string) error { cfg.StateDir = value; return nil },
+		get:     func(cfg *Config) interface{} { return cfg.StateDir },
+		restore: func(cfg, file *Config) { cfg.StateDir = file.StateDir },
+	},
+	{
+		name: EnvDisconnectGrace,
+		apply: func(cfg *Config, value string) error {
+			n, err := strconv.Atoi(value)
+			cfg.DisconnectGrace = n
+			return err
+		},
+		get:     func(cfg *Config) interface{} { return cfg.DisconnectGrace },
+		restore: func(cfg, file *Config) { cfg.DisconnectGrace = file.DisconnectGrace },
+	},
+	{
+		name:    EnvConnectivityCheck,
+		apply:   func(cfg *Config, value string) error { cfg.ConnectivityCheck = value; return nil },
+		get:     func(cfg *Config) interface{} { return cfg.ConnectivityCheck },
+		restore: func(cfg, file *Config) { cfg.ConnectivityCheck = file.ConnectivityCheck },
+	},
+}
+
+// applyEnvOverrides applies the environment variables that are set,
+// keeping the values from the file so Save doesn't persist the overrides.
+// Fields changed after loading are saved as usual.
+func (m *Manager) applyEnvOverrides() error {
+	file := *m.config
+	m.file = &file
+	m.overridden = nil
+
+	for _, override := range envOverrides {
+		value := os.Getenv(override.name)
+		if value == "" {
+			continue
+		}
+		if err := override.apply(m.config, value); err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", override.name, value, err)
+		}
+		m.overridden = append(m.overridden, appliedOverride{override, override.get(m.config)})
+	}
+
+	return nil
+}
+
+// restoreFileValues puts the file's values back into cfg for every field
+// that still holds its override
+func (m *Manager) restoreFileValues(cfg *Config) {
+	for _, override := range m.overridden {
+		if override.get(cfg) == override.value {
+			override.restore(cfg, m.file)
+		}
+	}
+}
+
+// Overrides returns the names of the environment variables overriding
+// the loaded configuration
+func (m *Manager) Overrides() []string {
+	var names []string
+	for _, override := range m.overridden {
+		names = append(names, override.name)
+	}
+	return names
+}
+
+// ConfigDir returns the directory holding config.json and the service
+// files, unless VRM_CONFIG_DIR is set the one from DefaultPaths
+func ConfigDir() string {
+	if dir := os.Getenv(EnvConfigDir); dir != "" {
+		return dir
+	}
+	return DefaultPaths().Config
+}
+
+// LogDir returns the directory holding the log files, unless VRM_LOG_DIR
+// is set the one from DefaultPaths
+func LogDir() string {
+	if dir := os.Getenv(EnvLogDir); dir != "" {
+		return dir
+	}
+	return DefaultPaths().Logs
+}
+
+// StateDir returns the directory holding the daemon's state, unless
+// VRM_STATE_DIR is set the one from DefaultPaths
+func StateDir() string {
+	if dir := os.Getenv(EnvStateDir); dir != "" {
+		return dir
+	}
+	return DefaultPaths().State
+}
+
+// DebugFromEnv checks if VRM_DEBUG turns on debug logging
+func DebugFromEnv() bool {
+	debug, _ := strconv.ParseBool(os.Getenv(EnvDebug))
+	return debug
+}
@@ -0,0 +1,312 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FileProblem is an issue found in a config or service file. Errors keep
+// the file from loading correctly; warnings are worth a look but harmless.
+// Line is 0 when the problem can't be tied to a line.
+type FileProblem struct {
+	File    string
+	Line    int
+	Error   bool
+	Message string
+}
+
+// configSection is a top-level key of a config file
+type configSection struct {
+	key   string
+	line  int
+	value json.RawMessage
+}
+
+// servicePrefix is a parsed network of a service file
+type servicePrefix struct {
+	service string
+	cidr    string
+	ipnet   *net.IPNet
+}
+
+// CheckServiceFiles validates every service file in a directory without
+// loading it, checking each file on its own and against the others. It
+// returns the files checked and the problems found.
+func CheckServiceFiles(servicesDir string) ([]string, []FileProblem, error) {
+	entries, err := os.ReadDir(servicesDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read services directory: %w", err)
+	}
+
+	var files []string
+	var problems []FileProblem
+	contents := make(map[string][]byte)
+	report := func(file string, isError bool, format string, args ...interface{}) {
+		problems = append(problems, FileProblem{File: file, Error: isError, Message: fmt.Sprintf(format, args...)})
+	}
+	// reportAt ties a problem to the line where text first appears
+	reportAt := func(file, text string, isError bool, format string, args ...interface{}) {
+		report(file, isError, format, args...)
+		problems[len(problems)-1].Line = lineOf(contents[file], text)
+	}
+
+	services := make(map[string]*Service)
+	fileOf := make(map[string]string)
+	var prefixes []servicePrefix
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		file := entry.Name()
+		files = append(files, file)
+
+		data, err := os.ReadFile(filepath.Join(servicesDir, file))
+		if err != nil {
+			report(file, true, "failed to read service file: %v", err)
+			continue
+		}
+		contents[file] = data
+
+		service, err := ParseService(data)
+		if err != nil {
+			problems = append(problems, FileProblem{File: file, Line: errorLine(data, err), Error: true, Message: err.Error()})
+			continue
+		}
+
+		key := strings.TrimSuffix(file, ".json")
+		if err := ValidateServiceKey(key); err != nil {
+			report(file, true, "%v", err)
+		}
+		services[key] = service
+		fileOf[key] = file
+
+		// Check networks one by one so every bad entry is reported
+		seen := make(map[string]bool)
+		var valid []string
+		for _, network := range service.Networks {
+			ip, ipnet, err := net.ParseCIDR(network)
+			if err != nil {
+				reportAt(file, quote(network), true, "invalid network CIDR '%s'", network)
+				continue
+			}
+			if seen[ipnet.String()] {
+				reportAt(file, quote(network), false, "duplicate network %s", network)
+				continue
+			}
+			seen[ipnet.String()] = true
+			if !ip.Equal(ipnet.IP) {
+				reportAt(file, quote(network), false, "network %s has host bits set, it routes %s", network, ipnet)
+			}
+			valid = append(valid, network)
+			prefixes = append(prefixes, servicePrefix{service: key, cidr: network, ipnet: ipnet})
+		}
+
+		// The remaining rules, without repeating the network errors. A
+		// file whose networks are all invalid has been reported already.
+		if len(valid) == 0 && len(service.Networks) > 0 && len(service.ASNs) == 0 && service.Extends == "" {
+			continue
+		}
+		checked := *service
+		checked.Networks = valid
+		if err := ValidateService(key, &checked); err != nil {
+			report(file, true, "%v", err)
+		}
+	}
+
+	if err := ValidateExtends(services); err != nil {
+		report("", true, "%v", err)
+	}
+
+	// Overlaps between services route the same addresses twice
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i].service < prefixes[j].service
+	})
+	for i, a := range prefixes {
+		for _, b := range prefixes[i+1:] {
+			if a.service == b.service || !overlaps(a.ipnet, b.ipnet) {
+				continue
+			}
+			switch {
+			case services[a.service].Extends == b.service:
+				reportAt(fileOf[a.service], quote(a.cidr), false, "network %s is already inherited from %s", a.cidr, b.service)
+				continue
+			case services[b.service].Extends == a.service:
+				reportAt(fileOf[b.service], quote(b.cidr), false, "network %s is already inherited from %s", b.cidr, a.service)
+				continue
+			}
+			if a.ipnet.String() == b.ipnet.String() {
+				reportAt(fileOf[a.service], quote(a.cidr), false, "network %s is also listed in %s", a.cidr, fileOf[b.service])
+				continue
+			}
+			reportAt(fileOf[a.service], quote(a.cidr), false, "network %s overlaps %s in %s", a.cidr, b.cidr, fileOf[b.service])
+		}
+	}
+
+	return files, problems, nil
+}
+
+// overlaps checks if two networks share any addresses
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// CheckConfigFile validates a main config file without loading it. Each
+// top-level section is checked on its own on top of the defaults, so every
+// broken section is reported instead of only the first.
+func CheckConfigFile(path string) ([]FileProblem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	file := filepath.Base(path)
+	var problems []FileProblem
+	withLines := true
+	report := func(line int, isError bool, format string, args ...interface{}) {
+		if !withLines {
+			line = 0
+		}
+		problems = append(problems, FileProblem{File: file, Line: line, Error: isError, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// YAML fragments are checked as JSON, so lines don't carry over
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		withLines = false
+		if data, err = yamlToJSON(data); err != nil {
+			report(0, true, "failed to parse config file: %v", err)
+			return problems, nil
+		}
+	}
+
+	sections, err := configSections(data)
+	if err != nil {
+		report(errorLine(data, err), true, "failed to parse config file: %v", err)
+		return problems, nil
+	}
+
+	known := reflect.ValueOf(GetDefaultConfig()).Elem()
+	for _, section := range sections {
+		if systemPolicyKeys[section.key] && path == SystemConfigPath() {
+			if err := (&Manager{config: GetDefaultConfig()}).loadSystemPolicy(data); err != nil {
+				report(section.line, true, "%v", err)
+			}
+			continue
+		}
+		if _, err := child(known, section.key, section.key); err != nil {
+			report(section.line, false, "unknown key '%s' is ignored", section.key)
+			continue
+		}
+
+		if section.key == "services" {
+			var services map[string]*Service
+			if err := json.Unmarshal(section.value, &services); err != nil {
+				report(section.line, true, "services: %v", err)
+				continue
+			}
+			names := make([]string, 0, len(services))
+			for name := range services {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if err := ValidateService(name, services[name]); err != nil {
+					report(lineOf(data, quote(name)), true, "service '%s': %v", name, err)
+				}
+			}
+			if err := ValidateExtends(services); err != nil {
+				report(section.line, true, "%v", err)
+			}
+			continue
+		}
+
+		cfg := GetDefaultConfig()
+		wrapped, _ := json.Marshal(map[string]json.RawMessage{section.key: section.value})
+		if err := json.Unmarshal(wrapped, cfg); err != nil {
+			report(section.line, true, "%s: %v", section.key, err)
+			continue
+		}
+		if err := ValidateConfig(cfg); err != nil {
+			report(section.line, true, "%v", err)
+		}
+	}
+
+	return problems, nil
+}
+
+// configSections splits a config file into its top-level keys, recording
+// the line each one starts on
+func configSections(data []byte) ([]configSection, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, fmt.Errorf("config must be a JSON object")
+	}
+
+	var sections []configSection
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		section := configSection{key: tok.(string), line: lineAt(data, dec.InputOffset())}
+		if err := dec.Decode(&section.value); err != nil {
+			return nil, err
+		}
+		sections = append(sections, section)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	return sections, nil
+}
+
+// errorLine returns the line a JSON decoding error points at, or 0
+func errorLine(data []byte, err error) int {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return lineAt(data, syntaxErr.Offset)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return lineAt(data, typeErr.Offset)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return lineAt(data, int64(len(bytes.TrimRight(data, " \t\r\n"))))
+	}
+	return 0
+}
+
+// lineAt returns the 1-based line of a byte offset
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// lineOf returns the line text first appears on, or 0
+func lineOf(data []byte, text string) int {
+	index := bytes.Index(data, []byte(text))
+	if index < 0 {
+		return 0
+	}
+	return lineAt(data, int64(index))
+}
+
+// quote returns s as it appears in a JSON file
+func quote(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// fragmentsDir returns the drop-in directory next to a config file
+func fragmentsDir(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "config.d")
+}
+
+// FragmentFiles lists the drop-in fragments for a config file in the
+// order they are applied: JSON and YAML files of config.d, lexically
+func FragmentFiles(configPath string) ([]string, error) {
+	dir := fragmentsDir(configPath)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".json", ".yaml", ".yml":
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readFragment reads a fragment as JSON, converting YAML
+func readFragment(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config fragment: %w", err)
+	}
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		return yamlToJSON(data)
+	}
+	return data, nil
+}
+
+// applyFragments merges the drop-in fragments into the loaded config, each
+// setting only the fields it lists. The config as it was before is kept
+// so Save doesn't write the fragments' settings into config.json.
+func (m *Manager) applyFragments() error {
+	files, err := FragmentFiles(m.configPath)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	below, err := toJSONValue(m.config)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := readFragment(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if err := json.Unmarshal(data, &m.config); err != nil {
+			return fmt.Errorf("failed to parse config fragment %s: %w", file, err)
+		}
+	}
+
+	applied, err := toJSONValue(m.config)
+	if err != nil {
+		return err
+	}
+	m.fragments = files
+	m.belowFragments = below
+	m.appliedFragments = applied
+	return nil
+}
+
+// Fragments returns the drop-in fragments applied to the loaded config
+func (m *Manager) Fragments() []string {
+	return m.fragments
+}
+
+// withoutFragments returns cfg with every setting that still holds the
+// value a fragment gave it put back to its value from config.json
+func (m *Manager) withoutFragments(cfg *Config) (*Config, error) {
+	value, err := toJSONValue(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(restoreLayer(value, m.appliedFragments, m.belowFragments))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var restored Config
+	if err := json.Unmarshal(data, &restored); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return &restored, nil
+}
+
+// restoreLayer replaces the parts of value still equal to applied by
+// below. Objects are compared key by key; a key missing from below is
+// dropped.
+func restoreLayer(value, applied, below interface{}) interface{} {
+	if reflect.DeepEqual(value, applied) {
+		return below
+	}
+
+	object, isObject := value.(map[string]interface{})
+	appliedObject, appliedIsObject := applied.(map[string]interface{})
+	if !isObject || !appliedIsObject {
+		return value
+	}
+	belowObject, _ := below.(map[string]interface{})
+
+	restored := make(map[string]interface{})
+	for key, child := range object {
+		var belowChild interface{}
+		if belowObject != nil {
+			belowChild = belowObject[key]
+		}
+		if kept := restoreLayer(child, appliedObject[key], belowChild); kept != nil {
+			restored[key] = kept
+		}
+	}
+	return restored
+}
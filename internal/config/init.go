@@ -0,0 +1,76 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// WriteDefaults writes the default configuration to dir as config.json,
+// with a file in dir/services for every default service. Unless force is
+// set nothing is written when any of the files already exists. It returns
+// the files written.
+func WriteDefaults(dir string, force bool) ([]string, error) {
+	return WriteInitial(dir, GetDefaultConfig(), GetDefaultServiceConfigs(), force)
+}
+
+// WriteInitial is WriteDefaults with the configuration and services to
+// write, such as defaults adjusted while installing
+func WriteInitial(dir string, cfg *Config, services map[string]*Service, force bool) ([]string, error) {
+	servicesDir := filepath.Join(dir, "services")
+
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := []string{filepath.Join(dir, "config.json")}
+	for _, name := range names {
+		files = append(files, filepath.Join(servicesDir, name+".json"))
+	}
+
+	if !force {
+		for _, file := range files {
+			if _, err := os.Stat(file); err == nil {
+				return nil, fmt.Errorf("%s already exists, use --force to overwrite", file)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(servicesDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create services directory: %w", err)
+	}
+
+	// Only the live configuration is shared with a running daemon
+	if filepath.Clean(dir) == filepath.Clean(ConfigDir()) {
+		unlock, err := lockConfig()
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	// Services live in their own files
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := writeFileAtomic(files[0], data); err != nil {
+		return nil, fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	for i, name := range names {
+		data, err := marshalServiceFile(services[name])
+		if err != nil {
+			return files[:i+1], err
+		}
+		if err := writeFileAtomic(files[i+1], data); err != nil {
+			return files[:i+1], fmt.Errorf("failed to write service file: %w", err)
+		}
+	}
+
+	return files, nil
+}
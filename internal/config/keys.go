@@ -0,0 +1,232 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// GetKey returns the value at a dotted key path such as "check_interval",
+// "asn_sync.interval" or "services.telegram.priority". Path elements are
+// the JSON field names, map keys and slice indexes.
+func (m *Manager) GetKey(key string) (interface{}, error) {
+	v, err := lookupPath(reflect.ValueOf(m.config).Elem(), splitKey(key), key)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// SetKey parses value according to the type of the field at a dotted key
+// path and sets it. The change is validated, and for service fields the
+// service file is updated; the caller saves the main config.
+func (m *Manager) SetKey(key, value string) error {
+	if m.Locked(key) {
+		return m.lockedError(key)
+	}
+	parts := splitKey(key)
+
+	// Validate a deep copy first so an invalid value leaves the config
+	// unchanged, then set the value itself
+	if len(parts) > 2 && parts[0] == "services" {
+		name := parts[1]
+		service, exists := m.config.Services[name]
+		if !exists {
+			return fmt.Errorf("service '%s' not found", name)
+		}
+		if service.subscription != "" {
+			return fmt.Errorf("service '%s' is managed by subscription '%s'", name, service.subscription)
+		}
+
+		var updated Service
+		if err := deepCopy(service, &updated); err != nil {
+			return err
+		}
+		if err := setPath(reflect.ValueOf(&updated).Elem(), parts[2:], key, value); err != nil {
+			return err
+		}
+		if err := ValidateService(name, &updated); err != nil {
+			return err
+		}
+		if err := setPath(reflect.ValueOf(service).Elem(), parts[2:], key, value); err != nil {
+			return err
+		}
+
+		if err := m.saveServiceFile(name, service); err != nil {
+			return fmt.Errorf("failed to update service file: %w", err)
+		}
+		return nil
+	}
+	if len(parts) > 0 && parts[0] == "services" {
+		return fmt.Errorf("set individual service fields, e.g. services.<name>.priority")
+	}
+
+	var updated Config
+	if err := deepCopy(m.config, &updated); err != nil {
+		return err
+	}
+	if err := setPath(reflect.ValueOf(&updated).Elem(), parts, key, value); err != nil {
+		return err
+	}
+	if err := ValidateConfig(&updated); err != nil {
+		return err
+	}
+	return setPath(reflect.ValueOf(m.config).Elem(), parts, key, value)
+}
+
+// deepCopy copies src into dst through JSON, so no map or slice is
+// shared between them
+func deepCopy(src, dst interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return fmt.Errorf("failed to copy config: %w", err)
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("failed to copy config: %w", err)
+	}
+	return nil
+}
+
+// splitKey splits a dotted key path into its elements
+func splitKey(key string) []string {
+	if key == "" {
+		return nil
+	}
+	return strings.Split(key, ".")
+}
+
+// lookupPath follows a key path from v
+func lookupPath(v reflect.Value, parts []string, key string) (reflect.Value, error) {
+	for i, part := range parts {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, fmt.Errorf("%s is not set", strings.Join(parts[:i], "."))
+			}
+			v = v.Elem()
+		}
+
+		next, err := child(v, part, key)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v = next
+	}
+	return v, nil
+}
+
+// setPath parses raw into the field at a key path below v
+func setPath(v reflect.Value, parts []string, key, raw string) error {
+	if len(parts) == 0 {
+		return parseInto(v, key, raw)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setPath(v.Elem(), parts, key, raw)
+	case reflect.Map:
+		// Map values aren't addressable, so update a copy and store it
+		next, err := child(v, parts[0], key)
+		if err != nil {
+			return err
+		}
+		elem := reflect.New(next.Type()).Elem()
+		elem.Set(next)
+		if err := setPath(elem, parts[1:], key, raw); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(parts[0]), elem)
+		return nil
+	}
+
+	next, err := child(v, parts[0], key)
+	if err != nil {
+		return err
+	}
+	return setPath(next, parts[1:], key, raw)
+}
+
+// child returns the struct field, map entry or slice element named part
+func child(v reflect.Value, part, key string) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name == part {
+				return v.Field(i), nil
+			}
+		}
+	case reflect.Map:
+		if value := v.MapIndex(reflect.ValueOf(part)); value.IsValid() {
+			return value, nil
+		}
+	case reflect.Slice:
+		if index, err := strconv.Atoi(part); err == nil && index >= 0 && index < v.Len() {
+			return v.Index(index), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("unknown config key: %s", key)
+}
+
+// parseInto parses raw according to the type of v. Lists of strings may
+// be given comma-separated; other composite values as JSON.
+func parseInto(v reflect.Value, key, raw string) error {
+	invalid := func(expected string) error {
+		return fmt.Errorf("invalid value for %s: expected %s, got '%s'", key, expected, raw)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return invalid("true or false")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return invalid("an integer")
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return invalid("a number")
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(raw), "[") {
+			var items []string
+			for _, item := range strings.Split(raw, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			v.Set(reflect.ValueOf(items).Convert(v.Type()))
+			return nil
+		}
+		fallthrough
+	default:
+		// Composite values, and values like timestamps that JSON expects
+		// quoted
+		target := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(raw), target.Interface()); err != nil {
+			quoted, _ := json.Marshal(raw)
+			if json.Unmarshal(quoted, target.Interface()) != nil {
+				return fmt.Errorf("invalid value for %s: %w", key, err)
+			}
+		}
+		v.Set(target.Elem())
+	}
+
+	return nil
+}
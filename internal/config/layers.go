@@ -0,0 +1,259 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// DefaultSystemConfigPath is the optional org-wide configuration read
+// before the user's config.json. Settings missing from the user's file
+// come from it, so admins can ship defaults while users keep their own
+// toggles. Its "mandatory" settings win over the user's, and the
+// networks in its "never_bypass" list are never routed around the VPN.
+const DefaultSystemConfigPath = "/etc/vpn-route-manager/config.json"
+
+// systemPolicy is what the system configuration enforces on top of the
+// user's settings: Mandatory holds settings in the form of the
+// configuration that users can't change, NeverBypass networks no service
+// may route around the VPN. Only the system configuration sets them.
+type systemPolicy struct {
+	Mandatory   json.RawMessage `json:"mandatory,omitempty"`
+	NeverBypass []string        `json:"never_bypass,omitempty"`
+}
+
+// systemPolicyKeys are the keys of the system configuration that hold its
+// policy rather than settings
+var systemPolicyKeys = map[string]bool{"mandatory": true, "never_bypass": true}
+
+// EnvSystemConfig relocates the system configuration
+const EnvSystemConfig = "VRM_SYSTEM_CONFIG"
+
+// SystemConfigPath returns the path of the system configuration
+func SystemConfigPath() string {
+	if path := os.Getenv(EnvSystemConfig); path != "" {
+		return path
+	}
+	return DefaultSystemConfigPath
+}
+
+// loadSystemConfig applies the system configuration to the defaults in
+// m.config and remembers the result as the base layer the user's file is
+// saved against. A missing system configuration is not an error.
+func (m *Manager) loadSystemConfig() error {
+	path := SystemConfigPath()
+	m.mandatory, m.neverBypass = nil, nil
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read system config %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &m.config); err != nil {
+		return fmt.Errorf("failed to parse system config %s: %w", path, err)
+	}
+	if err := m.loadSystemPolicy(data); err != nil {
+		return fmt.Errorf("invalid system config %s: %w", path, err)
+	}
+
+	// Mandatory settings are part of the base, so they aren't copied
+	// into the user's file
+	if err := m.applyMandatory(); err != nil {
+		return fmt.Errorf("invalid system config %s: %w", path, err)
+	}
+	base, err := toJSONValue(m.config)
+	if err != nil {
+		return err
+	}
+	m.systemPath = path
+	m.base = base
+	return nil
+}
+
+// SystemConfig returns the path of the system configuration the loaded
+// configuration is layered on, or "" when there is none
+func (m *Manager) SystemConfig() string {
+	return m.systemPath
+}
+
+// layeredData encodes cfg for the user's config file when it is layered
+// on a system configuration: only settings that differ from the system
+// layer, or were already in the user's file, are written, so the rest
+// keep following the system configuration.
+func (m *Manager) layeredData(cfg *Config) ([]byte, error) {
+	value, err := toJSONValue(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	pruned, _ := pruneLayer(value, m.base, m.user)
+	object, _ := pruned.(map[string]interface{})
+	if object == nil {
+		object = make(map[string]interface{})
+	}
+	object["schema_version"] = cfg.SchemaVersion
+
+	data, err := json.MarshalIndent(object, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return data, nil
+}
+
+// pruneLayer drops the parts of value equal to base, keeping those in
+// user. Objects are pruned key by key; other values are kept or dropped as
+// a whole. It reports whether anything is left.
+func pruneLayer(value, base, user interface{}) (interface{}, bool) {
+	object, isObject := value.(map[string]interface{})
+	baseObject, baseIsObject := base.(map[string]interface{})
+	if !isObject || !baseIsObject {
+		return value, user != nil || !reflect.DeepEqual(value, base)
+	}
+
+	userObject, _ := user.(map[string]interface{})
+	pruned := make(map[string]interface{})
+	for key, child := range object {
+		var userChild interface{}
+		if userObject != nil {
+			userChild = userObject[key]
+		}
+		if kept, ok := pruneLayer(child, baseObject[key], userChild); ok {
+			pruned[key] = kept
+		}
+	}
+	return pruned, len(pruned) > 0 || user != nil
+}
+
+// loadSystemPolicy reads the mandatory settings and never-bypass networks
+// of the system configuration
+func (m *Manager) loadSystemPolicy(data []byte) error {
+	var policy systemPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return err
+	}
+
+	if len(policy.Mandatory) > 0 {
+		var mandatory map[string]interface{}
+		if err := json.Unmarshal(policy.Mandatory, &mandatory); err != nil {
+			return fmt.Errorf("mandatory: %w", err)
+		}
+		if mandatory == nil {
+			return fmt.Errorf("mandatory: must be an object")
+		}
+		m.mandatory = mandatory
+	}
+
+	for _, network := range policy.NeverBypass {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return fmt.Errorf("never_bypass: invalid network '%s'", network)
+		}
+		m.neverBypass = append(m.neverBypass, ipNet)
+	}
+	return nil
+}
+
+// applyMandatory sets the mandatory settings of the system configuration
+// over whatever the user's layers set. Mandatory service settings apply
+// to services that exist, field by field. Settings that aren't known are
+// an error rather than a policy that is silently not enforced.
+func (m *Manager) applyMandatory() error {
+	if len(m.mandatory) == 0 {
+		return nil
+	}
+
+	settings := make(map[string]interface{}, len(m.mandatory))
+	for key, value := range m.mandatory {
+		if key != "services" {
+			settings[key] = value
+		}
+	}
+	if err := decodeStrict(settings, m.config); err != nil {
+		return fmt.Errorf("mandatory: %w", err)
+	}
+
+	services, isObject := m.mandatory["services"].(map[string]interface{})
+	if _, present := m.mandatory["services"]; present && !isObject {
+		return fmt.Errorf("mandatory: services must be an object")
+	}
+	for name, value := range services {
+		// Decoding into the map would replace the service as a whole.
+		// Settings of other services are checked all the same.
+		var updated Service
+		service, exists := m.config.Services[name]
+		if exists {
+			updated = *service
+		}
+		if err := decodeStrict(value, &updated); err != nil {
+			return fmt.Errorf("mandatory: service '%s': %w", name, err)
+		}
+		if exists {
+			*service = updated
+		}
+	}
+	return nil
+}
+
+// decodeStrict decodes a decoded JSON value into v, failing on fields v
+// doesn't have
+func decodeStrict(value interface{}, v interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// Locked reports whether the system configuration makes the setting at a
+// dotted key path, or any setting below it, mandatory
+func (m *Manager) Locked(key string) bool {
+	var node interface{} = m.mandatory
+	if len(m.mandatory) == 0 {
+		return false
+	}
+	for _, part := range splitKey(key) {
+		object, isObject := node.(map[string]interface{})
+		if !isObject {
+			return true
+		}
+		if node = object[part]; node == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// lockedError reports a setting the system configuration makes mandatory
+func (m *Manager) lockedError(key string) error {
+	return fmt.Errorf("%s is mandatory in the system configuration %s", key, m.systemPath)
+}
+
+// Bypassable reports whether a network may be routed around the VPN: it
+// must not overlap any network the system configuration lists in
+// never_bypass
+func (m *Manager) Bypassable(network string) bool {
+	if len(m.neverBypass) == 0 {
+		return true
+	}
+	if !strings.Contains(network, "/") {
+		network += "/32"
+	}
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		return false
+	}
+	for _, blocked := range m.neverBypass {
+		if blocked.Contains(ipNet.IP) || ipNet.Contains(blocked.IP) {
+			return false
+		}
+	}
+	return true
+}
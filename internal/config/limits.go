@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+// Route limit policies
+const (
+	RouteLimitRefuse   = "refuse"
+	RouteLimitTruncate = "truncate"
+)
+
+// RouteLimitsConfig caps how many networks an update may give a service
+// (MaxServiceRoutes, overridden by the service's own MaxRoutes) and all
+// enabled services together (MaxRoutes), so a bad ASN sync or network list
+// can't flood the routing table. Zero means unlimited. Policy "refuse"
+// rejects updates over a limit; "truncate" keeps the broadest networks
+// that fit.
+type RouteLimitsConfig struct {
+	MaxRoutes        int    `json:"max_routes"`
+	MaxServiceRoutes int    `json:"max_service_routes"`
+	Policy           string `json:"policy"`
+}
+
+// RouteLimit returns the most networks a service may have, or 0 when
+// unlimited. Under max_routes every network the other enabled services
+// route counts, including inherited, ASN and subscribed ones, so it may
+// also be 0 when they use up the limit; LimitNetworks tells the two apart.
+func (m *Manager) RouteLimit(name string) int {
+	limit := m.config.RouteLimits.MaxServiceRoutes
+	if service, exists := m.config.Services[name]; exists && service.MaxRoutes > 0 {
+		limit = service.MaxRoutes
+	}
+
+	if max := m.config.RouteLimits.MaxRoutes; max > 0 {
+		others := 0
+		for other, service := range m.config.Services {
+			if other != name && service.Enabled {
+				others += len(m.ServiceNetworks(other))
+			}
+		}
+
+		remaining := max - others
+		if remaining < 0 {
+			remaining = 0
+		}
+		if limit == 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+
+	return limit
+}
+
+// LimitNetworks applies the route limits to a new network list for a
+// service. Under the truncate policy it returns the list cut down to the
+// broadest networks that fit and how many were dropped; under the refuse
+// policy a list over the limit is an error, as is one under either policy
+// when no routes are left at all.
+func (m *Manager) LimitNetworks(name string, networks []string) ([]string, int, error) {
+	limit := m.RouteLimit(name)
+	if limit == 0 && m.config.RouteLimits.MaxRoutes == 0 {
+		return networks, 0, nil
+	}
+	if len(networks) <= limit {
+		return networks, 0, nil
+	}
+
+	// Truncating to nothing would leave the service without routes
+	if limit == 0 {
+		return nil, 0, fmt.Errorf("no routes left for %s, the other services use up the route limit of %d", name, m.config.RouteLimits.MaxRoutes)
+	}
+
+	if m.config.RouteLimits.Policy != RouteLimitTruncate {
+		return nil, 0, fmt.Errorf("%d networks for %s exceed the route limit of %d", len(networks), name, limit)
+	}
+
+	limited := append([]string(nil), networks...)
+	sort.SliceStable(limited, func(i, j int) bool {
+		return prefixLength(limited[i]) < prefixLength(limited[j])
+	})
+	limited = limited[:limit]
+
+	return limited, len(networks) - limit, nil
+}
+
+// prefixLength returns the prefix length of a CIDR network, treating
+// single addresses as host routes
+func prefixLength(network string) int {
+	_, ipnet, err := net.ParseCIDR(network)
+	if err != nil {
+		return 32
+	}
+	ones, _ := ipnet.Mask.Size()
+	return ones
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"vpn-route-manager/internal/system"
+)
+
+// lockTimeout is how long a writer waits for another process to finish
+// writing the configuration
+const lockTimeout = 10 * time.Second
+
+// lockConfig takes the advisory lock shared by every process that writes
+// config and service files, so the CLI and the daemon never interleave
+// their writes. The returned function releases the lock. The lock is not
+// reentrant; callers holding it must not take it again.
+func lockConfig() (func(), error) {
+	dir := ConfigDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	path := filepath.Join(dir, ".lock")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|syscall.O_NOFOLLOW, system.SharedFileMode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config lock: %w", err)
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if err != syscall.EWOULDBLOCK {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock config: %w", err)
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("timed out waiting for %s, another vpn-route-manager process is writing the configuration", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+// writeFileAtomic replaces a file through a temporary file and a rename,
+// so readers see either the old or the new contents
+func writeFileAtomic(path string, data []byte) error {
+	if err := system.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		os.Remove(path + ".tmp")
+		return err
+	}
+	return nil
+}
+
+// WriteFile atomically replaces a config or service file while holding
+// the config lock
+func WriteFile(path string, data []byte) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return writeFileAtomic(path, data)
+}
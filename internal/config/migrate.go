@@ -0,0 +1,252 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SchemaVersion is the layout version of config and service files written
+// by this build. Files without a version predate versioning and are
+// version 0.
+const SchemaVersion = 2
+
+// migration upgrades files from the previous schema version to version.
+// Files are migrated as decoded JSON rather than through the current
+// structs, so older layouts the structs can't parse are upgraded too.
+type migration struct {
+	version     int
+	description string
+	config      func(cfg map[string]interface{}) error
+	service     func(name string, file map[string]interface{}) (map[string]interface{}, error)
+}
+
+// migrations lists every schema upgrade in version order
+var migrations = []migration{
+	{
+		version:     1,
+		description: "version files and wrap single-service files in the name-keyed layout",
+		service: func(name string, file map[string]interface{}) (map[string]interface{}, error) {
+			if isWrappedService(file) {
+				return file, nil
+			}
+			return map[string]interface{}{name: file}, nil
+		},
+	},
+	{
+		version:     2,
+		description: "store the service itself in service files, without the name-keyed wrapper",
+		service: func(name string, file map[string]interface{}) (map[string]interface{}, error) {
+			if !isWrappedService(file) {
+				return file, nil
+			}
+			if service, ok := file[name].(map[string]interface{}); ok {
+				return service, nil
+			}
+
+			// Loading only ever used one service of the file; keep the
+			// first by name
+			keys := make([]string, 0, len(file))
+			for key := range file {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			return file[keys[0]].(map[string]interface{}), nil
+		},
+	},
+}
+
+// Migration describes a file upgraded to the current schema version.
+// Backup is the backup taken before the first file was changed.
+type Migration struct {
+	File   string
+	From   int
+	Backup string
+}
+
+// Migrate upgrades the config file and the service files in servicesDir
+// to SchemaVersion in place, backing up the configuration before changing
+// anything. It holds the config lock so a daemon and the CLI don't
+// migrate together.
+// Files written by a newer version are an error rather than being
+// mis-parsed.
+func (m *Manager) Migrate(servicesDir string) ([]Migration, error) {
+	unlock, err := lockConfig()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var migrated []Migration
+	var backup string
+	backUp := func() (string, error) {
+		if backup == "" {
+			path, err := Backup(BackupDir(), m.configPath)
+			if err != nil {
+				return "", err
+			}
+			backup = path
+		}
+		return backup, nil
+	}
+
+	done, err := migrateFile(m.configPath, backUp, func(file map[string]interface{}) (map[string]interface{}, int, error) {
+		from := intValue(file["schema_version"])
+		if from > SchemaVersion {
+			return nil, from, nil
+		}
+		for _, mig := range pending(from) {
+			if mig.config == nil {
+				continue
+			}
+			if err := mig.config(file); err != nil {
+				return nil, from, fmt.Errorf("migration to version %d (%s): %w", mig.version, mig.description, err)
+			}
+		}
+		file["schema_version"] = SchemaVersion
+		return file, from, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if done != nil {
+		migrated = append(migrated, *done)
+	}
+
+	entries, err := os.ReadDir(servicesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return migrated, nil
+		}
+		return nil, fmt.Errorf("failed to read services directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".json")
+
+		done, err := migrateFile(filepath.Join(servicesDir, entry.Name()), backUp, func(file map[string]interface{}) (map[string]interface{}, int, error) {
+			from := serviceFileVersion(file)
+			if from > SchemaVersion {
+				return nil, from, nil
+			}
+			for _, mig := range pending(from) {
+				if mig.service == nil {
+					continue
+				}
+				var err error
+				if file, err = mig.service(name, file); err != nil {
+					return nil, from, fmt.Errorf("migration to version %d (%s): %w", mig.version, mig.description, err)
+				}
+			}
+			file["schema_version"] = SchemaVersion
+			return file, from, nil
+		})
+		if err != nil {
+			return migrated, err
+		}
+		if done != nil {
+			migrated = append(migrated, *done)
+		}
+	}
+
+	return migrated, nil
+}
+
+// migrateFile applies upgrade to a JSON file unless it is current, calling
+// backUp first. upgrade returns the file's version and the upgraded
+// contents, or nil contents when the file is newer than this build. Files
+// that don't parse are left for loading to report.
+func migrateFile(path string, backUp func() (string, error), upgrade func(map[string]interface{}) (map[string]interface{}, int, error)) (*Migration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var file map[string]interface{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil
+	}
+
+	upgraded, from, err := upgrade(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+	if upgraded == nil {
+		return nil, fmt.Errorf("%s has schema version %d, newer than the supported %d; upgrade vpn-route-manager", path, from, SchemaVersion)
+	}
+	if from == SchemaVersion {
+		return nil, nil
+	}
+
+	updated, err := json.MarshalIndent(upgraded, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", path, err)
+	}
+
+	backup, err := backUp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up configuration before migrating: %w", err)
+	}
+	if err := writeFileAtomic(path, updated); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return &Migration{File: path, From: from, Backup: backup}, nil
+}
+
+// pending returns the migrations needed to upgrade from a version
+func pending(from int) []migration {
+	var list []migration
+	for _, mig := range migrations {
+		if mig.version > from {
+			list = append(list, mig)
+		}
+	}
+	return list
+}
+
+// isWrappedService checks if a service file holds services keyed by name
+// rather than a single service
+func isWrappedService(file map[string]interface{}) bool {
+	if len(file) == 0 {
+		return false
+	}
+	for _, value := range file {
+		if _, ok := value.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// serviceFileVersion returns the schema version of a service file in
+// either layout
+func serviceFileVersion(file map[string]interface{}) int {
+	if !isWrappedService(file) {
+		return intValue(file["schema_version"])
+	}
+	version := 0
+	for _, service := range file {
+		if v := intValue(service.(map[string]interface{})["schema_version"]); v > version {
+			version = v
+		}
+	}
+	return version
+}
+
+// intValue converts a decoded JSON number to an int, or 0
+func intValue(value interface{}) int {
+	if n, ok := value.(float64); ok {
+		return int(n)
+	}
+	return 0
+}
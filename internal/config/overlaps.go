@@ -0,0 +1,103 @@
+package config
+
+import (
+	"net"
+	"sort"
+)
+
+// SharedNetwork is a network listed by more than one service. Its route
+// is added once, for Owner, the service that comes first in ApplyOrder.
+type SharedNetwork struct {
+	Network  string
+	Owner    string
+	Services []string
+}
+
+// ApplyOrder returns the names of services in the order their routes are
+// added: by descending priority, so higher-priority services own the
+// networks they share with others, then parents before the services that
+// extend them
+func (m *Manager) ApplyOrder(services map[string]*Service) []string {
+	all := m.config.Services
+	depth := func(name string) int {
+		d := 0
+		visited := make(map[string]bool)
+		for !visited[name] {
+			visited[name] = true
+			service, exists := all[name]
+			if !exists || service.Extends == "" {
+				break
+			}
+			name = service.Extends
+			d++
+		}
+		return d
+	}
+
+	names := ServiceNames(services)
+	sort.SliceStable(names, func(i, j int) bool {
+		a, b := services[names[i]], services[names[j]]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return depth(names[i]) < depth(names[j])
+	})
+	return names
+}
+
+// SharedNetworks returns the networks listed by more than one of the
+// given services, sorted by network
+func (m *Manager) SharedNetworks(services map[string]*Service) []SharedNetwork {
+	byNetwork := make(map[string]*SharedNetwork)
+	var networks []string
+
+	for _, name := range m.ApplyOrder(services) {
+		for _, network := range services[name].Networks {
+			_, ipnet, err := net.ParseCIDR(network)
+			if err != nil {
+				continue
+			}
+			key := ipnet.String()
+
+			shared, exists := byNetwork[key]
+			if !exists {
+				byNetwork[key] = &SharedNetwork{Network: key, Owner: name, Services: []string{name}}
+				networks = append(networks, key)
+				continue
+			}
+			if shared.Services[len(shared.Services)-1] != name {
+				shared.Services = append(shared.Services, name)
+			}
+		}
+	}
+
+	var result []SharedNetwork
+	for _, network := range networks {
+		if shared := byNetwork[network]; len(shared.Services) > 1 {
+			result = append(result, *shared)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return compareNetworks(result[i].Network, result[j].Network)
+	})
+	return result
+}
+
+// compareNetworks orders networks by address, then prefix length
+func compareNetworks(a, b string) bool {
+	_, netA, errA := net.ParseCIDR(a)
+	_, netB, errB := net.ParseCIDR(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+
+	ipA, ipB := netA.IP.To16(), netB.IP.To16()
+	for i := range ipA {
+		if ipA[i] != ipB[i] {
+			return ipA[i] < ipB[i]
+		}
+	}
+	onesA, _ := netA.Mask.Size()
+	onesB, _ := netB.Mask.Size()
+	return onesA < onesB
+}
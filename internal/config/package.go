@@ -0,0 +1,44 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadInitial reads the configuration and services WriteInitial wrote to
+// dir, such as ones packaged on another machine, on top of the defaults.
+// The log and state directories are this machine's defaults rather than
+// those in the file, which belong to whoever wrote it.
+func ReadInitial(dir string) (*Config, map[string]*Service, error) {
+	cfg := GetDefaultConfig()
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	defaults := GetDefaultConfig()
+	cfg.LogDir = defaults.LogDir
+	cfg.StateDir = defaults.StateDir
+
+	services := make(map[string]*Service)
+	paths, _ := filepath.Glob(filepath.Join(dir, "services", "*.json"))
+	for _, path := range paths {
+		service, err := LoadServiceFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		services[strings.TrimSuffix(filepath.Base(path), ".json")] = service
+	}
+
+	cfg.Services = services
+	if err := ValidateConfig(cfg); err != nil {
+		return nil, nil, err
+	}
+	cfg.Services = make(map[string]*Service)
+	return cfg, services, nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// EnvHome sets a single root directory holding config, state and logs,
+// like the --base-dir flag
+const EnvHome = "VRM_HOME"
+
+// SystemBinDir is where install puts the binary unless installing for
+// the user only
+const SystemBinDir = "/usr/local/bin"
+
+// UserBinDir is where install --user puts the binary: bin under the
+// VRM_HOME root or ~/.vpn-route-manager, or under XDG_DATA_HOME with the
+// XDG layout, so creating it doesn't switch DefaultPaths to the legacy
+// directory. Unlike SystemBinDir the user owns it, so installing there
+// needs no sudo.
+func UserBinDir() string {
+	if root := os.Getenv(EnvHome); root != "" {
+		return filepath.Join(root, "bin")
+	}
+	homeDir, _ := os.UserHomeDir()
+	legacy := filepath.Join(homeDir, ".vpn-route-manager")
+	if DefaultPaths() == rootPaths(legacy) {
+		return filepath.Join(legacy, "bin")
+	}
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(dataHome, "vpn-route-manager", "bin")
+}
+
+// Paths are the directories configuration, state and logs are kept in
+type Paths struct {
+	Config string
+	State  string
+	Logs   string
+}
+
+// DefaultPaths returns where files are kept. A root set with VRM_HOME (or
+// --base-dir) holds config, state and logs subdirectories. Otherwise an
+// existing ~/.vpn-route-manager keeps being used, and new installs follow
+// XDG_CONFIG_HOME and XDG_STATE_HOME when either is set.
+func DefaultPaths() Paths {
+	if root := os.Getenv(EnvHome); root != "" {
+		return rootPaths(root)
+	}
+
+	homeDir, _ := os.UserHomeDir()
+	legacy := filepath.Join(homeDir, ".vpn-route-manager")
+	if _, err := os.Stat(legacy); err == nil {
+		return rootPaths(legacy)
+	}
+
+	configHome, stateHome := os.Getenv("XDG_CONFIG_HOME"), os.Getenv("XDG_STATE_HOME")
+	if configHome == "" && stateHome == "" {
+		return rootPaths(legacy)
+	}
+	if configHome == "" {
+		configHome = filepath.Join(homeDir, ".config")
+	}
+	if stateHome == "" {
+		stateHome = filepath.Join(homeDir, ".local", "state")
+	}
+
+	state := filepath.Join(stateHome, "vpn-route-manager")
+	return Paths{
+		Config: filepath.Join(configHome, "vpn-route-manager"),
+		State:  state,
+		Logs:   filepath.Join(state, "logs"),
+	}
+}
+
+// rootPaths returns the layout under a single root directory
+func rootPaths(root string) Paths {
+	return Paths{
+		Config: filepath.Join(root, "config"),
+		State:  filepath.Join(root, "state"),
+		Logs:   filepath.Join(root, "logs"),
+	}
+}
+
+// Dirs returns every directory of the layout
+func (p Paths) Dirs() []string {
+	return []string{p.Config, filepath.Join(p.Config, "services"), p.State, p.Logs}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// redacted replaces sensitive values in a redacted configuration
+const redacted = "REDACTED"
+
+// Redact returns a copy of cfg that is safe to share, such as in bug
+// reports. Credentials and query parameters are removed from URLs, which
+// may carry access tokens, Wi-Fi network names from profiles, as they give
+// away where the user is, and the arguments of the detection command.
+func Redact(cfg *Config) (*Config, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var safe Config
+	if err := json.Unmarshal(data, &safe); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+
+	safe.ConnectivityCheck = redactURL(safe.ConnectivityCheck)
+	safe.CatalogURL = redactURL(safe.CatalogURL)
+	safe.ASNSync.Source = redactURL(safe.ASNSync.Source)
+	for i := range safe.Subscriptions {
+		safe.Subscriptions[i].URL = redactURL(safe.Subscriptions[i].URL)
+	}
+	for _, service := range safe.Services {
+		service.NetworksURL = redactURL(service.NetworksURL)
+	}
+	for i := range safe.Profiles {
+		for j := range safe.Profiles[i].SSIDs {
+			safe.Profiles[i].SSIDs[j] = redacted
+		}
+	}
+	if fields := strings.Fields(safe.Detection.Command); len(fields) > 1 {
+		safe.Detection.Command = fields[0] + " " + redacted
+	}
+
+	return &safe, nil
+}
+
+// redactURL removes the user info and query values from a URL. Values
+// that don't parse are replaced as a whole.
+func redactURL(value string) string {
+	if value == "" {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return redacted
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query.Set(key, redacted)
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
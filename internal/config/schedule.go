@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ScheduleRule is a weekly window during which a service is routed. Days
+// lists weekdays (mon..sun, or weekdays/weekends) and means every day when
+// empty. Start and End are local HH:MM times; a window that ends before it
+// starts runs past midnight into the next day.
+type ScheduleRule struct {
+	Days  []string `json:"days,omitempty"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// InSchedule checks if a service should be routed at time t. Services
+// without schedule rules are always routed.
+func (s *Service) InSchedule(t time.Time) bool {
+	if len(s.Schedule) == 0 {
+		return true
+	}
+	for _, rule := range s.Schedule {
+		if rule.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains checks if t falls inside the rule's window
+func (r *ScheduleRule) Contains(t time.Time) bool {
+	start, err := parseClock(r.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(r.End)
+	if err != nil {
+		return false
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return r.onDay(t.Weekday()) && now >= start && now < end
+	}
+
+	// Overnight window: the evening part belongs to today, the morning
+	// part to the window that started yesterday
+	if now >= start {
+		return r.onDay(t.Weekday())
+	}
+	return now < end && r.onDay((t.Weekday()+6)%7)
+}
+
+// onDay checks if the rule applies to a weekday
+func (r *ScheduleRule) onDay(day time.Weekday) bool {
+	if len(r.Days) == 0 {
+		return true
+	}
+	for _, name := range r.Days {
+		switch name = strings.ToLower(name); name {
+		case "weekdays":
+			if day >= time.Monday && day <= time.Friday {
+				return true
+			}
+		case "weekends":
+			if day == time.Saturday || day == time.Sunday {
+				return true
+			}
+		default:
+			if weekday, ok := weekdayNames[name]; ok && weekday == day {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseClock parses an HH:MM time into minutes after midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s', expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// validateScheduleRule validates a single schedule rule
+func validateScheduleRule(rule ScheduleRule) error {
+	start, err := parseClock(rule.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(rule.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("start and end cannot be the same")
+	}
+
+	for _, day := range rule.Days {
+		day = strings.ToLower(day)
+		if _, ok := weekdayNames[day]; !ok && day != "weekdays" && day != "weekends" {
+			return fmt.Errorf("invalid day '%s'", day)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaURI is the JSON Schema draft the generated schemas follow
+const schemaURI = "http://json-schema.org/draft-07/schema#"
+
+// schemaHints adds the bounds enforced by the validator to the generated
+// schemas, keyed by type name and JSON field name
+var schemaHints = map[string]map[string]interface{}{
+	"Config.check_interval":                checkIntervalLimit.schema(),
+	"Config.disconnect_grace":              disconnectGraceLimit.schema(),
+	"DomainResolutionConfig.min_ttl":       ttlLimit.schema(),
+	"DomainResolutionConfig.max_ttl":       {"maximum": ttlLimit.max},
+	"ASNSyncConfig.interval":               syncIntervalLimit.schema(),
+	"NetworkUpdatesConfig.interval":        syncIntervalLimit.schema(),
+	"HealthCheckConfig.interval":           healthIntervalLimit.schema(),
+	"HealthCheckConfig.timeout":            healthTimeoutLimit.schema(),
+	"RouteLimitsConfig.max_routes":         {"minimum": 0},
+	"RouteLimitsConfig.max_service_routes": {"minimum": 0},
+	"RouteLimitsConfig.policy":             {"enum": []string{"", RouteLimitRefuse, RouteLimitTruncate}},
+	"LaunchdConfig.label":                  {"pattern": "^[A-Za-z0-9][A-Za-z0-9.-]*$"},
+	"LaunchdConfig.keep_alive":             {"enum": []string{KeepAliveAlways, KeepAliveOnFailure, KeepAliveCrashed, KeepAliveNever}},
+	"LaunchdConfig.throttle_interval":      throttleIntervalLimit.schema(),
+	"LaunchdConfig.nice":                   niceLimit.schema(),
+	"LoggingConfig.format":                 {"enum": []string{LogFormatText, LogFormatJSON}},
+	"LoggingConfig.outputs":                {"minItems": 1, "items": map[string]interface{}{"type": "string", "enum": []string{LogOutputFile, LogOutputSyslog}}},
+	"LoggingConfig.max_size_mb":            logSizeLimit.schema(),
+	"LoggingConfig.max_backups":            {"minimum": 0},
+	"LoggingConfig.max_age_days":           {"minimum": 0},
+	"LoggingConfig.max_total_size_mb":      {"minimum": 0},
+	"DetectionConfig.threshold":            {"exclusiveMinimum": 0, "maximum": 1},
+	"DetectionConfig.debounce_checks":      debounceChecksLimit.schema(),
+	"DetectionConfig.command_timeout":      commandTimeoutLimit.schema(),
+	"Subscription.interval":                subscriptionLimit.schema(),
+	"Service.priority":                     priorityLimit.schema(),
+	"Service.max_routes":                   {"minimum": 0},
+	"ScheduleRule.start":                   {"pattern": "^[0-9]{1,2}:[0-9]{2}$"},
+	"ScheduleRule.end":                     {"pattern": "^[0-9]{1,2}:[0-9]{2}$"},
+}
+
+// ConfigSchema returns a JSON Schema describing config.json
+func ConfigSchema() map[string]interface{} {
+	return newSchema("VPN Route Manager configuration", reflect.TypeOf(Config{}))
+}
+
+// ServiceSchema returns a JSON Schema describing a service file, which
+// holds the service itself. Files from before schema version 2 that wrap
+// it in an object keyed by its name are accepted too.
+func ServiceSchema() map[string]interface{} {
+	definitions := make(map[string]interface{})
+	service := structRef(reflect.TypeOf(Service{}), definitions)
+
+	return map[string]interface{}{
+		"$schema": schemaURI,
+		"title":   "VPN Route Manager service",
+		"anyOf": []interface{}{
+			service,
+			map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": service,
+				"minProperties":        1,
+				"maxProperties":        1,
+			},
+		},
+		"definitions": definitions,
+	}
+}
+
+// newSchema builds a schema for t, with every struct type it uses in
+// definitions
+func newSchema(title string, t reflect.Type) map[string]interface{} {
+	definitions := make(map[string]interface{})
+	schema := typeSchema(t, definitions)
+	schema["$schema"] = schemaURI
+	schema["title"] = title
+	if len(definitions) > 0 {
+		schema["definitions"] = definitions
+	}
+	return schema
+}
+
+// typeSchema describes the JSON encoding of t. Struct types are added to
+// definitions once and referenced, except for the top-level type.
+func typeSchema(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": structRef(t.Elem(), definitions)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": structRef(t.Elem(), definitions)}
+	case reflect.Struct:
+		return structSchema(t, definitions)
+	}
+
+	return map[string]interface{}{}
+}
+
+// structRef returns a reference to a struct type's definition, adding it
+// if needed, or the schema of any other type
+func structRef(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	base := t
+	for base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	if base.Kind() != reflect.Struct || base == reflect.TypeOf(time.Time{}) {
+		return typeSchema(t, definitions)
+	}
+
+	if _, exists := definitions[base.Name()]; !exists {
+		// Reserve the name first so recursive types terminate
+		definitions[base.Name()] = nil
+		definitions[base.Name()] = structSchema(base, definitions)
+	}
+	return map[string]interface{}{"$ref": "#/definitions/" + base.Name()}
+}
+
+// structSchema describes a struct by its JSON fields. No field is
+// required since missing fields keep their defaults, but unknown fields
+// are flagged as they are ignored when loading.
+func structSchema(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		property := structRef(field.Type, definitions)
+		if hints, ok := schemaHints[t.Name()+"."+name]; ok {
+			for key, value := range hints {
+				property[key] = value
+			}
+		}
+		properties[name] = property
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
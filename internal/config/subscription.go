@@ -0,0 +1,226 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultSubscriptionInterval is how often subscriptions are refreshed
+// when they don't set an interval, in hours
+const DefaultSubscriptionInterval = 6
+
+// MinSubscribedPrefix is the shortest prefix a subscribed service may
+// route, so a subscription can't take over the default route or other
+// huge parts of the address space
+const MinSubscribedPrefix = 8
+
+// Subscription is a URL serving service definitions that is refreshed
+// periodically, so bypass lists can be maintained centrally. Interval is
+// in hours. Services defined locally take precedence over subscribed ones.
+type Subscription struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Interval int    `json:"interval,omitempty"`
+}
+
+// RefreshInterval returns how often the subscription is refreshed
+func (s *Subscription) RefreshInterval() time.Duration {
+	if s.Interval <= 0 {
+		return DefaultSubscriptionInterval * time.Hour
+	}
+	return time.Duration(s.Interval) * time.Hour
+}
+
+// SubscriptionCache is the last downloaded copy of a subscription, kept so
+// services are available offline and unchanged lists aren't downloaded
+// again
+type SubscriptionCache struct {
+	URL       string              `json:"url"`
+	ETag      string              `json:"etag,omitempty"`
+	FetchedAt time.Time           `json:"fetched_at"`
+	Services  map[string]*Service `json:"services"`
+}
+
+// SubscriptionsDir returns where subscription caches are kept
+func SubscriptionsDir(cfg *Config) string {
+	return filepath.Join(cfg.StateDir, "subscriptions")
+}
+
+// ReadSubscriptionCache reads the cached copy of a subscription
+func ReadSubscriptionCache(dir, name string) (*SubscriptionCache, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var cache SubscriptionCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("failed to parse subscription cache: %w", err)
+	}
+	return &cache, nil
+}
+
+// FetchSubscription downloads a subscription into its cache unless the
+// server reports it unchanged since the cached ETag. It reports whether
+// the cached services changed.
+func FetchSubscription(sub Subscription, dir string) (bool, error) {
+	cache, err := ReadSubscriptionCache(dir, sub.Name)
+	if err != nil || cache.URL != sub.URL {
+		cache = &SubscriptionCache{URL: sub.URL}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, sub.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	if cache.ETag != "" {
+		req.Header.Set("If-None-Match", cache.ETag)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch %s: %w", sub.URL, err)
+	}
+	defer resp.Body.Close()
+
+	changed := false
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+	case http.StatusOK:
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", sub.URL, err)
+		}
+
+		services, err := ParseServices(data, "")
+		if err != nil {
+			return false, fmt.Errorf("invalid service list from %s: %w", sub.URL, err)
+		}
+		for name, service := range services {
+			if err := validateSubscribedService(name, service); err != nil {
+				return false, fmt.Errorf("invalid service list from %s: %w", sub.URL, err)
+			}
+		}
+
+		cache.Services = services
+		cache.ETag = resp.Header.Get("ETag")
+		changed = true
+	default:
+		return false, fmt.Errorf("failed to fetch %s: %s", sub.URL, resp.Status)
+	}
+
+	cache.FetchedAt = time.Now()
+	if err := writeSubscriptionCache(dir, sub.Name, cache); err != nil {
+		return false, err
+	}
+
+	return changed, nil
+}
+
+// validateServiceList checks every service in a parsed list
+func validateServiceList(services map[string]*Service) (map[string]*Service, error) {
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no services found")
+	}
+
+	for name, service := range services {
+		if err := ValidateServiceKey(name); err != nil {
+			return nil, err
+		}
+		if err := ValidateService(name, service); err != nil {
+			return nil, fmt.Errorf("service '%s': %w", name, err)
+		}
+	}
+	return services, nil
+}
+
+// validateSubscribedService checks a service from a subscription: a valid
+// key and service, and no network broader than MinSubscribedPrefix
+func validateSubscribedService(name string, service *Service) error {
+	if err := ValidateServiceKey(name); err != nil {
+		return err
+	}
+	if err := ValidateService(name, service); err != nil {
+		return fmt.Errorf("service '%s': %w", name, err)
+	}
+	for _, network := range service.Networks {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return fmt.Errorf("service '%s': invalid network '%s'", name, network)
+		}
+		if ones, _ := ipNet.Mask.Size(); ones < MinSubscribedPrefix {
+			return fmt.Errorf("service '%s': network %s is broader than /%d", name, network, MinSubscribedPrefix)
+		}
+	}
+	return nil
+}
+
+// writeSubscriptionCache atomically replaces the cached copy of a
+// subscription
+func writeSubscriptionCache(dir, name string, cache *SubscriptionCache) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create subscriptions directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscription cache: %w", err)
+	}
+
+	path := filepath.Join(dir, name+".json")
+	if err := WriteFile(path, data); err != nil {
+		return fmt.Errorf("failed to update subscription cache: %w", err)
+	}
+
+	return nil
+}
+
+// LoadSubscriptions adds the services of every cached subscription to the
+// configuration, replacing services from an earlier load of the same
+// subscription. Services defined locally are never replaced, and cached
+// services that aren't valid subscribed services are skipped.
+func (m *Manager) LoadSubscriptions() error {
+	if m.config.Services == nil {
+		m.config.Services = make(map[string]*Service)
+	}
+
+	dir := SubscriptionsDir(m.config)
+	for _, sub := range m.config.Subscriptions {
+		cache, err := ReadSubscriptionCache(dir, sub.Name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // Not fetched yet
+			}
+			fmt.Fprintf(os.Stderr, "Warning: failed to load subscription %s: %v\n", sub.Name, err)
+			continue
+		}
+
+		// Drop services the subscription no longer lists
+		for name, service := range m.config.Services {
+			if service.subscription == sub.Name && cache.Services[name] == nil {
+				delete(m.config.Services, name)
+			}
+		}
+
+		for name, service := range cache.Services {
+			if existing, exists := m.config.Services[name]; exists && existing.subscription != sub.Name {
+				continue
+			}
+			if err := validateSubscribedService(name, service); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: skipping service from subscription %s: %v\n", sub.Name, err)
+				continue
+			}
+			service.subscription = sub.Name
+			m.config.Services[name] = service
+		}
+	}
+
+	return m.applyMandatory()
+}
@@ -5,8 +5,67 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+// limit is the range of an integer setting, enforced by the validator and
+// published in the generated schemas
+type limit struct {
+	min, max int
+}
+
+// contains reports whether n is within the limit
+func (l limit) contains(n int) bool {
+	return n >= l.min && n <= l.max
+}
+
+// schema returns the limit as JSON Schema keywords
+func (l limit) schema() map[string]interface{} {
+	return map[string]interface{}{"minimum": l.min, "maximum": l.max}
+}
+
+// The limits of the integer settings
+var (
+	checkIntervalLimit    = limit{1, 300}
+	disconnectGraceLimit  = limit{0, 3600}
+	ttlLimit              = limit{10, 86400}
+	syncIntervalLimit     = limit{1, 720}
+	healthIntervalLimit   = limit{10, 3600}
+	healthTimeoutLimit    = limit{1, 60}
+	throttleIntervalLimit = limit{1, 3600}
+	niceLimit             = limit{-20, 20}
+	logSizeLimit          = limit{1, 1024}
+	debounceChecksLimit   = limit{1, 20}
+	commandTimeoutLimit   = limit{0, 60}
+	subscriptionLimit     = limit{0, 720}
+	priorityLimit         = limit{0, 1000}
+)
+
+// detectorNames holds the VPN detectors the detection settings may list
+// and weight, registered along with the detectors themselves
+var detectorNames = make(map[string]bool)
+
+// RegisterDetectorName makes a VPN detector known to ValidateDetection
+func RegisterDetectorName(name string) {
+	detectorNames[name] = true
+}
+
+// checkDetectorName checks that a detector is registered, so a misspelt
+// name doesn't silently leave a detector out
+func checkDetectorName(name string) error {
+	if detectorNames[name] {
+		return nil
+	}
+	known := make([]string, 0, len(detectorNames))
+	for detector := range detectorNames {
+		known = append(known, detector)
+	}
+	sort.Strings(known)
+	return fmt.Errorf("unknown detector '%s' (known: %s)", name, strings.Join(known, ", "))
+}
+
 // ValidateConfig validates the configuration
 func ValidateConfig(cfg *Config) error {
 	if cfg == nil {
@@ -21,8 +80,144 @@ func ValidateConfig(cfg *Config) error {
 	}
 
 	// Validate check interval
-	if cfg.CheckInterval < 1 || cfg.CheckInterval > 300 {
-		return fmt.Errorf("check_interval must be between 1 and 300 seconds")
+	if !checkIntervalLimit.contains(cfg.CheckInterval) {
+		return fmt.Errorf("check_interval must be between %d and %d seconds", checkIntervalLimit.min, checkIntervalLimit.max)
+	}
+
+	// Validate disconnect grace period
+	if !disconnectGraceLimit.contains(cfg.DisconnectGrace) {
+		return fmt.Errorf("disconnect_grace must be between %d and %d seconds", disconnectGraceLimit.min, disconnectGraceLimit.max)
+	}
+
+	// Validate connectivity probe
+	if cfg.ConnectivityCheck != "" &&
+		!strings.HasPrefix(cfg.ConnectivityCheck, "http://") && !strings.HasPrefix(cfg.ConnectivityCheck, "https://") {
+		return fmt.Errorf("connectivity_check must be an http(s) URL")
+	}
+
+	// Validate domain resolution TTL bounds
+	if !ttlLimit.contains(cfg.DomainResolution.MinTTL) {
+		return fmt.Errorf("domain_resolution.min_ttl must be between %d and %d seconds", ttlLimit.min, ttlLimit.max)
+	}
+	if cfg.DomainResolution.MaxTTL < cfg.DomainResolution.MinTTL || cfg.DomainResolution.MaxTTL > ttlLimit.max {
+		return fmt.Errorf("domain_resolution.max_ttl must be between min_ttl and %d seconds", ttlLimit.max)
+	}
+
+	if err := validateResolver(cfg.DomainResolution.Resolver); err != nil {
+		return fmt.Errorf("invalid domain_resolution.resolver: %w", err)
+	}
+
+	// Validate DNS proxy addresses
+	if cfg.DNSProxy.Enabled {
+		if _, _, err := net.SplitHostPort(cfg.DNSProxy.Listen); err != nil {
+			return fmt.Errorf("invalid dns_proxy.listen address %q: %w", cfg.DNSProxy.Listen, err)
+		}
+	}
+	if upstream := cfg.DNSProxy.Upstream; upstream != "" {
+		host, _, err := net.SplitHostPort(upstream)
+		if err != nil {
+			host = upstream
+		}
+		if net.ParseIP(host) == nil {
+			return fmt.Errorf("dns_proxy.upstream must be an IP address: %s", upstream)
+		}
+	}
+
+	// Validate split DNS nameserver
+	if cfg.SplitDNS.Nameserver != "" && net.ParseIP(cfg.SplitDNS.Nameserver) == nil {
+		return fmt.Errorf("split_dns.nameserver must be an IP address: %s", cfg.SplitDNS.Nameserver)
+	}
+
+	// Validate ASN sync settings
+	if !syncIntervalLimit.contains(cfg.ASNSync.Interval) {
+		return fmt.Errorf("asn_sync.interval must be between %d and %d hours", syncIntervalLimit.min, syncIntervalLimit.max)
+	}
+	if source := cfg.ASNSync.Source; source != "" &&
+		!strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return fmt.Errorf("asn_sync.source must be an http(s) URL")
+	}
+
+	// Validate network update settings
+	if !syncIntervalLimit.contains(cfg.NetworkUpdates.Interval) {
+		return fmt.Errorf("network_updates.interval must be between %d and %d hours", syncIntervalLimit.min, syncIntervalLimit.max)
+	}
+
+	// Validate health check settings
+	if !healthIntervalLimit.contains(cfg.HealthChecks.Interval) {
+		return fmt.Errorf("health_checks.interval must be between %d and %d seconds", healthIntervalLimit.min, healthIntervalLimit.max)
+	}
+	if !healthTimeoutLimit.contains(cfg.HealthChecks.Timeout) {
+		return fmt.Errorf("health_checks.timeout must be between %d and %d seconds", healthTimeoutLimit.min, healthTimeoutLimit.max)
+	}
+
+	// Validate route limits
+	if cfg.RouteLimits.MaxRoutes < 0 || cfg.RouteLimits.MaxServiceRoutes < 0 {
+		return fmt.Errorf("route_limits cannot be negative")
+	}
+	switch cfg.RouteLimits.Policy {
+	case "", RouteLimitRefuse, RouteLimitTruncate:
+	default:
+		return fmt.Errorf("route_limits.policy must be '%s' or '%s'", RouteLimitRefuse, RouteLimitTruncate)
+	}
+
+	// Validate launchd job settings
+	if label := cfg.Launchd.Label; label != "" && !validLabel(label) {
+		return fmt.Errorf("launchd.label must be letters, digits, dots and dashes: %s", label)
+	}
+	switch cfg.Launchd.KeepAlive {
+	case KeepAliveAlways, KeepAliveOnFailure, KeepAliveCrashed, KeepAliveNever:
+	default:
+		return fmt.Errorf("launchd.keep_alive must be '%s', '%s', '%s' or '%s'",
+			KeepAliveAlways, KeepAliveOnFailure, KeepAliveCrashed, KeepAliveNever)
+	}
+	if !throttleIntervalLimit.contains(cfg.Launchd.ThrottleInterval) {
+		return fmt.Errorf("launchd.throttle_interval must be between %d and %d seconds", throttleIntervalLimit.min, throttleIntervalLimit.max)
+	}
+	if !niceLimit.contains(cfg.Launchd.Nice) {
+		return fmt.Errorf("launchd.nice must be between %d and %d", niceLimit.min, niceLimit.max)
+	}
+	switch cfg.Logging.Format {
+	case LogFormatText, LogFormatJSON:
+	default:
+		return fmt.Errorf("logging.format must be '%s' or '%s'", LogFormatText, LogFormatJSON)
+	}
+	if len(cfg.Logging.Outputs) == 0 {
+		return fmt.Errorf("logging.outputs must list '%s', '%s' or both", LogOutputFile, LogOutputSyslog)
+	}
+	for _, output := range cfg.Logging.Outputs {
+		if output != LogOutputFile && output != LogOutputSyslog {
+			return fmt.Errorf("logging.outputs must list '%s', '%s' or both: %s", LogOutputFile, LogOutputSyslog, output)
+		}
+	}
+	if !logSizeLimit.contains(cfg.Logging.MaxSizeMB) {
+		return fmt.Errorf("logging.max_size_mb must be between %d and %d", logSizeLimit.min, logSizeLimit.max)
+	}
+	if cfg.Logging.MaxBackups < 0 || cfg.Logging.MaxAgeDays < 0 || cfg.Logging.MaxTotalSizeMB < 0 {
+		return fmt.Errorf("logging.max_backups, max_age_days and max_total_size_mb must not be negative")
+	}
+
+	// Validate catalog index
+	if url := cfg.CatalogURL; url != "" &&
+		!strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("catalog_url must be an http(s) URL")
+	}
+
+	// Validate subscriptions
+	seenSubscriptions := make(map[string]bool)
+	for i, sub := range cfg.Subscriptions {
+		if sub.Name == "" || strings.ContainsAny(sub.Name, "/\\ ") {
+			return fmt.Errorf("subscription %d: invalid name '%s'", i+1, sub.Name)
+		}
+		if seenSubscriptions[sub.Name] {
+			return fmt.Errorf("subscription '%s' listed more than once", sub.Name)
+		}
+		seenSubscriptions[sub.Name] = true
+		if !strings.HasPrefix(sub.URL, "http://") && !strings.HasPrefix(sub.URL, "https://") {
+			return fmt.Errorf("subscription '%s': url must be an http(s) URL", sub.Name)
+		}
+		if !subscriptionLimit.contains(sub.Interval) {
+			return fmt.Errorf("subscription '%s': interval must be between %d and %d hours", sub.Name, subscriptionLimit.min, subscriptionLimit.max)
+		}
 	}
 
 	// Validate directories
@@ -33,16 +228,190 @@ func ValidateConfig(cfg *Config) error {
 		return fmt.Errorf("state_dir cannot be empty")
 	}
 
+	// Validate detection settings
+	if err := ValidateDetection(&cfg.Detection); err != nil {
+		return fmt.Errorf("detection: %w", err)
+	}
+
+	// Validate network profiles
+	for i, profile := range cfg.Profiles {
+		if err := validateProfile(profile); err != nil {
+			return fmt.Errorf("profile %d: %w", i+1, err)
+		}
+	}
+
 	// Validate services
 	for name, service := range cfg.Services {
 		if err := ValidateService(name, service); err != nil {
 			return fmt.Errorf("service '%s': %w", name, err)
 		}
 	}
+	if err := ValidateExtends(cfg.Services); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ValidateDetection validates the VPN detection settings
+func ValidateDetection(detection *DetectionConfig) error {
+	if detection.Threshold <= 0 || detection.Threshold > 1 {
+		return fmt.Errorf("threshold must be greater than 0 and at most 1")
+	}
+
+	if !debounceChecksLimit.contains(detection.DebounceChecks) {
+		return fmt.Errorf("debounce_checks must be between %d and %d", debounceChecksLimit.min, debounceChecksLimit.max)
+	}
+
+	for signal, weight := range detection.Weights {
+		if err := checkDetectorName(signal); err != nil {
+			return err
+		}
+		if weight < 0 || weight > 1 {
+			return fmt.Errorf("weight for signal '%s' must be between 0 and 1", signal)
+		}
+	}
+
+	if !commandTimeoutLimit.contains(detection.CommandTimeout) {
+		return fmt.Errorf("command_timeout must be between %d and %d seconds", commandTimeoutLimit.min, commandTimeoutLimit.max)
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range detection.Detectors {
+		if err := checkDetectorName(name); err != nil {
+			return err
+		}
+		if seen[name] {
+			return fmt.Errorf("detector '%s' listed more than once", name)
+		}
+		seen[name] = true
+	}
+
+	for _, server := range detection.DNSServers {
+		if net.ParseIP(server) == nil {
+			if _, _, err := net.ParseCIDR(server); err != nil {
+				return fmt.Errorf("invalid DNS server address '%s'", server)
+			}
+		}
+	}
+
+	for i, match := range detection.Allowlist {
+		if err := validateVPNMatch(match); err != nil {
+			return fmt.Errorf("allowlist entry %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// validateVPNMatch validates a single VPN allowlist entry
+func validateVPNMatch(match VPNMatch) error {
+	if match.Server == "" && match.Interface == "" && match.Product == "" {
+		return fmt.Errorf("at least one of server, interface or product must be set")
+	}
+
+	if match.Server != "" && net.ParseIP(match.Server) == nil {
+		if _, _, err := net.ParseCIDR(match.Server); err != nil {
+			return fmt.Errorf("invalid server address '%s'", match.Server)
+		}
+	}
+
+	if match.Interface != "" {
+		if _, err := filepath.Match(match.Interface, ""); err != nil {
+			return fmt.Errorf("invalid interface pattern '%s': %w", match.Interface, err)
+		}
+	}
+
+	return nil
+}
+
+// validateProfile validates a network profile
+func validateProfile(profile NetworkProfile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("profile name cannot be empty")
+	}
+
+	if len(profile.SSIDs) == 0 && len(profile.Subnets) == 0 {
+		return fmt.Errorf("profile '%s' must match at least one SSID or subnet", profile.Name)
+	}
+
+	for _, subnet := range profile.Subnets {
+		if _, _, err := net.ParseCIDR(subnet); err != nil {
+			return fmt.Errorf("invalid subnet '%s' in profile '%s'", subnet, profile.Name)
+		}
+	}
 
 	return nil
 }
 
+// validLabel checks a launchd label, which also names the plist file: it
+// starts with a letter or digit and holds letters, digits, dots and dashes
+func validLabel(label string) bool {
+	for i, r := range label {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case (r == '.' || r == '-') && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validateResolver checks a resolver address: an IP with optional port,
+// an https:// URL or a tls://host[:port] address
+func validateResolver(resolver string) error {
+	switch {
+	case resolver == "":
+		return nil
+	case strings.HasPrefix(resolver, "https://"):
+		if len(resolver) == len("https://") {
+			return fmt.Errorf("missing host in %s", resolver)
+		}
+		return nil
+	case strings.HasPrefix(resolver, "tls://"):
+		if len(resolver) == len("tls://") {
+			return fmt.Errorf("missing host in %s", resolver)
+		}
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(resolver)
+	if err != nil {
+		host = resolver
+	}
+	if net.ParseIP(host) == nil {
+		return fmt.Errorf("%s is not an IP address, https:// URL or tls:// address", resolver)
+	}
+	return nil
+}
+
+// ValidateExtends checks that every service extends an existing service
+// and that no service ends up extending itself
+func ValidateExtends(services map[string]*Service) error {
+	for name, service := range services {
+		visited := map[string]bool{name: true}
+		for parent := service.Extends; parent != ""; parent = services[parent].Extends {
+			if _, exists := services[parent]; !exists {
+				return fmt.Errorf("service '%s': extends unknown service '%s'", name, parent)
+			}
+			if visited[parent] {
+				return fmt.Errorf("service '%s': extends forms a cycle through '%s'", name, parent)
+			}
+			visited[parent] = true
+		}
+	}
+	return nil
+}
+
+// ValidateServiceKey checks that a service key can be used as a file name
+func ValidateServiceKey(name string) error {
+	if name == "" || strings.ContainsAny(name, "/\\ \t") || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid service name '%s'", name)
+	}
+	return nil
+}
+
 // ValidateService validates a service configuration
 func ValidateService(name string, service *Service) error {
 	if service == nil {
@@ -53,8 +422,19 @@ func ValidateService(name string, service *Service) error {
 		return fmt.Errorf("service name cannot be empty")
 	}
 
-	if len(service.Networks) == 0 {
-		return fmt.Errorf("service must have at least one network")
+	// Networks of services with ASNs are filled in by syncing them
+	if len(service.Networks) == 0 && len(service.ASNs) == 0 && service.Extends == "" {
+		return fmt.Errorf("service must have at least one network, ASN or extends")
+	}
+	if service.Extends == name {
+		return fmt.Errorf("service cannot extend itself")
+	}
+
+	for _, asn := range service.ASNs {
+		number := strings.TrimPrefix(strings.ToUpper(asn), "AS")
+		if _, err := strconv.ParseUint(number, 10, 32); err != nil {
+			return fmt.Errorf("invalid ASN '%s'", asn)
+		}
 	}
 
 	// Validate network CIDR notation
@@ -66,8 +446,50 @@ func ValidateService(name string, service *Service) error {
 	}
 
 	// Validate priority
-	if service.Priority < 0 || service.Priority > 1000 {
-		return fmt.Errorf("priority must be between 0 and 1000")
+	if !priorityLimit.contains(service.Priority) {
+		return fmt.Errorf("priority must be between %d and %d", priorityLimit.min, priorityLimit.max)
+	}
+
+	// Wildcards are only allowed as the leading label
+	for _, domain := range service.Domains {
+		if strings.Contains(strings.TrimPrefix(domain, "*."), "*") {
+			return fmt.Errorf("invalid domain '%s': only a leading '*.' wildcard is supported", domain)
+		}
+	}
+
+	if service.MaxRoutes < 0 {
+		return fmt.Errorf("max_routes cannot be negative")
+	}
+
+	if service.Probe != "" {
+		host, port, err := net.SplitHostPort(service.Probe)
+		if err != nil || host == "" {
+			return fmt.Errorf("invalid probe '%s', expected host:port", service.Probe)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid probe port '%s'", port)
+		}
+	}
+
+	for i, rule := range service.Schedule {
+		if err := validateScheduleRule(rule); err != nil {
+			return fmt.Errorf("schedule rule %d: %w", i+1, err)
+		}
+	}
+
+	for _, tag := range service.Tags {
+		if tag == "" || strings.ContainsAny(tag, " ,\t") {
+			return fmt.Errorf("invalid tag '%s'", tag)
+		}
+	}
+
+	if url := service.NetworksURL; url != "" &&
+		!strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("networks_url must be an http(s) URL")
+	}
+
+	if service.Interface != "" && strings.ContainsAny(service.Interface, " /\t") {
+		return fmt.Errorf("invalid interface name '%s'", service.Interface)
 	}
 
 	return nil
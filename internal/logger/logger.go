@@ -1,13 +1,18 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/syslog"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"vpn-route-manager/internal/system"
 )
 
 // Level represents log levels
@@ -20,39 +25,110 @@ const (
 	ErrorLevel
 )
 
-// Logger handles structured logging with rotation
+// Log file formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Logger handles structured logging with rotation. Loggers made by With
+// share the file of the one they were made from, their base.
 type Logger struct {
 	mu           sync.Mutex
 	level        Level
 	file         *os.File
-	logger       *log.Logger
 	logPath      string
 	maxSize      int64
 	maxBackups   int
 	rotator      *Rotator
 	debugEnabled bool
+	console      io.Writer
+	format       string
+	syslog       *syslog.Writer
+	base         *Logger
+	fields       Fields
 }
 
-// Config holds logger configuration
+// Fields are the structured context of a log entry: the component that
+// logged it and the service, network and gateway it is about. Only the
+// JSON format records them apart from the message.
+type Fields struct {
+	Component string `json:"component,omitempty"`
+	Service   string `json:"service,omitempty"`
+	Network   string `json:"network,omitempty"`
+	Gateway   string `json:"gateway,omitempty"`
+}
+
+// merge returns the fields with those set in other replacing them
+func (f Fields) merge(other Fields) Fields {
+	if other.Component != "" {
+		f.Component = other.Component
+	}
+	if other.Service != "" {
+		f.Service = other.Service
+	}
+	if other.Network != "" {
+		f.Network = other.Network
+	}
+	if other.Gateway != "" {
+		f.Gateway = other.Gateway
+	}
+	return f
+}
+
+// Config holds logger configuration. Format is how entries are written
+// to the file, text unless json; the console always gets text. Without a
+// LogPath no file is written, and with Syslog entries also go to syslog,
+// tagged with SyslogTag.
+//
+// With Rotate the file is rotated once it reaches MaxSizeMB, and with
+// Daily also on the first write of a new day; only the daemon rotates, as
+// another process rotating the file would leave the daemon writing to the
+// old one. Rotated files are gzipped with Compress.
+// Of those, at most MaxBackups are kept, none older than MaxAgeDays, and
+// no more than fit in MaxTotalSizeMB along with the file; zero is no limit.
 type Config struct {
-	LogPath      string
-	MaxSizeMB    int
-	MaxBackups   int
-	Debug        bool
+	LogPath        string
+	MaxSizeMB      int
+	MaxBackups     int
+	Daily          bool
+	Compress       bool
+	MaxAgeDays     int
+	MaxTotalSizeMB int
+	Debug          bool
+	Console        io.Writer // Log lines are echoed here, stdout if nil
+	Format         string
+	Syslog         bool
+	SyslogTag      string
+	Rotate         bool
 }
 
 // New creates a new logger instance
 func New(config Config) (*Logger, error) {
-	// Ensure log directory exists
-	logDir := filepath.Dir(config.LogPath)
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	var file *os.File
+	if config.LogPath != "" {
+		// Ensure log directory exists
+		logDir := filepath.Dir(config.LogPath)
+		if err := os.MkdirAll(logDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+
+		// Open or create log file
+		var err error
+		file, err = os.OpenFile(config.LogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY|syscall.O_NOFOLLOW, system.SharedFileMode())
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
 	}
 
-	// Open or create log file
-	file, err := os.OpenFile(config.LogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open log file: %w", err)
+	// Losing syslog doesn't stop the daemon, entries still reach the console
+	var sysLog *syslog.Writer
+	if config.Syslog {
+		var err error
+		sysLog, err = syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, config.SyslogTag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect to syslog: %v\n", err)
+		}
 	}
 
 	level := InfoLevel
@@ -60,29 +136,55 @@ func New(config Config) (*Logger, error) {
 		level = DebugLevel
 	}
 
+	console := config.Console
+	if console == nil {
+		console = os.Stdout
+	}
+
 	l := &Logger{
 		level:        level,
 		file:         file,
-		logger:       log.New(io.MultiWriter(file, os.Stdout), "", 0),
 		logPath:      config.LogPath,
 		maxSize:      int64(config.MaxSizeMB) * 1024 * 1024,
 		maxBackups:   config.MaxBackups,
 		debugEnabled: config.Debug,
+		console:      console,
+		format:       config.Format,
+		syslog:       sysLog,
 	}
 
 	// Initialize rotator
-	l.rotator = NewRotator(l)
+	if config.Rotate {
+		l.rotator = NewRotator(l, config)
+	}
 
 	// Write startup message
 	l.Info("VPN Route Manager started")
-	l.Info("Log file: %s", config.LogPath)
+	if file != nil {
+		l.Info("Log file: %s", config.LogPath)
+	}
 	l.Info("Debug mode: %v", config.Debug)
 
 	return l, nil
 }
 
+// With returns a logger adding fields to the entries it logs, on top of
+// those of l, writing to the same file
+func (l *Logger) With(fields Fields) *Logger {
+	return &Logger{base: l.root(), fields: l.fields.merge(fields)}
+}
+
+// root returns the logger that owns the file
+func (l *Logger) root() *Logger {
+	if l.base != nil {
+		return l.base
+	}
+	return l
+}
+
 // SetLevel sets the logging level
 func (l *Logger) SetLevel(level Level) {
+	l = l.root()
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	l.level = level
@@ -90,6 +192,7 @@ func (l *Logger) SetLevel(level Level) {
 
 // SetDebug enables or disables debug logging
 func (l *Logger) SetDebug(enabled bool) {
+	l = l.root()
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	l.debugEnabled = enabled
@@ -102,6 +205,8 @@ func (l *Logger) SetDebug(enabled bool) {
 
 // log writes a log entry with the specified level
 func (l *Logger) log(level Level, format string, args ...interface{}) {
+	fields := l.fields
+	l = l.root()
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -109,23 +214,80 @@ func (l *Logger) log(level Level, format string, args ...interface{}) {
 		return
 	}
 
-	// Check if rotation is needed
-	if l.rotator.ShouldRotate() {
+	now := time.Now()
+
+	// Check if rotation is needed. The rotator can't log itself as the
+	// lock is held, so its message is written here.
+	if l.file != nil && l.rotator != nil && l.rotator.ShouldRotate(now) {
 		if err := l.rotator.Rotate(); err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to rotate log: %v\n", err)
+		} else {
+			l.write(InfoLevel, Entry{Time: now, Level: l.levelString(InfoLevel), Message: "Log rotated successfully"})
 		}
 	}
 
-	// Format timestamp and level
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	levelStr := l.levelString(level)
-	
-	// Format message
-	message := fmt.Sprintf(format, args...)
-	
-	// Write log entry
-	logEntry := fmt.Sprintf("%s [%s] %s", timestamp, levelStr, message)
-	l.logger.Println(logEntry)
+	l.write(level, Entry{
+		Time:    now,
+		Level:   l.levelString(level),
+		Message: fmt.Sprintf(format, args...),
+		Fields:  fields,
+	})
+}
+
+// write writes an entry to the console, the file and syslog
+func (l *Logger) write(level Level, entry Entry) {
+	line := entry.String()
+	fmt.Fprintln(l.console, line)
+	if l.format == FormatJSON {
+		line = entry.jsonLine()
+	}
+	if l.file != nil {
+		fmt.Fprintln(l.file, line)
+	}
+	if l.syslog != nil {
+		l.writeSyslog(level, entry)
+	}
+}
+
+// writeSyslog sends an entry to syslog at its level. Syslog records the
+// time itself, and the component starts the message so Console.app can
+// filter on it.
+func (l *Logger) writeSyslog(level Level, entry Entry) {
+	message := entry.Message
+	if l.format == FormatJSON {
+		message = entry.jsonLine()
+	} else if entry.Component != "" {
+		message = entry.Component + ": " + message
+	}
+
+	var err error
+	switch level {
+	case DebugLevel:
+		err = l.syslog.Debug(message)
+	case WarnLevel:
+		err = l.syslog.Warning(message)
+	case ErrorLevel:
+		err = l.syslog.Err(message)
+	default:
+		err = l.syslog.Info(message)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write to syslog: %v\n", err)
+	}
+}
+
+// jsonLine formats the entry as a line of the JSON log format
+func (e Entry) jsonLine() string {
+	data, err := json.Marshal(jsonEntry{
+		Time:    e.Time,
+		Level:   strings.ToLower(e.Level),
+		Fields:  e.Fields,
+		Message: e.Message,
+	})
+	if err != nil {
+		return e.String()
+	}
+	return string(data)
 }
 
 // Debug logs a debug message
@@ -159,9 +321,13 @@ func (l *Logger) Close() error {
 	// Log shutdown message before locking
 	l.Info("VPN Route Manager shutting down")
 	
+	l = l.root()
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	
+	if l.syslog != nil {
+		l.syslog.Close()
+	}
 	if l.file != nil {
 		return l.file.Close()
 	}
@@ -186,7 +352,7 @@ func (l *Logger) levelString(level Level) string {
 
 // GetLogPath returns the current log file path
 func (l *Logger) GetLogPath() string {
-	return l.logPath
+	return l.root().logPath
 }
 
 // GetLogSize returns the current log file size
@@ -205,12 +371,11 @@ func (l *Logger) reopenFile() error {
 		l.file.Close()
 	}
 
-	file, err := os.OpenFile(l.logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	file, err := os.OpenFile(l.logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY|syscall.O_NOFOLLOW, system.SharedFileMode())
 	if err != nil {
 		return err
 	}
 
 	l.file = file
-	l.logger = log.New(io.MultiWriter(file, os.Stdout), "", 0)
 	return nil
 }
\ No newline at end of file
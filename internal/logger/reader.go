@@ -0,0 +1,297 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// timeLayout is the timestamp format of log lines
+const timeLayout = "2006-01-02 15:04:05"
+
+// Entry is a log line parsed back into its parts. Lines that don't start
+// with a timestamp, such as the rest of a multi-line message, belong to
+// the entry before them. Source names the file for entries from other
+// files than the log, such as the launchd output streams. Fields are only
+// known for entries logged in the JSON format.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	Source  string    `json:"source,omitempty"`
+	Fields
+}
+
+// jsonEntry is an entry as written in the JSON log format, one object per
+// line with the level in lower case
+type jsonEntry struct {
+	Time  time.Time `json:"ts"`
+	Level string    `json:"level"`
+	Fields
+	Message string `json:"msg"`
+}
+
+// String formats the entry as it appears in the log
+func (e Entry) String() string {
+	if e.Time.IsZero() {
+		return e.Message
+	}
+	return fmt.Sprintf("%s [%s] %s", e.Time.Format(timeLayout), e.Level, e.Message)
+}
+
+// ParseLevel parses a level name such as "info" or "WARN"
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToUpper(name) {
+	case "DEBUG":
+		return DebugLevel, nil
+	case "INFO":
+		return InfoLevel, nil
+	case "WARN", "WARNING":
+		return WarnLevel, nil
+	case "ERROR":
+		return ErrorLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("invalid log level '%s': expected debug, info, warn or error", name)
+}
+
+// Filter selects log entries. Entries below Level, outside Since and
+// Until when they are set, or not matching Grep are left out.
+type Filter struct {
+	Level Level
+	Since time.Time
+	Until time.Time
+	Grep  *regexp.Regexp
+}
+
+// Match checks if the filter selects an entry
+func (f Filter) Match(e Entry) bool {
+	if level, err := ParseLevel(e.Level); err == nil && level < f.Level {
+		return false
+	}
+	if !f.Since.IsZero() && (e.Time.IsZero() || e.Time.Before(f.Since)) {
+		return false
+	}
+	if !f.Until.IsZero() && (e.Time.IsZero() || e.Time.After(f.Until)) {
+		return false
+	}
+	if f.Grep != nil && !f.Grep.MatchString(e.Message) {
+		return false
+	}
+	return true
+}
+
+// parseLine parses a log line in the text or JSON format, reporting false
+// for lines without the timestamp and level of a new entry
+func parseLine(line string) (Entry, bool) {
+	if strings.HasPrefix(line, "{") {
+		var entry jsonEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Time.IsZero() {
+			return Entry{}, false
+		}
+		return Entry{Time: entry.Time, Level: strings.ToUpper(entry.Level), Message: entry.Message, Fields: entry.Fields}, true
+	}
+	if len(line) < len(timeLayout)+3 || line[len(timeLayout)] != ' ' || line[len(timeLayout)+1] != '[' {
+		return Entry{}, false
+	}
+	t, err := time.ParseInLocation(timeLayout, line[:len(timeLayout)], time.Local)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	rest := line[len(timeLayout)+2:]
+	end := strings.Index(rest, "] ")
+	if end < 0 {
+		return Entry{}, false
+	}
+	return Entry{Time: t, Level: rest[:end], Message: rest[end+2:]}, true
+}
+
+// LogFiles returns the log at path and its rotated backups that exist,
+// oldest first, so reading them in order gives the whole history
+func LogFiles(path string) []string {
+	// Backups are numbered from path.1.log, the most recent
+	backups := logBackups(path)
+
+	var files []string
+	for i := len(backups) - 1; i >= 0; i-- {
+		files = append(files, backups[i].path)
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files
+}
+
+// ReadEntries reads the entries of files in order and returns those the
+// filter selects
+func ReadEntries(files []string, filter Filter) ([]Entry, error) {
+	var entries []Entry
+	for _, file := range files {
+		f, err := openLog(file)
+		if err != nil {
+			return nil, err
+		}
+		err = scanEntries(f, func(e Entry) {
+			if filter.Match(e) {
+				entries = append(entries, e)
+			}
+		})
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+	}
+	return entries, nil
+}
+
+// openLog opens a log file for reading, decompressing gzipped backups
+func openLog(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log: %w", err)
+	}
+	if !strings.HasSuffix(path, compressedExt) {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return gzipFile{gz, f}, nil
+}
+
+// gzipFile reads a gzipped file, closing both on Close
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+// Close closes the decompressor and the file
+func (g gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// scanEntries parses r into entries and calls fn for each once it is
+// complete
+func scanEntries(r io.Reader, fn func(Entry)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var current *Entry
+	for scanner.Scan() {
+		line := scanner.Text()
+		if entry, ok := parseLine(line); ok {
+			if current != nil {
+				fn(*current)
+			}
+			current = &entry
+		} else if current != nil {
+			current.Message += "\n" + line
+		} else if line != "" {
+			current = &Entry{Message: line}
+		}
+	}
+	if current != nil {
+		fn(*current)
+	}
+	return scanner.Err()
+}
+
+// Follow calls fn for every entry the filter selects as lines are
+// appended to the log at path, starting at its current end. The log is
+// reopened when it is rotated. It only returns on errors.
+func Follow(path string, filter Filter, fn func(Entry)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	defer func() { f.Close() }()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	var pending string
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			offset += int64(n)
+			pending += string(buf[:n])
+
+			// Only complete lines are parsed; a multi-line message is
+			// split into entries here, which is fine for following
+			if last := strings.LastIndexByte(pending, '\n'); last >= 0 {
+				scanEntries(strings.NewReader(pending[:last+1]), func(e Entry) {
+					if filter.Match(e) {
+						fn(e)
+					}
+				})
+				pending = pending[last+1:]
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		time.Sleep(500 * time.Millisecond)
+
+		// After a rotation path is a new, shorter file
+		if info, err := os.Stat(path); err == nil {
+			if current, err := f.Stat(); err == nil && (!os.SameFile(info, current) || info.Size() < offset) {
+				f.Close()
+				if f, err = os.Open(path); err != nil {
+					return fmt.Errorf("failed to reopen log: %w", err)
+				}
+				offset = 0
+				pending = ""
+			}
+		}
+	}
+}
+
+// MergeEntries interleaves entries from several sources, each in order,
+// by time. Entries without a time stay right after the entry before them
+// in their source, and on equal times earlier sources come first.
+func MergeEntries(sources ...[]Entry) []Entry {
+	var merged []Entry
+	next := make([]int, len(sources))
+	last := make([]time.Time, len(sources))
+
+	for {
+		pick := -1
+		var pickTime time.Time
+		for i, entries := range sources {
+			if next[i] >= len(entries) {
+				continue
+			}
+			t := entries[next[i]].Time
+			if t.IsZero() {
+				t = last[i]
+			}
+			if pick < 0 || t.Before(pickTime) {
+				pick, pickTime = i, t
+			}
+		}
+		if pick < 0 {
+			return merged
+		}
+
+		entry := sources[pick][next[pick]]
+		if !entry.Time.IsZero() {
+			last[pick] = entry.Time
+		}
+		merged = append(merged, entry)
+		next[pick]++
+	}
+}
@@ -1,32 +1,64 @@
 package logger
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
+
+	"vpn-route-manager/internal/system"
 )
 
+// compressedExt is added to the names of gzipped backups
+const compressedExt = ".gz"
+
 // Rotator handles log file rotation
 type Rotator struct {
-	logger     *Logger
-	maxSize    int64
-	maxBackups int
+	logger       *Logger
+	maxSize      int64
+	maxBackups   int
+	daily        bool
+	compress     bool
+	maxAge       time.Duration
+	maxTotalSize int64
+	lastWrite    time.Time
 }
 
 // NewRotator creates a new log rotator
-func NewRotator(logger *Logger) *Rotator {
-	return &Rotator{
-		logger:     logger,
-		maxSize:    logger.maxSize,
-		maxBackups: logger.maxBackups,
-	}
+func NewRotator(logger *Logger, config Config) *Rotator {
+	r := &Rotator{
+		logger:       logger,
+		maxSize:      logger.maxSize,
+		maxBackups:   logger.maxBackups,
+		daily:        config.Daily,
+		compress:     config.Compress,
+		maxAge:       time.Duration(config.MaxAgeDays) * 24 * time.Hour,
+		maxTotalSize: int64(config.MaxTotalSizeMB) * 1024 * 1024,
+		lastWrite:    time.Now(),
+	}
+
+	// A log left from an earlier day is rotated on the first write
+	if info, err := os.Stat(logger.logPath); err == nil && info.Size() > 0 {
+		r.lastWrite = info.ModTime()
+	}
+	return r
 }
 
-// ShouldRotate checks if rotation is needed
-func (r *Rotator) ShouldRotate() bool {
+// ShouldRotate checks if rotation is needed before writing at now, which
+// it records as the time of the last write
+func (r *Rotator) ShouldRotate(now time.Time) bool {
+	last := r.lastWrite
+	r.lastWrite = now
+	if r.daily && !sameDay(last, now) {
+		return true
+	}
+
 	size, err := r.logger.GetLogSize()
 	if err != nil {
 		return false
@@ -34,6 +66,13 @@ func (r *Rotator) ShouldRotate() bool {
 	return size >= r.maxSize
 }
 
+// sameDay reports whether a and b are on the same local day
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Local().Date()
+	by, bm, bd := b.Local().Date()
+	return ay == by && am == bm && ad == bd
+}
+
 // Rotate performs log rotation
 func (r *Rotator) Rotate() error {
 	// Close current file
@@ -46,19 +85,16 @@ func (r *Rotator) Rotate() error {
 	ext := filepath.Ext(basePath)
 	base := strings.TrimSuffix(basePath, ext)
 
-	// Rename existing backups
-	for i := r.maxBackups - 1; i > 0; i-- {
-		oldPath := fmt.Sprintf("%s.%d%s", base, i, ext)
-		newPath := fmt.Sprintf("%s.%d%s", base, i+1, ext)
-		
-		if _, err := os.Stat(oldPath); err == nil {
-			os.Rename(oldPath, newPath)
-		}
+	// Renumber existing backups, the oldest first so none is overwritten
+	backups := logBackups(basePath)
+	for i := len(backups) - 1; i >= 0; i-- {
+		b := backups[i]
+		os.Rename(b.path, backupPath(base, ext, b.number+1, b.compressed))
 	}
 
 	// Rename current log to .1
-	backupPath := fmt.Sprintf("%s.1%s", base, ext)
-	if err := os.Rename(basePath, backupPath); err != nil {
+	rotated := backupPath(base, ext, 1, false)
+	if err := os.Rename(basePath, rotated); err != nil {
 		return fmt.Errorf("failed to rename log file: %w", err)
 	}
 
@@ -67,108 +103,120 @@ func (r *Rotator) Rotate() error {
 		return fmt.Errorf("failed to create new log file: %w", err)
 	}
 
+	if r.compress {
+		if err := compressFile(rotated); err != nil {
+			return err
+		}
+	}
+
 	// Clean up old logs
 	r.cleanOldLogs()
-
-	r.logger.Info("Log rotated successfully")
 	return nil
 }
 
-// cleanOldLogs removes logs beyond maxBackups
-func (r *Rotator) cleanOldLogs() {
-	basePath := r.logger.logPath
-	ext := filepath.Ext(basePath)
-	base := strings.TrimSuffix(basePath, ext)
-	dir := filepath.Dir(basePath)
+// backup is a rotated log file, numbered from 1 for the most recent
+type backup struct {
+	path       string
+	number     int
+	compressed bool
+}
 
-	// Find all backup files
-	pattern := fmt.Sprintf("%s.*%s", filepath.Base(base), ext)
-	matches, err := filepath.Glob(filepath.Join(dir, pattern))
-	if err != nil {
-		return
+// backupPath returns the path of the backup numbered number of the log
+// base+ext
+func backupPath(base, ext string, number int, compressed bool) string {
+	path := fmt.Sprintf("%s.%d%s", base, number, ext)
+	if compressed {
+		path += compressedExt
 	}
+	return path
+}
 
-	// Sort by modification time
-	type fileInfo struct {
-		path    string
-		modTime time.Time
-	}
+// logBackups returns the backups of the log at path that exist, the most
+// recent first
+func logBackups(path string) []backup {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
 
-	var files []fileInfo
+	var backups []backup
+	matches, _ := filepath.Glob(base + ".*" + ext + "*")
 	for _, match := range matches {
-		if match == basePath {
-			continue // Skip current log
+		name := strings.TrimPrefix(match, base+".")
+		compressed := strings.HasSuffix(name, compressedExt)
+		name = strings.TrimSuffix(strings.TrimSuffix(name, compressedExt), ext)
+		if number, err := strconv.Atoi(name); err == nil {
+			backups = append(backups, backup{match, number, compressed})
 		}
-		
-		info, err := os.Stat(match)
-		if err != nil {
-			continue
-		}
-		
-		files = append(files, fileInfo{
-			path:    match,
-			modTime: info.ModTime(),
-		})
 	}
-
-	// Sort by modification time (newest first)
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].modTime.After(files[j].modTime)
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].number < backups[j].number
 	})
+	return backups
+}
+
+// compressFile gzips the file at path to path.gz and removes it
+func compressFile(path string) error {
+	in, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer in.Close()
 
-	// Remove files beyond maxBackups
-	for i := r.maxBackups; i < len(files); i++ {
-		os.Remove(files[i].path)
+	out, err := os.OpenFile(path+compressedExt, os.O_CREATE|os.O_TRUNC|os.O_WRONLY|syscall.O_NOFOLLOW, system.SharedFileMode())
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path+compressedExt, err)
 	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + compressedExt)
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	return os.Remove(path)
+}
 
-	// Also remove files older than 30 days
-	cutoff := time.Now().Add(-30 * 24 * time.Hour)
-	for _, file := range files {
-		if file.modTime.Before(cutoff) {
-			os.Remove(file.path)
+// cleanOldLogs removes the backups beyond maxBackups, older than maxAge or
+// past maxTotalSize along with the current log, keeping the most recent
+func (r *Rotator) cleanOldLogs() {
+	var total int64
+	if size, err := r.logger.GetLogSize(); err == nil {
+		total = size
+	}
+	cutoff := time.Now().Add(-r.maxAge)
+
+	for i, b := range logBackups(r.logger.logPath) {
+		info, err := os.Stat(b.path)
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+
+		switch {
+		case r.maxBackups > 0 && i >= r.maxBackups,
+			r.maxAge > 0 && info.ModTime().Before(cutoff),
+			r.maxTotalSize > 0 && total > r.maxTotalSize:
+			os.Remove(b.path)
 		}
 	}
 }
 
 // GetLogFiles returns all log files (current and backups)
 func (r *Rotator) GetLogFiles() ([]string, error) {
-	basePath := r.logger.logPath
-	ext := filepath.Ext(basePath)
-	base := strings.TrimSuffix(basePath, ext)
-	dir := filepath.Dir(basePath)
-
 	var files []string
 	
 	// Add current log file
-	if _, err := os.Stat(basePath); err == nil {
-		files = append(files, basePath)
-	}
-
-	// Add backup files
-	for i := 1; i <= r.maxBackups; i++ {
-		backupPath := fmt.Sprintf("%s.%d%s", base, i, ext)
-		if _, err := os.Stat(backupPath); err == nil {
-			files = append(files, backupPath)
-		}
+	if _, err := os.Stat(r.logger.logPath); err == nil {
+		files = append(files, r.logger.logPath)
 	}
 
-	// Also check for any other matching files
-	pattern := fmt.Sprintf("%s.*%s", filepath.Base(base), ext)
-	matches, err := filepath.Glob(filepath.Join(dir, pattern))
-	if err == nil {
-		for _, match := range matches {
-			// Avoid duplicates
-			found := false
-			for _, existing := range files {
-				if existing == match {
-					found = true
-					break
-				}
-			}
-			if !found {
-				files = append(files, match)
-			}
-		}
+	// Add backup files, compressed or not
+	for _, b := range logBackups(r.logger.logPath) {
+		files = append(files, b.path)
 	}
 
 	return files, nil
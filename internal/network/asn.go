@@ -0,0 +1,74 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultASNSource is the RIPEstat API used to look up announced prefixes
+const DefaultASNSource = "https://stat.ripe.net"
+
+// announcedPrefixes is the part of a RIPEstat announced-prefixes response
+// that lists the prefixes
+type announcedPrefixes struct {
+	Data struct {
+		Prefixes []struct {
+			Prefix string `json:"prefix"`
+		} `json:"prefixes"`
+	} `json:"data"`
+}
+
+// FetchASNPrefixes returns the IPv4 prefixes currently announced by the
+// given AS numbers (e.g. "AS62041"), deduplicated and sorted, as reported
+// by a RIPEstat-compatible BGP data source
+func FetchASNPrefixes(source string, asns []string) ([]string, error) {
+	if source == "" {
+		source = DefaultASNSource
+	}
+
+	seen := make(map[string]bool)
+	var prefixes []string
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, asn := range asns {
+		resource := "AS" + strings.TrimPrefix(strings.ToUpper(asn), "AS")
+		url := fmt.Sprintf("%s/data/announced-prefixes/data.json?resource=%s", strings.TrimSuffix(source, "/"), resource)
+
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch prefixes for %s: %w", resource, err)
+		}
+
+		var result announcedPrefixes
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch prefixes for %s: %s", resource, resp.Status)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix data for %s: %w", resource, err)
+		}
+
+		// Routes are only managed for IPv4
+		for _, entry := range result.Data.Prefixes {
+			ip, ipnet, err := net.ParseCIDR(entry.Prefix)
+			if err != nil || ip.To4() == nil || seen[ipnet.String()] {
+				continue
+			}
+			seen[ipnet.String()] = true
+			prefixes = append(prefixes, ipnet.String())
+		}
+	}
+
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("no IPv4 prefixes announced by %s", strings.Join(asns, ", "))
+	}
+
+	sort.Strings(prefixes)
+	return prefixes, nil
+}
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultConnectivityURL returns HTTP 204 when there is real internet access
+const DefaultConnectivityURL = "http://connectivitycheck.gstatic.com/generate_204"
+
+// CheckConnectivity probes the internet through the physical network behind
+// gateway, bypassing the VPN tunnel. It fails when the network has no
+// internet access or a captive portal intercepts the request, in which
+// case bypass routes would only break the affected services.
+func (m *Manager) CheckConnectivity(gateway string) error {
+	if m.connectivityURL == "" {
+		return nil
+	}
+
+	iface := interfaceForGateway(gateway)
+	if iface == "" {
+		return fmt.Errorf("no interface found for gateway %s", gateway)
+	}
+
+	cmd := exec.Command("curl", "-s", "-o", "/dev/null", "-w", "%{http_code}",
+		"--max-time", "5", "--interface", iface, m.connectivityURL)
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("no internet connectivity on %s: %w", iface, err)
+	}
+
+	code := strings.TrimSpace(string(output))
+	if code != "204" {
+		return fmt.Errorf("captive portal suspected on %s (probe returned HTTP %s)", iface, code)
+	}
+
+	return nil
+}
+
+// PingGateway checks that gateway answers a ping within timeout,
+// returning the round trip time
+func PingGateway(gateway string, timeout time.Duration) (time.Duration, error) {
+	seconds := int(timeout.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	output, err := exec.Command("ping", "-n", "-c", "1", "-t", strconv.Itoa(seconds), gateway).Output()
+	if err != nil {
+		return 0, fmt.Errorf("%s did not answer", gateway)
+	}
+
+	// The reply line ends in "time=1.234 ms"
+	for _, line := range strings.Split(string(output), "\n") {
+		i := strings.Index(line, "time=")
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(line[i+len("time="):])
+		if len(fields) == 0 {
+			continue
+		}
+		if ms, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			return time.Duration(ms * float64(time.Millisecond)), nil
+		}
+	}
+	return 0, nil
+}
+
+// interfaceForGateway returns the interface the kernel uses to reach gateway
+func interfaceForGateway(gateway string) string {
+	output, err := exec.Command("route", "-n", "get", gateway).Output()
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "interface:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "interface:"))
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,251 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/system"
+)
+
+// Built-in detector names, used as keys in the configured weights
+const (
+	SignalDefaultRoute = "default_route"
+	SignalVPNRoutes    = "vpn_routes"
+	SignalProcess      = "process"
+	SignalDNS          = "dns"
+	SignalCommand      = "command"
+)
+
+// Detector is a single VPN detection signal
+type Detector interface {
+	Name() string
+	Detect() bool
+}
+
+// VetoDetector is a detector that can also rule a VPN out, whatever the
+// other signals add up to
+type VetoDetector interface {
+	Detector
+	DetectVeto() (fired, veto bool)
+}
+
+// DetectorFactory creates a detector from the detection configuration.
+// It returns nil when the detector has nothing to check with the given
+// configuration.
+type DetectorFactory func(cfg config.DetectionConfig) Detector
+
+type registeredDetector struct {
+	weight  float64
+	factory DetectorFactory
+}
+
+var (
+	detectorRegistry = make(map[string]registeredDetector)
+	detectorOrder    []string
+)
+
+// RegisterDetector adds a detector to the registry with its default weight
+// and makes its name valid in the detection settings. Registering an
+// existing name replaces it.
+func RegisterDetector(name string, weight float64, factory DetectorFactory) {
+	if _, exists := detectorRegistry[name]; !exists {
+		detectorOrder = append(detectorOrder, name)
+	}
+	detectorRegistry[name] = registeredDetector{weight: weight, factory: factory}
+	config.RegisterDetectorName(name)
+}
+
+// RegisteredDetectors returns the names of all detectors in registration order
+func RegisteredDetectors() []string {
+	names := make([]string, len(detectorOrder))
+	copy(names, detectorOrder)
+	return names
+}
+
+// DefaultSignalWeights returns the default weight of each registered detector
+func DefaultSignalWeights() map[string]float64 {
+	weights := make(map[string]float64)
+	for name, entry := range detectorRegistry {
+		weights[name] = entry.weight
+	}
+	return weights
+}
+
+// buildDetectors instantiates the named detectors in order. Running as
+// root it refuses the command detector: the configuration is the user's
+// to write, so its command would let them run anything as root.
+func buildDetectors(names []string, cfg config.DetectionConfig) ([]Detector, error) {
+	var detectors []Detector
+	for _, name := range names {
+		entry, exists := detectorRegistry[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown detector '%s'", name)
+		}
+		if name == SignalCommand && cfg.Command != "" && !system.RequiresSudo() {
+			return nil, fmt.Errorf("detection.command can't be used when running as root, as with install --system")
+		}
+		if detector := entry.factory(cfg); detector != nil {
+			detectors = append(detectors, detector)
+		}
+	}
+	return detectors, nil
+}
+
+// init registers the built-in detectors, cheapest first. A utun default
+// route is strong enough on its own; private routes through utun (which
+// Docker and VM tools also create) need corroboration.
+func init() {
+	RegisterDetector(SignalDefaultRoute, 0.7, func(config.DetectionConfig) Detector {
+		return &funcDetector{name: SignalDefaultRoute, check: hasUTunDefaultRoute}
+	})
+	RegisterDetector(SignalVPNRoutes, 0.3, func(config.DetectionConfig) Detector {
+		return &funcDetector{name: SignalVPNRoutes, check: hasCorporateVPNInterface}
+	})
+	RegisterDetector(SignalDNS, 0.2, func(cfg config.DetectionConfig) Detector {
+		return &dnsDetector{servers: cfg.DNSServers, domains: cfg.DNSDomains}
+	})
+	RegisterDetector(SignalProcess, 0.3, func(config.DetectionConfig) Detector {
+		return &funcDetector{name: SignalProcess, check: hasVPNProcess}
+	})
+	RegisterDetector(SignalCommand, 1.0, func(cfg config.DetectionConfig) Detector {
+		if cfg.Command == "" {
+			return nil
+		}
+		timeout := 5 * time.Second
+		if cfg.CommandTimeout > 0 {
+			timeout = time.Duration(cfg.CommandTimeout) * time.Second
+		}
+		return &commandDetector{command: cfg.Command, timeout: timeout}
+	})
+}
+
+// funcDetector adapts a plain check function to the Detector interface
+type funcDetector struct {
+	name  string
+	check func() bool
+}
+
+// Name returns the detector name
+func (f *funcDetector) Name() string {
+	return f.name
+}
+
+// Detect runs the check function
+func (f *funcDetector) Detect() bool {
+	return f.check()
+}
+
+// hasVPNProcess checks for known VPN client processes
+func hasVPNProcess() bool {
+	return detectVPNProduct() != ""
+}
+
+// dnsDetector checks if the system DNS configuration points at VPN pushed
+// resolvers: either a resolver scoped to a utun interface, or one using a
+// configured corporate nameserver or search domain
+type dnsDetector struct {
+	servers []string
+	domains []string
+}
+
+// Name returns the detector name
+func (d *dnsDetector) Name() string {
+	return SignalDNS
+}
+
+// Detect inspects the current resolver configuration
+func (d *dnsDetector) Detect() bool {
+	for _, resolver := range GetResolvers() {
+		if strings.HasPrefix(resolver.Interface, "utun") {
+			return true
+		}
+
+		for _, nameserver := range resolver.Nameservers {
+			for _, pattern := range d.servers {
+				if matchesAddress(pattern, nameserver) {
+					return true
+				}
+			}
+		}
+
+		for _, domain := range resolver.Domains {
+			for _, corporate := range d.domains {
+				if MatchesDomain(corporate, domain) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// MatchesDomain checks if a domain equals or is a subdomain of another.
+// A "*." prefix on the parent matches subdomains only.
+func MatchesDomain(parent, domain string) bool {
+	parent = strings.ToLower(strings.TrimSuffix(parent, "."))
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if base := strings.TrimPrefix(parent, "*."); base != parent {
+		return strings.HasSuffix(domain, "."+base)
+	}
+	return domain == parent || strings.HasSuffix(domain, "."+parent)
+}
+
+// IsWildcardDomain checks if a domain is a "*.example.com" pattern
+func IsWildcardDomain(domain string) bool {
+	return strings.HasPrefix(domain, "*.")
+}
+
+// commandDetector runs an external command to decide the VPN state
+type commandDetector struct {
+	command string
+	timeout time.Duration
+}
+
+// Name returns the detector name
+func (c *commandDetector) Name() string {
+	return SignalCommand
+}
+
+// Detect runs the configured command and reports whether it said
+// connected
+func (c *commandDetector) Detect() bool {
+	fired, _ := c.DetectVeto()
+	return fired
+}
+
+// DetectVeto runs the configured command. Output of "connected" or
+// "disconnected" (or true/false, yes/no) decides the result; otherwise
+// exit status 0 means connected. Exit status 1 also vetoes the VPN, so it
+// counts as not connected whatever the other detectors find. Other
+// failures and timeouts count as not connected without a veto.
+func (c *commandDetector) DetectVeto() (fired, veto bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.command)
+	output, err := cmd.Output()
+	if ctx.Err() != nil {
+		return false, false
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, true
+	}
+
+	firstLine := strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)[0]
+	switch strings.ToLower(strings.TrimSpace(firstLine)) {
+	case "connected", "true", "yes", "1":
+		return true, false
+	case "disconnected", "false", "no", "0":
+		return false, false
+	}
+
+	return err == nil, false
+}
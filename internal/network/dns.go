@@ -0,0 +1,120 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DNSAnswer is an IPv4 address a domain resolved to and how long the
+// answer may be cached
+type DNSAnswer struct {
+	IP  string
+	TTL time.Duration
+}
+
+// ResolveDomain looks up the IPv4 addresses of a domain through the
+// configured resolver, or otherwise the local network's nameserver, so
+// answers match what the physical network (not the VPN) would serve
+func (m *Manager) ResolveDomain(domain string) ([]DNSAnswer, error) {
+	if m.dnsResolver != "" {
+		return resolveWith(m.dnsResolver, domain)
+	}
+
+	// dig falls back to the system resolver when no local nameserver is
+	// known
+	args := []string{"+noall", "+answer", "+time=2", "+tries=1"}
+	if server := m.gatewayDetector.LocalNameserver(); server != "" {
+		args = append(args, "@"+server)
+	}
+	args = append(args, domain, "A")
+
+	output, err := exec.Command("dig", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("dig failed for %s: %w", domain, err)
+	}
+
+	answers := parseDigAnswers(string(output))
+	if len(answers) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", domain)
+	}
+
+	return answers, nil
+}
+
+// resolveWith looks up the IPv4 addresses of a domain through a specific
+// resolver, which may use plain DNS, DNS over HTTPS or DNS over TLS
+func resolveWith(resolver, domain string) ([]DNSAnswer, error) {
+	query, err := buildDNSQuery(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := exchangeWithResolver(resolver, query)
+	if err != nil {
+		return nil, fmt.Errorf("query to %s failed for %s: %w", resolver, domain, err)
+	}
+	if len(response) < 2 || response[0] != query[0] || response[1] != query[1] {
+		return nil, fmt.Errorf("mismatched response from %s for %s", resolver, domain)
+	}
+
+	_, answers, err := parseDNSMessage(response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response from %s for %s: %w", resolver, domain, err)
+	}
+	if len(answers) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", domain)
+	}
+
+	return answers, nil
+}
+
+// parseDigAnswers extracts A records from `dig +noall +answer` output.
+// CNAME records in the chain are skipped.
+func parseDigAnswers(output string) []DNSAnswer {
+	var answers []DNSAnswer
+
+	for _, line := range strings.Split(output, "\n") {
+		// name TTL class type data
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[3] != "A" {
+			continue
+		}
+
+		ttl, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		if ip := net.ParseIP(fields[4]); ip == nil || ip.To4() == nil {
+			continue
+		}
+
+		answers = append(answers, DNSAnswer{
+			IP:  fields[4],
+			TTL: time.Duration(ttl) * time.Second,
+		})
+	}
+
+	return answers
+}
+
+// LocalNameserver returns the first DNS server handed out by DHCP on a
+// physical interface, or "" when there is none
+func (d *GatewayDetector) LocalNameserver() string {
+	for _, iface := range d.candidateInterfaces() {
+		output, err := exec.Command("ipconfig", "getoption", iface, "domain_name_server").Output()
+		if err != nil {
+			continue
+		}
+
+		server := strings.TrimSpace(string(output))
+		if net.ParseIP(server) != nil {
+			return server
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,113 @@
+package network
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dnsTimeout bounds a single query to any kind of resolver
+const dnsTimeout = 5 * time.Second
+
+// exchangeWithResolver sends a query to a resolver given as an IP address
+// with optional port (plain DNS), an https:// URL (DNS over HTTPS) or a
+// tls://host[:port] address (DNS over TLS)
+func exchangeWithResolver(resolver string, query []byte) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(resolver, "https://"):
+		return exchangeDoH(resolver, query)
+	case strings.HasPrefix(resolver, "tls://"):
+		return exchangeDoT(strings.TrimPrefix(resolver, "tls://"), query)
+	default:
+		return exchangeDNS(withDNSPort(resolver), query)
+	}
+}
+
+// buildDNSQuery encodes a recursive query for the A records of a domain
+func buildDNSQuery(domain string) ([]byte, error) {
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+
+	// ID, flags (recursion desired), one question
+	msg := []byte{id[0], id[1], 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0}
+	for _, label := range strings.Split(strings.TrimSuffix(domain, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid domain name %q", domain)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	// Root label, type A, class IN
+	msg = append(msg, 0, 0, 1, 0, 1)
+
+	return msg, nil
+}
+
+// exchangeDoH sends a query as an RFC 8484 POST request
+func exchangeDoH(url string, query []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	client := &http.Client{Timeout: dnsTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server returned %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 65535))
+}
+
+// exchangeDoT sends a query over a TLS connection using the two-byte
+// length framing of DNS over TCP
+func exchangeDoT(server string, query []byte) ([]byte, error) {
+	host := server
+	if h, _, err := net.SplitHostPort(server); err == nil {
+		host = h
+	} else {
+		server = net.JoinHostPort(server, "853")
+	}
+
+	dialer := &net.Dialer{Timeout: dnsTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", server, &tls.Config{ServerName: host})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dnsTimeout))
+
+	framed := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(framed, uint16(len(query)))
+	copy(framed[2:], query)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
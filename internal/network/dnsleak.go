@@ -0,0 +1,81 @@
+package network
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// DNSPath describes which nameserver answers queries for a domain and
+// which interface those queries leave through. Leak is set when they go
+// through a VPN tunnel instead of the local network.
+type DNSPath struct {
+	Domain     string
+	Nameserver string
+	Interface  string
+	Leak       bool
+}
+
+// CheckDNSPaths works out how queries for each domain are resolved, using
+// the same resolver selection as macOS: the most specific domain-scoped
+// resolver, or the default resolver otherwise
+func CheckDNSPaths(domains []string) []DNSPath {
+	output, err := exec.Command("scutil", "--dns").Output()
+	if err != nil {
+		return nil
+	}
+
+	// Only the unscoped configuration is used for ordinary queries
+	text := string(output)
+	if i := strings.Index(text, "DNS configuration (for scoped queries)"); i >= 0 {
+		text = text[:i]
+	}
+	resolvers := parseResolvers(text)
+
+	paths := make([]DNSPath, 0, len(domains))
+	for _, domain := range domains {
+		path := DNSPath{Domain: domain}
+
+		if resolver := selectResolver(resolvers, domain); resolver != nil && len(resolver.Nameservers) > 0 {
+			path.Nameserver = resolver.Nameservers[0]
+			path.Interface = interfaceForGateway(path.Nameserver)
+			path.Leak = isTunnelInterface(path.Interface)
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+// selectResolver picks the resolver macOS would use for a domain
+func selectResolver(resolvers []Resolver, domain string) *Resolver {
+	var best, fallback *Resolver
+
+	for i := range resolvers {
+		resolver := &resolvers[i]
+		if resolver.Domain == "" {
+			if fallback == nil && len(resolver.Nameservers) > 0 {
+				fallback = resolver
+			}
+			continue
+		}
+		if MatchesDomain(resolver.Domain, domain) && (best == nil || len(resolver.Domain) > len(best.Domain)) {
+			best = resolver
+		}
+	}
+
+	if best != nil {
+		return best
+	}
+	return fallback
+}
+
+// isTunnelInterface checks if an interface is a VPN tunnel
+func isTunnelInterface(iface string) bool {
+	for _, prefix := range []string{"utun", "ipsec", "ppp", "tun", "tap"} {
+		if strings.HasPrefix(iface, prefix) {
+			return true
+		}
+	}
+	return false
+}
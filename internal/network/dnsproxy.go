@@ -0,0 +1,268 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNSHandler is called with the question name and A records of every
+// upstream response, before the response is returned to the client
+type DNSHandler func(name string, answers []DNSAnswer)
+
+// DNSProxy is a minimal UDP DNS forwarder. Queries are relayed unchanged
+// to the upstream nameserver, and responses are inspected so routes can
+// be installed for the answered addresses before the client connects.
+type DNSProxy struct {
+	listen       string
+	upstream     string
+	detector     *GatewayDetector
+	handler      DNSHandler
+	logger       Logger
+	conn         *net.UDPConn
+	wg           sync.WaitGroup
+	mu           sync.Mutex
+	resolvedAt   time.Time
+	resolvedAddr string
+}
+
+// NewDNSProxy creates a DNS proxy listening on listen. An empty upstream
+// forwards to the nameserver handed out by DHCP on the physical network.
+func (m *Manager) NewDNSProxy(listen, upstream string, handler DNSHandler) *DNSProxy {
+	return &DNSProxy{
+		listen:   listen,
+		upstream: upstream,
+		detector: m.gatewayDetector,
+		handler:  handler,
+		logger:   m.logger,
+	}
+}
+
+// Start begins serving queries
+func (p *DNSProxy) Start() error {
+	addr, err := net.ResolveUDPAddr("udp", p.listen)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %s: %w", p.listen, err)
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", p.listen, err)
+	}
+	p.conn = conn
+
+	p.wg.Add(1)
+	go p.serve()
+
+	p.logger.Info("DNS proxy listening on %s", p.listen)
+	return nil
+}
+
+// Stop closes the listener and waits for in-flight queries
+func (p *DNSProxy) Stop() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.wg.Wait()
+	return err
+}
+
+// serve reads queries until the listener is closed
+func (p *DNSProxy) serve() {
+	defer p.wg.Done()
+
+	buf := make([]byte, 4096)
+	for {
+		n, client, err := p.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			p.logger.Debug("DNS proxy read failed: %v", err)
+			continue
+		}
+
+		query := make([]byte, n)
+		copy(query, buf[:n])
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.handle(query, client)
+		}()
+	}
+}
+
+// handle forwards one query and relays the response
+func (p *DNSProxy) handle(query []byte, client *net.UDPAddr) {
+	upstream := p.upstreamAddr()
+	if upstream == "" {
+		p.logger.Debug("DNS proxy has no upstream nameserver - dropping query")
+		return
+	}
+
+	response, err := exchangeDNS(upstream, query)
+	if err != nil {
+		p.logger.Debug("DNS proxy query to %s failed: %v", upstream, err)
+		return
+	}
+
+	if name, answers, err := parseDNSMessage(response); err == nil && len(answers) > 0 {
+		p.handler(name, answers)
+	}
+
+	if _, err := p.conn.WriteToUDP(response, client); err != nil {
+		p.logger.Debug("DNS proxy reply failed: %v", err)
+	}
+}
+
+// upstreamAddr returns the upstream nameserver address. The DHCP-provided
+// server is looked up at most every 30 seconds.
+func (p *DNSProxy) upstreamAddr() string {
+	if p.upstream != "" {
+		return withDNSPort(p.upstream)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Since(p.resolvedAt) > 30*time.Second {
+		p.resolvedAddr = ""
+		if server := p.detector.LocalNameserver(); server != "" {
+			p.resolvedAddr = withDNSPort(server)
+		}
+		p.resolvedAt = time.Now()
+	}
+
+	return p.resolvedAddr
+}
+
+// withDNSPort adds the default DNS port to an address without one
+func withDNSPort(addr string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(addr, "53")
+}
+
+// exchangeDNS sends a query to a nameserver and waits for its response
+func exchangeDNS(server string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", server, 3*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
+
+// parseDNSMessage returns the first question name and the A records of
+// a DNS message
+func parseDNSMessage(msg []byte) (string, []DNSAnswer, error) {
+	if len(msg) < 12 {
+		return "", nil, fmt.Errorf("message too short")
+	}
+
+	questions := int(binary.BigEndian.Uint16(msg[4:6]))
+	records := int(binary.BigEndian.Uint16(msg[6:8]))
+	offset := 12
+
+	var question string
+	for i := 0; i < questions; i++ {
+		name, next, err := readDNSName(msg, offset)
+		if err != nil {
+			return "", nil, err
+		}
+		if i == 0 {
+			question = name
+		}
+		// type and class
+		offset = next + 4
+	}
+
+	var answers []DNSAnswer
+	for i := 0; i < records; i++ {
+		_, next, err := readDNSName(msg, offset)
+		if err != nil {
+			return question, answers, err
+		}
+		if next+10 > len(msg) {
+			return question, answers, fmt.Errorf("truncated record")
+		}
+
+		rtype := binary.BigEndian.Uint16(msg[next : next+2])
+		class := binary.BigEndian.Uint16(msg[next+2 : next+4])
+		ttl := binary.BigEndian.Uint32(msg[next+4 : next+8])
+		length := int(binary.BigEndian.Uint16(msg[next+8 : next+10]))
+		data := next + 10
+		if data+length > len(msg) {
+			return question, answers, fmt.Errorf("truncated record data")
+		}
+
+		// A record in the IN class
+		if rtype == 1 && class == 1 && length == 4 {
+			answers = append(answers, DNSAnswer{
+				IP:  net.IP(msg[data : data+4]).String(),
+				TTL: time.Duration(ttl) * time.Second,
+			})
+		}
+		offset = data + length
+	}
+
+	return question, answers, nil
+}
+
+// readDNSName decodes a possibly compressed domain name at offset and
+// returns it with the offset just past it
+func readDNSName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	end := -1
+
+	for jumps := 0; jumps < 64; {
+		if offset >= len(msg) {
+			return "", 0, fmt.Errorf("name out of bounds")
+		}
+
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+			return strings.Join(labels, "."), end, nil
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(msg) {
+				return "", 0, fmt.Errorf("truncated name pointer")
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:offset+2]) & 0x3FFF)
+			jumps++
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, fmt.Errorf("label out of bounds")
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+
+	return "", 0, fmt.Errorf("too many name compression pointers")
+}
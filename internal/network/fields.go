@@ -0,0 +1,21 @@
+package network
+
+import "vpn-route-manager/internal/logger"
+
+// componentLogger returns log recording component with its entries, for
+// loggers that keep structured fields
+func componentLogger(log Logger, component string) Logger {
+	if l, ok := log.(*logger.Logger); ok {
+		return l.With(logger.Fields{Component: component})
+	}
+	return log
+}
+
+// routeLogger returns log recording the service, network and gateway of a
+// route with its entries, for loggers that keep structured fields
+func routeLogger(log Logger, service, network, gateway string) Logger {
+	if l, ok := log.(*logger.Logger); ok {
+		return l.With(logger.Fields{Service: service, Network: network, Gateway: gateway})
+	}
+	return log
+}
@@ -12,9 +12,13 @@ import (
 
 // GatewayDetector handles gateway detection
 type GatewayDetector struct {
-	cache        string
-	cacheTime    time.Time
+	cache         string
+	cacheTime     time.Time
+	cacheLink     string
 	cacheDuration time.Duration
+	staticGateway string
+	interfaces    []string
+	strict        bool
 }
 
 // NewGatewayDetector creates a new gateway detector
@@ -24,20 +28,133 @@ func NewGatewayDetector() *GatewayDetector {
 	}
 }
 
+// SetStaticGateway sets a fixed gateway that replaces detection.
+// An empty value or "auto" re-enables detection.
+func (d *GatewayDetector) SetStaticGateway(gateway string) {
+	if gateway == "auto" {
+		gateway = ""
+	}
+	d.staticGateway = gateway
+}
+
+// SetStrict enables strict mode, in which only authoritative sources
+// (DHCP, routing table, network settings) are trusted and no address is
+// guessed or returned as a fallback
+func (d *GatewayDetector) SetStrict(strict bool) {
+	d.strict = strict
+}
+
+// SetInterfacePriority sets the physical interfaces to try first, in order
+func (d *GatewayDetector) SetInterfacePriority(interfaces []string) {
+	d.interfaces = interfaces
+}
+
+// candidateInterfaces returns the physical interfaces to detect the
+// gateway on: the configured priority list first, then any other active
+// Ethernet-class interface with an IPv4 address
+func (d *GatewayDetector) candidateInterfaces() []string {
+	candidates := append([]string{}, d.interfaces...)
+	seen := make(map[string]bool)
+	for _, name := range candidates {
+		seen[name] = true
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return candidates
+	}
+
+	for _, iface := range ifaces {
+		if seen[iface.Name] || !strings.HasPrefix(iface.Name, "en") {
+			continue
+		}
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if !hasIPv4Address(iface) {
+			continue
+		}
+		candidates = append(candidates, iface.Name)
+	}
+
+	return candidates
+}
+
+// hasIPv4Address checks if an interface has an IPv4 address assigned
+func hasIPv4Address(iface net.Interface) bool {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// linkFingerprint summarizes the state of the physical interfaces: which
+// are up and which addresses they hold. Joining a different network,
+// plugging in a cable or losing Wi-Fi all change it.
+func linkFingerprint() string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+
+	var parts []string
+	for _, iface := range ifaces {
+		if !strings.HasPrefix(iface.Name, "en") || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			parts = append(parts, iface.Name+"="+addr.String())
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// InvalidateCache forgets the cached gateway so the next detection
+// queries the system again
+func (d *GatewayDetector) InvalidateCache() {
+	d.cache = ""
+	d.cacheLink = ""
+}
+
 // DetectGateway detects the local network gateway
 func (d *GatewayDetector) DetectGateway() (string, error) {
-	// Check cache first
-	if d.cache != "" && time.Since(d.cacheTime) < d.cacheDuration {
+	// A configured gateway skips detection entirely
+	if d.staticGateway != "" {
+		if !d.isGatewayReachable(d.staticGateway) {
+			return d.staticGateway, fmt.Errorf("configured gateway %s is not reachable", d.staticGateway)
+		}
+		return d.staticGateway, nil
+	}
+
+	// Check cache first, dropping it as soon as the physical links change
+	// so a network switch never serves the previous network's gateway
+	link := linkFingerprint()
+	if d.cache != "" && link == d.cacheLink && time.Since(d.cacheTime) < d.cacheDuration {
 		return d.cache, nil
 	}
 
 	// Try multiple detection methods
 	methods := []func() (string, error){
+		d.detectFromDHCP,
 		d.detectFromNetstat,
 		d.detectFromRoute,
 		d.detectFromNetworksetup,
-		d.detectFromIPConfig,
-		d.detectCommonGateways,
+	}
+	if !d.strict {
+		// Guessing from common addresses may pick a host that isn't
+		// the router at all
+		methods = append(methods, d.detectFromIPConfig, d.detectCommonGateways)
 	}
 
 	for _, method := range methods {
@@ -46,15 +163,62 @@ func (d *GatewayDetector) DetectGateway() (string, error) {
 			if !d.isVPNGateway(gateway) {
 				d.cache = gateway
 				d.cacheTime = time.Now()
+				d.cacheLink = link
 				return gateway, nil
 			}
 		}
 	}
 
+	if d.strict {
+		return "", fmt.Errorf("could not detect gateway reliably")
+	}
+
 	// Default fallback
 	return "192.168.1.1", fmt.Errorf("could not detect gateway reliably")
 }
 
+// detectFromDHCP reads the router option from the DHCP lease, which is
+// authoritative for the network the Mac is actually attached to
+func (d *GatewayDetector) detectFromDHCP() (string, error) {
+	for _, iface := range d.candidateInterfaces() {
+		cmd := exec.Command("ipconfig", "getoption", iface, "router")
+		output, err := cmd.Output()
+		if err != nil {
+			continue
+		}
+
+		gateway := strings.TrimSpace(string(output))
+		if net.ParseIP(gateway) != nil {
+			return gateway, nil
+		}
+	}
+
+	return "", fmt.Errorf("no DHCP lease with a router option found")
+}
+
+// DetectInterfaceGateway returns the gateway for routes bound to a given
+// interface: the router from its DHCP lease, or an empty string when the
+// interface has no router (a bridge or point-to-point link) and networks
+// should be routed directly onto it
+func (d *GatewayDetector) DetectInterfaceGateway(iface string) (string, error) {
+	link, err := net.InterfaceByName(iface)
+	if err != nil {
+		return "", fmt.Errorf("interface %s not found: %w", iface, err)
+	}
+	if link.Flags&net.FlagUp == 0 {
+		return "", fmt.Errorf("interface %s is down", iface)
+	}
+
+	output, err := exec.Command("ipconfig", "getoption", iface, "router").Output()
+	if err == nil {
+		if gateway := strings.TrimSpace(string(output)); net.ParseIP(gateway) != nil {
+			return gateway, nil
+		}
+	}
+
+	return "", nil
+}
+
 // detectFromNetstat uses netstat to find the gateway
 func (d *GatewayDetector) detectFromNetstat() (string, error) {
 	cmd := exec.Command("netstat", "-rn")
@@ -63,21 +227,29 @@ func (d *GatewayDetector) detectFromNetstat() (string, error) {
 		return "", err
 	}
 
+	// Collect default routes through physical interfaces
+	gateways := make(map[string]string)
 	scanner := bufio.NewScanner(strings.NewReader(string(output)))
 	for scanner.Scan() {
 		line := scanner.Text()
-		// Look for default route through physical interface (en0)
-		if strings.HasPrefix(line, "default") && strings.Contains(line, "en0") {
+		if strings.HasPrefix(line, "default") {
 			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				gateway := fields[1]
-				if net.ParseIP(gateway) != nil {
-					return gateway, nil
+			if len(fields) >= 4 {
+				gateway, iface := fields[1], fields[3]
+				if _, exists := gateways[iface]; !exists && net.ParseIP(gateway) != nil {
+					gateways[iface] = gateway
 				}
 			}
 		}
 	}
 
+	// Pick the one on the highest priority interface
+	for _, iface := range d.candidateInterfaces() {
+		if gateway, ok := gateways[iface]; ok {
+			return gateway, nil
+		}
+	}
+
 	return "", fmt.Errorf("no gateway found in netstat output")
 }
 
@@ -113,11 +285,15 @@ func (d *GatewayDetector) detectFromRoute() (string, error) {
 
 // detectFromNetworksetup uses networksetup to find gateway
 func (d *GatewayDetector) detectFromNetworksetup() (string, error) {
-	// Try WiFi first, then Ethernet
-	interfaces := []string{"Wi-Fi", "Ethernet"}
-	
-	for _, iface := range interfaces {
-		cmd := exec.Command("networksetup", "-getinfo", iface)
+	ports := d.hardwarePorts()
+
+	for _, iface := range d.candidateInterfaces() {
+		port, ok := ports[iface]
+		if !ok {
+			continue
+		}
+
+		cmd := exec.Command("networksetup", "-getinfo", port)
 		output, err := cmd.Output()
 		if err != nil {
 			continue
@@ -141,9 +317,43 @@ func (d *GatewayDetector) detectFromNetworksetup() (string, error) {
 	return "", fmt.Errorf("no gateway found via networksetup")
 }
 
+// hardwarePorts maps device names (en0) to hardware port names (Wi-Fi)
+func (d *GatewayDetector) hardwarePorts() map[string]string {
+	ports := make(map[string]string)
+
+	cmd := exec.Command("networksetup", "-listallhardwareports")
+	output, err := cmd.Output()
+	if err != nil {
+		return ports
+	}
+
+	var port string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "Hardware Port:") {
+			port = strings.TrimSpace(strings.TrimPrefix(line, "Hardware Port:"))
+		} else if strings.HasPrefix(line, "Device:") && port != "" {
+			ports[strings.TrimSpace(strings.TrimPrefix(line, "Device:"))] = port
+			port = ""
+		}
+	}
+
+	return ports
+}
+
 // detectFromIPConfig uses IP configuration to infer gateway
 func (d *GatewayDetector) detectFromIPConfig() (string, error) {
-	cmd := exec.Command("ifconfig", "en0")
+	for _, iface := range d.candidateInterfaces() {
+		if gateway, err := d.inferGateway(iface); err == nil {
+			return gateway, nil
+		}
+	}
+
+	return "", fmt.Errorf("could not infer gateway from IP")
+}
+
+// inferGateway guesses the gateway from an interface's own address
+func (d *GatewayDetector) inferGateway(iface string) (string, error) {
+	cmd := exec.Command("ifconfig", iface)
 	output, err := cmd.Output()
 	if err != nil {
 		return "", err
@@ -153,7 +363,7 @@ func (d *GatewayDetector) detectFromIPConfig() (string, error) {
 	ipRegex := regexp.MustCompile(`inet\s+(\d+\.\d+\.\d+\.\d+)`)
 	matches := ipRegex.FindStringSubmatch(string(output))
 	if len(matches) < 2 {
-		return "", fmt.Errorf("no IP found on en0")
+		return "", fmt.Errorf("no IP found on %s", iface)
 	}
 
 	ip := net.ParseIP(matches[1])
@@ -165,13 +375,13 @@ func (d *GatewayDetector) detectFromIPConfig() (string, error) {
 	if ip4 := ip.To4(); ip4 != nil {
 		// Try common patterns
 		gateway := fmt.Sprintf("%d.%d.%d.1", ip4[0], ip4[1], ip4[2])
-		if d.pingGateway(gateway) {
+		if d.isGatewayReachable(gateway) {
 			return gateway, nil
 		}
 		
 		// Try .254 as well (some routers use this)
 		gateway = fmt.Sprintf("%d.%d.%d.254", ip4[0], ip4[1], ip4[2])
-		if d.pingGateway(gateway) {
+		if d.isGatewayReachable(gateway) {
 			return gateway, nil
 		}
 	}
@@ -191,7 +401,7 @@ func (d *GatewayDetector) detectCommonGateways() (string, error) {
 	}
 
 	for _, gateway := range commonGateways {
-		if d.pingGateway(gateway) {
+		if d.isGatewayReachable(gateway) {
 			return gateway, nil
 		}
 	}
@@ -241,9 +451,41 @@ func (d *GatewayDetector) isPrivateIP(ip string) bool {
 	return false
 }
 
-// pingGateway checks if a gateway responds to ping
-func (d *GatewayDetector) pingGateway(gateway string) bool {
-	cmd := exec.Command("ping", "-c", "1", "-W", "1000", gateway)
-	err := cmd.Run()
-	return err == nil
-}
\ No newline at end of file
+// isGatewayReachable checks if a gateway answers ARP on the local link.
+// Many routers drop ICMP, but none can ignore ARP.
+func (d *GatewayDetector) isGatewayReachable(gateway string) bool {
+	if d.hasARPEntry(gateway) {
+		return true
+	}
+
+	// Sending any packet makes the kernel resolve the address via ARP
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(gateway, "9"), time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Write([]byte{0})
+	conn.Close()
+
+	for i := 0; i < 5; i++ {
+		time.Sleep(200 * time.Millisecond)
+		if d.hasARPEntry(gateway) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasARPEntry checks if the ARP table holds a resolved entry for an address
+func (d *GatewayDetector) hasARPEntry(ip string) bool {
+	cmd := exec.Command("arp", "-n", ip)
+	output, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+
+	// Resolved: "? (192.168.1.1) at aa:bb:cc:dd:ee:ff on en0 ifscope [ethernet]"
+	// Pending:  "? (192.168.1.1) at (incomplete) on en0 ifscope [ethernet]"
+	entry := string(output)
+	return strings.Contains(entry, " at ") && !strings.Contains(entry, "(incomplete)")
+}
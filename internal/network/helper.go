@@ -0,0 +1,196 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"vpn-route-manager/internal/system"
+)
+
+// minHelperPrefix is the shortest prefix the helper routes, so it can't
+// take over the default route, not even in halves like 0.0.0.0/1 and
+// 128.0.0.0/1 would
+const minHelperPrefix = 8
+
+var interfacePattern = regexp.MustCompile(`^[a-z]{1,12}[0-9]{1,3}$`)
+
+// RunHelper runs a command of the privileged helper, as root, and returns
+// its exit code. Every argument is checked here, so the sudoers entry for
+// the helper, or the helper daemon's socket, allows no more than these
+// commands:
+//
+//	route add -net <network> <gateway>
+//	route add -net <network> -interface <interface>
+//	route change -net <network> <gateway>
+//	route delete -net <network>
+//	route delete -net <network> <gateway>
+//	route delete -net <network> -interface <interface>
+//	resolver write <domain> <nameserver> <port>
+//	resolver remove <domain>
+//
+// Networks are IPv4 CIDRs with a prefix of at least /8 and gateways IPv4
+// addresses. Route output and its exit code are passed on. Registered with
+// launchd as the helper daemon it runs "serve <uid>" instead, taking these
+// commands over system.HelperSocket from that user.
+func RunHelper(args []string) int {
+	if len(args) == 2 && args[0] == "serve" {
+		if err := serveHelper(args[1]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	return runHelperCommand(args, os.Stdout, os.Stderr)
+}
+
+// runHelperCommand checks and runs a helper command, writing its output
+// to stdout and stderr, and returns its exit code
+func runHelperCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "route" {
+		if err := checkRouteArgs(args[1:]); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		cmd := exec.Command("/sbin/route", args[1:]...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		var exitErr *exec.ExitError
+		if err := cmd.Run(); errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		} else if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	if err := runHelper(args); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// serveHelper runs the helper daemon: it takes helper commands over
+// system.HelperSocket, which only root and the user with ID owner may use
+func serveHelper(owner string) error {
+	uid, err := strconv.Atoi(owner)
+	if err != nil || uid < 0 {
+		return fmt.Errorf("invalid user ID %q", owner)
+	}
+
+	// A socket left by an unclean shutdown would block listening
+	os.Remove(system.HelperSocket)
+	listener, err := net.Listen("unix", system.HelperSocket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", system.HelperSocket, err)
+	}
+	defer listener.Close()
+	if err := os.Chown(system.HelperSocket, uid, 0); err == nil {
+		err = os.Chmod(system.HelperSocket, 0600)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restrict %s to user %d: %w", system.HelperSocket, uid, err)
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go handleHelperConn(conn)
+	}
+}
+
+// handleHelperConn runs the helper command sent on a connection and
+// answers with its output and exit code
+func handleHelperConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	var request system.HelperRequest
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		return
+	}
+	var output bytes.Buffer
+	code := runHelperCommand(request.Args, &output, &output)
+	json.NewEncoder(conn).Encode(system.HelperResponse{Output: output.String(), Code: code})
+}
+
+// runHelper checks and runs a helper command other than route
+func runHelper(args []string) error {
+	switch {
+	case len(args) == 5 && args[0] == "resolver" && args[1] == "write":
+		if net.ParseIP(args[3]) == nil {
+			return fmt.Errorf("invalid nameserver %q", args[3])
+		}
+		port, err := strconv.Atoi(args[4])
+		if err != nil || port < 0 || port > 65535 {
+			return fmt.Errorf("invalid port %q", args[4])
+		}
+		return writeResolverFile(args[2], args[3], port)
+	case len(args) == 3 && args[0] == "resolver" && args[1] == "remove":
+		return removeResolverFile(args[2])
+	}
+	return fmt.Errorf("unsupported helper command")
+}
+
+// checkRouteArgs checks the arguments of a route command the helper runs
+func checkRouteArgs(args []string) error {
+	if len(args) < 3 || args[1] != "-net" {
+		return fmt.Errorf("unsupported route command")
+	}
+	if err := checkHelperNetwork(args[2]); err != nil {
+		return err
+	}
+
+	verb, rest := args[0], args[3:]
+	switch {
+	case len(rest) == 0 && verb == "delete":
+		return nil
+	case len(rest) == 1 && (verb == "add" || verb == "change" || verb == "delete"):
+		return checkHelperGateway(rest[0])
+	case len(rest) == 2 && rest[0] == "-interface" && (verb == "add" || verb == "delete"):
+		return checkHelperInterface(rest[1])
+	}
+	return fmt.Errorf("unsupported route command")
+}
+
+// checkHelperInterface checks that iface is an interface name
+func checkHelperInterface(iface string) error {
+	if !interfacePattern.MatchString(iface) {
+		return fmt.Errorf("invalid interface %q", iface)
+	}
+	return nil
+}
+
+// checkHelperNetwork checks that network is an IPv4 CIDR the helper may
+// route
+func checkHelperNetwork(network string) error {
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil || len(ipNet.Mask) != net.IPv4len {
+		return fmt.Errorf("invalid network %q", network)
+	}
+	if ones, _ := ipNet.Mask.Size(); ones < minHelperPrefix {
+		return fmt.Errorf("network %s is broader than /%d", network, minHelperPrefix)
+	}
+	return nil
+}
+
+// checkHelperGateway checks that gateway is an IPv4 address
+func checkHelperGateway(gateway string) error {
+	if ip := net.ParseIP(gateway); ip == nil || ip.To4() == nil || strings.Contains(gateway, ":") {
+		return fmt.Errorf("invalid gateway %q", gateway)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// publishedRanges covers the JSON layouts of published IP range lists:
+// Google's goog.json/cloud.json and RIPEstat announced-prefixes responses
+type publishedRanges struct {
+	Prefixes []struct {
+		IPv4Prefix string `json:"ipv4Prefix"`
+	} `json:"prefixes"`
+	Data struct {
+		Prefixes []struct {
+			Prefix string `json:"prefix"`
+		} `json:"prefixes"`
+	} `json:"data"`
+}
+
+// FetchPublishedNetworks downloads a published list of a service's IP
+// ranges and returns its IPv4 networks, deduplicated and sorted. The list
+// may be Google-style JSON, a RIPEstat response or plain text with one
+// CIDR per line.
+func FetchPublishedNetworks(url string) ([]string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", url, err)
+	}
+
+	var candidates []string
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var ranges publishedRanges
+		if err := json.Unmarshal(trimmed, &ranges); err != nil {
+			return nil, fmt.Errorf("invalid range list from %s: %w", url, err)
+		}
+		for _, prefix := range ranges.Prefixes {
+			candidates = append(candidates, prefix.IPv4Prefix)
+		}
+		for _, prefix := range ranges.Data.Prefixes {
+			candidates = append(candidates, prefix.Prefix)
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				candidates = append(candidates, line)
+			}
+		}
+	}
+
+	// Routes are only managed for IPv4
+	seen := make(map[string]bool)
+	var networks []string
+	for _, candidate := range candidates {
+		ip, ipnet, err := net.ParseCIDR(candidate)
+		if err != nil || ip.To4() == nil || seen[ipnet.String()] {
+			continue
+		}
+		seen[ipnet.String()] = true
+		networks = append(networks, ipnet.String())
+	}
+
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("no IPv4 networks found at %s", url)
+	}
+
+	sort.Strings(networks)
+	return networks, nil
+}
@@ -0,0 +1,70 @@
+package network
+
+import (
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// Location identifies the physical network the Mac is attached to
+type Location struct {
+	SSID      string
+	Addresses []string
+}
+
+// GetLocation returns the current Wi-Fi network name and the IPv4
+// addresses of the physical interfaces
+func (d *GatewayDetector) GetLocation() Location {
+	var location Location
+
+	for _, name := range d.candidateInterfaces() {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				location.Addresses = append(location.Addresses, ipnet.IP.String())
+			}
+		}
+	}
+
+	for device, port := range d.hardwarePorts() {
+		if port == "Wi-Fi" || port == "AirPort" {
+			location.SSID = getSSID(device)
+			break
+		}
+	}
+
+	return location
+}
+
+// getSSID returns the name of the Wi-Fi network a device is joined to
+func getSSID(device string) string {
+	// ipconfig works on recent macOS where networksetup no longer
+	// reports the network name
+	cmd := exec.Command("ipconfig", "getsummary", device)
+	if output, err := cmd.Output(); err == nil {
+		for _, line := range strings.Split(string(output), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "SSID : ") {
+				return strings.TrimPrefix(line, "SSID : ")
+			}
+		}
+	}
+
+	cmd = exec.Command("networksetup", "-getairportnetwork", device)
+	if output, err := cmd.Output(); err == nil {
+		const prefix = "Current Wi-Fi Network: "
+		line := strings.TrimSpace(string(output))
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimPrefix(line, prefix)
+		}
+	}
+
+	return ""
+}
@@ -3,6 +3,8 @@ package network
 import (
 	"fmt"
 	"time"
+
+	"vpn-route-manager/internal/config"
 )
 
 // Manager implements the NetworkManager interface
@@ -11,6 +13,8 @@ type Manager struct {
 	vpnDetector     *VPNDetector
 	routeManager    *RouteManager
 	logger          Logger
+	connectivityURL string
+	dnsResolver     string
 }
 
 // NewManager creates a new network manager
@@ -19,10 +23,35 @@ func NewManager(logger Logger) *Manager {
 		gatewayDetector: NewGatewayDetector(),
 		vpnDetector:     NewVPNDetector(),
 		routeManager:    NewRouteManager(logger),
-		logger:          logger,
+		logger:          componentLogger(logger, "network"),
+		connectivityURL: DefaultConnectivityURL,
 	}
 }
 
+// Configure applies configuration settings to the network components
+func (m *Manager) Configure(cfg *config.Config) error {
+	m.gatewayDetector.SetStaticGateway(cfg.Gateway)
+	m.gatewayDetector.SetInterfacePriority(cfg.Interfaces)
+	m.gatewayDetector.SetStrict(cfg.StrictGateway)
+	m.connectivityURL = cfg.ConnectivityCheck
+	m.dnsResolver = cfg.DomainResolution.Resolver
+	if err := m.vpnDetector.Configure(cfg.Detection); err != nil {
+		return fmt.Errorf("invalid detection configuration: %w", err)
+	}
+	return nil
+}
+
+// LocalNameserver returns the DNS server of the physical network
+func (m *Manager) LocalNameserver() string {
+	return m.gatewayDetector.LocalNameserver()
+}
+
+// InvalidateGatewayCache forces the next gateway detection to query the
+// system instead of returning a cached result
+func (m *Manager) InvalidateGatewayCache() {
+	m.gatewayDetector.InvalidateCache()
+}
+
 // DetectGateway detects the local network gateway
 func (m *Manager) DetectGateway() (string, error) {
 	gateway, err := m.gatewayDetector.DetectGateway()
@@ -30,13 +59,17 @@ func (m *Manager) DetectGateway() (string, error) {
 		m.logger.Error("Gateway detection failed: %v", err)
 		return gateway, err
 	}
-	m.logger.Info("Detected gateway: %s", gateway)
+	m.logger.Debug("Detected gateway: %s", gateway)
 	return gateway, nil
 }
 
 // IsVPNConnected checks if VPN is connected
 func (m *Manager) IsVPNConnected() bool {
-	connected := m.vpnDetector.IsVPNConnected()
+	detection := m.vpnDetector.Detect()
+	m.logger.Debug("VPN detection score %.2f (threshold %.2f), signals: %v, vetoed by: %q",
+		detection.Score, detection.Threshold, detection.Signals, detection.VetoedBy)
+
+	connected := detection.Connected
 	if connected {
 		iface := m.vpnDetector.GetVPNInterface()
 		gateway := m.vpnDetector.GetVPNGateway()
@@ -45,6 +78,32 @@ func (m *Manager) IsVPNConnected() bool {
 	return connected
 }
 
+// GetLocation returns the physical network the Mac is attached to
+func (m *Manager) GetLocation() Location {
+	return m.gatewayDetector.GetLocation()
+}
+
+// DetectVPN runs VPN detection and returns the full scoring result
+func (m *Manager) DetectVPN() Detection {
+	return m.vpnDetector.Detect()
+}
+
+// GetVPNInfo returns details about the active VPN connection
+func (m *Manager) GetVPNInfo() VPNInfo {
+	return m.vpnDetector.GetVPNInfo()
+}
+
+// GetVPNTunnel returns the VPN tunnel interface and its local address
+func (m *Manager) GetVPNTunnel() (string, string) {
+	iface := m.vpnDetector.GetVPNInterface()
+	return iface, m.vpnDetector.GetTunnelAddress(iface)
+}
+
+// GetResolvers returns the system DNS resolver configuration
+func (m *Manager) GetResolvers() []Resolver {
+	return GetResolvers()
+}
+
 // AddRoute adds a network route
 func (m *Manager) AddRoute(network, gateway, service string) error {
 	return m.routeManager.AddRoute(network, gateway, service)
@@ -60,18 +119,31 @@ func (m *Manager) RemoveAllRoutes() error {
 	return m.routeManager.RemoveAllRoutes()
 }
 
+// MigrateRoutes re-points all active routes at a new gateway
+func (m *Manager) MigrateRoutes(gateway string) error {
+	return m.routeManager.MigrateRoutes(gateway)
+}
+
 // GetActiveRoutes returns all active routes
 func (m *Manager) GetActiveRoutes() []Route {
 	return m.routeManager.GetActiveRoutes()
 }
 
-// AddServiceRoutes adds all routes for a service
-func (m *Manager) AddServiceRoutes(serviceName string, networks []string, gateway string) error {
+// AddServiceRoutes adds all routes for a service. When iface is set the
+// routes are bound to that interface and use its own gateway instead.
+func (m *Manager) AddServiceRoutes(serviceName string, networks []string, gateway, iface string) error {
+	if iface != "" {
+		var err error
+		if gateway, err = m.gatewayDetector.DetectInterfaceGateway(iface); err != nil {
+			return err
+		}
+	}
+
 	var errors []string
 	addedCount := 0
 
 	for _, network := range networks {
-		if err := m.AddRoute(network, gateway, serviceName); err != nil {
+		if err := m.routeManager.AddInterfaceRoute(network, gateway, iface, serviceName); err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %v", network, err))
 		} else {
 			addedCount++
@@ -113,6 +185,30 @@ func (m *Manager) VerifyRoutes() map[string]bool {
 	return m.routeManager.VerifyAllRoutes()
 }
 
+// CheckRoutes compares the active routes to the routing table
+func (m *Manager) CheckRoutes() ([]RouteCheck, error) {
+	table, err := ReadRoutingTable()
+	if err != nil {
+		return nil, err
+	}
+	return CheckRoutes(m.GetActiveRoutes(), table), nil
+}
+
+// ClearRoutes deletes routes from the routing table, tracked or not
+func (m *Manager) ClearRoutes(routes []Route) (int, error) {
+	return m.routeManager.ClearRoutes(routes)
+}
+
+// RepairRoute makes the routing table match a checked route
+func (m *Manager) RepairRoute(check RouteCheck) error {
+	return m.routeManager.RepairRoute(check)
+}
+
+// RestoreRoute adds a missing route back to the routing table
+func (m *Manager) RestoreRoute(route Route) error {
+	return m.routeManager.RestoreRoute(route)
+}
+
 // GetStatus returns current network status
 func (m *Manager) GetStatus() map[string]interface{} {
 	status := make(map[string]interface{})
@@ -0,0 +1,57 @@
+package network
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// TrafficPath describes how traffic to an address leaves the machine: the
+// gateway and interface the kernel routes it through and, when traced,
+// the first hop that answered. Bypassed is set when it goes through the
+// local gateway rather than a VPN tunnel.
+type TrafficPath struct {
+	Address   string `json:"address"`
+	Gateway   string `json:"gateway,omitempty"`
+	Interface string `json:"interface,omitempty"`
+	FirstHop  string `json:"first_hop,omitempty"`
+	Bypassed  bool   `json:"bypassed"`
+}
+
+// CheckTrafficPath works out the path to address with `route get` and,
+// with trace, the first hop with traceroute. The path is bypassed when it
+// avoids tunnel interfaces and goes via gateway, or directly onto the
+// local network; a traced first hop must be gateway too.
+func CheckTrafficPath(address, gateway string, trace bool) TrafficPath {
+	path := TrafficPath{Address: address}
+	path.Gateway, path.Interface = routeLookup(address)
+	path.Bypassed = path.Interface != "" && !isTunnelInterface(path.Interface) &&
+		(path.Gateway == gateway || path.Gateway == "")
+
+	if trace {
+		path.FirstHop = firstHop(address)
+		if path.FirstHop != "" && path.FirstHop != gateway {
+			path.Bypassed = false
+		}
+	}
+
+	return path
+}
+
+// firstHop returns the address of the first hop on the way to address, or
+// nothing when it didn't answer
+func firstHop(address string) string {
+	output, err := exec.Command("traceroute", "-n", "-m", "1", "-q", "1", "-w", "2", address).Output()
+	if err != nil {
+		return ""
+	}
+
+	// The hop line is " 1  192.168.1.1  1.234 ms", or " 1  *" without an
+	// answer
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "1" && fields[1] != "*" {
+			return fields[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Probe opens a TCP connection to target (host:port) and returns how long
+// it took. It fails when the target isn't routed via gateway, since a
+// connection through the VPN says nothing about the bypass route.
+func (m *Manager) Probe(target, gateway string, timeout time.Duration) (time.Duration, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return 0, fmt.Errorf("invalid probe target %s: %w", target, err)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		addrs, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+		if err != nil || len(addrs) == 0 {
+			return 0, fmt.Errorf("failed to resolve %s: %v", host, err)
+		}
+		ip = addrs[0]
+	}
+
+	if via := routeGateway(ip.String()); via != gateway {
+		return 0, fmt.Errorf("%s is routed via %s, not the bypass gateway %s", ip, via, gateway)
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip.String(), port), timeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to %s: %w", target, err)
+	}
+	conn.Close()
+
+	return time.Since(start), nil
+}
+
+// routeGateway returns the gateway the kernel uses to reach address, or
+// the interface when it is reached directly
+func routeGateway(address string) string {
+	gateway, iface := routeLookup(address)
+	if gateway != "" {
+		return gateway
+	}
+	return iface
+}
+
+// routeLookup returns the gateway and interface the kernel uses to reach
+// address. The gateway is empty when it is reached directly.
+func routeLookup(address string) (string, string) {
+	output, err := exec.Command("route", "-n", "get", address).Output()
+	if err != nil {
+		return "", ""
+	}
+
+	gateway, iface := "", ""
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "gateway:"):
+			gateway = strings.TrimSpace(strings.TrimPrefix(line, "gateway:"))
+		case strings.HasPrefix(line, "interface:"):
+			iface = strings.TrimSpace(strings.TrimPrefix(line, "interface:"))
+		}
+	}
+
+	return gateway, iface
+}
@@ -0,0 +1,43 @@
+package network
+
+import "time"
+
+// Progress reports on a bulk route operation service by service: how
+// many routes each had, how long they took and how far along the whole
+// operation is, so adding or removing hundreds of routes isn't silent
+// until the end
+type Progress struct {
+	logger   Logger
+	verb     string
+	total    int
+	done     int
+	services int
+	started  time.Time
+	step     time.Time
+}
+
+// NewProgress starts reporting on an operation on total routes. Verb is
+// the past tense the reports use, such as "Added".
+func NewProgress(logger Logger, verb string, total int) *Progress {
+	now := time.Now()
+	return &Progress{logger: logger, verb: verb, total: total, started: now, step: now}
+}
+
+// Start marks the start of the next service's routes
+func (p *Progress) Start() {
+	p.step = time.Now()
+}
+
+// Done reports the routes of a service as done
+func (p *Progress) Done(service string, count int) {
+	p.done += count
+	p.services++
+	p.logger.Info("%s %d routes for %s in %v (%d/%d routes)",
+		p.verb, count, service, time.Since(p.step).Round(time.Millisecond), p.done, p.total)
+}
+
+// Finish reports the totals of the operation
+func (p *Progress) Finish() {
+	p.logger.Info("%s %d routes for %d services in %v",
+		p.verb, p.done, p.services, time.Since(p.started).Round(time.Millisecond))
+}
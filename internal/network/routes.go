@@ -7,15 +7,17 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"vpn-route-manager/internal/system"
 )
 
 // Route represents a network route
 type Route struct {
-	Network   string
-	Gateway   string
-	Interface string
-	AddedAt   time.Time
-	Service   string
+	Network   string    `json:"network"`
+	Gateway   string    `json:"gateway"`
+	Interface string    `json:"interface,omitempty"`
+	AddedAt   time.Time `json:"added_at"`
+	Service   string    `json:"service"`
 }
 
 // RouteManager handles route manipulation
@@ -36,12 +38,22 @@ type Logger interface {
 func NewRouteManager(logger Logger) *RouteManager {
 	return &RouteManager{
 		activeRoutes: make(map[string]*Route),
-		logger:       logger,
+		logger:       componentLogger(logger, "routes"),
 	}
 }
 
 // AddRoute adds a network route
 func (m *RouteManager) AddRoute(network, gateway, service string) error {
+	return m.AddInterfaceRoute(network, gateway, "", service)
+}
+
+// AddInterfaceRoute adds a network route bound to an interface. With a
+// gateway the route goes through that gateway, which must be on the
+// interface's subnet so the kernel sends it out there; it isn't scoped
+// with -ifscope, as macOS only uses scoped routes for traffic already bound
+// to the interface. Without one the network is routed directly onto the
+// interface.
+func (m *RouteManager) AddInterfaceRoute(network, gateway, iface, service string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -53,32 +65,40 @@ func (m *RouteManager) AddRoute(network, gateway, service string) error {
 
 	// Check if route already exists
 	if existing, exists := m.activeRoutes[network]; exists {
-		if existing.Gateway == gateway {
+		if existing.Gateway == gateway && existing.Interface == iface {
 			m.logger.Debug("Route for %s already exists with gateway %s", network, gateway)
 			return nil
 		}
 		// Remove existing route first
-		if err := m.removeRouteCommand(network); err != nil {
+		if err := m.removeRouteCommand(*existing); err != nil {
 			m.logger.Error("Failed to remove existing route for %s: %v", network, err)
 		}
 	}
 
 	// Add the route
-	cmd := exec.Command("sudo", "route", "add", "-net", network, gateway)
+	if gateway == "" && iface == "" {
+		return fmt.Errorf("no gateway or interface for %s", network)
+	}
+	cmd := sudoCommand(routeArgs("add", network, gateway, iface)...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("failed to add route: %s: %w", string(output), err)
+		return fmt.Errorf("failed to add route: %s: %w", string(output), routeFailed(output, err))
 	}
 
 	// Store route information
 	m.activeRoutes[network] = &Route{
-		Network: network,
-		Gateway: gateway,
-		AddedAt: time.Now(),
-		Service: service,
+		Network:   network,
+		Gateway:   gateway,
+		Interface: iface,
+		AddedAt:   time.Now(),
+		Service:   service,
 	}
 
-	m.logger.Info("Added route: %s -> %s (service: %s)", network, gateway, service)
+	if iface != "" {
+		routeLogger(m.logger, service, network, gateway).Info("Added route: %s -> %s via %s (service: %s)", network, gateway, iface, service)
+	} else {
+		routeLogger(m.logger, service, network, gateway).Info("Added route: %s -> %s (service: %s)", network, gateway, service)
+	}
 	return nil
 }
 
@@ -93,25 +113,57 @@ func (m *RouteManager) RemoveRoute(network string) error {
 		return nil
 	}
 
-	if err := m.removeRouteCommand(network); err != nil {
+	if err := m.removeRouteCommand(*route); err != nil {
 		return err
 	}
 
 	delete(m.activeRoutes, network)
-	m.logger.Info("Removed route: %s (service: %s)", network, route.Service)
+	routeLogger(m.logger, route.Service, network, route.Gateway).Info("Removed route: %s (service: %s)", network, route.Service)
 	return nil
 }
 
+// privilegedCommand is a command run as root, directly or through the
+// privileged helper
+type privilegedCommand interface {
+	CombinedOutput() ([]byte, error)
+}
+
+// sudoCommand runs a command through the privileged helper, over the helper
+// daemon's socket or with sudo, or directly when running as root like the
+// system daemon, which needs neither
+func sudoCommand(args ...string) privilegedCommand {
+	if !system.RequiresSudo() {
+		return exec.Command(args[0], args[1:]...)
+	}
+	return system.HelperCommand(args...)
+}
+
+// routeFailed classifies the error of a failed route command as
+// system.ErrRouteFailed, or system.ErrPermission when sudo refused it
+func routeFailed(output []byte, err error) error {
+	return system.CommandFailed(output, err, system.ErrRouteFailed)
+}
+
+// routeArgs returns the route command adding or deleting a route: through
+// its gateway, or directly onto the interface when it has no gateway
+func routeArgs(verb, network, gateway, iface string) []string {
+	args := []string{"route", verb, "-net", network}
+	if gateway == "" {
+		return append(args, "-interface", iface)
+	}
+	return append(args, gateway)
+}
+
 // removeRouteCommand executes the route delete command
-func (m *RouteManager) removeRouteCommand(network string) error {
-	cmd := exec.Command("sudo", "route", "delete", "-net", network)
+func (m *RouteManager) removeRouteCommand(route Route) error {
+	cmd := sudoCommand("route", "delete", "-net", route.Network)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		// If route doesn't exist, that's OK
 		if strings.Contains(string(output), "not in table") {
 			return nil
 		}
-		return fmt.Errorf("failed to remove route: %s: %w", string(output), err)
+		return fmt.Errorf("failed to remove route: %s: %w", string(output), routeFailed(output, err))
 	}
 	return nil
 }
@@ -122,8 +174,8 @@ func (m *RouteManager) RemoveAllRoutes() error {
 	defer m.mu.Unlock()
 
 	var errors []string
-	for network := range m.activeRoutes {
-		if err := m.removeRouteCommand(network); err != nil {
+	for network, route := range m.activeRoutes {
+		if err := m.removeRouteCommand(*route); err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %v", network, err))
 		} else {
 			delete(m.activeRoutes, network)
@@ -131,7 +183,8 @@ func (m *RouteManager) RemoveAllRoutes() error {
 	}
 
 	if len(errors) > 0 {
-		return fmt.Errorf("failed to remove some routes: %s", strings.Join(errors, "; "))
+		failed := strings.Join(errors, "; ")
+		return routeFailed([]byte(failed), fmt.Errorf("failed to remove some routes: %s", failed))
 	}
 
 	m.logger.Info("Removed all %d active routes", len(m.activeRoutes))
@@ -163,75 +216,15 @@ func (m *RouteManager) VerifyRoute(network string) bool {
 
 	// Check the actual routing table using netstat
 	// This is more reliable than "route get" for broad network ranges
-	cmd := exec.Command("netstat", "-rn")
-	output, err := cmd.Output()
+	table, err := ReadRoutingTable()
 	if err != nil {
 		return false
 	}
-
-	// Parse CIDR to get network address
-	ip, ipnet, err := net.ParseCIDR(network)
-	if err != nil {
+	if check := CheckRoutes([]Route{*route}, table)[0]; !check.Present {
+		m.logger.Debug("Route verification failed: network=%s, gateway=%s", network, route.Gateway)
 		return false
 	}
-
-	// Format network for netstat matching
-	// netstat on macOS shows networks without trailing zeros:
-	// 172.217.0.0/16 -> "172.217/16"
-	// 74.125.0.0/16 -> "74.125/16"
-	// 91.108.4.0/22 -> "91.108.4/22"
-	// 185.76.151.0/24 -> "185.76.151/24"
-	
-	ones, _ := ipnet.Mask.Size()
-	ipBytes := ip.To4()
-	
-	// Build the netstat format by removing trailing zero octets
-	var netstatFormat string
-	
-	// Special handling for /16 networks
-	if ones == 16 && ipBytes[3] == 0 && ipBytes[2] == 0 {
-		// All /16 networks with .0.0 are shown without /16 suffix
-		// e.g., 172.217.0.0/16 -> "172.217"
-		netstatFormat = fmt.Sprintf("%d.%d", ipBytes[0], ipBytes[1])
-	} else if ipBytes[3] == 0 && ipBytes[2] == 0 && ipBytes[1] == 0 {
-		// x.0.0.0/n -> x/n
-		netstatFormat = fmt.Sprintf("%d/%d", ipBytes[0], ones)
-	} else if ipBytes[3] == 0 && ipBytes[2] == 0 {
-		// x.y.0.0/n -> x.y/n (for non-/16 networks)
-		netstatFormat = fmt.Sprintf("%d.%d/%d", ipBytes[0], ipBytes[1], ones)
-	} else if ipBytes[3] == 0 {
-		// x.y.z.0/n -> x.y.z/n
-		netstatFormat = fmt.Sprintf("%d.%d.%d/%d", ipBytes[0], ipBytes[1], ipBytes[2], ones)
-	} else {
-		// x.y.z.w/n -> x.y.z.w/n
-		netstatFormat = fmt.Sprintf("%d.%d.%d.%d/%d", ipBytes[0], ipBytes[1], ipBytes[2], ipBytes[3], ones)
-	}
-
-	// Check if the route exists in the routing table with our gateway
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		// Skip empty lines and headers
-		if line == "" || strings.Contains(line, "Destination") || strings.Contains(line, "Internet") {
-			continue
-		}
-		
-		// Split the line to check destination and gateway
-		fields := strings.Fields(line)
-		if len(fields) >= 2 {
-			// Check if this is our route by comparing destination and gateway
-			if fields[0] == netstatFormat && fields[1] == route.Gateway {
-				return true
-			}
-		}
-	}
-
-	// Log for debugging if we have debug enabled
-	if m.logger != nil {
-		m.logger.Debug("Route verification failed: network=%s, netstatFormat=%s, gateway=%s", 
-			network, netstatFormat, route.Gateway)
-	}
-
-	return false
+	return true
 }
 
 // VerifyAllRoutes checks all active routes
@@ -258,17 +251,59 @@ func (m *RouteManager) RestoreRoutes(gateway string) error {
 
 	var errors []string
 	for network, route := range m.activeRoutes {
-		cmd := exec.Command("sudo", "route", "add", "-net", network, gateway)
+		cmd := sudoCommand("route", "add", "-net", network, gateway)
 		if output, err := cmd.CombinedOutput(); err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %s", network, string(output)))
 		} else {
 			route.Gateway = gateway
-			m.logger.Info("Restored route: %s -> %s", network, gateway)
+			routeLogger(m.logger, route.Service, network, gateway).Info("Restored route: %s -> %s", network, gateway)
 		}
 	}
 
 	if len(errors) > 0 {
-		return fmt.Errorf("failed to restore some routes: %s", strings.Join(errors, "; "))
+		failed := strings.Join(errors, "; ")
+		return routeFailed([]byte(failed), fmt.Errorf("failed to restore some routes: %s", failed))
+	}
+
+	return nil
+}
+
+// MigrateRoutes re-points all active routes at a new gateway. Each route
+// is changed in place so traffic never falls back into the VPN tunnel;
+// routes the kernel refuses to change are deleted and re-added. Routes
+// bound to a specific interface are left alone.
+func (m *RouteManager) MigrateRoutes(gateway string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errors []string
+	for network, route := range m.activeRoutes {
+		if route.Gateway == gateway || route.Interface != "" {
+			continue
+		}
+
+		cmd := sudoCommand("route", "change", "-net", network, gateway)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			m.logger.Debug("route change failed for %s: %s", network, strings.TrimSpace(string(output)))
+			if err := m.removeRouteCommand(*route); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", network, err))
+				continue
+			}
+			addCmd := sudoCommand("route", "add", "-net", network, gateway)
+			if output, err := addCmd.CombinedOutput(); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %s", network, string(output)))
+				delete(m.activeRoutes, network)
+				continue
+			}
+		}
+
+		routeLogger(m.logger, route.Service, network, gateway).Info("Migrated route: %s -> %s (was %s)", network, gateway, route.Gateway)
+		route.Gateway = gateway
+	}
+
+	if len(errors) > 0 {
+		failed := strings.Join(errors, "; ")
+		return routeFailed([]byte(failed), fmt.Errorf("failed to migrate some routes: %s", failed))
 	}
 
 	return nil
@@ -293,4 +328,72 @@ func (m *RouteManager) GetServiceRouteCount(service string) int {
 		}
 	}
 	return count
-}
\ No newline at end of file
+}
+// CountTableRoutes counts the routes in the routing table to one of the
+// given networks or host addresses, whoever added them
+func CountTableRoutes(networks []string) (int, error) {
+	wanted := make(map[string]bool)
+	for _, network := range networks {
+		if !strings.Contains(network, "/") {
+			network += "/32"
+		}
+		if _, ipnet, err := net.ParseCIDR(network); err == nil {
+			wanted[ipnet.String()] = true
+		}
+	}
+
+	output, err := exec.Command("netstat", "-rn", "-f", "inet").Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read routing table: %w", err)
+	}
+
+	count := 0
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		ipnet := parseNetstatDestination(fields[0])
+		if ipnet == nil || seen[ipnet.String()] {
+			continue
+		}
+		if wanted[ipnet.String()] {
+			seen[ipnet.String()] = true
+			count++
+		}
+	}
+	return count, nil
+}
+
+// parseNetstatDestination parses a destination as netstat on macOS shows
+// it, without trailing zero octets: "172.217" is 172.217.0.0/16,
+// "91.108.4/22" is 91.108.4.0/22 and a bare address is a host route. It
+// returns nil for anything else, such as "default" or link addresses.
+func parseNetstatDestination(dest string) *net.IPNet {
+	address, bits, hasBits := strings.Cut(dest, "/")
+
+	octets := strings.Split(address, ".")
+	if len(octets) > 4 {
+		return nil
+	}
+	for len(octets) < 4 {
+		octets = append(octets, "0")
+	}
+
+	ip := net.ParseIP(strings.Join(octets, ".")).To4()
+	if ip == nil {
+		return nil
+	}
+
+	// Without a prefix length the octets shown make up the network
+	ones := 8 * len(strings.Split(address, "."))
+	if hasBits {
+		if _, err := fmt.Sscanf(bits, "%d", &ones); err != nil || ones < 0 || ones > 32 {
+			return nil
+		}
+	}
+
+	mask := net.CIDRMask(ones, 32)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
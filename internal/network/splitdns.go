@@ -0,0 +1,114 @@
+package network
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"vpn-route-manager/internal/system"
+)
+
+// resolverDir holds per-domain resolver configuration on macOS
+const resolverDir = "/etc/resolver"
+
+// resolverMarker identifies resolver files written by this tool, so files
+// created by the user or other software are never touched
+const resolverMarker = "# Managed by vpn-route-manager"
+
+var resolverDomainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+// InstallResolverFile points DNS for a domain and its subdomains at the
+// given nameserver by writing /etc/resolver/<domain>. A port of 0 uses
+// the default DNS port. Without root the privileged helper writes it.
+func InstallResolverFile(domain, nameserver string, port int) error {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if !resolverDomainPattern.MatchString(domain) {
+		return fmt.Errorf("invalid domain name %q", domain)
+	}
+
+	if !system.RequiresSudo() {
+		return writeResolverFile(domain, nameserver, port)
+	}
+	cmd := system.HelperCommand("resolver", "write", domain, nameserver, strconv.Itoa(port))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to write %s: %s: %w", filepath.Join(resolverDir, domain), strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// RemoveResolverFile removes /etc/resolver/<domain> if this tool wrote it.
+// Without root the privileged helper removes it.
+func RemoveResolverFile(domain string) error {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if !resolverDomainPattern.MatchString(domain) {
+		return fmt.Errorf("invalid domain name %q", domain)
+	}
+
+	if !system.RequiresSudo() {
+		return removeResolverFile(domain)
+	}
+	if output, err := system.HelperCommand("resolver", "remove", domain).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove %s: %s: %w", filepath.Join(resolverDir, domain), strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// writeResolverFile writes /etc/resolver/<domain>, as root, unless a file
+// this tool didn't write is there
+func writeResolverFile(domain, nameserver string, port int) error {
+	if !resolverDomainPattern.MatchString(domain) {
+		return fmt.Errorf("invalid domain name %q", domain)
+	}
+
+	path := filepath.Join(resolverDir, domain)
+	if data, err := os.ReadFile(path); err == nil && !strings.HasPrefix(string(data), resolverMarker) {
+		return fmt.Errorf("%s exists and is not managed by vpn-route-manager", path)
+	}
+
+	content := fmt.Sprintf("%s\nnameserver %s\n", resolverMarker, nameserver)
+	if port != 0 {
+		content += fmt.Sprintf("port %d\n", port)
+	}
+
+	if err := os.MkdirAll(resolverDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", resolverDir, err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// removeResolverFile removes /etc/resolver/<domain>, as root, if this tool
+// wrote it
+func removeResolverFile(domain string) error {
+	if !resolverDomainPattern.MatchString(domain) {
+		return fmt.Errorf("invalid domain name %q", domain)
+	}
+
+	path := filepath.Join(resolverDir, domain)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if !strings.HasPrefix(string(data), resolverMarker) {
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package network
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// TableRoute is an IPv4 entry of the kernel's routing table
+type TableRoute struct {
+	Network   string `json:"network"`
+	Gateway   string `json:"gateway"`
+	Interface string `json:"interface"`
+}
+
+// RouteCheck is how a route compares to the routing table. Present is set
+// when the table routes the network through the route's gateway, or onto
+// its interface for interface routes; Stray lists the entries for the
+// same network through anything else.
+type RouteCheck struct {
+	Route   Route        `json:"route"`
+	Present bool         `json:"present"`
+	Stray   []TableRoute `json:"stray,omitempty"`
+}
+
+// OK reports whether the route is in the table as it should be, and
+// nothing else is routing its network
+func (c RouteCheck) OK() bool {
+	return c.Present && len(c.Stray) == 0
+}
+
+// Problems describes what is wrong with the route in the table, if
+// anything
+func (c RouteCheck) Problems() []string {
+	var problems []string
+	if !c.Present {
+		problems = append(problems, "missing")
+	}
+	for _, stray := range c.Stray {
+		problems = append(problems, fmt.Sprintf("stray via %s", stray.Gateway))
+	}
+	return problems
+}
+
+// ReadRoutingTable reads the IPv4 routing table with netstat, leaving out
+// entries netstat shows without a parseable destination, such as default
+func ReadRoutingTable() ([]TableRoute, error) {
+	output, err := exec.Command("netstat", "-rn", "-f", "inet").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routing table: %w", err)
+	}
+
+	// Columns are Destination, Gateway, Flags, Netif and Expire, though
+	// older systems put more columns before Netif
+	var table []TableRoute
+	netif := 3
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "Destination" {
+			for i, field := range fields {
+				if field == "Netif" {
+					netif = i
+				}
+			}
+			continue
+		}
+		ipnet := parseNetstatDestination(fields[0])
+		if ipnet == nil || len(fields) <= netif {
+			continue
+		}
+		table = append(table, TableRoute{
+			Network:   ipnet.String(),
+			Gateway:   fields[1],
+			Interface: fields[netif],
+		})
+	}
+	return table, nil
+}
+
+// CheckRoutes compares routes to the routing table
+func CheckRoutes(routes []Route, table []TableRoute) []RouteCheck {
+	byNetwork := make(map[string][]TableRoute)
+	for _, entry := range table {
+		byNetwork[entry.Network] = append(byNetwork[entry.Network], entry)
+	}
+
+	checks := make([]RouteCheck, 0, len(routes))
+	for _, route := range routes {
+		check := RouteCheck{Route: route}
+		network := route.Network
+		if !strings.Contains(network, "/") {
+			network += "/32"
+		}
+		if ipnet := parseNetstatDestination(network); ipnet != nil {
+			network = ipnet.String()
+		}
+
+		for _, entry := range byNetwork[network] {
+			if matchesTableRoute(route, entry) {
+				check.Present = true
+			} else {
+				check.Stray = append(check.Stray, entry)
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// matchesTableRoute checks if a table entry is the route: through its
+// gateway, on its interface when it has one too, or onto its interface
+// when it has no gateway
+func matchesTableRoute(route Route, entry TableRoute) bool {
+	if route.Gateway == "" {
+		return entry.Interface == route.Interface
+	}
+	return entry.Gateway == route.Gateway && (route.Interface == "" || entry.Interface == route.Interface)
+}
+
+// RepairRoute makes the routing table match a checked route: entries for
+// its network through other gateways are deleted and the route is added
+// back when it is missing
+func (m *RouteManager) RepairRoute(check RouteCheck) error {
+	route := check.Route
+	for _, stray := range check.Stray {
+		args := []string{"route", "delete", "-net", route.Network, stray.Gateway}
+		if strings.HasPrefix(stray.Gateway, "link#") {
+			args = []string{"route", "delete", "-net", route.Network, "-interface", stray.Interface}
+		}
+		if output, err := sudoCommand(args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to remove stray route %s via %s: %s: %w", route.Network, stray.Gateway, strings.TrimSpace(string(output)), routeFailed(output, err))
+		}
+		routeLogger(m.logger, route.Service, route.Network, stray.Gateway).Info("Removed stray route: %s -> %s (service: %s)", route.Network, stray.Gateway, route.Service)
+	}
+
+	if check.Present {
+		return nil
+	}
+	return m.RestoreRoute(route)
+}
+
+// RestoreRoute adds a route that went missing from the routing table back,
+// leaving other entries for its network alone
+func (m *RouteManager) RestoreRoute(route Route) error {
+	args := routeArgs("add", route.Network, route.Gateway, route.Interface)
+	if output, err := sudoCommand(args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to restore route %s: %s: %w", route.Network, strings.TrimSpace(string(output)), routeFailed(output, err))
+	}
+	routeLogger(m.logger, route.Service, route.Network, route.Gateway).Info("Restored route: %s -> %s (service: %s)", route.Network, route.Gateway, route.Service)
+	return nil
+}
+
+// ClearRoutes deletes routes from the routing table whether or not this
+// manager added them, such as the routes of a daemon that is going away.
+// Only entries through the routes' own gateways are deleted. It returns
+// how many were.
+func (m *RouteManager) ClearRoutes(routes []Route) (int, error) {
+	table, err := ReadRoutingTable()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	var errors []string
+	for _, check := range CheckRoutes(routes, table) {
+		if !check.Present {
+			continue
+		}
+		route := check.Route
+		args := routeArgs("delete", route.Network, route.Gateway, route.Interface)
+		if output, err := sudoCommand(args...).CombinedOutput(); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %s", route.Network, strings.TrimSpace(string(output))))
+			continue
+		}
+		removed++
+		m.mu.Lock()
+		delete(m.activeRoutes, route.Network)
+		m.mu.Unlock()
+		routeLogger(m.logger, route.Service, route.Network, route.Gateway).Info("Removed route: %s (service: %s)", route.Network, route.Service)
+	}
+
+	if len(errors) > 0 {
+		failed := strings.Join(errors, "; ")
+		return removed, routeFailed([]byte(failed), fmt.Errorf("failed to remove some routes: %s", failed))
+	}
+	return removed, nil
+}
@@ -1,35 +1,115 @@
 package network
 
 import (
+	"fmt"
+	"net"
 	"os/exec"
+	"path/filepath"
 	"strings"
+
+	"vpn-route-manager/internal/config"
 )
 
-// VPNDetector handles VPN connection detection
-type VPNDetector struct{}
+// DefaultDetectionThreshold is the score at which a VPN is considered connected
+const DefaultDetectionThreshold = 0.5
+
+// Detection holds the outcome of a single VPN detection pass. VetoedBy
+// names the detector that ruled the VPN out, if any.
+type Detection struct {
+	Connected bool
+	Score     float64
+	Threshold float64
+	Signals   map[string]bool
+	VetoedBy  string
+}
+
+// VPNDetector combines the configured detectors into a VPN connection state
+type VPNDetector struct {
+	threshold float64
+	weights   map[string]float64
+	detectors []Detector
+}
 
-// NewVPNDetector creates a new VPN detector
+// NewVPNDetector creates a new VPN detector using all registered detectors
 func NewVPNDetector() *VPNDetector {
-	return &VPNDetector{}
+	d := &VPNDetector{}
+	d.Configure(config.DetectionConfig{Threshold: DefaultDetectionThreshold})
+	return d
+}
+
+// Configure builds the detector chain from the detection configuration.
+// Detectors run in the configured order, or in registration order when
+// none are listed.
+func (d *VPNDetector) Configure(cfg config.DetectionConfig) error {
+	names := cfg.Detectors
+	if len(names) == 0 {
+		names = RegisteredDetectors()
+	}
+
+	detectors, err := buildDetectors(names, cfg)
+	if err != nil {
+		return err
+	}
+
+	d.detectors = detectors
+	d.weights = DefaultSignalWeights()
+	for signal, weight := range cfg.Weights {
+		d.weights[signal] = weight
+	}
+	d.threshold = DefaultDetectionThreshold
+	if cfg.Threshold > 0 {
+		d.threshold = cfg.Threshold
+	}
+
+	return nil
 }
 
 // IsVPNConnected checks if a VPN is currently connected
 func (d *VPNDetector) IsVPNConnected() bool {
-	// Method 1: Check for utun interface in default route (most reliable)
-	if d.hasUTunDefaultRoute() {
-		return true
+	return d.Detect().Connected
+}
+
+// Detect runs every detector and combines their results into a weighted
+// confidence score, compared to the threshold once all have run so the
+// score and signals don't depend on the detectors' order. A detector that
+// vetoes the VPN makes it not connected whatever the score.
+func (d *VPNDetector) Detect() Detection {
+	result := Detection{
+		Threshold: d.threshold,
+		Signals:   make(map[string]bool),
 	}
 
-	// Method 2: Check for corporate VPN interface (routes to private networks via utun)
-	if d.hasCorporateVPNInterface() {
-		return true
+	for _, detector := range d.detectors {
+		weight := d.weights[detector.Name()]
+		if weight <= 0 {
+			// Disabled signals are not evaluated at all
+			continue
+		}
+		var fired bool
+		if vetoDetector, ok := detector.(VetoDetector); ok {
+			var veto bool
+			if fired, veto = vetoDetector.DetectVeto(); veto {
+				result.VetoedBy = detector.Name()
+			}
+		} else {
+			fired = detector.Detect()
+		}
+		result.Signals[detector.Name()] = fired
+		if fired {
+			result.Score += weight
+		}
 	}
 
-	return false
+	if result.Score > 1 {
+		result.Score = 1
+	}
+	result.Connected = result.Score >= d.threshold && result.VetoedBy == ""
+
+	return result
 }
 
 // hasUTunDefaultRoute checks if default route goes through utun interface
-func (d *VPNDetector) hasUTunDefaultRoute() bool {
+func hasUTunDefaultRoute() bool {
 	// Check netstat for default routes - VPN is primary if utun appears first
 	cmd := exec.Command("netstat", "-rn")
 	output, err := cmd.Output()
@@ -61,7 +141,7 @@ func (d *VPNDetector) hasUTunDefaultRoute() bool {
 
 // hasCorporateVPNInterface checks for corporate VPN interfaces
 // Detects VPNs like GlobalProtect, Cisco AnyConnect, FortiClient, etc.
-func (d *VPNDetector) hasCorporateVPNInterface() bool {
+func hasCorporateVPNInterface() bool {
 	cmd := exec.Command("netstat", "-rn")
 	output, err := cmd.Output()
 	if err != nil {
@@ -78,10 +158,8 @@ func (d *VPNDetector) hasCorporateVPNInterface() bool {
 				dest := fields[0]
 				iface := fields[3]
 				// Check for private network routes through utun
-				if strings.HasPrefix(iface, "utun") {
-					if strings.HasPrefix(dest, "10.") || strings.HasPrefix(dest, "172.") {
-						return true
-					}
+				if strings.HasPrefix(iface, "utun") && isPrivateDestination(dest) {
+					return true
 				}
 			}
 		}
@@ -90,8 +168,95 @@ func (d *VPNDetector) hasCorporateVPNInterface() bool {
 	return false
 }
 
-// hasVPNProcess checks for known VPN client processes
-func (d *VPNDetector) hasVPNProcess() bool {
+// isPrivateDestination checks if a netstat destination lies in 10.0.0.0/8
+// or 172.16.0.0/12. netstat abbreviates networks ("10", "172.20/16"), so
+// only the leading octets are inspected.
+func isPrivateDestination(dest string) bool {
+	dest = strings.SplitN(dest, "/", 2)[0]
+	octets := strings.Split(dest, ".")
+
+	switch octets[0] {
+	case "10":
+		return true
+	case "172":
+		if len(octets) < 2 {
+			return false
+		}
+		var second int
+		if _, err := fmt.Sscanf(octets[1], "%d", &second); err != nil {
+			return false
+		}
+		return second >= 16 && second <= 31
+	}
+
+	return false
+}
+
+// Resolver is a DNS resolver entry reported by scutil. Domain is set for
+// resolvers that only answer queries for that domain, such as those from
+// /etc/resolver files; Domains also includes search domains.
+type Resolver struct {
+	Nameservers []string
+	Domain      string
+	Domains     []string
+	Interface   string
+}
+
+// GetResolvers returns the system DNS resolver configuration
+func GetResolvers() []Resolver {
+	cmd := exec.Command("scutil", "--dns")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	return parseResolvers(string(output))
+}
+
+// parseResolvers parses "scutil --dns" output into resolver entries
+func parseResolvers(output string) []Resolver {
+	var resolvers []Resolver
+	var current *Resolver
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "resolver #") {
+			resolvers = append(resolvers, Resolver{})
+			current = &resolvers[len(resolvers)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		switch {
+		case strings.HasPrefix(key, "nameserver["):
+			current.Nameservers = append(current.Nameservers, value)
+		case key == "domain":
+			current.Domain = value
+			current.Domains = append(current.Domains, value)
+		case strings.HasPrefix(key, "search domain["):
+			current.Domains = append(current.Domains, value)
+		case key == "if_index":
+			// Format: "18 (utun3)"
+			if start := strings.Index(value, "("); start >= 0 {
+				current.Interface = strings.TrimSuffix(value[start+1:], ")")
+			}
+		}
+	}
+
+	return resolvers
+}
+
+// detectVPNProduct returns the name of the first running VPN client
+func detectVPNProduct() string {
 	vpnProcesses := []string{
 		"GlobalProtect",
 		"openvpn",
@@ -107,11 +272,169 @@ func (d *VPNDetector) hasVPNProcess() bool {
 	for _, process := range vpnProcesses {
 		cmd := exec.Command("pgrep", "-i", process)
 		if err := cmd.Run(); err == nil {
-			return true
+			return process
 		}
 	}
 
-	return false
+	return ""
+}
+
+// VPNInfo describes the currently connected VPN. FullTunnel is set when
+// the VPN owns the default route; split-tunnel VPNs only route specific
+// prefixes through utun.
+type VPNInfo struct {
+	Interface  string
+	TunnelIP   string
+	Gateway    string
+	Product    string
+	Servers    []string
+	Routes     []string
+	DNSServers []string
+	FullTunnel bool
+}
+
+// GetVPNInfo collects details about the active VPN connection
+func (d *VPNDetector) GetVPNInfo() VPNInfo {
+	iface := d.GetVPNInterface()
+
+	return VPNInfo{
+		Interface:  iface,
+		TunnelIP:   d.GetTunnelAddress(iface),
+		Gateway:    d.GetVPNGateway(),
+		Product:    detectVPNProduct(),
+		Servers:    getVPNServers(),
+		Routes:     getInterfaceRoutes(iface),
+		DNSServers: getInterfaceNameservers(iface),
+		FullTunnel: hasUTunDefaultRoute(),
+	}
+}
+
+// getInterfaceRoutes returns the destinations routed through an interface,
+// i.e. the routes a VPN pushed for its tunnel
+func getInterfaceRoutes(iface string) []string {
+	if iface == "" {
+		return nil
+	}
+
+	cmd := exec.Command("netstat", "-rn", "-f", "inet")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var routes []string
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && fields[3] == iface {
+			routes = append(routes, fields[0])
+		}
+	}
+
+	return routes
+}
+
+// getInterfaceNameservers returns the DNS servers scoped to an interface
+func getInterfaceNameservers(iface string) []string {
+	if iface == "" {
+		return nil
+	}
+
+	var servers []string
+	seen := make(map[string]bool)
+	for _, resolver := range GetResolvers() {
+		if resolver.Interface != iface {
+			continue
+		}
+		for _, server := range resolver.Nameservers {
+			if !seen[server] {
+				seen[server] = true
+				servers = append(servers, server)
+			}
+		}
+	}
+
+	return servers
+}
+
+// getVPNServers returns the likely VPN server addresses. VPN clients pin a
+// host route to their server through the physical gateway so the tunnel
+// itself isn't routed into the tunnel.
+func getVPNServers() []string {
+	cmd := exec.Command("netstat", "-rn", "-f", "inet")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var servers []string
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		dest, flags, iface := fields[0], fields[2], fields[3]
+		if !strings.Contains(flags, "H") || !strings.Contains(flags, "G") {
+			continue
+		}
+		if strings.HasPrefix(iface, "utun") || strings.HasPrefix(iface, "lo") {
+			continue
+		}
+
+		ip := net.ParseIP(dest)
+		if ip == nil || ip.To4() == nil || ip.IsPrivate() || ip.IsLoopback() {
+			continue
+		}
+		servers = append(servers, dest)
+	}
+
+	return servers
+}
+
+// Matches checks whether the VPN matches an allowlist entry. Every field
+// set on the entry must match: servers by IP or CIDR, the interface by
+// glob pattern and the product by case-insensitive substring.
+func (info VPNInfo) Matches(match config.VPNMatch) bool {
+	if match.Interface != "" {
+		if ok, _ := filepath.Match(match.Interface, info.Interface); !ok {
+			return false
+		}
+	}
+
+	if match.Product != "" {
+		if !strings.Contains(strings.ToLower(info.Product), strings.ToLower(match.Product)) {
+			return false
+		}
+	}
+
+	if match.Server != "" {
+		found := false
+		for _, server := range info.Servers {
+			if matchesAddress(match.Server, server) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesAddress checks if an address equals an IP or falls inside a CIDR
+func matchesAddress(pattern, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	if _, network, err := net.ParseCIDR(pattern); err == nil {
+		return network.Contains(ip)
+	}
+
+	return ip.Equal(net.ParseIP(pattern))
 }
 
 // GetVPNInterface returns the active VPN interface name
@@ -135,6 +458,50 @@ func (d *VPNDetector) GetVPNInterface() string {
 		}
 	}
 
+	// Split-tunnel VPNs leave the default route alone, so fall back to
+	// the tunnel carrying private network routes
+	return getPrivateRouteInterface()
+}
+
+// getPrivateRouteInterface returns the utun interface that routes private
+// networks, if any
+func getPrivateRouteInterface() string {
+	cmd := exec.Command("netstat", "-rn", "-f", "inet")
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && strings.HasPrefix(fields[3], "utun") && isPrivateDestination(fields[0]) {
+			return fields[3]
+		}
+	}
+
+	return ""
+}
+
+// GetTunnelAddress returns the local IPv4 address assigned to a tunnel
+// interface
+func (d *VPNDetector) GetTunnelAddress(iface string) string {
+	if iface == "" {
+		return ""
+	}
+
+	cmd := exec.Command("ifconfig", iface)
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "inet" {
+			return fields[1]
+		}
+	}
+
 	return ""
 }
 
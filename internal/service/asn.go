@@ -0,0 +1,48 @@
+package service
+
+import (
+	"time"
+
+	"vpn-route-manager/internal/network"
+)
+
+// syncASNs regenerates the networks of services that list AS numbers once
+// the configured interval has passed. The prefixes are fetched in the
+// background and applied by the monitoring loop.
+func (m *Manager) syncASNs() {
+	cfg := m.config.Get().ASNSync
+	if !cfg.Enabled || time.Now().Before(m.nextASNSync) {
+		return
+	}
+	m.nextASNSync = time.Now().Add(time.Duration(cfg.Interval) * time.Hour)
+
+	asns := make(map[string][]string)
+	for name, service := range m.config.Get().Services {
+		if len(service.ASNs) > 0 {
+			asns[name] = append([]string(nil), service.ASNs...)
+		}
+	}
+	if len(asns) == 0 {
+		return
+	}
+
+	m.fetchInBackground("asn-sync", func() func() {
+		fetched := make(map[string][]string)
+		for name, list := range asns {
+			prefixes, err := network.FetchASNPrefixes(cfg.Source, list)
+			if err != nil {
+				m.logger.Warn("Failed to sync ASNs for %s: %v", name, err)
+				continue
+			}
+			fetched[name] = prefixes
+		}
+
+		return func() {
+			for name, prefixes := range fetched {
+				if m.applyServiceNetworks(name, prefixes) {
+					m.logger.Info("Synced %d networks for %s from %v", len(prefixes), name, asns[name])
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+	"time"
+
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/network"
+	"vpn-route-manager/internal/system"
+)
+
+// ControlSocket is where the system daemon, which runs as root, takes
+// requests from the CLI for what needs root, such as removing routes. It
+// is owned by root and the control group, and closed to everyone else.
+const ControlSocket = "/var/run/vpn-route-manager.sock"
+
+// defaultControlGroup may use the control socket unless control_group
+// names another group; it holds the Mac's administrators
+const defaultControlGroup = "admin"
+
+// Commands taken over the control socket
+const (
+	ControlStatus = "status"
+	ControlClear  = "clear"
+	ControlVerify = "verify"
+)
+
+// ControlRequest is a request sent over the control socket. Fix repairs
+// the routes a verify finds wrong.
+type ControlRequest struct {
+	Command string `json:"command"`
+	Fix     bool   `json:"fix,omitempty"`
+}
+
+// ControlResponse answers a ControlRequest, with Error set when it failed
+type ControlResponse struct {
+	Error   string          `json:"error,omitempty"`
+	Status  *Status         `json:"status,omitempty"`
+	Removed int             `json:"removed,omitempty"`
+	Routes  []VerifiedRoute `json:"routes,omitempty"`
+}
+
+// VerifiedRoute is the result of verifying one route, and of repairing it
+// when asked to
+type VerifiedRoute struct {
+	network.RouteCheck
+	Fixed bool   `json:"fixed,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// VerifyRoutes compares routes to the routing table, repairing the ones
+// that don't match when fix is set
+func VerifyRoutes(netMgr *network.Manager, routes []network.Route, fix bool) ([]VerifiedRoute, error) {
+	table, err := network.ReadRoutingTable()
+	if err != nil {
+		return nil, err
+	}
+
+	checks := network.CheckRoutes(routes, table)
+	results := make([]VerifiedRoute, 0, len(checks))
+	for _, check := range checks {
+		result := VerifiedRoute{RouteCheck: check}
+		if !check.OK() && fix {
+			if err := netMgr.RepairRoute(check); err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Fixed = true
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// controlCall is a request handed to the monitoring loop, which makes all
+// changes to routes, and where to send its response
+type controlCall struct {
+	request  ControlRequest
+	response chan ControlResponse
+}
+
+// ControlGroupName returns the group allowed to use the control socket
+func ControlGroupName(cfg *config.Config) string {
+	if cfg.ControlGroup == "" {
+		return defaultControlGroup
+	}
+	return cfg.ControlGroup
+}
+
+// serveControl listens on the control socket until the service stops. A
+// socket launchd created is used as is and left to launchd to remove.
+func (m *Manager) serveControl() error {
+	groupName := ControlGroupName(m.config.Get())
+	listener := m.controlListener
+	if listener == nil {
+		var err error
+		if listener, err = listenControl(groupName); err != nil {
+			return err
+		}
+	}
+
+	m.control = make(chan controlCall)
+	go func() {
+		<-m.ctx.Done()
+		listener.Close()
+		if m.controlListener == nil {
+			os.Remove(ControlSocket)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go m.handleControlConn(conn)
+		}
+	}()
+
+	m.logger.Info("Taking control requests on %s from group %s", ControlSocket, groupName)
+	return nil
+}
+
+// listenControl creates the control socket, restricted to root and the
+// group groupName
+func listenControl(groupName string) (net.Listener, error) {
+	group, err := user.LookupGroup(groupName)
+	if err != nil {
+		return nil, fmt.Errorf("unknown control group %s: %w", groupName, err)
+	}
+	gid, err := strconv.Atoi(group.Gid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID of group %s: %s", groupName, group.Gid)
+	}
+
+	// A socket left by an unclean shutdown would block listening
+	os.Remove(ControlSocket)
+	listener, err := net.Listen("unix", ControlSocket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", ControlSocket, err)
+	}
+	if err := os.Chown(ControlSocket, 0, gid); err == nil {
+		err = os.Chmod(ControlSocket, 0660)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict %s to group %s: %w", ControlSocket, groupName, err)
+	}
+	return listener, nil
+}
+
+// handleControlConn answers the request on a control connection
+func (m *Manager) handleControlConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Minute))
+
+	var request ControlRequest
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		m.logger.Debug("Invalid control request: %v", err)
+		return
+	}
+
+	call := controlCall{request: request, response: make(chan ControlResponse, 1)}
+	var response ControlResponse
+	select {
+	case m.control <- call:
+		response = <-call.response
+	case <-m.ctx.Done():
+		response.Error = "the service is stopping"
+	}
+
+	if err := json.NewEncoder(conn).Encode(response); err != nil {
+		m.logger.Debug("Failed to answer control request: %v", err)
+	}
+}
+
+// handleControl carries out a control request, from the monitoring loop
+func (m *Manager) handleControl(request ControlRequest) ControlResponse {
+	m.logger.Info("Control request: %s", request.Command)
+
+	var response ControlResponse
+	switch request.Command {
+	case ControlStatus:
+		status, err := m.Status()
+		if err != nil {
+			response.Error = err.Error()
+		}
+		response.Status = status
+	case ControlClear:
+		removed := len(m.network.GetActiveRoutes())
+		if err := m.removeAllRoutes(); err != nil {
+			response.Error = err.Error()
+		} else {
+			response.Removed = removed
+		}
+	case ControlVerify:
+		routes, err := VerifyRoutes(m.network, m.network.GetActiveRoutes(), request.Fix)
+		if err != nil {
+			response.Error = err.Error()
+		}
+		response.Routes = routes
+	default:
+		response.Error = fmt.Sprintf("unknown control command '%s'", request.Command)
+	}
+	return response
+}
+
+// ControlAvailable checks if a system daemon takes requests on the control
+// socket. A socket left behind by a daemon that is gone refuses the
+// connection and doesn't count; one the user may not use does, so Control
+// reports why.
+func ControlAvailable() bool {
+	conn, err := net.DialTimeout("unix", ControlSocket, time.Second)
+	if err != nil {
+		return errors.Is(err, fs.ErrPermission)
+	}
+	conn.Close()
+	return true
+}
+
+// Control sends a request to the system daemon over the control socket
+func Control(request ControlRequest) (*ControlResponse, error) {
+	conn, err := net.DialTimeout("unix", ControlSocket, 5*time.Second)
+	if err != nil {
+		switch {
+		case errors.Is(err, fs.ErrPermission):
+			return nil, fmt.Errorf("%w: not allowed to use %s, join the group it belongs to", system.ErrPermission, ControlSocket)
+		case errors.Is(err, fs.ErrNotExist), errors.Is(err, syscall.ECONNREFUSED):
+			return nil, system.ErrDaemonNotRunning
+		}
+		return nil, fmt.Errorf("failed to connect to the service: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Minute))
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	var response ControlResponse
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if response.Error != "" {
+		return &response, fmt.Errorf("%s failed: %s", request.Command, response.Error)
+	}
+	return &response, nil
+}
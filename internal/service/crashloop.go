@@ -0,0 +1,70 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CrashLoopWindow is how far back daemon starts count towards a crash loop
+const CrashLoopWindow = 10 * time.Minute
+
+// crashLoopRestarts is the least number of restarts within
+// CrashLoopWindow that launchd respawning the daemon is reported for
+const crashLoopRestarts = 3
+
+// RecordStart adds a daemon start at now to the state file in stateDir,
+// dropping those older than CrashLoopWindow. The daemon records it before
+// loading anything else, so starts that fail right away count too.
+func RecordStart(stateDir string, now time.Time) error {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	stateFile := filepath.Join(stateDir, "state.json")
+
+	state := State{ActiveServices: make(map[string]bool)}
+	data, err := os.ReadFile(stateFile)
+	if err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("failed to parse state file: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	starts := []time.Time{}
+	for _, start := range state.Starts {
+		if now.Sub(start) < CrashLoopWindow {
+			starts = append(starts, start)
+		}
+	}
+	state.Starts = append(starts, now)
+
+	return writeStateFile(stateFile, &state)
+}
+
+// CrashLoopRestarts returns how often the daemon was restarted within
+// CrashLoopWindow before now, or 0 when too rarely to be a crash loop.
+// Starts are forgotten when the daemon stops cleanly, so only restarts
+// after it died or failed to start count.
+func CrashLoopRestarts(state *State, now time.Time) int {
+	starts := 0
+	for _, start := range state.Starts {
+		if now.Sub(start) < CrashLoopWindow {
+			starts++
+		}
+	}
+	if restarts := starts - 1; restarts >= crashLoopRestarts {
+		return restarts
+	}
+	return 0
+}
+
+// ClearStarts forgets the recorded daemon starts, when it stops cleanly
+func (sm *StateManager) ClearStarts() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.state.Starts = nil
+}
@@ -0,0 +1,31 @@
+package service
+
+import "time"
+
+// expireServices disables temporarily enabled services whose time has run
+// out. The expiry is kept in the service's config, so it also applies to
+// services that expired while the daemon wasn't running. Only the
+// service's file is updated, so changes the CLI made to the rest of the
+// configuration since the daemon started are kept.
+func (m *Manager) expireServices() {
+	now := time.Now()
+	for name, service := range m.config.Get().Services {
+		if !service.Expired(now) {
+			continue
+		}
+
+		if err := m.config.ExpireService(name, now); err != nil {
+			m.logger.Error("Failed to disable %s: %v", name, err)
+			continue
+		}
+		if service.Enabled {
+			m.logger.Info("Temporary enable of %s was extended", name)
+			continue
+		}
+
+		m.logger.Info("Temporary enable of %s expired", name)
+		if err := m.deactivateService(name); err != nil {
+			m.logger.Error("Failed to disable %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package service
+
+// fetchResult is what a background download hands to the monitoring loop:
+// which fetch finished and the function applying its results
+type fetchResult struct {
+	kind  string
+	apply func()
+}
+
+// fetchInBackground runs fetch outside the monitoring loop, so slow
+// downloads don't hold up VPN detection, route repair or control
+// requests, and hands the function it returns to the loop to apply the
+// results. A fetch of the same kind that hasn't finished yet isn't
+// started again.
+func (m *Manager) fetchInBackground(kind string, fetch func() func()) {
+	if m.fetching[kind] {
+		m.logger.Debug("Still fetching %s - not starting again", kind)
+		return
+	}
+	if m.fetching == nil {
+		m.fetching = make(map[string]bool)
+	}
+	m.fetching[kind] = true
+
+	go func() {
+		result := fetchResult{kind: kind, apply: fetch()}
+		select {
+		case m.fetched <- result:
+		case <-m.ctx.Done():
+		}
+	}()
+}
+
+// applyFetched applies the results of a background download, from the
+// monitoring loop
+func (m *Manager) applyFetched(result fetchResult) {
+	delete(m.fetching, result.kind)
+	result.apply()
+}
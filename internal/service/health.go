@@ -0,0 +1,75 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// probeServices checks the probe targets of active services through their
+// bypass routes, so routes that are installed but not working show up in
+// status. The probes run in the background, as a slow target would
+// otherwise hold up VPN detection and route changes for the timeout.
+func (m *Manager) probeServices() {
+	cfg := m.config.Get().HealthChecks
+	if time.Now().Before(m.nextProbe) {
+		return
+	}
+	m.nextProbe = time.Now().Add(time.Duration(cfg.Interval) * time.Second)
+
+	gateway := m.state.GetState().LastGateway
+	if gateway == "" {
+		return
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+
+	targets := make(map[string]string)
+	for name, service := range m.config.Get().Services {
+		if service.Probe != "" && m.state.IsServiceActive(name) {
+			targets[name] = service.Probe
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	m.fetchInBackground("probes", func() func() {
+		var wg sync.WaitGroup
+		for name, target := range targets {
+			wg.Add(1)
+			go func(name, target string) {
+				defer wg.Done()
+				m.probeService(name, target, gateway, timeout)
+			}(name, target)
+		}
+		wg.Wait()
+
+		return func() {
+			if err := m.state.Save(); err != nil {
+				m.logger.Error("Failed to save state: %v", err)
+			}
+		}
+	})
+}
+
+// probeService probes one service's target and records its health
+func (m *Manager) probeService(name, target, gateway string, timeout time.Duration) {
+	health := ServiceHealth{CheckedAt: time.Now()}
+	latency, err := m.network.Probe(target, gateway, timeout)
+	if err != nil {
+		health.Error = err.Error()
+	} else {
+		health.Healthy = true
+		health.Latency = latency
+	}
+
+	// Only log changes, probes run every interval
+	previous, checked := m.state.GetServiceHealth(name)
+	switch {
+	case !health.Healthy && (!checked || previous.Healthy):
+		m.logger.Warn("Service %s is unhealthy: %s", name, health.Error)
+	case health.Healthy && checked && !previous.Healthy:
+		m.logger.Info("Service %s is healthy again (%v)", name, latency.Round(time.Millisecond))
+	}
+
+	m.state.SetServiceHealth(name, health)
+}
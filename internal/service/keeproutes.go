@@ -0,0 +1,47 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"vpn-route-manager/internal/system"
+)
+
+// keepRoutesFile returns the path of the request, written by stop
+// --keep-routes, to leave the bypass routes in place when the daemon stops
+func keepRoutesFile(stateDir string) string {
+	return filepath.Join(stateDir, "keep-routes")
+}
+
+// WriteKeepRoutes asks the daemon to keep its routes when it next stops
+func WriteKeepRoutes(stateDir string) error {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := system.WriteFile(keepRoutesFile(stateDir), nil, 0644); err != nil {
+		return fmt.Errorf("failed to write keep-routes file: %w", err)
+	}
+	return nil
+}
+
+// ClearKeepRoutes withdraws a request to keep the routes
+func ClearKeepRoutes(stateDir string) error {
+	if err := os.Remove(keepRoutesFile(stateDir)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove keep-routes file: %w", err)
+	}
+	return nil
+}
+
+// takeKeepRoutes reports whether the routes are to be kept as the daemon
+// stops, consuming the request so the next stop removes them again
+func (m *Manager) takeKeepRoutes() bool {
+	path := keepRoutesFile(m.config.Get().StateDir)
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	if err := os.Remove(path); err != nil {
+		m.logger.Warn("Failed to remove keep-routes file: %v", err)
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/system"
+)
+
+// loadedConfigFile returns the path of the copy of the configuration the
+// daemon is running with
+func loadedConfigFile(stateDir string) string {
+	return filepath.Join(stateDir, "loaded-config.json")
+}
+
+// ReadLoadedConfig reads the configuration the running daemon has loaded,
+// including the changes it made itself since, or nil when no daemon has
+// recorded one
+func ReadLoadedConfig(stateDir string) (*config.Config, error) {
+	data, err := os.ReadFile(loadedConfigFile(stateDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read loaded config: %w", err)
+	}
+
+	var cfg config.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse loaded config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// recordLoadedConfig writes the configuration in use for `config diff`
+// when it changed since it was last written
+func (m *Manager) recordLoadedConfig() {
+	data, err := json.MarshalIndent(m.config.Get(), "", "  ")
+	if err != nil {
+		m.logger.Error("Failed to marshal loaded config: %v", err)
+		return
+	}
+	if bytes.Equal(data, m.loadedConfig) {
+		return
+	}
+
+	path := loadedConfigFile(m.config.Get().StateDir)
+	if err := system.WriteFile(path+".tmp", data, 0644); err != nil {
+		m.logger.Error("Failed to write loaded config: %v", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		os.Remove(path + ".tmp")
+		m.logger.Error("Failed to write loaded config: %v", err)
+		return
+	}
+	m.loadedConfig = data
+}
+
+// clearLoadedConfig removes the record of the configuration in use once
+// the daemon stops
+func (m *Manager) clearLoadedConfig() {
+	if err := os.Remove(loadedConfigFile(m.config.Get().StateDir)); err != nil && !os.IsNotExist(err) {
+		m.logger.Error("Failed to remove loaded config: %v", err)
+	}
+}
@@ -3,8 +3,12 @@ package service
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"os/user"
+	"sort"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -12,21 +16,53 @@ import (
 	"vpn-route-manager/internal/config"
 	"vpn-route-manager/internal/logger"
 	"vpn-route-manager/internal/network"
+	"vpn-route-manager/internal/system"
 )
 
+// verifyInterval is how often the routing table is checked against the
+// active routes
+const verifyInterval = time.Minute
+
 // Manager handles the main service loop
 type Manager struct {
-	config         *config.Manager
-	network        *network.Manager
-	state          *StateManager
-	logger         *logger.Logger
-	ctx            context.Context
-	cancel         context.CancelFunc
-	wg             sync.WaitGroup
-	mu             sync.Mutex
-	isRunning      bool
-	lastVPNState   bool
-	checkInterval  time.Duration
+	config            *config.Manager
+	network           *network.Manager
+	state             *StateManager
+	logger            *logger.Logger
+	ctx               context.Context
+	cancel            context.CancelFunc
+	wg                sync.WaitGroup
+	mu                sync.Mutex
+	isRunning         bool
+	lastVPNState      bool
+	checkInterval     time.Duration
+	checked           bool
+	pendingChecks     int
+	debounceChecks    int
+	disconnectGrace   time.Duration
+	removalDeadline   time.Time
+	ignoringVPN       bool
+	retryAt           time.Time
+	retryBackoff      time.Duration
+	dnsAnswers        chan dnsAnswer
+	domainRoutes      map[string]*domainRoute
+	nextResolve       map[string]time.Time
+	observedDomains   map[string]string
+	dnsProxy          *network.DNSProxy
+	nextASNSync       time.Time
+	nextNetworkUpdate time.Time
+	nextRefresh       map[string]time.Time
+	fetching          map[string]bool
+	fetched           chan fetchResult
+	paused            bool
+	nextProbe         time.Time
+	nextVerify        time.Time
+	lastVerification  *RouteVerification
+	version           string
+	loadedConfig      []byte
+	recordedStatus    []byte
+	control           chan controlCall
+	controlListener   net.Listener
 }
 
 // NewManager creates a new service manager
@@ -39,13 +75,17 @@ func NewManager(cfg *config.Manager, net *network.Manager, log *logger.Logger) (
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &Manager{
-		config:        cfg,
-		network:       net,
-		state:         stateManager,
-		logger:        log,
-		ctx:           ctx,
-		cancel:        cancel,
-		checkInterval: time.Duration(cfg.Get().CheckInterval) * time.Second,
+		config:          cfg,
+		network:         net,
+		state:           stateManager,
+		logger:          log.With(logger.Fields{Component: "service"}),
+		ctx:             ctx,
+		cancel:          cancel,
+		checkInterval:   time.Duration(cfg.Get().CheckInterval) * time.Second,
+		debounceChecks:  cfg.Get().Detection.DebounceChecks,
+		disconnectGrace: time.Duration(cfg.Get().DisconnectGrace) * time.Second,
+		dnsAnswers:      make(chan dnsAnswer, 64),
+		fetched:         make(chan fetchResult),
 	}, nil
 }
 
@@ -59,16 +99,53 @@ func (m *Manager) Start() error {
 	m.isRunning = true
 	m.mu.Unlock()
 
-	m.logger.Info("Starting VPN Route Manager service")
+	if m.version != "" {
+		m.logger.Info("Starting VPN Route Manager service %s", m.version)
+	} else {
+		m.logger.Info("Starting VPN Route Manager service")
+	}
 
 	// Load state
 	if err := m.state.Load(); err != nil {
 		m.logger.Warn("Failed to load state: %v", err)
 	}
 
+	m.logSharedNetworks()
+	m.recordLoadedConfig()
+
+	// Clean up resolver files left behind by an unclean shutdown; they
+	// are installed again when routes are added
+	m.removeSplitDNS()
+
+	// A request to keep routes is for the daemon that was stopping
+	if err := ClearKeepRoutes(m.config.Get().StateDir); err != nil {
+		m.logger.Warn("Failed to clear keep-routes request: %v", err)
+	}
+
 	// Setup signal handling
 	m.setupSignalHandling()
 
+	// Start the DNS proxy for domain-based bypass
+	if proxyConfig := m.config.Get().DNSProxy; proxyConfig.Enabled {
+		m.dnsProxy = m.network.NewDNSProxy(proxyConfig.Listen, proxyConfig.Upstream, m.handleDNSAnswer)
+		if err := m.dnsProxy.Start(); err != nil {
+			m.logger.Error("Failed to start DNS proxy: %v", err)
+			m.dnsProxy = nil
+		}
+	}
+
+	// Running as root there are no sudo rules to go through, so the CLI
+	// asks for route changes over the control socket
+	if system.RequiresSudo() {
+		if !system.HelperDaemonInstalled() {
+			m.checkSudoers()
+		}
+	} else {
+		if err := m.serveControl(); err != nil {
+			m.logger.Error("Failed to open control socket: %v", err)
+		}
+	}
+
 	// Start monitoring
 	m.wg.Add(1)
 	go m.monitorLoop()
@@ -77,6 +154,24 @@ func (m *Manager) Start() error {
 	return nil
 }
 
+// checkSudoers warns when the sudoers entries routes are changed through
+// aren't intact, such as after an OS update replaced them
+func (m *Manager) checkSudoers() {
+	current, err := user.Current()
+	if err != nil {
+		m.logger.Warn("Failed to check sudoers: %v", err)
+		return
+	}
+
+	integrity := system.NewSudoManager(current.Username).CheckIntegrity()
+	for _, problem := range integrity.Problems {
+		m.logger.Warn("Sudoers: %s", problem)
+	}
+	if !integrity.OK() {
+		m.logger.Warn("Routes may fail to change, run 'sudo vpn-route-manager install --repair-sudo'")
+	}
+}
+
 // Stop stops the service
 func (m *Manager) Stop() error {
 	m.mu.Lock()
@@ -106,15 +201,30 @@ func (m *Manager) Stop() error {
 		m.logger.Warn("Service stop timeout - some operations may not have completed")
 	}
 
-	// Remove all routes
-	if err := m.removeAllRoutes(); err != nil {
+	if m.dnsProxy != nil {
+		if err := m.dnsProxy.Stop(); err != nil {
+			m.logger.Error("Failed to stop DNS proxy: %v", err)
+		}
+	}
+
+	// Remove all routes, unless stopped with --keep-routes. The status
+	// then still records them, for route clear to find later.
+	keepRoutes := m.takeKeepRoutes()
+	if keepRoutes {
+		m.logger.Info("Keeping %d bypass routes as requested", len(m.network.GetActiveRoutes()))
+	} else if err := m.removeAllRoutes(); err != nil {
 		m.logger.Error("Failed to remove routes during shutdown: %v", err)
 	}
 
-	// Save state
+	// Save state; a clean stop isn't part of a crash loop
+	m.state.ClearStarts()
 	if err := m.state.Save(); err != nil {
 		m.logger.Error("Failed to save state: %v", err)
 	}
+	m.clearLoadedConfig()
+	if !keepRoutes {
+		m.clearStatus()
+	}
 
 	return nil
 }
@@ -126,7 +236,11 @@ func (m *Manager) monitorLoop() {
 	m.logger.Info("Starting VPN monitoring loop (interval: %v)", m.checkInterval)
 
 	// Initial check
-	m.checkAndUpdateRoutes()
+	var sleep sleepDetector
+	sleep.slept()
+	if !m.checkPause() {
+		m.runChecks()
+	}
 
 	ticker := time.NewTicker(m.checkInterval)
 	defer ticker.Stop()
@@ -137,14 +251,43 @@ func (m *Manager) monitorLoop() {
 			m.logger.Info("Monitoring loop stopped")
 			return
 		case <-ticker.C:
-			m.checkAndUpdateRoutes()
+			if asleep := sleep.slept(); asleep > 0 {
+				m.handleWake(asleep)
+			}
+			if !m.checkPause() {
+				m.runChecks()
+			}
+		case call := <-m.control:
+			call.response <- m.handleControl(call.request)
+		case answer := <-m.dnsAnswers:
+			m.addDNSAnswerRoutes(answer.name, answer.answers)
+			close(answer.done)
+		case result := <-m.fetched:
+			m.applyFetched(result)
 		}
 	}
 }
 
+// runChecks runs the periodic maintenance and route checks
+func (m *Manager) runChecks() {
+	m.expireServices()
+	m.syncASNs()
+	m.updateNetworks()
+	m.refreshSubscriptions()
+	m.checkAndUpdateRoutes()
+	m.recordLoadedConfig()
+	m.recordStatus()
+}
+
 // checkAndUpdateRoutes checks VPN status and updates routes accordingly
 func (m *Manager) checkAndUpdateRoutes() {
 	isVPNConnected := m.network.IsVPNConnected()
+	if !isVPNConnected {
+		m.ignoringVPN = false
+	} else if !m.shouldManageVPN() {
+		// Treat VPNs we shouldn't act on as if none were connected
+		isVPNConnected = false
+	}
 	
 	// Always update the last check time
 	m.state.UpdateLastCheck()
@@ -157,8 +300,24 @@ func (m *Manager) checkAndUpdateRoutes() {
 			m.state.GetLastCheck().Format("15:04:05"))
 	}
 
+	// Require several consecutive checks to agree before acting on a state
+	// change, so route-table flaps during VPN renegotiation are ignored.
+	// The very first check is trusted so startup isn't delayed.
+	if isVPNConnected == m.lastVPNState {
+		m.pendingChecks = 0
+	} else if m.checked {
+		m.pendingChecks++
+		if m.pendingChecks < m.debounceChecks {
+			m.logger.Debug("VPN state change to connected=%v pending (%d/%d checks)",
+				isVPNConnected, m.pendingChecks, m.debounceChecks)
+			return
+		}
+	}
+	m.checked = true
+
 	// Check if state changed
 	if isVPNConnected != m.lastVPNState {
+		m.pendingChecks = 0
 		m.logger.Info("VPN state changed: connected=%v", isVPNConnected)
 		
 		if isVPNConnected {
@@ -174,55 +333,292 @@ func (m *Manager) checkAndUpdateRoutes() {
 		if err := m.state.Save(); err != nil {
 			m.logger.Error("Failed to save state: %v", err)
 		}
+	} else if isVPNConnected && m.tunnelChanged() {
+		// The VPN reconnected on a new tunnel without a visible disconnect
+		m.logger.Info("Refreshing bypass routes for the new tunnel")
+		if err := m.removeAllRoutes(); err != nil {
+			m.logger.Error("Failed to remove routes: %v", err)
+		}
+		m.handleVPNConnected()
+
+		if err := m.state.Save(); err != nil {
+			m.logger.Error("Failed to save state: %v", err)
+		}
+	} else if isVPNConnected && !m.retryAt.IsZero() {
+		// Routes are waiting for a gateway or internet connectivity
+		if time.Now().After(m.retryAt) {
+			m.handleVPNConnected()
+			if err := m.state.Save(); err != nil {
+				m.logger.Error("Failed to save state: %v", err)
+			}
+		}
+	} else if isVPNConnected {
+		m.checkGatewayChange()
+		m.checkProfileChange()
+		m.checkSchedules()
+		m.refreshDomainRoutes()
+		m.probeServices()
+		m.verifyRoutes()
+	}
+
+	// Remove routes once the disconnect grace period has run out
+	if !m.lastVPNState && !m.removalDeadline.IsZero() && time.Now().After(m.removalDeadline) {
+		m.removalDeadline = time.Time{}
+		m.logger.Info("Disconnect grace period expired - removing bypass routes")
+
+		if err := m.removeAllRoutes(); err != nil {
+			m.logger.Error("Failed to remove routes: %v", err)
+		}
+		if err := m.state.Save(); err != nil {
+			m.logger.Error("Failed to save state: %v", err)
+		}
+	}
+
+	m.updateServiceStats()
+}
+
+// shouldManageVPN decides whether routes should be managed for the
+// connected VPN, based on the allowlist and its tunnel mode. The decision
+// is logged only when it changes.
+func (m *Manager) shouldManageVPN() bool {
+	detection := m.config.Get().Detection
+	if len(detection.Allowlist) == 0 && !detection.SkipSplitTunnel {
+		return true
+	}
+
+	info := m.network.GetVPNInfo()
+	reason := ""
+
+	if len(detection.Allowlist) > 0 {
+		allowed := false
+		for _, match := range detection.Allowlist {
+			if info.Matches(match) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			reason = fmt.Sprintf("VPN connected via %s (product: %s, servers: %v) is not in the allowlist",
+				info.Interface, info.Product, info.Servers)
+		}
+	}
+
+	if reason == "" && detection.SkipSplitTunnel && !info.FullTunnel {
+		reason = "VPN is split-tunnel, bypass routes are not needed"
 	}
 
-	// Verify routes periodically
-	// Disabled for now - netstat format inconsistencies with /16 networks
-	// if isVPNConnected && m.state.HasActiveRoutes() {
-	// 	m.verifyRoutes()
-	// }
+	if reason != "" && !m.ignoringVPN {
+		m.logger.Info("%s - staying idle", reason)
+	}
+	m.ignoringVPN = reason != ""
+
+	return reason == ""
 }
 
 // handleVPNConnected handles VPN connection event
 func (m *Manager) handleVPNConnected() {
+	if !m.removalDeadline.IsZero() {
+		m.removalDeadline = time.Time{}
+		if m.tunnelChanged() {
+			if err := m.removeAllRoutes(); err != nil {
+				m.logger.Error("Failed to remove routes: %v", err)
+			}
+		} else {
+			m.logger.Info("VPN reconnected within grace period - keeping existing routes")
+		}
+	}
+	m.state.SetVPNTunnel(m.network.GetVPNTunnel())
+
 	m.logger.Info("VPN connected - adding bypass routes")
 
+	profile := m.activeProfile()
+	m.state.SetProfile(profileName(profile))
+	if profile != nil {
+		m.logger.Info("Using network profile: %s", profile.Name)
+	}
+
 	// Detect gateway
+	// A (re)connect often follows a network switch
+	m.network.InvalidateGatewayCache()
 	gateway, err := m.network.DetectGateway()
 	if err != nil {
 		m.logger.Error("Failed to detect gateway: %v", err)
+		m.scheduleRetry("no gateway detected")
 		return
 	}
 
-	// Get enabled services
-	services := m.config.GetEnabledServices()
+	// Routes installed behind a captive portal would break the bypassed
+	// services until the portal is passed
+	if err := m.network.CheckConnectivity(gateway); err != nil {
+		m.logger.Warn("Deferring bypass routes: %v", err)
+		m.scheduleRetry("no internet connectivity")
+		return
+	}
+	m.retryAt = time.Time{}
+	m.retryBackoff = 0
+
+	m.logger.Info("Using gateway: %s", gateway)
+	m.state.SetLastGateway(gateway)
+
+	// Get enabled services, as adjusted by the current network profile
+	services := m.servicesForProfile(profile)
 	if len(services) == 0 {
-		m.logger.Warn("No services enabled for bypass")
+		if profile != nil && profile.DisableBypass {
+			m.logger.Info("Bypass disabled by network profile %s", profile.Name)
+		} else {
+			m.logger.Warn("No services enabled for bypass")
+		}
 		return
 	}
+	services = m.scheduledServices(services, time.Now())
 
-	// Add routes for each service
+	// Add routes for each service in priority order. Networks shared by
+	// several services are routed once, for the first of them.
 	totalRoutes := 0
-	for name, service := range services {
-		m.logger.Info("Adding routes for service: %s", name)
+	maxRoutes := m.config.Get().RouteLimits.MaxRoutes
+	routed := make(map[string]bool)
+	order := m.config.ApplyOrder(services)
+	m.state.SetApplyOrder(order)
+	pending := make(map[string]bool)
+	for _, name := range order {
+		for _, network := range m.config.ServiceNetworks(name) {
+			pending[network] = true
+		}
+	}
+	progress := network.NewProgress(m.logger, "Added", len(pending))
+	for i, name := range order {
+		service := services[name]
+		m.serviceLogger(name).Info("Adding routes for service: %s (%d/%d, priority %d)", name, i+1, len(order), service.Priority)
+
+		var networks []string
+		for _, network := range m.config.ServiceNetworks(name) {
+			if !routed[network] {
+				networks = append(networks, network)
+			}
+		}
+
+		if maxRoutes > 0 && totalRoutes+len(networks) > maxRoutes {
+			m.logger.Warn("Skipping %s: %d more routes would exceed the route limit of %d",
+				name, len(networks), maxRoutes)
+			continue
+		}
 		
-		if err := m.network.AddServiceRoutes(name, service.Networks, gateway); err != nil {
+		progress.Start()
+		if err := m.network.AddServiceRoutes(name, networks, gateway, service.Interface); err != nil {
 			m.logger.Error("Failed to add routes for %s: %v", name, err)
 			continue
 		}
+		for _, network := range networks {
+			routed[network] = true
+		}
 		
-		routeCount := len(service.Networks)
+		routeCount := len(networks)
 		totalRoutes += routeCount
 		m.state.SetServiceActive(name, true)
-		m.logger.Info("Added %d routes for %s", routeCount, name)
+		progress.Done(name, routeCount)
 	}
 
 	m.state.SetRoutesActive(true)
-	m.logger.Info("Successfully added %d total routes", totalRoutes)
+	progress.Finish()
+
+	// Probe the new routes on the next check
+	m.nextProbe = time.Time{}
+
+	// Expired routes are only cleaned up while domains are re-resolved
+	if m.config.Get().DomainResolution.Enabled {
+		m.restoreDomainRoutes(services, gateway)
+	}
+
+	m.installSplitDNS(services)
+}
+
+// scheduleRetry schedules another attempt at adding routes with
+// exponential backoff, capped at five minutes
+func (m *Manager) scheduleRetry(reason string) {
+	if m.retryBackoff == 0 {
+		m.retryBackoff = m.checkInterval
+	} else {
+		m.retryBackoff *= 2
+	}
+	if m.retryBackoff > 5*time.Minute {
+		m.retryBackoff = 5 * time.Minute
+	}
+
+	m.retryAt = time.Now().Add(m.retryBackoff)
+	m.logger.Warn("Routes not added (%s), retrying in %v", reason, m.retryBackoff)
+}
+
+// tunnelChanged reports whether the VPN tunnel interface or address differs
+// from the one routes were last set up for
+func (m *Manager) tunnelChanged() bool {
+	iface, tunnelIP := m.network.GetVPNTunnel()
+	state := m.state.GetState()
+
+	if iface == "" || state.VPNInterface == "" {
+		return false
+	}
+	if iface == state.VPNInterface && tunnelIP == state.VPNTunnelIP {
+		return false
+	}
+
+	m.logger.Info("VPN tunnel changed: %s (%s) -> %s (%s)",
+		state.VPNInterface, state.VPNTunnelIP, iface, tunnelIP)
+	return true
+}
+
+// checkGatewayChange re-points managed routes when the physical gateway
+// changes, e.g. after joining another Wi-Fi network or a DHCP renewal
+func (m *Manager) checkGatewayChange() {
+	if !m.state.HasActiveRoutes() {
+		return
+	}
+
+	gateway, err := m.network.DetectGateway()
+	if err != nil {
+		return
+	}
+
+	lastGateway := m.state.GetState().LastGateway
+	if gateway == lastGateway {
+		return
+	}
+
+	// A new network may sit behind a captive portal; drop the routes and
+	// add them again once it is passed
+	if err := m.network.CheckConnectivity(gateway); err != nil {
+		m.logger.Warn("Gateway changed to %s but %v - removing bypass routes", gateway, err)
+		if err := m.removeAllRoutes(); err != nil {
+			m.logger.Error("Failed to remove routes: %v", err)
+		}
+		m.scheduleRetry("no internet connectivity")
+		return
+	}
+
+	if lastGateway != "" {
+		m.logger.Info("Gateway changed from %s to %s - migrating routes", lastGateway, gateway)
+		if err := m.network.MigrateRoutes(gateway); err != nil {
+			m.logger.Error("Failed to migrate routes: %v", err)
+		}
+	}
+
+	m.state.SetLastGateway(gateway)
+	if err := m.state.Save(); err != nil {
+		m.logger.Error("Failed to save state: %v", err)
+	}
 }
 
 // handleVPNDisconnected handles VPN disconnection event
 func (m *Manager) handleVPNDisconnected() {
+	m.retryAt = time.Time{}
+	m.retryBackoff = 0
+
+	// Hold routes for a while in case the VPN is only reconnecting
+	if m.disconnectGrace > 0 && m.state.HasActiveRoutes() {
+		m.removalDeadline = time.Now().Add(m.disconnectGrace)
+		m.logger.Info("VPN disconnected - removing bypass routes in %v unless it reconnects", m.disconnectGrace)
+		return
+	}
+
 	m.logger.Info("VPN disconnected - removing bypass routes")
 
 	if err := m.removeAllRoutes(); err != nil {
@@ -232,6 +628,8 @@ func (m *Manager) handleVPNDisconnected() {
 
 // removeAllRoutes removes all active routes
 func (m *Manager) removeAllRoutes() error {
+	m.removeSplitDNS()
+
 	activeRoutes := m.network.GetActiveRoutes()
 	if len(activeRoutes) == 0 {
 		m.logger.Debug("No active routes to remove")
@@ -239,12 +637,35 @@ func (m *Manager) removeAllRoutes() error {
 	}
 
 	m.logger.Info("Removing %d active routes", len(activeRoutes))
-	
-	if err := m.network.RemoveAllRoutes(); err != nil {
-		return fmt.Errorf("failed to remove routes: %w", err)
+
+	// Service by service, to report progress along the way
+	byService := make(map[string]int)
+	var serviceOrder []string
+	for _, route := range activeRoutes {
+		if byService[route.Service] == 0 {
+			serviceOrder = append(serviceOrder, route.Service)
+		}
+		byService[route.Service]++
+	}
+	sort.Strings(serviceOrder)
+	progress := network.NewProgress(m.logger, "Removed", len(activeRoutes))
+	var failed []string
+	for _, name := range serviceOrder {
+		progress.Start()
+		if err := m.network.RemoveServiceRoutes(name); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		progress.Done(name, byService[name])
+	}
+	progress.Finish()
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove routes: %s", strings.Join(failed, "; "))
 	}
 
 	// Update state
+	m.resetDomainRoutes()
+	m.state.ClearServiceHealth("")
 	m.state.SetRoutesActive(false)
 	for name := range m.config.Get().Services {
 		m.state.SetServiceActive(name, false)
@@ -254,30 +675,47 @@ func (m *Manager) removeAllRoutes() error {
 	return nil
 }
 
-// verifyRoutes verifies that active routes are working
+// verifyRoutes compares the active routes to the routing table once per
+// verifyInterval and adds back those that went missing, as when a VPN
+// client resets the table. Stray entries for a network through another
+// gateway are only reported: the VPN client usually installed them and
+// would put them back, so only 'route verify --fix' removes them.
 func (m *Manager) verifyRoutes() {
-	results := m.network.VerifyRoutes()
-	failedCount := 0
-	
-	for network, ok := range results {
-		if !ok {
-			failedCount++
-			m.logger.Warn("Route verification failed for %s", network)
-		}
+	if time.Now().Before(m.nextVerify) {
+		return
 	}
+	m.nextVerify = time.Now().Add(verifyInterval)
 
-	if failedCount > 0 {
-		m.logger.Warn("%d routes failed verification - attempting to restore", failedCount)
-		
-		// Try to restore routes
-		_, err := m.network.DetectGateway()
-		if err != nil {
-			m.logger.Error("Failed to detect gateway for route restoration: %v", err)
-			return
+	checks, err := m.network.CheckRoutes()
+	if err != nil {
+		m.logger.Error("Failed to verify routes: %v", err)
+		return
+	}
+
+	verification := &RouteVerification{CheckedAt: time.Now(), Failed: make(map[string]string)}
+	repaired := 0
+	for _, check := range checks {
+		if check.OK() {
+			continue
+		}
+		problem := strings.Join(check.Problems(), ", ")
+		m.serviceLogger(check.Route.Service).With(logger.Fields{Network: check.Route.Network, Gateway: check.Route.Gateway}).Warn("Route verification failed for %s (service: %s): %s", check.Route.Network, check.Route.Service, problem)
+		if check.Present || len(check.Stray) > 0 {
+			verification.Failed[check.Route.Network] = problem
+			continue
 		}
+		if err := m.network.RestoreRoute(check.Route); err != nil {
+			m.logger.Error("Failed to repair route: %v", err)
+			verification.Failed[check.Route.Network] = problem + ", repair failed"
+			continue
+		}
+		verification.Failed[check.Route.Network] = problem + ", repaired"
+		repaired++
+	}
+	m.lastVerification = verification
 
-		// Re-add all routes
-		m.handleVPNConnected()
+	if len(verification.Failed) > 0 {
+		m.logger.Warn("%d routes failed verification, %d repaired", len(verification.Failed), repaired)
 	}
 }
 
@@ -294,15 +732,13 @@ func (m *Manager) setupSignalHandling() {
 	}()
 }
 
-// Status returns the current service status
+// Status returns the current service status as the daemon sees it:
+// the VPN state it last acted on rather than a fresh detection
 func (m *Manager) Status() (*Status, error) {
 	m.mu.Lock()
 	running := m.isRunning
 	m.mu.Unlock()
 
-	// Get network status
-	netStatus := m.network.GetStatus()
-
 	// Get state
 	state := m.state.GetState()
 
@@ -314,18 +750,44 @@ func (m *Manager) Status() (*Status, error) {
 		}
 	}
 
+	routes := m.network.GetActiveRoutes()
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Network < routes[j].Network
+	})
+
 	return &Status{
 		Running:         running,
-		VPNConnected:    netStatus["vpn_connected"].(bool),
+		VPNConnected:    state.VPNConnected,
 		RoutesActive:    state.RoutesActive,
-		ActiveRoutes:    m.network.GetActiveRoutes(),
+		ActiveRoutes:    routes,
 		EnabledServices: enabledServices,
-		Gateway:         fmt.Sprintf("%v", netStatus["local_gateway"]),
+		Gateway:         state.LastGateway,
 		LastCheck:       state.LastCheck,
+		StartTime:       state.StartTime,
 		Uptime:          time.Since(state.StartTime),
+		Verification:    m.lastVerification,
+		Version:         m.version,
 	}, nil
 }
 
+// serviceLogger returns the logger recording name as the service of its
+// entries
+func (m *Manager) serviceLogger(name string) *logger.Logger {
+	return m.logger.With(logger.Fields{Service: name})
+}
+
+// SetVersion sets the build of the daemon, shown in its status and logged
+// when it starts
+func (m *Manager) SetVersion(version string) {
+	m.version = version
+}
+
+// SetControlListener makes the daemon take control requests on listener,
+// the socket launchd created for it, instead of creating the socket itself
+func (m *Manager) SetControlListener(listener net.Listener) {
+	m.controlListener = listener
+}
+
 // EnableService enables a service
 func (m *Manager) EnableService(name string) error {
 	if err := m.config.EnableService(name); err != nil {
@@ -345,14 +807,14 @@ func (m *Manager) EnableService(name string) error {
 			return fmt.Errorf("failed to detect gateway: %w", err)
 		}
 		
-		if err := m.network.AddServiceRoutes(name, service.Networks, gateway); err != nil {
+		if err := m.network.AddServiceRoutes(name, m.config.ServiceNetworks(name), gateway, service.Interface); err != nil {
 			return fmt.Errorf("failed to add routes: %w", err)
 		}
 		
 		m.state.SetServiceActive(name, true)
-		m.logger.Info("Service %s enabled and routes added", name)
+		m.serviceLogger(name).Info("Service %s enabled and routes added", name)
 	} else {
-		m.logger.Info("Service %s enabled (routes will be added when VPN connects)", name)
+		m.serviceLogger(name).Info("Service %s enabled (routes will be added when VPN connects)", name)
 	}
 
 	return nil
@@ -360,26 +822,30 @@ func (m *Manager) EnableService(name string) error {
 
 // DisableService disables a service
 func (m *Manager) DisableService(name string) error {
+	// Only the service file is written: saving config.json from the
+	// daemon's copy would revert changes the CLI made since it started
 	if err := m.config.DisableService(name); err != nil {
 		return err
 	}
 
-	// Save config
-	if err := m.config.Save(); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
-	}
+	return m.deactivateService(name)
+}
 
-	// Remove routes if active
+// deactivateService removes the routes of a service that was disabled if
+// they are active
+func (m *Manager) deactivateService(name string) error {
 	if m.state.IsServiceActive(name) {
 		if err := m.network.RemoveServiceRoutes(name); err != nil {
 			return fmt.Errorf("failed to remove routes: %w", err)
 		}
 		
 		m.state.SetServiceActive(name, false)
-		m.logger.Info("Service %s disabled and routes removed", name)
+		m.state.ClearServiceHealth(name)
+		m.restoreSharedRoutes(name)
+		m.serviceLogger(name).Info("Service %s disabled and routes removed", name)
 	} else {
-		m.logger.Info("Service %s disabled", name)
+		m.serviceLogger(name).Info("Service %s disabled", name)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
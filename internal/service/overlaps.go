@@ -0,0 +1,57 @@
+package service
+
+// logSharedNetworks reports networks listed by several enabled services.
+// Their routes are added once, for the owner, so removing another of the
+// services leaves them in place.
+func (m *Manager) logSharedNetworks() {
+	shared := m.config.SharedNetworks(m.config.GetEnabledServices())
+	if len(shared) == 0 {
+		return
+	}
+
+	m.logger.Info("%d networks are listed by several services and routed once for their owner "+
+		"(see 'vpn-route-manager service overlaps')", len(shared))
+	for _, network := range shared {
+		m.logger.Debug("Network %s owned by %s, also listed by %v",
+			network.Network, network.Owner, network.Services[1:])
+	}
+}
+
+// restoreSharedRoutes adds back the routes removed with a service that
+// other active services still need, since a shared network's route is
+// tagged with only one of them
+func (m *Manager) restoreSharedRoutes(removed string) {
+	gateway := m.state.GetState().LastGateway
+	if gateway == "" {
+		return
+	}
+
+	routed := make(map[string]bool)
+	for _, route := range m.network.GetActiveRoutes() {
+		routed[route.Network] = true
+	}
+
+	for _, name := range m.config.ApplyOrder(m.config.Get().Services) {
+		if name == removed || !m.state.IsServiceActive(name) {
+			continue
+		}
+
+		var missing []string
+		for _, network := range m.config.ServiceNetworks(name) {
+			if !routed[network] {
+				missing = append(missing, network)
+				routed[network] = true
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		service := m.config.Get().Services[name]
+		if err := m.network.AddServiceRoutes(name, missing, gateway, service.Interface); err != nil {
+			m.logger.Error("Failed to restore routes for %s: %v", name, err)
+			continue
+		}
+		m.logger.Info("Restored %d routes shared with %s for %s", len(missing), removed, name)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"vpn-route-manager/internal/system"
+)
+
+// Pause is a request to suspend route management, written by the pause
+// command and picked up by the daemon on its next check. A zero Until
+// pauses until resumed.
+type Pause struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until,omitempty"`
+}
+
+// Expired checks if a timed pause has run out
+func (p *Pause) Expired(now time.Time) bool {
+	return !p.Until.IsZero() && !now.Before(p.Until)
+}
+
+// pauseFile returns the path of the pause request
+func pauseFile(stateDir string) string {
+	return filepath.Join(stateDir, "pause.json")
+}
+
+// ReadPause reads the current pause request, or nil when not paused
+func ReadPause(stateDir string) (*Pause, error) {
+	data, err := os.ReadFile(pauseFile(stateDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read pause file: %w", err)
+	}
+
+	var pause Pause
+	if err := json.Unmarshal(data, &pause); err != nil {
+		return nil, fmt.Errorf("failed to parse pause file: %w", err)
+	}
+	return &pause, nil
+}
+
+// WritePause pauses route management, for duration or until resumed when
+// duration is zero
+func WritePause(stateDir string, duration time.Duration) (*Pause, error) {
+	pause := &Pause{Since: time.Now()}
+	if duration > 0 {
+		pause.Until = pause.Since.Add(duration)
+	}
+
+	data, err := json.MarshalIndent(pause, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pause: %w", err)
+	}
+
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := system.WriteFile(pauseFile(stateDir), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write pause file: %w", err)
+	}
+
+	return pause, nil
+}
+
+// ClearPause resumes route management
+func ClearPause(stateDir string) error {
+	if err := os.Remove(pauseFile(stateDir)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove pause file: %w", err)
+	}
+	return nil
+}
+
+// checkPause removes all routes when a pause is requested and reports
+// whether monitoring is suspended. Once the pause is lifted the next
+// check starts over as if the daemon had just started.
+func (m *Manager) checkPause() bool {
+	stateDir := m.config.Get().StateDir
+
+	pause, err := ReadPause(stateDir)
+	if err != nil {
+		m.logger.Error("Ignoring pause request: %v", err)
+		pause = nil
+	}
+	if pause != nil && pause.Expired(time.Now()) {
+		m.logger.Info("Pause expired")
+		if err := ClearPause(stateDir); err != nil {
+			m.logger.Error("%v", err)
+		}
+		pause = nil
+	}
+
+	if pause == nil {
+		if m.paused {
+			m.paused = false
+			m.logger.Info("Resuming VPN monitoring")
+		}
+		return false
+	}
+
+	if !m.paused {
+		m.paused = true
+		if pause.Until.IsZero() {
+			m.logger.Info("Paused - removing bypass routes until resumed")
+		} else {
+			m.logger.Info("Paused - removing bypass routes until %s", pause.Until.Format("15:04:05"))
+		}
+
+		if err := m.removeAllRoutes(); err != nil {
+			m.logger.Error("Failed to remove routes: %v", err)
+		}
+
+		// Start over on resume, without waiting for debounce checks
+		m.lastVPNState = false
+		m.checked = false
+		m.pendingChecks = 0
+		m.removalDeadline = time.Time{}
+		m.retryAt = time.Time{}
+		m.retryBackoff = 0
+		m.state.SetVPNConnected(false)
+
+		if err := m.state.Save(); err != nil {
+			m.logger.Error("Failed to save state: %v", err)
+		}
+	}
+
+	m.state.UpdateLastCheck()
+	return true
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"vpn-route-manager/internal/config"
+)
+
+// activeProfile returns the network profile matching the current location,
+// or nil when no profile applies
+func (m *Manager) activeProfile() *config.NetworkProfile {
+	profiles := m.config.Get().Profiles
+	if len(profiles) == 0 {
+		return nil
+	}
+
+	location := m.network.GetLocation()
+	for i := range profiles {
+		if profiles[i].Matches(location.SSID, location.Addresses) {
+			return &profiles[i]
+		}
+	}
+
+	return nil
+}
+
+// servicesForProfile returns the services to route under a profile
+func (m *Manager) servicesForProfile(profile *config.NetworkProfile) map[string]*config.Service {
+	if profile == nil {
+		return m.config.GetEnabledServices()
+	}
+
+	services := make(map[string]*config.Service)
+	if profile.DisableBypass {
+		return services
+	}
+	if len(profile.Services) == 0 {
+		return m.config.GetEnabledServices()
+	}
+
+	all := m.config.Get().Services
+	for _, name := range profile.Services {
+		if name == "*" {
+			return all
+		}
+		if svc, exists := all[name]; exists {
+			services[name] = svc
+		} else {
+			m.logger.Warn("Profile %s references unknown service %s", profile.Name, name)
+		}
+	}
+
+	return services
+}
+
+// profileName returns the name of a profile, or "" for none
+func profileName(profile *config.NetworkProfile) string {
+	if profile == nil {
+		return ""
+	}
+	return profile.Name
+}
+
+// checkProfileChange re-applies routes when the Mac moves to a network
+// with a different profile
+func (m *Manager) checkProfileChange() {
+	if len(m.config.Get().Profiles) == 0 {
+		return
+	}
+
+	current := profileName(m.activeProfile())
+	previous := m.state.GetState().Profile
+	if current == previous {
+		return
+	}
+
+	m.logger.Info("Network profile changed: %q -> %q - re-applying routes", previous, current)
+	if err := m.removeAllRoutes(); err != nil {
+		m.logger.Error("Failed to remove routes: %v", err)
+	}
+	m.handleVPNConnected()
+
+	if err := m.state.Save(); err != nil {
+		m.logger.Error("Failed to save state: %v", err)
+	}
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/network"
+)
+
+// domainRoute is a host route added for an address a service domain
+// resolved to
+type domainRoute struct {
+	service string
+	expires time.Time
+}
+
+// refreshDomainRoutes re-resolves service domains whose answers have
+// expired, adds host routes for new addresses and removes routes for
+// addresses that were not returned again before their TTL ran out. This
+// keeps CDN-backed services working as their addresses rotate.
+func (m *Manager) refreshDomainRoutes() {
+	cfg := m.config.Get().DomainResolution
+	if !cfg.Enabled || !m.state.HasActiveRoutes() {
+		return
+	}
+
+	gateway := m.state.GetState().LastGateway
+	if gateway == "" {
+		return
+	}
+
+	if m.nextResolve == nil {
+		m.nextResolve = make(map[string]time.Time)
+	}
+
+	now := time.Now()
+	minTTL := time.Duration(cfg.MinTTL) * time.Second
+	maxTTL := time.Duration(cfg.MaxTTL) * time.Second
+	resolved := false
+
+	for name, service := range m.config.Get().Services {
+		if len(service.Domains) == 0 || !m.state.IsServiceActive(name) {
+			continue
+		}
+
+		for _, domain := range m.resolvableDomains(name, service) {
+			if now.Before(m.nextResolve[domain]) {
+				continue
+			}
+
+			answers, err := m.network.ResolveDomain(domain)
+			if err != nil {
+				m.logger.Debug("Failed to resolve %s: %v", domain, err)
+				m.nextResolve[domain] = now.Add(minTTL)
+				continue
+			}
+
+			next := maxTTL
+			for _, answer := range answers {
+				ttl := m.clampTTL(answer.TTL)
+				if ttl < next {
+					next = ttl
+				}
+
+				// Give re-resolution a chance to refresh the address
+				// before its route expires
+				m.addDomainRoute(name, service, domain, answer.IP, gateway, now.Add(ttl+minTTL))
+			}
+			m.nextResolve[domain] = now.Add(next)
+			resolved = true
+		}
+	}
+
+	// Keep the learned addresses across restarts
+	if resolved {
+		if err := m.state.Save(); err != nil {
+			m.logger.Error("Failed to save state: %v", err)
+		}
+	}
+
+	for ip, route := range m.domainRoutes {
+		if now.Before(route.expires) {
+			continue
+		}
+		if err := m.network.RemoveRoute(ip + "/32"); err != nil {
+			m.logger.Error("Failed to remove expired route for %s: %v", ip, err)
+			continue
+		}
+		delete(m.domainRoutes, ip)
+		m.logger.Info("Expired route for %s (service: %s)", ip, route.service)
+	}
+}
+
+// dnsAnswerWait is how long a DNS proxy response is held back for the
+// monitoring loop to add routes for its addresses
+const dnsAnswerWait = 2 * time.Second
+
+// dnsAnswer is an answer of the DNS proxy handed to the monitoring loop,
+// which makes all changes to routes and to the configuration, and closes
+// done once the answer's routes are added
+type dnsAnswer struct {
+	name    string
+	answers []network.DNSAnswer
+	done    chan struct{}
+}
+
+// handleDNSAnswer hands an answer of the DNS proxy to the monitoring loop
+// and waits for its routes, so they are in place before the response
+// reaches the client. While the loop is busy for longer the response goes
+// out without them and the routes follow.
+func (m *Manager) handleDNSAnswer(name string, answers []network.DNSAnswer) {
+	answer := dnsAnswer{name: name, answers: answers, done: make(chan struct{})}
+	timeout := time.NewTimer(dnsAnswerWait)
+	defer timeout.Stop()
+
+	select {
+	case m.dnsAnswers <- answer:
+	case <-timeout.C:
+		m.logger.Debug("Dropping DNS answer for %s: the service is busy", name)
+		return
+	case <-m.ctx.Done():
+		return
+	}
+
+	select {
+	case <-answer.done:
+	case <-timeout.C:
+	case <-m.ctx.Done():
+	}
+}
+
+// addDNSAnswerRoutes installs host routes for DNS proxy answers to queries
+// for an active service's domains, from the monitoring loop
+func (m *Manager) addDNSAnswerRoutes(name string, answers []network.DNSAnswer) {
+	if !m.state.HasActiveRoutes() {
+		return
+	}
+
+	gateway := m.state.GetState().LastGateway
+	if gateway == "" {
+		return
+	}
+
+	for serviceName, service := range m.config.Get().Services {
+		if !m.state.IsServiceActive(serviceName) || !matchesServiceDomain(service, name) {
+			continue
+		}
+
+		now := time.Now()
+		minTTL := time.Duration(m.config.Get().DomainResolution.MinTTL) * time.Second
+
+		domain := strings.ToLower(strings.TrimSuffix(name, "."))
+
+		if matchesWildcard(service, name) {
+			// Remember the subdomain so it is re-resolved like a
+			// configured domain
+			if m.observedDomains == nil {
+				m.observedDomains = make(map[string]string)
+			}
+			m.observedDomains[domain] = serviceName
+		}
+		for _, answer := range answers {
+			m.addDomainRoute(serviceName, service, domain, answer.IP, gateway, now.Add(m.clampTTL(answer.TTL)+minTTL))
+		}
+		return
+	}
+}
+
+// matchesServiceDomain checks if a queried name belongs to one of a
+// service's domains
+func matchesServiceDomain(service *config.Service, name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	for _, domain := range service.Domains {
+		if network.MatchesDomain(domain, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWildcard checks if a queried name matches one of a service's
+// wildcard domains
+func matchesWildcard(service *config.Service, name string) bool {
+	for _, domain := range service.Domains {
+		if network.IsWildcardDomain(domain) && network.MatchesDomain(domain, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvableDomains returns the names to re-resolve for a service: its
+// plain domains plus any subdomains seen by the DNS proxy that match its
+// wildcard domains, which cannot be resolved themselves
+func (m *Manager) resolvableDomains(name string, service *config.Service) []string {
+	var domains []string
+	for _, domain := range service.Domains {
+		if !network.IsWildcardDomain(domain) {
+			domains = append(domains, domain)
+		}
+	}
+
+	for observed, owner := range m.observedDomains {
+		if owner == name {
+			domains = append(domains, observed)
+		}
+	}
+
+	return domains
+}
+
+// clampTTL bounds a DNS TTL to the configured range
+func (m *Manager) clampTTL(ttl time.Duration) time.Duration {
+	cfg := m.config.Get().DomainResolution
+	if min := time.Duration(cfg.MinTTL) * time.Second; ttl < min {
+		return min
+	}
+	if max := time.Duration(cfg.MaxTTL) * time.Second; ttl > max {
+		return max
+	}
+	return ttl
+}
+
+// addDomainRoute adds a host route for an address a domain resolved to
+// unless one of the service's networks already covers it, and records
+// the address in the state.
+func (m *Manager) addDomainRoute(name string, service *config.Service, domain, ip, gateway string, expires time.Time) {
+	if m.domainRoutes == nil {
+		m.domainRoutes = make(map[string]*domainRoute)
+	}
+	if route, exists := m.domainRoutes[ip]; exists {
+		if expires.After(route.expires) {
+			route.expires = expires
+		}
+		m.recordResolvedAddress(name, domain, ip, route.expires)
+		return
+	}
+
+	if !m.config.Bypassable(ip) {
+		m.logger.Debug("Not routing %s of %s: the system configuration never bypasses it", ip, domain)
+		return
+	}
+
+	addr := net.ParseIP(ip)
+	for _, network := range m.config.ServiceNetworks(name) {
+		if _, ipnet, err := net.ParseCIDR(network); err == nil && ipnet.Contains(addr) {
+			return
+		}
+	}
+
+	if err := m.network.AddServiceRoutes(name, []string{ip + "/32"}, gateway, service.Interface); err != nil {
+		m.logger.Error("Failed to add route for %s: %v", ip, err)
+		return
+	}
+	m.domainRoutes[ip] = &domainRoute{service: name, expires: expires}
+	m.recordResolvedAddress(name, domain, ip, expires)
+}
+
+// recordResolvedAddress stores a domain's address in the state
+func (m *Manager) recordResolvedAddress(name, domain, ip string, expires time.Time) {
+	m.state.SetResolvedAddress(domain, ResolvedAddress{
+		IP:         ip,
+		Service:    name,
+		ResolvedAt: time.Now(),
+		Expires:    expires,
+	})
+}
+
+// restoreDomainRoutes adds host routes again for addresses learned before
+// a restart or reconnect whose answers have not expired yet, so they work
+// before the domains are resolved again
+func (m *Manager) restoreDomainRoutes(services map[string]*config.Service, gateway string) {
+	restored := 0
+	for domain, addresses := range m.state.GetResolvedDomains() {
+		for _, address := range addresses {
+			service, exists := services[address.Service]
+			if !exists || !matchesServiceDomain(service, domain) {
+				continue
+			}
+			if _, exists := m.domainRoutes[address.IP]; exists {
+				continue
+			}
+
+			m.addDomainRoute(address.Service, service, domain, address.IP, gateway, address.Expires)
+			if _, exists := m.domainRoutes[address.IP]; exists {
+				restored++
+			}
+		}
+	}
+
+	if restored > 0 {
+		m.logger.Info("Restored %d cached domain routes", restored)
+	}
+}
+
+// resetDomainRoutes forgets all resolved addresses, after their routes
+// have been removed
+func (m *Manager) resetDomainRoutes() {
+	m.domainRoutes = nil
+	m.nextResolve = nil
+}
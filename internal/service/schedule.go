@@ -0,0 +1,74 @@
+package service
+
+import (
+	"time"
+
+	"vpn-route-manager/internal/config"
+)
+
+// scheduledServices returns the services whose schedule allows them to be
+// routed at the given time
+func (m *Manager) scheduledServices(services map[string]*config.Service, now time.Time) map[string]*config.Service {
+	scheduled := make(map[string]*config.Service, len(services))
+	for name, service := range services {
+		if !service.InSchedule(now) {
+			m.logger.Info("Skipping %s: outside its schedule", name)
+			continue
+		}
+		scheduled[name] = service
+	}
+	return scheduled
+}
+
+// checkSchedules adds or removes the routes of scheduled services as their
+// windows open and close
+func (m *Manager) checkSchedules() {
+	if !m.state.HasActiveRoutes() {
+		return
+	}
+
+	gateway := m.state.GetState().LastGateway
+	if gateway == "" {
+		return
+	}
+
+	now := time.Now()
+	changed := false
+
+	for name, service := range m.servicesForProfile(m.activeProfile()) {
+		if len(service.Schedule) == 0 {
+			continue
+		}
+
+		inWindow := service.InSchedule(now)
+		active := m.state.IsServiceActive(name)
+
+		switch {
+		case inWindow && !active:
+			networks := m.config.ServiceNetworks(name)
+			if err := m.network.AddServiceRoutes(name, networks, gateway, service.Interface); err != nil {
+				m.logger.Error("Failed to add routes for %s: %v", name, err)
+				continue
+			}
+			m.state.SetServiceActive(name, true)
+			m.logger.Info("Schedule window opened for %s - added %d routes", name, len(networks))
+			changed = true
+		case !inWindow && active:
+			if err := m.network.RemoveServiceRoutes(name); err != nil {
+				m.logger.Error("Failed to remove routes for %s: %v", name, err)
+				continue
+			}
+			m.state.SetServiceActive(name, false)
+			m.state.ClearServiceHealth(name)
+			m.restoreSharedRoutes(name)
+			m.logger.Info("Schedule window closed for %s - routes removed", name)
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := m.state.Save(); err != nil {
+			m.logger.Error("Failed to save state: %v", err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import "time"
+
+// minSleep is the least time the Mac must have slept for a wake to be
+// handled; shorter gaps are clock adjustments rather than sleep
+const minSleep = 30 * time.Second
+
+// sleepDetector notices the Mac waking from sleep between checks. Go's
+// monotonic clock stops while macOS sleeps but the wall clock doesn't, so
+// the wall clock running ahead of it is the time spent asleep. This needs
+// no IOKit power notifications, which a binary built without cgo can't
+// subscribe to.
+type sleepDetector struct {
+	last time.Time
+}
+
+// slept returns how long the Mac slept since it was last called, or zero
+// when it didn't
+func (d *sleepDetector) slept() time.Duration {
+	now := time.Now()
+	last := d.last
+	d.last = now
+	if last.IsZero() {
+		return 0
+	}
+
+	// Round(0) strips the monotonic reading, leaving the wall clock
+	asleep := now.Round(0).Sub(last.Round(0)) - now.Sub(last)
+	if asleep < minSleep {
+		return 0
+	}
+	return asleep
+}
+
+// handleWake prepares the next checks after the Mac woke: the VPN and the
+// gateway often changed while it slept, so both are detected afresh, the
+// first VPN state seen is trusted as it is at startup, and the routes are
+// verified right away instead of at the next verify interval
+func (m *Manager) handleWake(asleep time.Duration) {
+	m.logger.Info("System woke after sleeping %s - re-detecting VPN and gateway", asleep.Round(time.Second))
+
+	m.network.InvalidateGatewayCache()
+	m.checked = false
+	m.pendingChecks = 0
+	m.nextVerify = time.Time{}
+	if !m.retryAt.IsZero() {
+		m.retryAt = time.Now()
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/network"
+)
+
+// installSplitDNS writes /etc/resolver entries for the domains of the
+// routed services, pointing them at a nameserver outside the VPN
+func (m *Manager) installSplitDNS(services map[string]*config.Service) {
+	cfg := m.config.Get()
+	if !cfg.SplitDNS.Enabled {
+		return
+	}
+
+	nameserver, port := m.splitDNSNameserver()
+	if nameserver == "" {
+		m.logger.Warn("Split DNS enabled but no local nameserver found")
+		return
+	}
+
+	installed := m.state.GetState().ResolverDomains
+	seen := make(map[string]bool)
+	for _, domain := range installed {
+		seen[domain] = true
+	}
+
+	for _, service := range services {
+		for _, domain := range service.Domains {
+			// Resolver files already cover subdomains
+			domain = strings.TrimPrefix(domain, "*.")
+			if seen[domain] {
+				continue
+			}
+			if err := network.InstallResolverFile(domain, nameserver, port); err != nil {
+				m.logger.Warn("Failed to install resolver for %s: %v", domain, err)
+				continue
+			}
+			seen[domain] = true
+			installed = append(installed, domain)
+		}
+	}
+
+	m.state.SetResolverDomains(installed)
+	m.logger.Info("Split DNS: %d domains resolved via %s", len(installed), nameserver)
+}
+
+// removeSplitDNS removes all /etc/resolver entries this service installed
+func (m *Manager) removeSplitDNS() {
+	domains := m.state.GetState().ResolverDomains
+	if len(domains) == 0 {
+		return
+	}
+
+	var remaining []string
+	for _, domain := range domains {
+		if err := network.RemoveResolverFile(domain); err != nil {
+			m.logger.Error("Failed to remove resolver for %s: %v", domain, err)
+			remaining = append(remaining, domain)
+		}
+	}
+
+	m.state.SetResolverDomains(remaining)
+	m.logger.Info("Split DNS: removed %d resolver entries", len(domains)-len(remaining))
+}
+
+// splitDNSNameserver picks the nameserver resolver entries point at: the
+// local DNS proxy when enabled, the configured server, or the DHCP one
+func (m *Manager) splitDNSNameserver() (string, int) {
+	cfg := m.config.Get()
+
+	if cfg.DNSProxy.Enabled && m.dnsProxy != nil {
+		host, portStr, err := net.SplitHostPort(cfg.DNSProxy.Listen)
+		if err == nil {
+			port, _ := strconv.Atoi(portStr)
+			return host, port
+		}
+	}
+
+	if cfg.SplitDNS.Nameserver != "" {
+		return cfg.SplitDNS.Nameserver, 0
+	}
+
+	return m.network.LocalNameserver(), 0
+}
@@ -8,17 +8,56 @@ import (
 	"sync"
 	"syscall"
 	"time"
+
+	"vpn-route-manager/internal/system"
 )
 
 // State represents the service state
 type State struct {
-	VPNConnected    bool                   `json:"vpn_connected"`
-	RoutesActive    bool                   `json:"routes_active"`
-	ActiveServices  map[string]bool        `json:"active_services"`
-	LastCheck       time.Time              `json:"last_check"`
-	StartTime       time.Time              `json:"start_time"`
-	LastGateway     string                 `json:"last_gateway"`
-	Version         string                 `json:"version"`
+	VPNConnected    bool                         `json:"vpn_connected"`
+	RoutesActive    bool                         `json:"routes_active"`
+	ActiveServices  map[string]bool              `json:"active_services"`
+	LastCheck       time.Time                    `json:"last_check"`
+	StartTime       time.Time                    `json:"start_time"`
+	LastGateway     string                       `json:"last_gateway"`
+	VPNInterface    string                       `json:"vpn_interface,omitempty"`
+	VPNTunnelIP     string                       `json:"vpn_tunnel_ip,omitempty"`
+	Profile         string                       `json:"profile,omitempty"`
+	ResolverDomains []string                     `json:"resolver_domains,omitempty"`
+	ResolvedDomains map[string][]ResolvedAddress `json:"resolved_domains,omitempty"`
+	ServiceHealth   map[string]ServiceHealth     `json:"service_health,omitempty"`
+	ApplyOrder      []string                     `json:"apply_order,omitempty"`
+	ServiceStats    map[string]ServiceStats      `json:"service_stats,omitempty"`
+	Starts          []time.Time                  `json:"starts,omitempty"`
+	Version         string                       `json:"version"`
+}
+
+// ResolvedAddress is an address a service domain resolved to and got a
+// host route for. It is kept until Expires so the route can be added
+// again right away after a restart.
+type ResolvedAddress struct {
+	IP         string    `json:"ip"`
+	Service    string    `json:"service"`
+	ResolvedAt time.Time `json:"resolved_at"`
+	Expires    time.Time `json:"expires"`
+}
+
+// ServiceHealth is the outcome of the last probe of a service's health
+// check target through the bypass route
+type ServiceHealth struct {
+	Healthy   bool          `json:"healthy"`
+	Error     string        `json:"error,omitempty"`
+	Latency   time.Duration `json:"latency,omitempty"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+// ServiceStats counts the routes installed for a service. DomainRoutes are
+// the host routes for resolved domain addresses, included in Routes.
+// AppliedAt is when the most recent of them was added.
+type ServiceStats struct {
+	Routes       int       `json:"routes"`
+	DomainRoutes int       `json:"domain_routes"`
+	AppliedAt    time.Time `json:"applied_at"`
 }
 
 // StateManager manages service state persistence
@@ -83,6 +122,12 @@ func (sm *StateManager) Load() error {
 	sm.state.RoutesActive = state.RoutesActive
 	sm.state.LastCheck = state.LastCheck
 	sm.state.LastGateway = state.LastGateway
+	sm.state.VPNInterface = state.VPNInterface
+	sm.state.VPNTunnelIP = state.VPNTunnelIP
+	sm.state.Profile = state.Profile
+	sm.state.ResolverDomains = state.ResolverDomains
+	sm.state.ResolvedDomains = state.ResolvedDomains
+	sm.state.Starts = state.Starts
 	
 	if state.ActiveServices != nil {
 		sm.state.ActiveServices = state.ActiveServices
@@ -91,6 +136,32 @@ func (sm *StateManager) Load() error {
 	return nil
 }
 
+// UpgradeState sets aside a state file this build can't parse, such as one
+// an older version wrote in another layout, so the daemon starts from fresh
+// state rather than failing to load it. State is rebuilt as the daemon
+// runs. It returns where the file was moved, or "" when it was kept.
+func UpgradeState(stateDir string) (string, error) {
+	stateFile := filepath.Join(stateDir, "state.json")
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var state State
+	if json.Unmarshal(data, &state) == nil {
+		return "", nil
+	}
+
+	moved := fmt.Sprintf("%s.%s.old", stateFile, time.Now().Format("20060102-150405"))
+	if err := os.Rename(stateFile, moved); err != nil {
+		return "", fmt.Errorf("failed to set aside state file: %w", err)
+	}
+	return moved, nil
+}
+
 // Save saves state to file
 func (sm *StateManager) Save() error {
 	sm.mu.RLock()
@@ -105,7 +176,7 @@ func (sm *StateManager) Save() error {
 
 	// Write to temporary file first
 	tmpFile := sm.stateFile + ".tmp"
-	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+	if err := system.WriteFile(tmpFile, data, 0644); err != nil {
 		return fmt.Errorf("failed to write state file: %w", err)
 	}
 
@@ -123,12 +194,34 @@ func (sm *StateManager) GetState() State {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
-	// Deep copy
+	// Deep copy, as the monitoring loop and the probes keep changing the
+	// maps and slices while the copy is read
 	state := *sm.state
 	state.ActiveServices = make(map[string]bool)
 	for k, v := range sm.state.ActiveServices {
 		state.ActiveServices[k] = v
 	}
+	if sm.state.ResolvedDomains != nil {
+		state.ResolvedDomains = make(map[string][]ResolvedAddress, len(sm.state.ResolvedDomains))
+		for domain, addresses := range sm.state.ResolvedDomains {
+			state.ResolvedDomains[domain] = append([]ResolvedAddress(nil), addresses...)
+		}
+	}
+	if sm.state.ServiceHealth != nil {
+		state.ServiceHealth = make(map[string]ServiceHealth, len(sm.state.ServiceHealth))
+		for k, v := range sm.state.ServiceHealth {
+			state.ServiceHealth[k] = v
+		}
+	}
+	if sm.state.ServiceStats != nil {
+		state.ServiceStats = make(map[string]ServiceStats, len(sm.state.ServiceStats))
+		for k, v := range sm.state.ServiceStats {
+			state.ServiceStats[k] = v
+		}
+	}
+	state.ResolverDomains = append([]string(nil), sm.state.ResolverDomains...)
+	state.ApplyOrder = append([]string(nil), sm.state.ApplyOrder...)
+	state.Starts = append([]time.Time(nil), sm.state.Starts...)
 
 	return state
 }
@@ -161,6 +254,216 @@ func (sm *StateManager) SetLastGateway(gateway string) {
 	sm.state.LastGateway = gateway
 }
 
+// SetVPNTunnel records the tunnel interface and address routes were set up for
+func (sm *StateManager) SetVPNTunnel(iface, tunnelIP string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.state.VPNInterface = iface
+	sm.state.VPNTunnelIP = tunnelIP
+}
+
+// SetProfile records the network profile routes were set up under
+func (sm *StateManager) SetProfile(profile string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.state.Profile = profile
+}
+
+// SetServiceStats replaces the route counts of every service, reporting
+// whether they changed
+func (sm *StateManager) SetServiceStats(stats map[string]ServiceStats) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	changed := len(stats) != len(sm.state.ServiceStats)
+	for name, s := range stats {
+		if previous, exists := sm.state.ServiceStats[name]; !exists || previous != s {
+			changed = true
+		}
+	}
+	sm.state.ServiceStats = stats
+	return changed
+}
+
+// SetApplyOrder records the order service routes were added in
+func (sm *StateManager) SetApplyOrder(order []string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.state.ApplyOrder = order
+}
+
+// SetResolverDomains records the domains with installed /etc/resolver files
+func (sm *StateManager) SetResolverDomains(domains []string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.state.ResolverDomains = domains
+}
+
+// SetResolvedAddress records an address a domain resolved to, replacing
+// any earlier record of the same address and dropping expired ones
+func (sm *StateManager) SetResolvedAddress(domain string, address ResolvedAddress) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.state.ResolvedDomains == nil {
+		sm.state.ResolvedDomains = make(map[string][]ResolvedAddress)
+	}
+
+	addresses := []ResolvedAddress{address}
+	for _, existing := range sm.state.ResolvedDomains[domain] {
+		if existing.IP != address.IP && existing.Expires.After(address.ResolvedAt) {
+			addresses = append(addresses, existing)
+		}
+	}
+	sm.state.ResolvedDomains[domain] = addresses
+}
+
+// GetResolvedDomains returns the recorded addresses of each domain that
+// have not expired yet
+func (sm *StateManager) GetResolvedDomains() map[string][]ResolvedAddress {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	now := time.Now()
+	domains := make(map[string][]ResolvedAddress)
+	for domain, addresses := range sm.state.ResolvedDomains {
+		for _, address := range addresses {
+			if address.Expires.After(now) {
+				domains[domain] = append(domains[domain], address)
+			}
+		}
+	}
+	return domains
+}
+
+// SetServiceHealth records the outcome of a service's health probe
+func (sm *StateManager) SetServiceHealth(service string, health ServiceHealth) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.state.ServiceHealth == nil {
+		sm.state.ServiceHealth = make(map[string]ServiceHealth)
+	}
+	sm.state.ServiceHealth[service] = health
+}
+
+// ClearServiceHealth forgets the probe outcome of a service, or of every
+// service when service is ""
+func (sm *StateManager) ClearServiceHealth(service string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if service == "" {
+		sm.state.ServiceHealth = nil
+		return
+	}
+	delete(sm.state.ServiceHealth, service)
+}
+
+// GetServiceHealth returns the last probe outcome of a service
+func (sm *StateManager) GetServiceHealth(service string) (ServiceHealth, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	health, exists := sm.state.ServiceHealth[service]
+	return health, exists
+}
+
+// renameServiceState moves a service's entries in state to a new name
+func renameServiceState(state *State, oldName, newName string) {
+	if active, exists := state.ActiveServices[oldName]; exists {
+		delete(state.ActiveServices, oldName)
+		state.ActiveServices[newName] = active
+	}
+
+	for i, name := range state.ApplyOrder {
+		if name == oldName {
+			state.ApplyOrder[i] = newName
+		}
+	}
+
+	if stats, exists := state.ServiceStats[oldName]; exists {
+		delete(state.ServiceStats, oldName)
+		state.ServiceStats[newName] = stats
+	}
+
+	if health, exists := state.ServiceHealth[oldName]; exists {
+		delete(state.ServiceHealth, oldName)
+		state.ServiceHealth[newName] = health
+	}
+
+	for _, addresses := range state.ResolvedDomains {
+		for i := range addresses {
+			if addresses[i].Service == oldName {
+				addresses[i].Service = newName
+			}
+		}
+	}
+}
+
+// ReadStateFile reads the state saved by the daemon, for commands that run
+// outside it
+func ReadStateFile(stateDir string) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir, "state.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	return &state, nil
+}
+
+// RenameServiceInStateFile renames a service in the saved state, for
+// commands that run outside the daemon
+func RenameServiceInStateFile(stateDir, oldName, newName string) error {
+	stateFile := filepath.Join(stateDir, "state.json")
+
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if state.ActiveServices == nil {
+		state.ActiveServices = make(map[string]bool)
+	}
+
+	renameServiceState(&state, oldName, newName)
+	return writeStateFile(stateFile, &state)
+}
+
+// writeStateFile saves state to stateFile for commands that run outside
+// the daemon
+func writeStateFile(stateFile string, state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	// Write to temporary file first
+	tmpFile := stateFile + ".tmp"
+	if err := system.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	// Atomic rename
+	if err := os.Rename(tmpFile, stateFile); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to update state file: %w", err)
+	}
+
+	return nil
+}
+
 // IsServiceActive checks if a service is active
 func (sm *StateManager) IsServiceActive(service string) bool {
 	sm.mu.RLock()
@@ -178,7 +481,7 @@ func (sm *StateManager) HasActiveRoutes() bool {
 // writePID writes the process PID to file
 func (sm *StateManager) writePID() error {
 	pid := os.Getpid()
-	return os.WriteFile(sm.pidFile, []byte(fmt.Sprintf("%d", pid)), 0644)
+	return system.WriteFile(sm.pidFile, []byte(fmt.Sprintf("%d", pid)), 0644)
 }
 
 // RemovePID removes the PID file
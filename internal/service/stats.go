@@ -0,0 +1,27 @@
+package service
+
+// updateServiceStats records how many routes are installed for each
+// service, so commands outside the daemon can report them
+func (m *Manager) updateServiceStats() {
+	stats := make(map[string]ServiceStats)
+	for _, route := range m.network.GetActiveRoutes() {
+		s := stats[route.Service]
+		s.Routes++
+		if route.AddedAt.After(s.AppliedAt) {
+			s.AppliedAt = route.AddedAt
+		}
+		stats[route.Service] = s
+	}
+
+	for _, route := range m.domainRoutes {
+		s := stats[route.service]
+		s.DomainRoutes++
+		stats[route.service] = s
+	}
+
+	if m.state.SetServiceStats(stats) {
+		if err := m.state.Save(); err != nil {
+			m.logger.Error("Failed to save state: %v", err)
+		}
+	}
+}
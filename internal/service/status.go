@@ -1,9 +1,14 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 	"vpn-route-manager/internal/network"
+	"vpn-route-manager/internal/system"
 )
 
 // Status represents the current service status
@@ -15,7 +20,18 @@ type Status struct {
 	EnabledServices map[string]bool        `json:"enabled_services"`
 	Gateway         string                 `json:"gateway"`
 	LastCheck       time.Time              `json:"last_check"`
+	StartTime       time.Time              `json:"start_time"`
 	Uptime          time.Duration          `json:"uptime"`
+	Verification    *RouteVerification     `json:"verification,omitempty"`
+	Version         string                 `json:"version,omitempty"`
+}
+
+// RouteVerification is the outcome of the daemon's last comparison of its
+// routes to the routing table. Failed maps the networks that didn't match
+// to what was wrong, and whether that was repaired.
+type RouteVerification struct {
+	CheckedAt time.Time         `json:"checked_at"`
+	Failed    map[string]string `json:"failed,omitempty"`
 }
 
 // GetStatusSummary returns a human-readable status summary
@@ -40,4 +56,81 @@ func (s *Status) GetStatusSummary() string {
 	}
 
 	return fmt.Sprintf("VPN connected, %d services active, %d routes", activeCount, len(s.ActiveRoutes))
-}
\ No newline at end of file
+}
+
+// statusFile returns the path of the status the daemon records for the
+// CLI, which has no other way to see its routes
+func statusFile(stateDir string) string {
+	return filepath.Join(stateDir, "status.json")
+}
+
+// ReadStatusFile reads the status the running daemon last recorded, or
+// nil when no daemon has recorded one. LastCheck is only as recent as the
+// last change; the state file has the latest one.
+func ReadStatusFile(stateDir string) (*Status, error) {
+	data, err := os.ReadFile(statusFile(stateDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read status file: %w", err)
+	}
+
+	var status Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse status file: %w", err)
+	}
+	if !status.StartTime.IsZero() {
+		status.Uptime = time.Since(status.StartTime)
+	}
+	return &status, nil
+}
+
+// recordStatus writes the daemon's status when it changed since it was
+// last written. The check time and uptime change every check, so they
+// are left out of the comparison.
+func (m *Manager) recordStatus() {
+	status, err := m.Status()
+	if err != nil {
+		m.logger.Error("Failed to get status: %v", err)
+		return
+	}
+
+	lastCheck := status.LastCheck
+	status.LastCheck = time.Time{}
+	status.Uptime = 0
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		m.logger.Error("Failed to marshal status: %v", err)
+		return
+	}
+	if bytes.Equal(data, m.recordedStatus) {
+		return
+	}
+	compared := data
+
+	status.LastCheck = lastCheck
+	if data, err = json.MarshalIndent(status, "", "  "); err != nil {
+		m.logger.Error("Failed to marshal status: %v", err)
+		return
+	}
+
+	path := statusFile(m.config.Get().StateDir)
+	if err := system.WriteFile(path+".tmp", data, 0644); err != nil {
+		m.logger.Error("Failed to write status: %v", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		os.Remove(path + ".tmp")
+		m.logger.Error("Failed to write status: %v", err)
+		return
+	}
+	m.recordedStatus = compared
+}
+
+// clearStatus removes the recorded status once the daemon stops
+func (m *Manager) clearStatus() {
+	if err := os.Remove(statusFile(m.config.Get().StateDir)); err != nil && !os.IsNotExist(err) {
+		m.logger.Error("Failed to remove status: %v", err)
+	}
+}
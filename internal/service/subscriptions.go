@@ -0,0 +1,78 @@
+package service
+
+import (
+	"time"
+
+	"vpn-route-manager/internal/config"
+)
+
+// refreshSubscriptions downloads subscribed service lists that are due in
+// the background, and re-applies routes from the monitoring loop when any
+// of them changed
+func (m *Manager) refreshSubscriptions() {
+	cfg := m.config.Get()
+	if len(cfg.Subscriptions) == 0 {
+		return
+	}
+	if m.nextRefresh == nil {
+		m.nextRefresh = make(map[string]time.Time)
+	}
+
+	now := time.Now()
+	var due []config.Subscription
+	for _, sub := range cfg.Subscriptions {
+		if now.Before(m.nextRefresh[sub.Name]) {
+			continue
+		}
+		m.nextRefresh[sub.Name] = now.Add(sub.RefreshInterval())
+		due = append(due, sub)
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	dir := config.SubscriptionsDir(cfg)
+	m.fetchInBackground("subscriptions", func() func() {
+		changed := false
+		for _, sub := range due {
+			updated, err := config.FetchSubscription(sub, dir)
+			if err != nil {
+				m.logger.Warn("Failed to refresh subscription %s: %v", sub.Name, err)
+				continue
+			}
+			if updated {
+				m.logger.Info("Subscription %s updated", sub.Name)
+				changed = true
+			} else {
+				m.logger.Debug("Subscription %s unchanged", sub.Name)
+			}
+		}
+
+		return func() {
+			if changed {
+				m.applySubscriptions()
+			}
+		}
+	})
+}
+
+// applySubscriptions loads the refreshed subscriptions and re-applies
+// routes
+func (m *Manager) applySubscriptions() {
+	if err := m.config.LoadSubscriptions(); err != nil {
+		m.logger.Error("Failed to load subscriptions: %v", err)
+		return
+	}
+
+	if m.state.HasActiveRoutes() {
+		m.logger.Info("Re-applying routes for updated subscriptions")
+		if err := m.removeAllRoutes(); err != nil {
+			m.logger.Error("Failed to remove routes: %v", err)
+		}
+		m.handleVPNConnected()
+
+		if err := m.state.Save(); err != nil {
+			m.logger.Error("Failed to save state: %v", err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"time"
+
+	"vpn-route-manager/internal/config"
+	"vpn-route-manager/internal/network"
+)
+
+// updateNetworks refreshes the networks of services from the IP range
+// lists their operators publish once the configured interval has passed.
+// Services that list AS numbers are left to syncASNs. The lists are
+// fetched in the background and applied by the monitoring loop.
+func (m *Manager) updateNetworks() {
+	cfg := m.config.Get().NetworkUpdates
+	if !cfg.Enabled || time.Now().Before(m.nextNetworkUpdate) {
+		return
+	}
+	m.nextNetworkUpdate = time.Now().Add(time.Duration(cfg.Interval) * time.Hour)
+
+	sources := make(map[string]string)
+	for name, service := range m.config.Get().Services {
+		if source := config.NetworksSource(name, service); source != "" && len(service.ASNs) == 0 {
+			sources[name] = source
+		}
+	}
+	if len(sources) == 0 {
+		return
+	}
+
+	m.fetchInBackground("network-updates", func() func() {
+		fetched := make(map[string][]string)
+		for name, source := range sources {
+			networks, err := network.FetchPublishedNetworks(source)
+			if err != nil {
+				m.logger.Warn("Failed to update networks for %s: %v", name, err)
+				continue
+			}
+			fetched[name] = networks
+		}
+
+		return func() {
+			for name, networks := range fetched {
+				if m.applyServiceNetworks(name, networks) {
+					m.logger.Info("Updated %d networks for %s from %s", len(networks), name, sources[name])
+				}
+			}
+		}
+	})
+}
+
+// applyServiceNetworks replaces the networks of a service when they
+// changed, saving its file and, if it is active, adding routes for the new
+// networks and removing those of the dropped ones. It
+// reports whether anything changed. Updates over the route limits are
+// refused or truncated as configured.
+func (m *Manager) applyServiceNetworks(name string, networks []string) bool {
+	// The service may have gone while its networks were fetched
+	service, exists := m.config.Get().Services[name]
+	if !exists {
+		return false
+	}
+
+	networks, dropped, err := m.config.LimitNetworks(name, networks)
+	if err != nil {
+		m.logger.Warn("Not updating networks for %s: %v", name, err)
+		return false
+	}
+	if dropped > 0 {
+		m.logger.Warn("Dropped %d networks for %s to stay within the route limit", dropped, name)
+	}
+
+	if sameNetworks(service.Networks, networks) {
+		return false
+	}
+
+	previous := m.config.ServiceNetworks(name)
+	if err := m.config.SetServiceNetworks(name, networks); err != nil {
+		m.logger.Error("Failed to update networks for %s: %v", name, err)
+		return false
+	}
+
+	gateway := m.state.GetState().LastGateway
+	if !m.state.IsServiceActive(name) || gateway == "" {
+		return true
+	}
+
+	// Add the new networks before removing the dropped ones, so traffic
+	// to the networks that stay never goes through the VPN in between
+	current := m.config.ServiceNetworks(name)
+	if err := m.network.AddServiceRoutes(name, current, gateway, service.Interface); err != nil {
+		m.logger.Error("Failed to add routes for %s: %v", name, err)
+	}
+
+	removed := make(map[string]bool)
+	for _, network := range previous {
+		removed[network] = true
+	}
+	for _, network := range current {
+		delete(removed, network)
+	}
+	for _, route := range m.network.GetActiveRoutes() {
+		if route.Service != name || !removed[route.Network] {
+			continue
+		}
+		if err := m.network.RemoveRoute(route.Network); err != nil {
+			m.logger.Error("Failed to remove route %s for %s: %v", route.Network, name, err)
+		}
+	}
+	m.restoreSharedRoutes(name)
+	return true
+}
+
+// sameNetworks reports whether two network lists hold the same entries
+func sameNetworks(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := make(map[string]bool, len(a))
+	for _, network := range a {
+		set[network] = true
+	}
+	for _, network := range b {
+		if !set[network] {
+			return false
+		}
+	}
+	return true
+}
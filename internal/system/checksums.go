@@ -0,0 +1,95 @@
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WriteChecksums writes the SHA-256 sums of the files in dir, with paths
+// relative to it, to checksums.txt in dir in sha256sum format
+func WriteChecksums(dir string) error {
+	var names []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if name != checksumsAsset {
+			names = append(names, filepath.ToSlash(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	sort.Strings(names)
+
+	var sums bytes.Buffer
+	for _, name := range names {
+		sum, err := fileChecksum(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&sums, "%s  %s\n", sum, name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, checksumsAsset), sums.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", checksumsAsset, err)
+	}
+	return nil
+}
+
+// VerifyChecksums checks the files listed in checksums.txt in dir against
+// their sums, and that each of required is listed
+func VerifyChecksums(dir string, required ...string) error {
+	sums, err := os.ReadFile(filepath.Join(dir, checksumsAsset))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", checksumsAsset, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		name := strings.TrimPrefix(fields[1], "*")
+		if filepath.IsAbs(name) || strings.HasPrefix(filepath.Clean(name), "..") {
+			return fmt.Errorf("%s lists %s outside %s", checksumsAsset, name, dir)
+		}
+		actual, err := fileChecksum(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		if expected := strings.ToLower(fields[0]); actual != expected {
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, expected, actual)
+		}
+	}
+
+	for _, name := range required {
+		if findChecksum(sums, name) == "" {
+			return fmt.Errorf("%s has no checksum for %s", checksumsAsset, name)
+		}
+	}
+	return nil
+}
+
+// fileChecksum returns the SHA-256 sum of the file at path
+func fileChecksum(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
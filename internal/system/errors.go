@@ -0,0 +1,55 @@
+package system
+
+import (
+	"errors"
+	"strings"
+)
+
+// Errors commands fail with that scripts may want to tell apart. The CLI
+// exits with a code of its own for each; match them with errors.Is.
+var (
+	ErrNotInstalled     = errors.New("service not installed")
+	ErrDaemonNotRunning = errors.New("service not running")
+	ErrPermission       = errors.New("insufficient privileges")
+	ErrRouteFailed      = errors.New("route command failed")
+)
+
+// deniedOutput are what sudo and the commands it runs print when they
+// aren't allowed to do something
+var deniedOutput = []string{
+	"a password is required",
+	"is not in the sudoers file",
+	"is not allowed to execute",
+	"may not run sudo",
+	"must be root",
+	"Operation not permitted",
+	"Permission denied",
+}
+
+// CommandError is the error of a failed command, which also matches Kind
+// with errors.Is while keeping the command's own message
+type CommandError struct {
+	Err  error
+	Kind error
+}
+
+// Error returns the command's error message
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns both the command's error and its kind
+func (e *CommandError) Unwrap() []error {
+	return []error{e.Err, e.Kind}
+}
+
+// CommandFailed classifies the error of a command run with sudo from its
+// output: ErrPermission when it was denied, otherwise kind
+func CommandFailed(output []byte, err error, kind error) error {
+	for _, denied := range deniedOutput {
+		if strings.Contains(string(output), denied) {
+			return &CommandError{Err: err, Kind: ErrPermission}
+		}
+	}
+	return &CommandError{Err: err, Kind: kind}
+}
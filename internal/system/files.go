@@ -0,0 +1,33 @@
+package system
+
+import (
+	"os"
+	"syscall"
+)
+
+// WriteFile writes data to path like os.WriteFile but doesn't follow a
+// symlink at path. The daemon may run as root and write its state and
+// logs in directories the user owns, where a symlink would otherwise
+// redirect the write to any file.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// SharedFileMode returns the mode to create files in the user's
+// directories with: group writable for the root daemon installed with
+// --system, which runs with umask 002 so the CLI can still write to what
+// it creates, and 0644 for everyone else
+func SharedFileMode() os.FileMode {
+	if !RequiresSudo() {
+		return 0664
+	}
+	return 0644
+}
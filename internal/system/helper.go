@@ -0,0 +1,84 @@
+package system
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// HelperPath is where the privileged helper is installed: a copy of the
+// binary owned by root, in a directory only root can write, that the
+// sudoers entries let the user run as root. Run from there it only takes
+// the fixed commands it checks itself, argument by argument, which sudoers
+// patterns can't do.
+const HelperPath = "/Library/PrivilegedHelperTools/vpn-route-manager-helper"
+
+// IsHelper reports whether this process is the privileged helper
+func IsHelper() bool {
+	executable, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	return executable == HelperPath
+}
+
+// InstallHelper copies binary to HelperPath with sudo, owned by root
+func InstallHelper(binary string) error {
+	for _, args := range [][]string{
+		{"mkdir", "-p", filepath.Dir(HelperPath)},
+		{"install", "-o", "root", "-g", "wheel", "-m", "0755", binary, HelperPath},
+	} {
+		if output, err := exec.Command("sudo", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to install helper: %s", strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// HelperOutdated reports whether the installed helper is missing or
+// differs from binary
+func HelperOutdated(binary string) bool {
+	installed, err := os.ReadFile(HelperPath)
+	if err != nil {
+		return true
+	}
+	current, err := os.ReadFile(binary)
+	return err != nil || !bytes.Equal(installed, current)
+}
+
+// removeHelper removes the helper with sudo
+func removeHelper() error {
+	if _, err := os.Stat(HelperPath); os.IsNotExist(err) {
+		return nil
+	}
+	if output, err := exec.Command("sudo", "rm", "-f", HelperPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove helper: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// helperProblems returns what makes the helper unsafe or unusable: missing,
+// or it or its directory not owned by root or writable by others
+func helperProblems() []string {
+	var problems []string
+	for _, path := range []string{filepath.Dir(HelperPath), HelperPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return append(problems, fmt.Sprintf("%s is missing", path))
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 {
+			problems = append(problems, fmt.Sprintf("%s is owned by UID %d instead of root", path, stat.Uid))
+		}
+		if info.Mode().Perm()&0022 != 0 {
+			problems = append(problems, fmt.Sprintf("%s is writable by others than root", path))
+		}
+	}
+	return problems
+}
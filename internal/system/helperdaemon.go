@@ -0,0 +1,180 @@
+package system
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The helper daemon is the privileged helper registered with launchd as a
+// LaunchDaemon, the way SMJobBless registers one: it runs as root from
+// HelperPath and takes its commands over HelperSocket, which only the
+// user it was installed for may use, so no sudoers rules are needed.
+const (
+	HelperDaemonLabel = "com.vpn-route-manager.helper"
+	HelperDaemonPlist = "/Library/LaunchDaemons/" + HelperDaemonLabel + ".plist"
+	HelperSocket      = "/var/run/vpn-route-manager-helper.sock"
+)
+
+// helperDaemonPlist is the launchd job of the helper daemon; the helper
+// checks every command it is sent, as it does run with sudo
+const helperDaemonPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    <string>%s</string>
+
+    <key>ProgramArguments</key>
+    <array>
+        <string>%s</string>
+        <string>serve</string>
+        <string>%d</string>
+    </array>
+
+    <key>RunAtLoad</key>
+    <true/>
+
+    <key>KeepAlive</key>
+    <true/>
+
+    <key>ProcessType</key>
+    <string>Background</string>
+</dict>
+</plist>
+`
+
+// HelperRequest is a helper command sent to the helper daemon
+type HelperRequest struct {
+	Args []string `json:"args"`
+}
+
+// HelperResponse is the combined output and exit code of a helper command
+type HelperResponse struct {
+	Output string `json:"output"`
+	Code   int    `json:"code"`
+}
+
+// HelperCall is a command of the privileged helper, sent to the helper
+// daemon when it is listening and run with sudo otherwise
+type HelperCall struct {
+	args []string
+}
+
+// HelperCommand returns a command of the privileged helper
+func HelperCommand(args ...string) *HelperCall {
+	return &HelperCall{args: args}
+}
+
+// errHelperDaemonDown is returned when no helper daemon listens on
+// HelperSocket
+var errHelperDaemonDown = errors.New("helper daemon not running")
+
+// CombinedOutput runs the command and returns its combined output, with
+// an error when it failed
+func (c *HelperCall) CombinedOutput() ([]byte, error) {
+	output, err := callHelperDaemon(c.args)
+	if !errors.Is(err, errHelperDaemonDown) {
+		return output, err
+	}
+	return exec.Command("sudo", append([]string{HelperPath}, c.args...)...).CombinedOutput()
+}
+
+// callHelperDaemon sends a command to the helper daemon. A socket left
+// behind by a helper daemon that is gone counts as none.
+func callHelperDaemon(args []string) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", HelperSocket, 5*time.Second)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, errHelperDaemonDown
+		}
+		return nil, fmt.Errorf("failed to connect to the helper: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	if err := json.NewEncoder(conn).Encode(HelperRequest{Args: args}); err != nil {
+		return nil, fmt.Errorf("failed to send helper command: %w", err)
+	}
+	var response HelperResponse
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to read helper response: %w", err)
+	}
+	output := []byte(response.Output)
+	if response.Code != 0 {
+		return output, fmt.Errorf("helper exited with status %d", response.Code)
+	}
+	return output, nil
+}
+
+// HelperDaemonInstalled reports whether the helper daemon is registered
+// with launchd
+func HelperDaemonInstalled() bool {
+	_, err := os.Stat(HelperDaemonPlist)
+	return err == nil
+}
+
+// InstallHelperDaemon installs binary as the helper and registers it with
+// launchd, with sudo, taking commands from the user with ID uid only
+func InstallHelperDaemon(binary string, uid int) error {
+	if err := InstallHelper(binary); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "vpn-route-manager-helper-*.plist")
+	if err != nil {
+		return fmt.Errorf("failed to write helper plist: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = fmt.Fprintf(tmp, helperDaemonPlist, HelperDaemonLabel, HelperPath, uid)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write helper plist: %w", err)
+	}
+
+	// Loading it again restarts the helper on the installed binary
+	exec.Command("sudo", "launchctl", "unload", HelperDaemonPlist).Run()
+	for _, args := range [][]string{
+		{"install", "-o", "root", "-g", "wheel", "-m", "0644", tmp.Name(), HelperDaemonPlist},
+		{"launchctl", "load", "-w", HelperDaemonPlist},
+	} {
+		if output, err := exec.Command("sudo", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to register helper daemon: %s", strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// RestartHelperDaemon restarts the helper daemon, if registered, so it
+// runs the helper installed last
+func RestartHelperDaemon() error {
+	if !HelperDaemonInstalled() {
+		return nil
+	}
+	if output, err := exec.Command("sudo", "launchctl", "kickstart", "-k", "system/"+HelperDaemonLabel).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to restart helper daemon: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// RemoveHelperDaemon unregisters the helper daemon and removes the helper,
+// with sudo
+func RemoveHelperDaemon() error {
+	if !HelperDaemonInstalled() {
+		return nil
+	}
+	exec.Command("sudo", "launchctl", "unload", HelperDaemonPlist).Run()
+	if output, err := exec.Command("sudo", "rm", "-f", HelperDaemonPlist, HelperSocket).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove helper daemon: %s", strings.TrimSpace(string(output)))
+	}
+	return removeHelper()
+}
@@ -1,9 +1,14 @@
 package system
 
 import (
+	"bytes"
+	"encoding/xml"
 	"fmt"
+	"html"
+	"net"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -11,14 +16,54 @@ import (
 	"text/template"
 )
 
-// LaunchAgent handles macOS LaunchAgent management
+// launchDaemonsDir holds the system-wide jobs, which launchd starts at boot
+// rather than when the user logs in
+const launchDaemonsDir = "/Library/LaunchDaemons"
+
+// LaunchAgent handles macOS LaunchAgent management. With system set it
+// manages a LaunchDaemon instead, which runs from boot and is loaded with
+// sudo: as the user too, or with root set as root.
 type LaunchAgent struct {
 	serviceName string
 	plistPath   string
 	username    string
+	system      bool
+	root        bool
+	options     JobOptions
+}
+
+// JobOptions are the configurable launchd settings of the job. KeepAlive
+// is when launchd restarts the daemon: always, on-failure, crashed or
+// never. With OnDemand a root daemon isn't started at load: launchd
+// creates its control socket, owned by ControlGroup, and starts it when
+// the CLI first connects.
+type JobOptions struct {
+	KeepAlive        string
+	ThrottleInterval int
+	Nice             int
+	OnDemand         bool
+	ControlGroup     string
+	ControlSocket    string
+}
+
+// DefaultJobOptions returns the settings jobs are written with unless
+// configured otherwise
+func DefaultJobOptions() JobOptions {
+	return JobOptions{KeepAlive: "always", ThrottleInterval: 10, Nice: 1}
 }
 
-// LaunchAgentConfig holds configuration for the plist template
+// plistMarker identifies the plists written for a user's job, so one
+// installed under another label is found too
+const plistMarker = "<!-- vpn-route-manager job of %s -->"
+
+// LaunchAgentConfig holds configuration for the plist template.
+// Environment holds the VRM_* configuration overrides and the XDG base
+// directories set when installing, so the daemon runs with them too and
+// finds its configuration and state where the CLI does.
+// A root daemon runs with umask 002: the files it creates in the user's
+// directories take their group, so the CLI can still write to them.
+// SocketMode and SocketGroup are the permissions and group ID launchd
+// gives the control socket of an OnDemand job.
 type LaunchAgentConfig struct {
 	Label            string
 	BinaryPath       string
@@ -26,23 +71,146 @@ type LaunchAgentConfig struct {
 	LogDirectory     string
 	Username         string
 	HomeDirectory    string
+	Environment      map[string]string
+	System           bool
+	Root             bool
+	Marker           string
+	KeepAlive        string
+	ThrottleInterval int
+	Nice             int
+	OnDemand         bool
+	ControlSocket    string
+	SocketMode       int
+	SocketGroup      int
 }
 
-// NewLaunchAgent creates a new LaunchAgent manager
+// NewLaunchAgent creates a new LaunchAgent manager, for the installed
+// job of the user whatever its label. When only a LaunchDaemon is
+// installed for the user, it manages that instead.
 func NewLaunchAgent(username string) *LaunchAgent {
-	serviceName := fmt.Sprintf("com.%s.vpn.route.manager", username)
 	homeDir, _ := os.UserHomeDir()
-	plistPath := filepath.Join(homeDir, "Library", "LaunchAgents", serviceName+".plist")
+	dir := filepath.Join(homeDir, "Library", "LaunchAgents")
+	serviceName, installed := installedLabel(dir, username)
+
+	if !installed {
+		if daemon := NewLaunchDaemon(username); daemon.IsInstalled() {
+			return daemon
+		}
+	}
 
 	return &LaunchAgent{
 		serviceName: serviceName,
-		plistPath:   plistPath,
+		plistPath:   filepath.Join(dir, serviceName+".plist"),
 		username:    username,
+		options:     DefaultJobOptions(),
+	}
+}
+
+// NewLaunchDaemon creates a manager for a LaunchDaemon running the service
+// as username. An installed one that runs as root is managed as such.
+func NewLaunchDaemon(username string) *LaunchAgent {
+	serviceName, _ := installedLabel(launchDaemonsDir, username)
+	la := &LaunchAgent{
+		serviceName: serviceName,
+		plistPath:   filepath.Join(launchDaemonsDir, serviceName+".plist"),
+		username:    username,
+		system:      true,
+		options:     DefaultJobOptions(),
+	}
+	if data, err := os.ReadFile(la.plistPath); err == nil {
+		la.root = !strings.Contains(string(data), "<key>UserName</key>")
+	}
+	return la
+}
+
+// NewRootDaemon creates a manager for a LaunchDaemon running the service
+// as root for username, so it changes routes without any sudo rules
+func NewRootDaemon(username string) *LaunchAgent {
+	la := NewLaunchDaemon(username)
+	la.root = true
+	return la
+}
+
+// defaultLabel is the label of the job of username unless configured
+// otherwise
+func defaultLabel(username string) string {
+	return fmt.Sprintf("com.%s.vpn.route.manager", username)
+}
+
+// installedLabel returns the label of the job installed in dir for
+// username: the default one, or another label of a plist with the user's
+// marker. Without any it returns the default label and false.
+func installedLabel(dir, username string) (string, bool) {
+	label := defaultLabel(username)
+	if _, err := os.Stat(filepath.Join(dir, label+".plist")); err == nil {
+		return label, true
+	}
+
+	marker := fmt.Sprintf(plistMarker, username)
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.plist"))
+	for _, path := range paths {
+		if data, err := os.ReadFile(path); err == nil && strings.Contains(string(data), marker) {
+			return strings.TrimSuffix(filepath.Base(path), ".plist"), true
+		}
+	}
+	return label, false
+}
+
+// WithLabel returns a manager for the same kind of job under another
+// label, or the default one when label is empty
+func (la *LaunchAgent) WithLabel(label string) *LaunchAgent {
+	if label == "" {
+		label = defaultLabel(la.username)
+	}
+	job := *la
+	job.serviceName = label
+	job.plistPath = filepath.Join(filepath.Dir(la.plistPath), label+".plist")
+	return &job
+}
+
+// Label returns the job's label
+func (la *LaunchAgent) Label() string {
+	return la.serviceName
+}
+
+// SetOptions sets the launchd settings the plist is written with
+func (la *LaunchAgent) SetOptions(options JobOptions) {
+	la.options = options
+}
+
+// IsDaemon reports whether this is a LaunchDaemon rather than a LaunchAgent
+func (la *LaunchAgent) IsDaemon() bool {
+	return la.system
+}
+
+// IsRoot reports whether this is a LaunchDaemon running as root
+func (la *LaunchAgent) IsRoot() bool {
+	return la.root
+}
+
+// IsOnDemand reports whether the installed job is started on demand, when
+// the CLI connects to the control socket launchd created for it
+func (la *LaunchAgent) IsOnDemand() bool {
+	return la.plistValue("Sockets") == "dict"
+}
+
+// PlistPath returns the path of the job's plist
+func (la *LaunchAgent) PlistPath() string {
+	return la.plistPath
+}
+
+// command returns a command that runs with sudo for a LaunchDaemon, unless
+// already running as root
+func (la *LaunchAgent) command(name string, args ...string) *exec.Cmd {
+	if la.system && RequiresSudo() {
+		return exec.Command("sudo", append([]string{name}, args...)...)
 	}
+	return exec.Command(name, args...)
 }
 
-// Install creates and loads the LaunchAgent
-func (la *LaunchAgent) Install(binaryPath string) error {
+// Install creates and loads the LaunchAgent, with its output logged to
+// logDir
+func (la *LaunchAgent) Install(binaryPath, logDir string) error {
 	// Ensure LaunchAgents directory exists
 	launchAgentsDir := filepath.Dir(la.plistPath)
 	if err := os.MkdirAll(launchAgentsDir, 0755); err != nil {
@@ -50,7 +218,7 @@ func (la *LaunchAgent) Install(binaryPath string) error {
 	}
 
 	// Create plist file
-	if err := la.createPlist(binaryPath); err != nil {
+	if err := la.createPlist(binaryPath, logDir); err != nil {
 		return fmt.Errorf("failed to create plist: %w", err)
 	}
 
@@ -72,7 +240,11 @@ func (la *LaunchAgent) Uninstall() error {
 	}
 
 	// Remove plist file
-	if err := os.Remove(la.plistPath); err != nil && !os.IsNotExist(err) {
+	if la.system {
+		if output, err := la.command("rm", "-f", la.plistPath).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to remove plist: %s", string(output))
+		}
+	} else if err := os.Remove(la.plistPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove plist: %w", err)
 	}
 
@@ -81,7 +253,7 @@ func (la *LaunchAgent) Uninstall() error {
 
 // Load loads the LaunchAgent
 func (la *LaunchAgent) Load() error {
-	cmd := exec.Command("launchctl", "load", la.plistPath)
+	cmd := la.command("launchctl", "load", la.plistPath)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("launchctl load failed: %s", string(output))
 	}
@@ -90,22 +262,144 @@ func (la *LaunchAgent) Load() error {
 
 // Unload unloads the LaunchAgent
 func (la *LaunchAgent) Unload() error {
-	cmd := exec.Command("launchctl", "unload", la.plistPath)
+	cmd := la.command("launchctl", "unload", la.plistPath)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("launchctl unload failed: %s", string(output))
 	}
 	return nil
 }
 
+// IsInstalled checks if the LaunchAgent plist exists
+func (la *LaunchAgent) IsInstalled() bool {
+	_, err := os.Stat(la.plistPath)
+	return err == nil
+}
+
+// BinaryPath returns the binary the LaunchAgent starts, read back from
+// its plist
+func (la *LaunchAgent) BinaryPath() (string, error) {
+	data, err := os.ReadFile(la.plistPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read plist: %w", err)
+	}
+
+	// The binary is the first string of ProgramArguments
+	content := string(data)
+	start := strings.Index(content, "<key>ProgramArguments</key>")
+	if start < 0 {
+		return "", fmt.Errorf("no ProgramArguments in %s", la.plistPath)
+	}
+	content = content[start:]
+	open := strings.Index(content, "<string>")
+	end := strings.Index(content, "</string>")
+	if open < 0 || end < open {
+		return "", fmt.Errorf("no program in %s", la.plistPath)
+	}
+	return html.UnescapeString(content[open+len("<string>") : end]), nil
+}
+
 // IsLoaded checks if the LaunchAgent is loaded
 func (la *LaunchAgent) IsLoaded() bool {
+	// Jobs of the system domain aren't listed for users, but can be printed
+	if la.system {
+		return exec.Command("launchctl", "print", "system/"+la.serviceName).Run() == nil
+	}
 	cmd := exec.Command("launchctl", "list", la.serviceName)
 	err := cmd.Run()
 	return err == nil
 }
 
+// target is the job's service target for launchctl: in the system domain
+// for a LaunchDaemon, or in the user's GUI domain
+func (la *LaunchAgent) target() (string, error) {
+	if la.system {
+		return "system/" + la.serviceName, nil
+	}
+	account, err := user.Lookup(la.username)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up user %s: %w", la.username, err)
+	}
+	return fmt.Sprintf("gui/%s/%s", account.Uid, la.serviceName), nil
+}
+
+// Kickstart starts the loaded job unless it is already running
+func (la *LaunchAgent) Kickstart() error {
+	target, err := la.target()
+	if err != nil {
+		return err
+	}
+	if output, err := la.command("launchctl", "kickstart", target).CombinedOutput(); err != nil {
+		return fmt.Errorf("launchctl kickstart failed: %s", string(output))
+	}
+	return nil
+}
+
+// Kill sends SIGTERM to the job's process, which stops cleanly. The job
+// stays loaded, so launchd starts it again if it restarts on any exit.
+func (la *LaunchAgent) Kill() error {
+	target, err := la.target()
+	if err != nil {
+		return err
+	}
+	if output, err := la.command("launchctl", "kill", "SIGTERM", target).CombinedOutput(); err != nil {
+		return fmt.Errorf("launchctl kill failed: %s", string(output))
+	}
+	return nil
+}
+
+// RestartsOnExit reports whether launchd restarts the job whenever it
+// exits, as with keep_alive always, so only unloading it keeps it stopped
+func (la *LaunchAgent) RestartsOnExit() bool {
+	return la.plistValue("KeepAlive") == "true"
+}
+
+// plistValue returns the element type of the value of a top-level key in
+// the installed plist, such as "true" or "dict", or "" when the key or the
+// plist is missing
+func (la *LaunchAgent) plistValue(key string) string {
+	file, err := os.Open(la.plistPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	// The top-level keys and their values are elements of the plist's dict
+	decoder := xml.NewDecoder(file)
+	depth, found := 0, false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth != 3 {
+				continue
+			}
+			if found {
+				return element.Name.Local
+			}
+			if element.Name.Local == "key" {
+				var name string
+				if err := decoder.DecodeElement(&name, &element); err != nil {
+					return ""
+				}
+				depth--
+				found = strings.TrimSpace(name) == key
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
 // IsRunning checks if the service is actually running
 func (la *LaunchAgent) IsRunning() (bool, int) {
+	if la.system {
+		return la.daemonPID()
+	}
+
 	// Use launchctl list without service name and grep for it
 	// This gives us the simple format: "PID Status Label"
 	cmd := exec.Command("sh", "-c", fmt.Sprintf("launchctl list | grep %s", la.serviceName))
@@ -152,34 +446,149 @@ func (la *LaunchAgent) IsRunning() (bool, int) {
 	return false, 0
 }
 
-// createPlist creates the LaunchAgent plist file
-func (la *LaunchAgent) createPlist(binaryPath string) error {
+// daemonPID finds the process of a LaunchDaemon in the "pid = N" line
+// launchctl prints for running jobs
+func (la *LaunchAgent) daemonPID() (bool, int) {
+	output, err := exec.Command("launchctl", "print", "system/"+la.serviceName).Output()
+	if err != nil {
+		return false, 0
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " = ")
+		if !ok || key != "pid" {
+			continue
+		}
+		if pid, err := strconv.Atoi(value); err == nil && pid > 0 {
+			return true, pid
+		}
+	}
+	return false, 0
+}
+
+// LaunchdSocket returns the control socket launchd passes an OnDemand job.
+// launchd listens on it and starts the job at the first connection,
+// handing the listening socket over as stdin.
+func LaunchdSocket() (net.Listener, error) {
+	listener, err := net.FileListener(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take the socket from launchd: %w", err)
+	}
+	return listener, nil
+}
+
+// PlistChanged reports whether the installed plist differs from the one
+// this build writes for binaryPath and logDir, as when the template changed
+func (la *LaunchAgent) PlistChanged(binaryPath, logDir string) (bool, error) {
+	installed, err := os.ReadFile(la.plistPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read plist: %w", err)
+	}
+	plist, err := la.renderPlist(binaryPath, logDir)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(installed, plist), nil
+}
+
+// WritePlist rewrites the plist without loading it. An unloaded job picks
+// the changes up when it is loaded again.
+func (la *LaunchAgent) WritePlist(binaryPath, logDir string) error {
+	return la.createPlist(binaryPath, logDir)
+}
+
+// Plist returns the plist the job is installed with for binaryPath and
+// logDir, without writing it
+func (la *LaunchAgent) Plist(binaryPath, logDir string) ([]byte, error) {
+	return la.renderPlist(binaryPath, logDir)
+}
+
+// xdgVariables are the XDG base directories the configuration, state and
+// user binary directories follow
+var xdgVariables = map[string]bool{"XDG_CONFIG_HOME": true, "XDG_STATE_HOME": true, "XDG_DATA_HOME": true}
+
+// renderPlist returns the plist for the job running binaryPath, with its
+// output logged to logDir
+func (la *LaunchAgent) renderPlist(binaryPath, logDir string) ([]byte, error) {
 	homeDir, _ := os.UserHomeDir()
 	
 	config := LaunchAgentConfig{
 		Label:            la.serviceName,
 		BinaryPath:       binaryPath,
 		WorkingDirectory: filepath.Dir(binaryPath),
-		LogDirectory:     filepath.Join(homeDir, ".vpn-route-manager", "logs"),
+		LogDirectory:     logDir,
 		Username:         la.username,
 		HomeDirectory:    homeDir,
+		Environment:      make(map[string]string),
+		System:           la.system,
+		Root:             la.root,
+		Marker:           fmt.Sprintf(plistMarker, la.username),
+		KeepAlive:        la.options.KeepAlive,
+		ThrottleInterval: la.options.ThrottleInterval,
+		Nice:             la.options.Nice,
+	}
+	// Only a root daemon serves the control socket
+	if la.root && la.options.OnDemand {
+		group, err := user.LookupGroup(la.options.ControlGroup)
+		if err != nil {
+			return nil, fmt.Errorf("unknown control group %s: %w", la.options.ControlGroup, err)
+		}
+		gid, err := strconv.Atoi(group.Gid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID of group %s: %s", la.options.ControlGroup, group.Gid)
+		}
+		config.OnDemand = true
+		config.ControlSocket = la.options.ControlSocket
+		config.SocketMode = 0660
+		config.SocketGroup = gid
+	}
+	for _, entry := range os.Environ() {
+		if key, value, ok := strings.Cut(entry, "="); ok && (strings.HasPrefix(key, "VRM_") || xdgVariables[key]) {
+			config.Environment[key] = value
+		}
 	}
 
 	tmpl, err := template.New("plist").Parse(plistTemplate)
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	file, err := os.Create(la.plistPath)
+	var plist bytes.Buffer
+	if err := tmpl.Execute(&plist, config); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+	return plist.Bytes(), nil
+}
+
+// createPlist creates the LaunchAgent plist file
+func (la *LaunchAgent) createPlist(binaryPath, logDir string) error {
+	plist, err := la.renderPlist(binaryPath, logDir)
 	if err != nil {
-		return fmt.Errorf("failed to create plist file: %w", err)
+		return err
 	}
-	defer file.Close()
 
-	if err := tmpl.Execute(file, config); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+	if !la.system {
+		if err := os.WriteFile(la.plistPath, plist, 0644); err != nil {
+			return fmt.Errorf("failed to create plist file: %w", err)
+		}
+		return nil
 	}
 
+	// launchd only loads daemons from files owned by root
+	tmp, err := os.CreateTemp("", "vpn-route-manager-plist-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(plist); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	tmp.Close()
+
+	cmd := la.command("install", "-m", "644", "-o", "root", "-g", "wheel", tmp.Name(), la.plistPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create plist file: %s", string(output))
+	}
 	return nil
 }
 
@@ -187,24 +596,78 @@ const plistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
 <!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
 <plist version="1.0">
 <dict>
+    {{.Marker}}
     <key>Label</key>
     <string>{{.Label}}</string>
+    {{- if .Root}}
+
+    <key>Umask</key>
+    <integer>2</integer>
+    {{- else if .System}}
+
+    <key>UserName</key>
+    <string>{{.Username}}</string>
+    {{- end}}
     
     <key>ProgramArguments</key>
     <array>
         <string>{{.BinaryPath}}</string>
         <string>start</string>
         <string>--daemon</string>
+        {{- if .OnDemand}}
+        <string>--launchd-socket</string>
+        {{- end}}
     </array>
     
     <key>WorkingDirectory</key>
     <string>{{.WorkingDirectory}}</string>
+    {{- if .OnDemand}}
+
+    <key>Sockets</key>
+    <dict>
+        <key>Control</key>
+        <dict>
+            <key>SockType</key>
+            <string>stream</string>
+            <key>SockPathName</key>
+            <string>{{.ControlSocket}}</string>
+            <key>SockPathMode</key>
+            <integer>{{.SocketMode}}</integer>
+            <key>SockPathGroup</key>
+            <integer>{{.SocketGroup}}</integer>
+        </dict>
+    </dict>
+
+    <key>inetdCompatibility</key>
+    <dict>
+        <key>Wait</key>
+        <true/>
+    </dict>
+    {{- end}}
     
     <key>RunAtLoad</key>
+    {{- if .OnDemand}}
+    <false/>
+    {{- else}}
     <true/>
+    {{- end}}
     
     <key>KeepAlive</key>
+    {{- if or (eq .KeepAlive "on-failure") (and .OnDemand (eq .KeepAlive "always"))}}
+    <dict>
+        <key>SuccessfulExit</key>
+        <false/>
+    </dict>
+    {{- else if eq .KeepAlive "crashed"}}
+    <dict>
+        <key>Crashed</key>
+        <true/>
+    </dict>
+    {{- else if eq .KeepAlive "never"}}
+    <false/>
+    {{- else}}
     <true/>
+    {{- end}}
     
     <key>ProcessType</key>
     <string>Background</string>
@@ -223,19 +686,20 @@ const plistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
         <string>{{.HomeDirectory}}</string>
         <key>USER</key>
         <string>{{.Username}}</string>
+{{- range $key, $value := .Environment}}
+        <key>{{$key | html}}</key>
+        <string>{{$value | html}}</string>
+{{- end}}
     </dict>
     
     <key>ThrottleInterval</key>
-    <integer>10</integer>
-    
-    <key>StartInterval</key>
-    <integer>15</integer>
+    <integer>{{.ThrottleInterval}}</integer>
     
     <key>ExitTimeOut</key>
     <integer>30</integer>
     
     <key>Nice</key>
-    <integer>1</integer>
+    <integer>{{.Nice}}</integer>
     
     <key>LowPriorityIO</key>
     <true/>
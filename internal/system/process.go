@@ -211,7 +211,7 @@ func (pm *ProcessManager) WaitForProcess(timeout time.Duration) (int, error) {
 // CreatePIDFile creates a PID file for the current process
 func CreatePIDFile(path string) error {
 	pid := os.Getpid()
-	return os.WriteFile(path, []byte(strconv.Itoa(pid)), 0644)
+	return WriteFile(path, []byte(strconv.Itoa(pid)), 0644)
 }
 
 // ReadPIDFile reads a PID from a file
@@ -5,12 +5,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"syscall"
 )
 
 // SudoManager handles sudo configuration
 type SudoManager struct {
 	username    string
 	sudoersFile string
+	legacyFile  string
 }
 
 // NewSudoManager creates a new sudo manager
@@ -18,48 +21,111 @@ func NewSudoManager(username string) *SudoManager {
 	return &SudoManager{
 		username:    username,
 		sudoersFile: fmt.Sprintf("/etc/sudoers.d/vpn-route-bypass-%s", username),
+		legacyFile:  fmt.Sprintf("/etc/sudoers.d/vpn-route-manager-%s", username),
 	}
 }
 
-// Setup configures passwordless sudo for route commands
-func (sm *SudoManager) Setup() error {
-	// Check if already configured
-	if sm.IsConfigured() {
-		return nil
-	}
+// sudoersRules returns the commands the daemon may run without a password:
+// only the privileged helper, which checks the arguments of the route
+// commands and split DNS files it is asked for itself. Sudoers wildcards
+// match any text, spaces and slashes included, so they can't pin route
+// arguments down to addresses.
+func sudoersRules() []string {
+	return []string{HelperPath}
+}
 
-	// Create sudoers content
-	content := fmt.Sprintf("%s ALL=(root) NOPASSWD: /sbin/route\n", sm.username)
+// Content returns the sudoers entries Setup writes, one rule per line
+func (sm *SudoManager) Content() string {
+	var b strings.Builder
+	b.WriteString("# Generated by vpn-route-manager, regenerate with 'vpn-route-manager sudoers regenerate'\n")
+	for _, rule := range sudoersRules() {
+		fmt.Fprintf(&b, "%s ALL=(root) NOPASSWD: %s\n", sm.username, rule)
+	}
+	return b.String()
+}
 
-	// Write to temporary file
-	tmpFile := filepath.Join("/tmp", fmt.Sprintf("sudoers-%s-%d", sm.username, os.Getpid()))
-	if err := os.WriteFile(tmpFile, []byte(content), 0440); err != nil {
-		return fmt.Errorf("failed to create temp sudoers file: %w", err)
+// Setup configures passwordless sudo for route commands, replacing any
+// entries written before, and removes the file older installers wrote.
+// The privileged helper is installed from this binary first.
+func (sm *SudoManager) Setup() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	if err := InstallHelper(executable); err != nil {
+		return err
 	}
-	defer os.Remove(tmpFile)
 
-	// Validate sudoers file
-	cmd := exec.Command("visudo", "-c", "-f", tmpFile)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("invalid sudoers syntax: %s", string(output))
+	tmpFile, err := sm.validatedTempFile([]byte(sm.Content()))
+	if err != nil {
+		return err
 	}
+	defer os.Remove(tmpFile)
 
 	// Move to sudoers.d
-	cmd = exec.Command("sudo", "cp", tmpFile, sm.sudoersFile)
+	cmd := exec.Command("sudo", "cp", tmpFile, sm.sudoersFile)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to install sudoers file: %s", string(output))
 	}
 
-	// Set correct permissions
+	// Set correct owner and permissions, which cp keeps when replacing
+	cmd = exec.Command("sudo", "chown", "root:wheel", sm.sudoersFile)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set sudoers owner: %s", string(output))
+	}
 	cmd = exec.Command("sudo", "chmod", "440", sm.sudoersFile)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to set sudoers permissions: %s", string(output))
 	}
 
+	if _, err := os.Stat(sm.legacyFile); err == nil {
+		cmd = exec.Command("sudo", "rm", "-f", sm.legacyFile)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to remove %s: %s", sm.legacyFile, string(output))
+		}
+	}
+
 	return nil
 }
 
-// Remove removes the sudo configuration
+// validatedTempFile writes sudoers content to a temporary file and checks
+// it with visudo. The caller removes the file.
+func (sm *SudoManager) validatedTempFile(content []byte) (string, error) {
+	tmpFile := filepath.Join("/tmp", fmt.Sprintf("sudoers-%s-%d", sm.username, os.Getpid()))
+	if err := os.WriteFile(tmpFile, content, 0440); err != nil {
+		return "", fmt.Errorf("failed to create temp sudoers file: %w", err)
+	}
+
+	cmd := exec.Command("visudo", "-c", "-f", tmpFile)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpFile)
+		if len(output) == 0 {
+			return "", fmt.Errorf("failed to run visudo: %w", err)
+		}
+		return "", fmt.Errorf("invalid sudoers syntax: %s", strings.TrimSpace(string(output)))
+	}
+	return tmpFile, nil
+}
+
+// readSudoers reads the sudoers file, with sudo unless running as root.
+// Unless interactive, sudo fails rather than asking for a password.
+func (sm *SudoManager) readSudoers(interactive bool) ([]byte, error) {
+	data, err := os.ReadFile(sm.sudoersFile)
+	if err == nil || !RequiresSudo() {
+		return data, err
+	}
+	args := []string{"cat", sm.sudoersFile}
+	if !interactive {
+		args = append([]string{"-n"}, args...)
+	}
+	return exec.Command("sudo", args...).Output()
+}
+
+// Remove removes the sudo configuration, and the privileged helper unless
+// other users' entries still run it
 func (sm *SudoManager) Remove() error {
 	if !sm.IsConfigured() {
 		return nil
@@ -70,13 +136,16 @@ func (sm *SudoManager) Remove() error {
 		return fmt.Errorf("failed to remove sudoers file: %s", string(output))
 	}
 
+	if others, _ := filepath.Glob("/etc/sudoers.d/vpn-route-bypass-*"); len(others) == 0 {
+		return removeHelper()
+	}
 	return nil
 }
 
 // IsConfigured checks if sudo is already configured
 func (sm *SudoManager) IsConfigured() bool {
-	// Test if we can run route command without password
-	cmd := exec.Command("sudo", "-n", "route", "get", "default")
+	// Check, without running it, that a route may be added without password
+	cmd := exec.Command("sudo", "-n", "-l", HelperPath, "route", "add", "-net", "192.0.2.0/24", "192.0.2.1")
 	err := cmd.Run()
 	return err == nil
 }
@@ -84,18 +153,181 @@ func (sm *SudoManager) IsConfigured() bool {
 // TestAccess verifies sudo access works
 func (sm *SudoManager) TestAccess() error {
 	if !sm.IsConfigured() {
-		return fmt.Errorf("sudo not configured for passwordless route access")
+		return fmt.Errorf("%w: sudo not configured for passwordless route access", ErrPermission)
 	}
-
-	// Try to get default route
-	cmd := exec.Command("sudo", "-n", "route", "get", "default")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("sudo test failed: %s", string(output))
+	if missing := sm.MissingCommands(); len(missing) > 0 {
+		return fmt.Errorf("%w: sudo test failed, no passwordless sudo for %s", ErrPermission, strings.Join(missing, ", "))
 	}
 
 	return nil
 }
 
+// sudoCommands are sample commands of every sudoers entry Setup writes
+var sudoCommands = [][]string{
+	{HelperPath, "route", "add", "-net", "192.0.2.0/24", "192.0.2.1"},
+	{HelperPath, "route", "delete", "-net", "192.0.2.0/24"},
+	{HelperPath, "resolver", "remove", "example.com"},
+}
+
+// MissingCommands returns the commands the sudoers entries should allow
+// without a password but don't. Nothing is run, sudo only checks.
+func (sm *SudoManager) MissingCommands() []string {
+	var missing []string
+	for _, command := range sudoCommands {
+		args := append([]string{"-n", "-l"}, command...)
+		if err := exec.Command("sudo", args...).Run(); err != nil {
+			missing = append(missing, strings.Join(command, " "))
+		}
+	}
+	return missing
+}
+
+// SudoersAudit compares the installed sudoers entries with the ones Setup
+// writes
+type SudoersAudit struct {
+	// File is the sudoers file audited
+	File string `json:"file"`
+	// Installed is false when the file is missing
+	Installed bool `json:"installed"`
+	// Missing are the entries the file lacks
+	Missing []string `json:"missing,omitempty"`
+	// Unexpected are the entries in the file Setup doesn't write
+	Unexpected []string `json:"unexpected,omitempty"`
+	// Legacy is the file older installers wrote, when it is still there
+	Legacy string `json:"legacy,omitempty"`
+}
+
+// OK reports whether the sudoers entries are exactly the ones Setup writes
+func (a *SudoersAudit) OK() bool {
+	return a.Installed && len(a.Missing) == 0 && len(a.Unexpected) == 0 && a.Legacy == ""
+}
+
+// Audit reads the sudoers file, with sudo unless running as root, and
+// compares it with the entries Setup writes
+func (sm *SudoManager) Audit() (*SudoersAudit, error) {
+	audit := &SudoersAudit{File: sm.sudoersFile}
+	if _, err := os.Stat(sm.legacyFile); err == nil {
+		audit.Legacy = sm.legacyFile
+	}
+
+	if _, err := os.Stat(sm.sudoersFile); err != nil {
+		audit.Missing = sudoersLines(sm.Content())
+		return audit, nil
+	}
+	audit.Installed = true
+
+	data, err := sm.readSudoers(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", sm.sudoersFile, err)
+	}
+
+	installed := sudoersLines(string(data))
+	expected := sudoersLines(sm.Content())
+	audit.Missing = linesNotIn(expected, installed)
+	audit.Unexpected = linesNotIn(installed, expected)
+	return audit, nil
+}
+
+// SudoersIntegrity is the result of checking the installed sudoers file
+type SudoersIntegrity struct {
+	// File is the sudoers file checked
+	File string `json:"file"`
+	// Problems are what was found wrong, none when the file is intact
+	Problems []string `json:"problems,omitempty"`
+	// Unverified is set when the file couldn't be read without a
+	// password, so its syntax and entries weren't checked
+	Unverified bool `json:"unverified,omitempty"`
+}
+
+// OK reports whether no problems were found
+func (i *SudoersIntegrity) OK() bool {
+	return len(i.Problems) == 0
+}
+
+// CheckIntegrity checks that the sudoers file is as Setup leaves it:
+// owned by root with mode 0440, accepted by visudo, holding exactly the
+// expected entries and allowing them without a password, with the
+// privileged helper owned by root. It never asks for a password, so run as
+// the user the syntax and entries are only checked if the file can be read.
+func (sm *SudoManager) CheckIntegrity() *SudoersIntegrity {
+	result := &SudoersIntegrity{File: sm.sudoersFile}
+
+	info, err := os.Stat(sm.sudoersFile)
+	if err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("%s is missing", sm.sudoersFile))
+		return result
+	}
+	if perm := info.Mode().Perm(); perm != 0440 {
+		result.Problems = append(result.Problems, fmt.Sprintf("mode is %04o instead of 0440", perm))
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 {
+		result.Problems = append(result.Problems, fmt.Sprintf("owned by UID %d instead of root", stat.Uid))
+	}
+	if _, err := os.Stat(sm.legacyFile); err == nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("older entries still installed in %s", sm.legacyFile))
+	}
+	result.Problems = append(result.Problems, helperProblems()...)
+
+	if data, err := sm.readSudoers(false); err != nil {
+		result.Unverified = true
+	} else {
+		if tmpFile, err := sm.validatedTempFile(data); err != nil {
+			result.Problems = append(result.Problems, err.Error())
+		} else {
+			os.Remove(tmpFile)
+		}
+		installed := sudoersLines(string(data))
+		expected := sudoersLines(sm.Content())
+		if missing := linesNotIn(expected, installed); len(missing) > 0 {
+			result.Problems = append(result.Problems, fmt.Sprintf("missing %s of the expected ones", countEntries(len(missing))))
+		}
+		if unexpected := linesNotIn(installed, expected); len(unexpected) > 0 {
+			result.Problems = append(result.Problems, fmt.Sprintf("%s that don't belong there", countEntries(len(unexpected))))
+		}
+	}
+
+	if missing := sm.MissingCommands(); len(missing) > 0 {
+		result.Problems = append(result.Problems, fmt.Sprintf("no passwordless sudo for %s", strings.Join(missing, ", ")))
+	}
+	return result
+}
+
+// countEntries returns a count of sudoers entries for messages
+func countEntries(n int) string {
+	if n == 1 {
+		return "1 entry"
+	}
+	return fmt.Sprintf("%d entries", n)
+}
+
+// sudoersLines returns the entries of sudoers content, without comments
+// and blank lines
+func sudoersLines(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// linesNotIn returns the lines of a that aren't in b
+func linesNotIn(a, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, line := range b {
+		seen[line] = true
+	}
+	var missing []string
+	for _, line := range a {
+		if !seen[line] {
+			missing = append(missing, line)
+		}
+	}
+	return missing
+}
+
 // GetSudoersFile returns the path to the sudoers file
 func (sm *SudoManager) GetSudoersFile() string {
 	return sm.sudoersFile
@@ -0,0 +1,194 @@
+package system
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ReleasesURL is the GitHub API endpoint of the latest release
+const ReleasesURL = "https://api.github.com/repos/btriapitsyn/vpn-route-manager/releases/latest"
+
+// checksumsAsset is the release asset with the SHA-256 sums of the others
+const checksumsAsset = "checksums.txt"
+
+// Release is a published release and its downloadable files by name
+type Release struct {
+	Version string
+	URL     string
+	Assets  map[string]string
+}
+
+// LatestRelease looks up the latest published release
+func LatestRelease() (*Release, error) {
+	data, err := download(ReleasesURL, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for releases: %w", err)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+		Assets  []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(data, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+
+	result := &Release{Version: release.TagName, URL: release.HTMLURL, Assets: make(map[string]string)}
+	for _, asset := range release.Assets {
+		result.Assets[asset.Name] = asset.URL
+	}
+	return result, nil
+}
+
+// BinaryAsset returns the name of the release package for an architecture
+func BinaryAsset(arch string) string {
+	return fmt.Sprintf("vpn-route-manager-darwin-%s.zip", arch)
+}
+
+// DownloadBinary downloads the release package for arch, checks it
+// against the release's checksums and returns the binary inside it
+func (r *Release) DownloadBinary(arch string) ([]byte, error) {
+	name := BinaryAsset(arch)
+	url, ok := r.Assets[name]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no package for darwin/%s", r.Version, arch)
+	}
+	sumsURL, ok := r.Assets[checksumsAsset]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s to verify the download with", r.Version, checksumsAsset)
+	}
+
+	sums, err := download(sumsURL, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download checksums: %w", err)
+	}
+	expected := findChecksum(sums, name)
+	if expected == "" {
+		return nil, fmt.Errorf("%s has no checksum for %s", checksumsAsset, name)
+	}
+
+	pkg, err := download(url, 100<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	sum := sha256.Sum256(pkg)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, expected, actual)
+	}
+
+	return extractBinary(pkg)
+}
+
+// findChecksum finds the SHA-256 sum of name in sha256sum output
+func findChecksum(sums []byte, name string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0])
+		}
+	}
+	return ""
+}
+
+// extractBinary returns the vpn-route-manager binary from a release
+// package
+func extractBinary(pkg []byte) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(pkg), int64(len(pkg)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+
+	for _, file := range archive.File {
+		if filepath.Base(file.Name) != "vpn-route-manager" || file.FileInfo().IsDir() {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract binary: %w", err)
+		}
+		defer f.Close()
+		return io.ReadAll(io.LimitReader(f, 100<<20))
+	}
+	return nil, fmt.Errorf("package has no vpn-route-manager binary")
+}
+
+// download fetches url, reading at most limit bytes
+func download(url string, limit int64) ([]byte, error) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// ReplaceBinary atomically replaces the binary at path with data: it is
+// written next to path and renamed over it, so a failure leaves the old
+// binary in place. When the directory isn't writable, as for
+// /usr/local/bin, the copy and rename are done with sudo.
+func ReplaceBinary(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	staged := filepath.Join(dir, "."+filepath.Base(path)+".new")
+
+	if err := os.WriteFile(staged, data, 0755); err == nil {
+		if err := os.Chmod(staged, 0755); err != nil {
+			os.Remove(staged)
+			return fmt.Errorf("failed to make binary executable: %w", err)
+		}
+		if err := os.Rename(staged, path); err != nil {
+			os.Remove(staged)
+			return fmt.Errorf("failed to replace binary: %w", err)
+		}
+		return nil
+	}
+
+	tmp, err := os.CreateTemp("", "vpn-route-manager-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	for _, args := range [][]string{
+		{"cp", tmp.Name(), staged},
+		{"chmod", "755", staged},
+		{"mv", "-f", staged, path},
+	} {
+		cmd := exec.Command("sudo", args...)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			exec.Command("sudo", "-n", "rm", "-f", staged).Run()
+			return fmt.Errorf("failed to replace binary: sudo %s: %w", args[0], err)
+		}
+	}
+	return nil
+}